@@ -1,7 +1,8 @@
 #!/bin/bash
 
-# Exit immediately if a command exits with a non-zero status
+# Exit immediately if a command exits with a non-zero status, including any command of a pipeline
 set -e
+set -o pipefail
 
 # Function to print a separator
 print_separator() {
@@ -46,24 +47,115 @@ if [[ "${RPC_URL}" == */ ]]; then
     RPC_URL="${RPC_URL%/}"
 fi
 
-# Combine the RPC URL and RPC Key if RPC_KEY is specified
-if [ -n "$RPC_KEY" ]; then
-    FULL_RPC_URL="${RPC_URL}/${RPC_KEY}"
+# Apply the auth type of the RPCProvider; forge can only send credentials that are part of the URL
+# LOG_RPC_URL is the URL with the key masked, for the log lines
+FULL_RPC_URL="${RPC_URL}"
+LOG_RPC_URL="${RPC_URL}"
+RPC_PROXY_HEADERS=""
+case "${RPC_AUTH_TYPE:-Path}" in
+    Path)
+        if [ -n "$RPC_KEY" ]; then
+            FULL_RPC_URL="${RPC_URL}/${RPC_KEY}"
+            LOG_RPC_URL="${RPC_URL}/************"
+        fi
+        ;;
+    Query)
+        # Percent-encode the key, so characters such as & or # do not end the query parameter
+        RPC_KEY_ENCODED=$(jq -rn --arg key "$RPC_KEY" '$key | @uri')
+        if [[ "${RPC_URL}" == *\?* ]]; then
+            FULL_RPC_URL="${RPC_URL}&${RPC_AUTH_QUERY_PARAM}=${RPC_KEY_ENCODED}"
+            LOG_RPC_URL="${RPC_URL}&${RPC_AUTH_QUERY_PARAM}=************"
+        else
+            FULL_RPC_URL="${RPC_URL}?${RPC_AUTH_QUERY_PARAM}=${RPC_KEY_ENCODED}"
+            LOG_RPC_URL="${RPC_URL}?${RPC_AUTH_QUERY_PARAM}=************"
+        fi
+        ;;
+    Bearer)
+        RPC_PROXY_HEADERS="Authorization: Bearer ${RPC_KEY}"
+        ;;
+    Header)
+        RPC_PROXY_HEADERS="${RPC_AUTH_HEADER}: ${RPC_KEY}"
+        ;;
+    Basic)
+        RPC_PROXY_HEADERS="Authorization: Basic $(printf '%s:%s' "$RPC_USERNAME" "$RPC_KEY" | base64 -w0)"
+        ;;
+esac
+
+# Send header credentials and client certificates through a local forwarder
+if [ -n "$RPC_PROXY_HEADERS" ] || [ -n "$RPC_TLS_DIR" ]; then
+    log "Starting RPC forwarder for ${RPC_AUTH_TYPE} authentication..."
+    RPC_PROXY_UPSTREAM="$FULL_RPC_URL" RPC_PROXY_HEADERS="$RPC_PROXY_HEADERS" RPC_PROXY_PORT=8545 \
+        node /home/foundryuser/rpc-proxy.js &
+    for _ in $(seq 1 50); do
+        if (echo > /dev/tcp/127.0.0.1/8545) 2>/dev/null; then
+            break
+        fi
+        sleep 0.1
+    done
+    FULL_RPC_URL="http://127.0.0.1:8545"
+    LOG_RPC_URL="$FULL_RPC_URL"
+fi
+
+# Select how forge signs transactions: the operator's deploy gateway, an encrypted keystore or a raw private key
+if [ -n "$WALLET_ADDRESS" ]; then
+    # The gateway signs for the Wallet, so the Job never holds its key
+    WALLET_ARGS=(--unlocked --from "$WALLET_ADDRESS")
+    WALLET_ARGS_LOG="--unlocked --from $WALLET_ADDRESS"
+    SCRIPT_WALLET_ARGS=(--unlocked --sender "$WALLET_ADDRESS")
+    SCRIPT_WALLET_ARGS_LOG="--unlocked --sender $WALLET_ADDRESS"
+elif [ -n "$WALLET_KEYSTORE" ]; then
+    # Unwrap the keystore passphrase through Vault transit when a KMS data key is used
+    # The passphrase is kept in a private file, so it never appears on a command line
+    if [ -n "$KMS_WRAPPED_KEY" ]; then
+        log "Unwrapping keystore passphrase with KMS key $KMS_KEY_NAME..."
+        WALLET_KEYSTORE_PASSWORD_FILE=$(umask 077 && mktemp)
+        trap 'rm -f "$WALLET_KEYSTORE_PASSWORD_FILE"' EXIT
+        curl -sSf -X POST \
+            -H "X-Vault-Token: $KMS_TOKEN" \
+            -H "Content-Type: application/json" \
+            -d "$(jq -n --arg ciphertext "$KMS_WRAPPED_KEY" '{ciphertext: $ciphertext}')" \
+            "$KMS_ADDRESS/v1/transit/decrypt/$KMS_KEY_NAME" | jq -er '.data.plaintext' | base64 -d > "$WALLET_KEYSTORE_PASSWORD_FILE"
+        if [ ! -s "$WALLET_KEYSTORE_PASSWORD_FILE" ]; then
+            log "KMS returned an empty keystore passphrase"
+            exit 1
+        fi
+    fi
+    WALLET_ARGS=(--keystore "$WALLET_KEYSTORE" --password-file "$WALLET_KEYSTORE_PASSWORD_FILE")
+    WALLET_ARGS_LOG="--keystore $WALLET_KEYSTORE --password-file $WALLET_KEYSTORE_PASSWORD_FILE"
 else
-    FULL_RPC_URL="${RPC_URL}"
+    WALLET_ARGS=(--private-key "$WALLET_PRV_KEY")
+    WALLET_ARGS_LOG="--private-key ************"
+fi
+if [ -z "$SCRIPT_WALLET_ARGS_LOG" ]; then
+    SCRIPT_WALLET_ARGS=("${WALLET_ARGS[@]}")
+    SCRIPT_WALLET_ARGS_LOG="$WALLET_ARGS_LOG"
 fi
 
 # Deploy the contract and capture the deployed address
 DEPLOY_OUTPUT_FILE=$(mktemp)
+DEPLOY_STATUS=0
+
+# require_deployment exits unless forge reported the address of the deployed contract.
+# forge also exits with an error when only the source verification fails, which must not fail a finished deployment.
+require_deployment() {
+    if [ -z "$CONTRACT_ADDRESS" ]; then
+        log "Deployment failed, forge exited with status $DEPLOY_STATUS"
+        exit 1
+    fi
+    if [ "$DEPLOY_STATUS" -ne 0 ]; then
+        log "forge exited with status $DEPLOY_STATUS after deploying the contract, the source verification may have failed"
+    fi
+}
 
 if [ -f "$SCRIPT_FILE" ]; then
     log "Running deployment script..."
-    log "forge script $SCRIPT_FILE --rpc-url $FULL_RPC_URL --private-key ************ --broadcast"
-    forge script "$SCRIPT_FILE" --rpc-url "$FULL_RPC_URL" --private-key "$WALLET_PRV_KEY" --broadcast | tee "$DEPLOY_OUTPUT_FILE"
+    log "forge script $SCRIPT_FILE --rpc-url $LOG_RPC_URL $SCRIPT_WALLET_ARGS_LOG --broadcast"
+    forge script "$SCRIPT_FILE" --rpc-url "$FULL_RPC_URL" "${SCRIPT_WALLET_ARGS[@]}" --broadcast | tee "$DEPLOY_OUTPUT_FILE" || DEPLOY_STATUS=$?
     echo "Script completed."
 
     # Extract the deployed contract address and transaction hash from the output
-    CONTRACT_ADDRESS=$(grep -oP 'Contract deployed at: \K(0x[a-fA-F0-9]{40})' "$DEPLOY_OUTPUT_FILE")
+    CONTRACT_ADDRESS=$(grep -oP 'Contract deployed at: \K(0x[a-fA-F0-9]{40})' "$DEPLOY_OUTPUT_FILE" || true)
+    require_deployment
 else
     # Check for test files and run tests if any exist
     if ls test/*.sol 1> /dev/null 2>&1; then
@@ -74,22 +166,36 @@ else
     fi
     print_separator
 
-    if [ -n "$PARAMS" ] && [ -n "$ETHERSCAN_API_KEY" ]; then
-        log "forge create $CONTRACT_FILE:$CONTRACT_NAME --rpc-url $FULL_RPC_URL --private-key ************ --constructor-args $PARAMS --verify --etherscan-api-key ************"
-        forge create "$CONTRACT_FILE:$CONTRACT_NAME" --rpc-url "$FULL_RPC_URL" --private-key "$WALLET_PRV_KEY" --constructor-args $PARAMS --verify --etherscan-api-key "$ETHERSCAN_API_KEY" | tee "$DEPLOY_OUTPUT_FILE"
-    elif [ -n "$PARAMS" ]; then
-        log "forge create $CONTRACT_FILE:$CONTRACT_NAME --rpc-url $FULL_RPC_URL --private-key ************ --constructor-args $PARAMS"
-        forge create "$CONTRACT_FILE:$CONTRACT_NAME" --rpc-url "$FULL_RPC_URL" --private-key "$WALLET_PRV_KEY" --constructor-args $PARAMS | tee "$DEPLOY_OUTPUT_FILE"
-    elif [ -n "$ETHERSCAN_API_KEY" ]; then
-        log "forge create $CONTRACT_FILE:$CONTRACT_NAME --rpc-url $FULL_RPC_URL --private-key ************ --verify --etherscan-api-key ************"
-        forge create "$CONTRACT_FILE:$CONTRACT_NAME" --rpc-url "$FULL_RPC_URL" --private-key "$WALLET_PRV_KEY" --verify --etherscan-api-key "$ETHERSCAN_API_KEY" | tee "$DEPLOY_OUTPUT_FILE"
+    # Verify the source on the block explorer of the Network, if it has one
+    VERIFY_ARGS=()
+    VERIFY_ARGS_LOG=""
+    if [ -z "$VERIFIER" ] && [ -n "$ETHERSCAN_API_KEY" ]; then
+        VERIFIER="etherscan"
+    fi
+    if [ -n "$VERIFIER" ]; then
+        VERIFY_ARGS=(--verify --verifier "$VERIFIER")
+        VERIFY_ARGS_LOG="--verify --verifier $VERIFIER"
+        if [ -n "$VERIFIER_URL" ]; then
+            VERIFY_ARGS+=(--verifier-url "$VERIFIER_URL")
+            VERIFY_ARGS_LOG="$VERIFY_ARGS_LOG --verifier-url $VERIFIER_URL"
+        fi
+        if [ -n "$ETHERSCAN_API_KEY" ]; then
+            VERIFY_ARGS+=(--etherscan-api-key "$ETHERSCAN_API_KEY")
+            VERIFY_ARGS_LOG="$VERIFY_ARGS_LOG --etherscan-api-key ************"
+        fi
+    fi
+
+    if [ -n "$PARAMS" ]; then
+        log "forge create $CONTRACT_FILE:$CONTRACT_NAME --rpc-url $LOG_RPC_URL $WALLET_ARGS_LOG --constructor-args $PARAMS $VERIFY_ARGS_LOG"
+        forge create "$CONTRACT_FILE:$CONTRACT_NAME" --rpc-url "$FULL_RPC_URL" "${WALLET_ARGS[@]}" --constructor-args $PARAMS "${VERIFY_ARGS[@]}" | tee "$DEPLOY_OUTPUT_FILE" || DEPLOY_STATUS=$?
     else
-        log "forge create $CONTRACT_FILE:$CONTRACT_NAME --rpc-url $FULL_RPC_URL --private-key ************"
-        forge create "$CONTRACT_FILE:$CONTRACT_NAME" --rpc-url "$FULL_RPC_URL" --private-key "$WALLET_PRV_KEY" | tee "$DEPLOY_OUTPUT_FILE"
+        log "forge create $CONTRACT_FILE:$CONTRACT_NAME --rpc-url $LOG_RPC_URL $WALLET_ARGS_LOG $VERIFY_ARGS_LOG"
+        forge create "$CONTRACT_FILE:$CONTRACT_NAME" --rpc-url "$FULL_RPC_URL" "${WALLET_ARGS[@]}" "${VERIFY_ARGS[@]}" | tee "$DEPLOY_OUTPUT_FILE" || DEPLOY_STATUS=$?
     fi
 
     # Extract the deployed contract address and transaction hash from the output
-    CONTRACT_ADDRESS=$(grep -oP 'Deployed to: \K(0x[a-fA-F0-9]{40})' "$DEPLOY_OUTPUT_FILE")
+    CONTRACT_ADDRESS=$(grep -oP 'Deployed to: \K(0x[a-fA-F0-9]{40})' "$DEPLOY_OUTPUT_FILE" || true)
+    require_deployment
     TRANSACTION_HASH=$(grep -oP 'Transaction hash: \K(0x[a-fA-F0-9]{64})' "$DEPLOY_OUTPUT_FILE")
 fi
 
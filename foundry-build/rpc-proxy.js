@@ -0,0 +1,57 @@
+// rpc-proxy forwards JSON-RPC requests from forge to an RPC endpoint that needs
+// authentication headers or a client certificate, which forge cannot send itself.
+//
+// RPC_PROXY_UPSTREAM  URL of the endpoint
+// RPC_PROXY_HEADERS   headers added to every request, one "Name: value" per line
+// RPC_PROXY_PORT      local port to listen on, 8545 by default
+// RPC_TLS_DIR         directory with tls.crt, tls.key and optionally ca.crt for mutual TLS
+'use strict';
+
+const fs = require('fs');
+const http = require('http');
+const https = require('https');
+const { URL } = require('url');
+
+const upstream = new URL(process.env.RPC_PROXY_UPSTREAM);
+const port = parseInt(process.env.RPC_PROXY_PORT || '8545', 10);
+
+const headers = {};
+(process.env.RPC_PROXY_HEADERS || '').split('\n').forEach((line) => {
+    const separator = line.indexOf(':');
+    if (separator > 0) {
+        headers[line.slice(0, separator).trim()] = line.slice(separator + 1).trim();
+    }
+});
+
+const transport = upstream.protocol === 'https:' ? https : http;
+const agentOptions = { keepAlive: true };
+const tlsDir = process.env.RPC_TLS_DIR;
+if (tlsDir) {
+    agentOptions.cert = fs.readFileSync(`${tlsDir}/tls.crt`);
+    agentOptions.key = fs.readFileSync(`${tlsDir}/tls.key`);
+    if (fs.existsSync(`${tlsDir}/ca.crt`)) {
+        agentOptions.ca = fs.readFileSync(`${tlsDir}/ca.crt`);
+    }
+}
+const agent = new transport.Agent(agentOptions);
+
+http.createServer((req, res) => {
+    const forwarded = transport.request({
+        protocol: upstream.protocol,
+        hostname: upstream.hostname,
+        port: upstream.port,
+        path: upstream.pathname + upstream.search,
+        method: req.method,
+        agent,
+        headers: Object.assign({ 'content-type': req.headers['content-type'] || 'application/json' }, headers),
+    }, (upstreamRes) => {
+        res.writeHead(upstreamRes.statusCode, upstreamRes.headers);
+        upstreamRes.pipe(res);
+    });
+    forwarded.on('error', (err) => {
+        console.error(`RPC forwarder: ${err.message}`);
+        res.writeHead(502, { 'content-type': 'application/json' });
+        res.end(JSON.stringify({ jsonrpc: '2.0', id: null, error: { code: -32603, message: err.message } }));
+    });
+    req.pipe(forwarded);
+}).listen(port, '127.0.0.1');
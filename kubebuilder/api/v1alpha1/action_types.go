@@ -32,30 +32,78 @@ type ActionParameter struct {
 // ActionSpec defines the desired state of Action
 type ActionSpec struct {
 	// ActionType defines the type of action (e.g., invoke, query, upgrade, test)
+	// On anvil Networks it can also be a cheat code: anvil_mine, evm_increaseTime, anvil_setBalance,
+	// anvil_impersonateAccount, evm_snapshot, evm_revert or anvil_setStorageAt
 	ActionType string `json:"actionType"`
 
 	// ContractRef references the Contract resource for the action
+	// +optional
 	ContractRef string `json:"contractRef"`
 
 	// WalletRef references the Wallet resource used for the action
+	// +optional
 	WalletRef string `json:"walletRef"`
 
 	// NetworkRef references the Network resource where the action will be executed
 	NetworkRef string `json:"networkRef"`
 
 	// GasStrategyRef references the GasStrategy resource for gas price management
+	// +optional
 	GasStrategyRef string `json:"gasStrategyRef"`
 
 	// FunctionName is the name of the contract function to execute (for invoke or test actions)
+	// Functions with parameters are given as a signature (e.g., transfer(address,uint256))
 	FunctionName string `json:"functionName,omitempty"`
 
-	// Parameters are the parameters to pass to the contract function
+	// Parameters are the parameters to pass to the contract function, in the order of the signature
 	Parameters []ActionParameter `json:"parameters,omitempty"`
 
+	// Anvil holds the arguments of a cheat code action
+	// +optional
+	Anvil *AnvilActionSpec `json:"anvil,omitempty"`
+
 	// Schedule is an optional cron schedule for recurring actions
 	Schedule string `json:"schedule,omitempty"`
 }
 
+// AnvilActionSpec holds the arguments of the cheat code actions of anvil Networks
+type AnvilActionSpec struct {
+	// Blocks is the number of blocks anvil_mine mines
+	// Defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Blocks *int64 `json:"blocks,omitempty"`
+
+	// Seconds is how far evm_increaseTime moves the time of the next block
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Seconds *int64 `json:"seconds,omitempty"`
+
+	// Address is the account of anvil_setBalance, anvil_impersonateAccount and anvil_setStorageAt
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// Balance is the balance in wei anvil_setBalance sets, in decimal or 0x-prefixed hex
+	// +optional
+	Balance string `json:"balance,omitempty"`
+
+	// Slot is the storage slot anvil_setStorageAt writes, as a number or 32-byte hex
+	// +optional
+	Slot string `json:"slot,omitempty"`
+
+	// Value is the 32-byte value anvil_setStorageAt writes, as a number or hex
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// SnapshotID is the id of the EVM snapshot evm_revert returns to
+	// +optional
+	SnapshotID string `json:"snapshotID,omitempty"`
+
+	// SnapshotActionRef names an evm_snapshot Action whose snapshot evm_revert returns to
+	// +optional
+	SnapshotActionRef string `json:"snapshotActionRef,omitempty"`
+}
+
 // ActionStatus defines the observed state of Action
 type ActionStatus struct {
 	// LastExecution is the timestamp of the last execution of the action
@@ -66,6 +114,18 @@ type ActionStatus struct {
 
 	// Result is the result of the last action execution (e.g., Success, Failure)
 	Result string `json:"result,omitempty"`
+
+	// ObservedGeneration is the generation of the Action that was last executed
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Output is the result returned by the last execution of a cheat code action, such as the id of an EVM snapshot
+	// +optional
+	Output string `json:"output,omitempty"`
+
+	// Transaction reports the progress of the last execution, including Safe signatures
+	// +optional
+	Transaction *WalletTransactionStatus `json:"transaction,omitempty"`
 }
 
 // +kubebuilder:object:root=true
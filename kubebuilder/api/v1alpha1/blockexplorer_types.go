@@ -25,21 +25,73 @@ type BlockExplorerSpec struct {
 	// ExplorerName is the name of the block explorer (e.g., Etherscan)
 	ExplorerName string `json:"explorerName"`
 
+	// ExplorerType selects the API of the explorer:
+	// Etherscan is the per-chain Etherscan API, EtherscanV2 the multichain Etherscan API that takes a chain ID,
+	// Blockscout and Routescan are Etherscan-compatible APIs and Sourcify is the Sourcify verification server
+	// +kubebuilder:validation:Enum=Etherscan;EtherscanV2;Blockscout;Routescan;Sourcify
+	// +kubebuilder:default=Etherscan
+	// +optional
+	ExplorerType string `json:"explorerType,omitempty"`
+
+	// ChainID is the chain the health check queries on multichain APIs (EtherscanV2 and Sourcify)
+	// Deployments always use the chain ID of their Network
+	// Defaults to the chain ID of a Network that references the explorer, or 1
+	// +optional
+	ChainID *int64 `json:"chainID,omitempty"`
+
 	// SecretRef references a Kubernetes Secret and specifies the keys for API token and URL
 	SecretRef BlockExplorerSecretRef `json:"secretRef"`
+
+	// HealthCheck configures the periodic API health check
+	// +optional
+	HealthCheck *BlockExplorerHealthCheckSpec `json:"healthCheck,omitempty"`
+}
+
+// BlockExplorerHealthCheckSpec configures the health check of a BlockExplorer
+type BlockExplorerHealthCheckSpec struct {
+	// IntervalSeconds is how often the API is checked
+	// Defaults to 60
+	// +kubebuilder:validation:Minimum=5
+	// +optional
+	IntervalSeconds *int64 `json:"intervalSeconds,omitempty"`
 }
 
 // BlockExplorerSecretRef is a custom struct for handling specific keys in the Secret
 type BlockExplorerSecretRef struct {
-	Name     string `json:"name"`
-	TokenKey string `json:"tokenKey"`
-	URLKey   string `json:"urlKey"`
+	Name string `json:"name"`
+
+	// TokenKey is the key within the secret that contains the API key
+	// Required for Etherscan and EtherscanV2
+	// +optional
+	TokenKey string `json:"tokenKey,omitempty"`
+
+	// URLKey is the key within the secret that contains the API URL
+	// Optional for EtherscanV2 and Sourcify, which default to their public APIs
+	// +optional
+	URLKey string `json:"urlKey,omitempty"`
 }
 
 // BlockExplorerStatus defines the observed state of BlockExplorer
 type BlockExplorerStatus struct {
 	Healthy     bool   `json:"healthy,omitempty"`
 	APIEndpoint string `json:"apiEndpoint,omitempty"`
+
+	// Message describes the last health check failure
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastChecked is the time of the last health check
+	// +optional
+	LastChecked *metav1.Time `json:"lastChecked,omitempty"`
+
+	// ObservedGeneration is the generation of the BlockExplorer that was last checked
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// SecretVersions is the resourceVersion of each referenced Secret at the last check, by Secret name
+	// A change of any of these Secrets forces a new check
+	// +optional
+	SecretVersions map[string]string `json:"secretVersions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
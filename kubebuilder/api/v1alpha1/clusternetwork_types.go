@@ -0,0 +1,79 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AllowedNamespaces lists the namespaces whose resources may reference a cluster-scoped resource
+type AllowedNamespaces struct {
+	// Names lists allowed namespaces by name
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// Selector allows the namespaces whose labels match it; an empty selector allows every namespace
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ClusterNetworkSpec defines the desired state of ClusterNetwork
+// RPCProviderRef, RPCProviderRefs and BlockExplorerRef name resources in the cluster resource namespace of the operator
+type ClusterNetworkSpec struct {
+	NetworkSpec `json:",inline"`
+
+	// AllowedNamespaces lists the namespaces that may reference the ClusterNetwork
+	// A ClusterNetwork without it cannot be referenced from any namespace
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+}
+
+// ClusterNetworkStatus defines the observed state of ClusterNetwork
+type ClusterNetworkStatus struct {
+	NetworkStatus `json:",inline"`
+
+	// Network is the Network in the cluster resource namespace that serves the ClusterNetwork, as namespace/name
+	// +optional
+	Network string `json:"network,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// ClusterNetwork is the Schema for the clusternetworks API
+// It is a Network shared by the namespaces it allows
+type ClusterNetwork struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterNetworkSpec   `json:"spec,omitempty"`
+	Status ClusterNetworkStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterNetworkList contains a list of ClusterNetwork
+type ClusterNetworkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterNetwork `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterNetwork{}, &ClusterNetworkList{})
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterRPCProviderSpec defines the desired state of ClusterRPCProvider
+// The Secrets it references are read from the cluster resource namespace of the operator
+type ClusterRPCProviderSpec struct {
+	RPCProviderSpec `json:",inline"`
+
+	// AllowedNamespaces lists the namespaces whose Networks may reference the ClusterRPCProvider
+	// A ClusterRPCProvider without it can only be used by ClusterNetworks
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+}
+
+// ClusterRPCProviderStatus defines the observed state of ClusterRPCProvider
+type ClusterRPCProviderStatus struct {
+	RPCProviderStatus `json:",inline"`
+
+	// RPCProvider is the RPCProvider in the cluster resource namespace that serves the ClusterRPCProvider, as namespace/name
+	// +optional
+	RPCProvider string `json:"rpcProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// ClusterRPCProvider is the Schema for the clusterrpcproviders API
+// It is an RPCProvider shared by the namespaces it allows
+type ClusterRPCProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterRPCProviderSpec   `json:"spec,omitempty"`
+	Status ClusterRPCProviderStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterRPCProviderList contains a list of ClusterRPCProvider
+type ClusterRPCProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterRPCProvider `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterRPCProvider{}, &ClusterRPCProviderList{})
+}
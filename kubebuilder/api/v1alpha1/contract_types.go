@@ -48,9 +48,11 @@ type ContractSpec struct {
 	ContractName string `json:"contractName"`
 
 	// NetworkRefs references the Network resources where this contract is deployed
+	// A reference of the form namespace/name to another namespace needs a ReferenceGrant there
 	NetworkRefs []string `json:"networkRefs"`
 
 	// WalletRef references the Wallet resource that will sign transactions
+	// A reference of the form namespace/name to another namespace needs a ReferenceGrant there
 	WalletRef string `json:"walletRef"`
 
 	// ExternalModules is a list of external modules to be imported via npm
@@ -81,12 +83,48 @@ type ContractSpec struct {
 	// Optional, can be a direct string or a reference to a ConfigMap key
 	FoundryConfig    string                 `json:"foundryConfig,omitempty"`
 	FoundryConfigRef *ConfigMapKeyReference `json:"foundryConfigRef,omitempty"`
+
+	// RedeployOnSourceChange creates a new version when the content of a referenced ConfigMap changes.
+	// When false, only edits of the Contract create new versions
+	// Defaults to true
+	// +optional
+	RedeployOnSourceChange *bool `json:"redeployOnSourceChange,omitempty"`
+}
+
+// ContractNetworkStatus reports the version of a Contract on one of its Networks
+type ContractNetworkStatus struct {
+	// Network is the reference of the Network as listed in networkRefs
+	Network string `json:"network"`
+
+	// Version is the name of the ContractVersion deployed to the Network
+	Version string `json:"version"`
+
+	// SourceHash is the hash of the inputs the version was created from
+	SourceHash string `json:"sourceHash,omitempty"`
 }
 
 // ContractStatus defines the observed state of Contract
 type ContractStatus struct {
 	// CurrentVersion is the current version of the contract
 	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// ObservedGeneration is the generation of the Contract the current version was created for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// SourceHash is the hash of the inputs of the current version that affect the deployed bytecode
+	SourceHash string `json:"sourceHash,omitempty"`
+
+	// Networks reports the version each Network of the contract is on
+	// +optional
+	// +listType=map
+	// +listMapKey=network
+	Networks []ContractNetworkStatus `json:"networks,omitempty"`
+
+	// Conditions describe the state of the contract; ResolvedRefs reports whether its references are permitted
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -39,12 +39,28 @@ type ContractProxySpec struct {
 
 	// ProxyAdminRef references the ProxyAdmin resource managing this proxy
 	ProxyAdminRef string `json:"proxyAdminRef"`
+
+	// ProxyAddress is the address of an existing proxy contract to manage
+	// +optional
+	ProxyAddress string `json:"proxyAddress,omitempty"`
 }
 
 // ContractProxyStatus defines the observed state of ContractProxy
 type ContractProxyStatus struct {
 	// ProxyAddress is the address of the proxy contract on the blockchain
 	ProxyAddress string `json:"proxyAddress,omitempty"`
+
+	// ImplementationAddress is the implementation the proxy currently points to
+	// +optional
+	ImplementationAddress string `json:"implementationAddress,omitempty"`
+
+	// UpgradeTarget is the implementation address of the last upgrade
+	// +optional
+	UpgradeTarget string `json:"upgradeTarget,omitempty"`
+
+	// Upgrade reports the progress of the last upgrade, including Safe signatures
+	// +optional
+	Upgrade *WalletTransactionStatus `json:"upgrade,omitempty"`
 }
 
 // +kubebuilder:object:root=true
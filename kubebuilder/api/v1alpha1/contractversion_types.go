@@ -44,6 +44,9 @@ type ContractVersionStatus struct {
 	TransactionHash string      `json:"transactionHash,omitempty"`
 	Test            string      `json:"test,omitempty"`
 	State           string      `json:"state,omitempty"`
+
+	// SourceHash is the hash of the resolved sources the version was created from
+	SourceHash string `json:"sourceHash,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -27,14 +28,168 @@ type NetworkSpec struct {
 	NetworkName string `json:"networkName"`
 
 	// ChainID is the unique identifier for the blockchain network (e.g., 1 for Ethereum Mainnet)
-	ChainID int `json:"chainID"`
+	// Defaults to the chain ID of NetworkName in the built-in chain catalog
+	// +optional
+	ChainID int `json:"chainID,omitempty"`
+
+	// Chain overrides the built-in chain catalog entry of NetworkName, or describes a chain the catalog does not know
+	// +optional
+	Chain *ChainSpec `json:"chain,omitempty"`
 
 	// RPCProviderRef references the RPCProvider resource to be used for interacting with the blockchain
-	RPCProviderRef corev1.LocalObjectReference `json:"rpcProviderRef"`
+	// +optional
+	RPCProviderRef corev1.LocalObjectReference `json:"rpcProviderRef,omitempty"`
+
+	// RPCProviderRefs lists further RPCProvider resources in order of priority
+	// They are used after RPCProviderRef when its endpoints are unhealthy
+	// +optional
+	RPCProviderRefs []corev1.LocalObjectReference `json:"rpcProviderRefs,omitempty"`
 
 	// BlockExplorerRef references the BlockExplorer resource to be used for querying blockchain data
 	// +optional
 	BlockExplorerRef *corev1.LocalObjectReference `json:"blockExplorerRef,omitempty"`
+
+	// Anvil configures the local chain the operator runs for an anvil Network
+	// +optional
+	Anvil *AnvilSpec `json:"anvil,omitempty"`
+
+	// TTL is how long after its creation the Network expires
+	// An expired Network is deleted together with its chain and the Wallets and ContractVersions on it
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// ExpiresAt is when the Network expires; when TTL is also set, the earlier time applies
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// TelemetryIntervalSeconds is how often the head block, finality and fees of the chain are refreshed in status
+	// Defaults to 30
+	// +kubebuilder:validation:Minimum=5
+	// +optional
+	TelemetryIntervalSeconds *int64 `json:"telemetryIntervalSeconds,omitempty"`
+}
+
+// ChainSpec describes the properties of a chain
+type ChainSpec struct {
+	// CurrencySymbol is the symbol of the native currency (e.g., ETH)
+	// +optional
+	CurrencySymbol string `json:"currencySymbol,omitempty"`
+
+	// CurrencyDecimals is the number of decimals of the native currency
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CurrencyDecimals *int32 `json:"currencyDecimals,omitempty"`
+
+	// ExplorerType is the API type of the usual block explorer of the chain
+	// +kubebuilder:validation:Enum=Etherscan;EtherscanV2;Blockscout;Routescan;Sourcify
+	// +optional
+	ExplorerType string `json:"explorerType,omitempty"`
+
+	// EIP1559 indicates whether the chain prices gas with a base fee and priority fee
+	// +optional
+	EIP1559 *bool `json:"eip1559,omitempty"`
+
+	// BlockTimeMillis is the average time between blocks in milliseconds
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BlockTimeMillis *int64 `json:"blockTimeMillis,omitempty"`
+
+	// FinalityDepth is the number of confirmations after which a block is considered final
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FinalityDepth *int64 `json:"finalityDepth,omitempty"`
+}
+
+// AnvilSpec configures the Anvil workload of a local Network
+type AnvilSpec struct {
+	// Image is the container image that provides the anvil binary
+	// Defaults to docker.io/expedio/kontract-foundry:latest
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// BlockTimeSeconds mines a block at a fixed interval
+	// Blocks are mined on every transaction when unset
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	BlockTimeSeconds *int64 `json:"blockTimeSeconds,omitempty"`
+
+	// GasLimit is the block gas limit
+	// +kubebuilder:validation:Minimum=21000
+	// +optional
+	GasLimit *int64 `json:"gasLimit,omitempty"`
+
+	// Accounts is the number of funded dev accounts
+	// Defaults to 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Accounts *int32 `json:"accounts,omitempty"`
+
+	// Mnemonic is the BIP-39 phrase the dev accounts are derived from, along m/44'/60'/0'/0/<index>
+	// Defaults to the well-known Anvil mnemonic; the keys are stored in Secrets but the phrase itself is not secret
+	// +optional
+	Mnemonic string `json:"mnemonic,omitempty"`
+
+	// Balance is the initial balance of each dev account in ether
+	// Defaults to 10000
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Balance *int64 `json:"balance,omitempty"`
+
+	// Resources are the compute resources of the Anvil container
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// ForkFrom is the name of a Network in the same namespace whose chain state Anvil forks,
+	// using the credentials of that Network's RPCProviders
+	// +optional
+	ForkFrom string `json:"forkFrom,omitempty"`
+
+	// ForkBlockNumber is the block of the source chain the fork starts from
+	// Defaults to the head of the source chain when the fork is first created, which is then kept across restarts
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ForkBlockNumber *int64 `json:"forkBlockNumber,omitempty"`
+
+	// Persistence stores the chain state on a PersistentVolume so it survives restarts of the Anvil pod
+	// +optional
+	Persistence *AnvilPersistenceSpec `json:"persistence,omitempty"`
+
+	// Snapshots lists named snapshots of the chain state
+	// A snapshot is taken once when its name is added and deleted when its name is removed
+	// +optional
+	Snapshots []string `json:"snapshots,omitempty"`
+
+	// Restore requests restoring the chain state from a snapshot
+	// +optional
+	Restore *AnvilRestoreSpec `json:"restore,omitempty"`
+}
+
+// AnvilPersistenceSpec configures the PersistentVolume that holds the Anvil state
+type AnvilPersistenceSpec struct {
+	// Size is the requested size of the volume
+	// Defaults to 1Gi
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// StorageClassName is the storage class of the volume
+	// Defaults to the default storage class of the cluster
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// DumpIntervalSeconds is how often Anvil writes its state to the volume, in addition to on shutdown
+	// Defaults to 30
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	DumpIntervalSeconds *int64 `json:"dumpIntervalSeconds,omitempty"`
+}
+
+// AnvilRestoreSpec defines a request to restore the chain state of a local Network from a snapshot
+type AnvilRestoreSpec struct {
+	// RequestID identifies the restore; changing it restores again
+	RequestID string `json:"requestID"`
+
+	// Snapshot is the name of a snapshot in spec.anvil.snapshots of this Network
+	Snapshot string `json:"snapshot"`
 }
 
 // NetworkStatus defines the observed state of Network
@@ -48,6 +203,196 @@ type NetworkStatus struct {
 
 	// Healthy indicates whether the network is healthy
 	Healthy bool `json:"healthy,omitempty"`
+
+	// Anvil reports the local chain of an anvil Network
+	// +optional
+	Anvil *AnvilStatus `json:"anvil,omitempty"`
+
+	// Chain reports the chain properties in effect, from the catalog and the overrides in the spec
+	// +optional
+	Chain *ChainStatus `json:"chain,omitempty"`
+
+	// Telemetry reports the live state of the chain
+	// +optional
+	Telemetry *ChainTelemetryStatus `json:"telemetry,omitempty"`
+
+	// ExpiresAt is when the Network expires, including any extension
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ExpiryWarning is the expiry the ExpiringSoon event was last emitted for
+	// +optional
+	ExpiryWarning *metav1.Time `json:"expiryWarning,omitempty"`
+}
+
+// ChainStatus reports the chain properties of a Network
+type ChainStatus struct {
+	// Catalog is the name of the catalog entry the properties default to, if any
+	// +optional
+	Catalog string `json:"catalog,omitempty"`
+
+	// ChainID is the chain ID of the Network
+	ChainID int64 `json:"chainID"`
+
+	// CurrencySymbol is the symbol of the native currency
+	// +optional
+	CurrencySymbol string `json:"currencySymbol,omitempty"`
+
+	// CurrencyDecimals is the number of decimals of the native currency
+	CurrencyDecimals int32 `json:"currencyDecimals"`
+
+	// ExplorerType is the API type of the usual block explorer of the chain
+	// +optional
+	ExplorerType string `json:"explorerType,omitempty"`
+
+	// EIP1559 indicates whether the chain prices gas with a base fee and priority fee
+	EIP1559 bool `json:"eip1559"`
+
+	// BlockTimeMillis is the average time between blocks in milliseconds
+	// +optional
+	BlockTimeMillis int64 `json:"blockTimeMillis,omitempty"`
+
+	// FinalityDepth is the number of confirmations after which a block is considered final
+	FinalityDepth int64 `json:"finalityDepth"`
+}
+
+// ChainTelemetryStatus reports the live state of the chain of a Network
+type ChainTelemetryStatus struct {
+	// LastChecked is the time of the last refresh
+	LastChecked metav1.Time `json:"lastChecked"`
+
+	// Endpoint is the RPC endpoint the last successful refresh queried, as provider/endpoint
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Message describes the last refresh failure; the other fields keep the values of the last successful refresh
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// HeadBlockNumber is the number of the latest block
+	// +optional
+	HeadBlockNumber int64 `json:"headBlockNumber,omitempty"`
+
+	// HeadBlockTime is the timestamp of the latest block
+	// +optional
+	HeadBlockTime *metav1.Time `json:"headBlockTime,omitempty"`
+
+	// SafeBlockNumber is the number of the latest safe block, if the chain reports one
+	// +optional
+	SafeBlockNumber *int64 `json:"safeBlockNumber,omitempty"`
+
+	// FinalizedBlockNumber is the number of the latest finalized block, if the chain reports one
+	// +optional
+	FinalizedBlockNumber *int64 `json:"finalizedBlockNumber,omitempty"`
+
+	// BaseFeeWei is the base fee of the latest block, if the chain uses EIP-1559
+	// +optional
+	BaseFeeWei string `json:"baseFeeWei,omitempty"`
+
+	// PriorityFeeWei is the priority fee suggested by eth_maxPriorityFeePerGas
+	// +optional
+	PriorityFeeWei string `json:"priorityFeeWei,omitempty"`
+
+	// BlockTimeMillis is the average time between the recent blocks in milliseconds
+	// +optional
+	BlockTimeMillis int64 `json:"blockTimeMillis,omitempty"`
+}
+
+// AnvilStatus defines the observed state of the Anvil workload of a local Network
+type AnvilStatus struct {
+	// Ready indicates whether the Anvil pod passes its readiness probe
+	Ready bool `json:"ready"`
+
+	// StatefulSet is the name of the StatefulSet that runs Anvil
+	// +optional
+	StatefulSet string `json:"statefulSet,omitempty"`
+
+	// Endpoint is the in-cluster RPC URL of the chain
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// ForkSource is the Network the chain was forked from
+	// +optional
+	ForkSource string `json:"forkSource,omitempty"`
+
+	// ForkBlockNumber is the block of the source chain the fork started from
+	// +optional
+	ForkBlockNumber int64 `json:"forkBlockNumber,omitempty"`
+
+	// Accounts lists the dev accounts and the Wallets created for them
+	// +optional
+	Accounts []AnvilAccountStatus `json:"accounts,omitempty"`
+
+	// Instance identifies the running Anvil process by pod UID and restart count
+	// +optional
+	Instance string `json:"instance,omitempty"`
+
+	// LastReset is when the chain state was last lost or replaced, by a restart or a restore
+	// ContractVersions deployed before it are validated against the chain again
+	// +optional
+	LastReset *metav1.Time `json:"lastReset,omitempty"`
+
+	// Snapshots lists the snapshots taken of the chain
+	// +optional
+	Snapshots []AnvilSnapshotStatus `json:"snapshots,omitempty"`
+
+	// Restore reports the last restore request
+	// +optional
+	Restore *AnvilRestoreStatus `json:"restore,omitempty"`
+}
+
+// AnvilAccountStatus describes a dev account of a local Network
+type AnvilAccountStatus struct {
+	// Index of the account in the derivation path
+	Index int32 `json:"index"`
+
+	// Address of the account
+	Address string `json:"address"`
+
+	// Wallet is the name of the Wallet that holds the key of the account
+	Wallet string `json:"wallet"`
+}
+
+// AnvilSnapshotStatus describes a snapshot of the chain state
+type AnvilSnapshotStatus struct {
+	// Name of the snapshot
+	Name string `json:"name"`
+
+	// ConfigMap holds the compressed state of the snapshot
+	// +optional
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// BlockNumber is the head block when the snapshot was taken
+	// +optional
+	BlockNumber int64 `json:"blockNumber,omitempty"`
+
+	// Time is when the snapshot was taken
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+
+	// Message describes why the snapshot could not be taken
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// AnvilRestoreStatus defines the observed state of a restore request
+type AnvilRestoreStatus struct {
+	// RequestID is the restore request this status refers to
+	RequestID string `json:"requestID"`
+
+	// Snapshot is the snapshot that was restored
+	Snapshot string `json:"snapshot"`
+
+	// Phase is Completed or Failed
+	Phase string `json:"phase"`
+
+	// Message describes why the restore failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// CompletionTime is when the restore completed or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
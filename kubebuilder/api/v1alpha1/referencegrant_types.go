@@ -0,0 +1,79 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReferenceGrantFrom describes resources in another namespace that may reference resources of the namespace of the grant
+type ReferenceGrantFrom struct {
+	// Kind of the referencing resources
+	// ContractVersions created by a Contract are checked as their Contract
+	// +kubebuilder:validation:Enum=Contract;ContractVersion
+	Kind string `json:"kind"`
+
+	// Namespace of the referencing resources
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo describes resources of the namespace of the grant that may be referenced
+type ReferenceGrantTo struct {
+	// Kind of the referenced resources
+	// +kubebuilder:validation:Enum=Network;Wallet
+	Kind string `json:"kind"`
+
+	// Name of the referenced resource
+	// Defaults to every resource of the kind
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// ReferenceGrantSpec defines the desired state of ReferenceGrant
+type ReferenceGrantSpec struct {
+	// From lists the resources that may reference resources of this namespace
+	// +kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+
+	// To lists the resources of this namespace that may be referenced
+	// +kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReferenceGrant is the Schema for the referencegrants API
+// It permits resources in other namespaces to reference resources of its namespace as namespace/name
+type ReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReferenceGrantList contains a list of ReferenceGrant
+type ReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ReferenceGrant{}, &ReferenceGrantList{})
+}
@@ -31,6 +31,46 @@ type SecretKeyReference struct {
 
 	// URLKey is the key within the secret that contains the API endpoint URL
 	URLKey string `json:"urlKey"`
+
+	// UsernameKey is the key within the secret that contains the user name for Basic authentication
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// WebSocketURLKey is the key within the secret that contains the WebSocket URL of the endpoint
+	// When set, the operator subscribes to new blocks over it instead of polling
+	// +optional
+	WebSocketURLKey string `json:"webSocketURLKey,omitempty"`
+}
+
+// RPCAuthSpec defines how the token of an endpoint is sent
+type RPCAuthSpec struct {
+	// Type selects how the token from tokenKey is sent:
+	// Path appends it to the URL path (Infura style), Bearer sends it as an Authorization bearer token,
+	// Header sends it in the header named by headerName, Basic sends usernameKey and tokenKey as Basic credentials,
+	// Query adds it as the query parameter named by queryParameter and None sends no token
+	// +kubebuilder:validation:Enum=Path;Bearer;Header;Basic;Query;None
+	// +kubebuilder:default=Path
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// HeaderName is the header that carries the token when Type is Header (e.g., x-api-key)
+	// +optional
+	HeaderName string `json:"headerName,omitempty"`
+
+	// QueryParameter is the query parameter that carries the token when Type is Query (e.g., apikey)
+	// +optional
+	QueryParameter string `json:"queryParameter,omitempty"`
+
+	// TLS presents a client certificate for mutual TLS, in addition to the token
+	// +optional
+	TLS *RPCTLSSpec `json:"tls,omitempty"`
+}
+
+// RPCTLSSpec references the client certificate used for mutual TLS
+type RPCTLSSpec struct {
+	// SecretName is a kubernetes.io/tls Secret in the same namespace with tls.crt and tls.key
+	// An optional ca.crt key is trusted as the CA of the endpoint
+	SecretName string `json:"secretName"`
 }
 
 // RPCProviderSpec defines the desired state of RPCProvider
@@ -38,17 +78,213 @@ type RPCProviderSpec struct {
 	// ProviderName is the name of the RPC provider (e.g., Infura)
 	ProviderName string `json:"providerName"`
 
+	// SecretRef references a Kubernetes Secret that contains the API token and endpoint URL
+	// Ignored when Endpoints is set
+	// +optional
+	SecretRef SecretKeyReference `json:"secretRef,omitempty"`
+
+	// Endpoints lists the endpoints of the provider, each with its own credentials
+	// With the Failover strategy they are used in order, with the Weighted strategy in proportion to their weight
+	// +optional
+	Endpoints []RPCEndpoint `json:"endpoints,omitempty"`
+
+	// Strategy selects how requests are spread over healthy endpoints (Failover or Weighted)
+	// +kubebuilder:validation:Enum=Failover;Weighted
+	// +kubebuilder:default=Failover
+	// +optional
+	Strategy string `json:"strategy,omitempty"`
+
+	// Auth selects how the credentials of the endpoints are sent
+	// Defaults to appending the token to the URL path
+	// +optional
+	Auth *RPCAuthSpec `json:"auth,omitempty"`
+
+	// HealthCheck sets the thresholds that decide whether an endpoint is Healthy or Degraded
+	// +optional
+	HealthCheck *RPCHealthCheckSpec `json:"healthCheck,omitempty"`
+
+	// RateLimit bounds the requests the operator sends to the provider across all its endpoints
+	// +optional
+	RateLimit *RPCRateLimitSpec `json:"rateLimit,omitempty"`
+}
+
+// RPCRateLimitSpec defines the request rate and budget of an RPCProvider
+type RPCRateLimitSpec struct {
+	// RequestsPerSecond is the sustained number of requests per second; unlimited when unset
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RequestsPerSecond *int32 `json:"requestsPerSecond,omitempty"`
+
+	// Burst is the number of requests that may be sent at once
+	// Defaults to RequestsPerSecond
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Burst *int32 `json:"burst,omitempty"`
+
+	// DailyRequests is the number of requests allowed per UTC day; unlimited when unset
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	DailyRequests *int64 `json:"dailyRequests,omitempty"`
+}
+
+// RPCHealthCheckSpec defines the thresholds of the periodic endpoint health check
+// An endpoint that fails a threshold, or is still syncing, is Degraded
+type RPCHealthCheckSpec struct {
+	// IntervalSeconds is how often the endpoints are checked
+	// Defaults to 60
+	// +kubebuilder:validation:Minimum=5
+	// +optional
+	IntervalSeconds *int64 `json:"intervalSeconds,omitempty"`
+
+	// MaxBlockLag is the number of blocks an endpoint may trail the highest head seen for the same chain
+	// Defaults to 5
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxBlockLag *int64 `json:"maxBlockLag,omitempty"`
+
+	// MaxLatencyMilliseconds is the maximum response time of the head block request
+	// Defaults to 2000
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxLatencyMilliseconds *int64 `json:"maxLatencyMilliseconds,omitempty"`
+
+	// MaxHeadAgeSeconds is the maximum age of the latest block; 0 disables the check
+	// Networks that only mine on demand, such as Anvil, should leave it disabled
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxHeadAgeSeconds *int64 `json:"maxHeadAgeSeconds,omitempty"`
+}
+
+// RPCEndpoint defines a single endpoint of an RPCProvider
+type RPCEndpoint struct {
+	// Name identifies the endpoint in status and events
+	Name string `json:"name"`
+
 	// SecretRef references a Kubernetes Secret that contains the API token and endpoint URL
 	SecretRef SecretKeyReference `json:"secretRef"`
+
+	// Weight is the share of requests sent to the endpoint with the Weighted strategy
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	Weight int32 `json:"weight,omitempty"`
+
+	// Auth overrides the authentication of the provider for this endpoint
+	// +optional
+	Auth *RPCAuthSpec `json:"auth,omitempty"`
+}
+
+// RPCEndpointStatus defines the observed health of a single endpoint
+type RPCEndpointStatus struct {
+	// Name of the endpoint
+	Name string `json:"name"`
+
+	// Healthy indicates whether the last health check of the endpoint succeeded within all thresholds
+	Healthy bool `json:"healthy"`
+
+	// State is the result of the last health check (Healthy, Degraded or Unhealthy)
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// APIEndpoint is the endpoint URL, without its token
+	// +optional
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+
+	// Message describes the last health check failure
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastChecked is the time of the last health check
+	// +optional
+	LastChecked metav1.Time `json:"lastChecked,omitempty"`
+
+	// LatencyMilliseconds is the response time of the head block request
+	// +optional
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+
+	// ChainID is the chain ID reported by eth_chainId
+	// +optional
+	ChainID int64 `json:"chainID,omitempty"`
+
+	// BlockNumber is the number of the latest block
+	// +optional
+	BlockNumber int64 `json:"blockNumber,omitempty"`
+
+	// BlockTimestamp is the timestamp of the latest block
+	// +optional
+	BlockTimestamp *metav1.Time `json:"blockTimestamp,omitempty"`
+
+	// BlockLag is how many blocks the endpoint trails the highest head seen for the same chain
+	// +optional
+	BlockLag int64 `json:"blockLag,omitempty"`
+
+	// Syncing indicates whether eth_syncing reported the node as syncing
+	// +optional
+	Syncing bool `json:"syncing,omitempty"`
+}
+
+// RPCUsageStatus reports the requests sent to an RPCProvider during a UTC day
+type RPCUsageStatus struct {
+	// Day is the UTC day the counters refer to (YYYY-MM-DD)
+	Day string `json:"day"`
+
+	// Requests is the number of requests sent during the day
+	Requests int64 `json:"requests"`
+
+	// Throttled is the number of requests the provider answered with HTTP 429
+	// +optional
+	Throttled int64 `json:"throttled,omitempty"`
+
+	// BudgetExhausted indicates that the daily request budget is used up and requests are refused until the next day
+	// +optional
+	BudgetExhausted bool `json:"budgetExhausted,omitempty"`
+
+	// Workloads breaks the requests down by the operator component that sent them
+	// +optional
+	Workloads []RPCWorkloadUsage `json:"workloads,omitempty"`
+}
+
+// RPCWorkloadUsage is the number of requests one operator component sent to an RPCProvider
+type RPCWorkloadUsage struct {
+	// Name of the component (e.g., Action, HealthCheck)
+	Name string `json:"name"`
+
+	// Requests is the number of requests the component sent during the day
+	Requests int64 `json:"requests"`
 }
 
 // RPCProviderStatus defines the observed state of RPCProvider
 type RPCProviderStatus struct {
-	// Healthy indicates whether the RPCProvider is healthy
+	// Healthy indicates whether the RPCProvider has at least one healthy endpoint
 	Healthy bool `json:"healthy"`
 
+	// State is Healthy if any endpoint is healthy, Degraded if any endpoint is degraded and Unhealthy otherwise
+	// +optional
+	State string `json:"state,omitempty"`
+
 	// APIEndpoint is the actual API endpoint used for RPC calls
 	APIEndpoint string `json:"apiEndpoint"`
+
+	// Endpoints reports the health of each endpoint
+	// +optional
+	Endpoints []RPCEndpointStatus `json:"endpoints,omitempty"`
+
+	// Usage reports the requests the operator sent to the provider today
+	// +optional
+	Usage *RPCUsageStatus `json:"usage,omitempty"`
+
+	// LastChecked is the time of the last health check
+	// +optional
+	LastChecked *metav1.Time `json:"lastChecked,omitempty"`
+
+	// ObservedGeneration is the generation of the RPCProvider that was last checked
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// SecretVersions is the resourceVersion of each referenced Secret at the last check, by Secret name
+	// A change of any of these Secrets forces a new check
+	// +optional
+	SecretVersions map[string]string `json:"secretVersions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
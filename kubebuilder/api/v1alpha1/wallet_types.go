@@ -7,12 +7,78 @@ import (
 // ImportFromSpec defines the optional import settings
 type ImportFromSpec struct {
 	// SecretRef references a Kubernetes Secret that contains the wallet's private key or mnemonic
+	// When Keystore is set, the Secret holds a V3 JSON keystore under the "keystore" key instead
 	SecretRef string `json:"secretRef,omitempty"`
 }
 
+// SecretKeySelector selects a key of a Secret in the same namespace
+type SecretKeySelector struct {
+	// Name of the Secret
+	Name string `json:"name"`
+	// Key within the Secret
+	Key string `json:"key"`
+}
+
+// KMSDataKeySpec defines a data key that is wrapped by a KMS and used as the keystore passphrase
+type KMSDataKeySpec struct {
+	// Provider is the KMS that wraps the data key
+	// +kubebuilder:validation:Enum=VaultTransit
+	Provider string `json:"provider"`
+
+	// Address is the base URL of the KMS (e.g., https://vault.example.com:8200)
+	Address string `json:"address"`
+
+	// KeyName is the name of the KMS key that wraps the data key
+	KeyName string `json:"keyName"`
+
+	// WrappedKeySecretRef references the Secret key that holds the wrapped data key
+	WrappedKeySecretRef SecretKeySelector `json:"wrappedKeySecretRef"`
+
+	// CredentialsSecretRef references the Secret key that holds the token used to call the KMS
+	CredentialsSecretRef SecretKeySelector `json:"credentialsSecretRef"`
+}
+
+// KeystoreSpec defines how the wallet key is stored as an encrypted Ethereum V3 JSON keystore
+type KeystoreSpec struct {
+	// PassphraseSecretRef references the Secret key that holds the keystore passphrase
+	// +optional
+	PassphraseSecretRef *SecretKeySelector `json:"passphraseSecretRef,omitempty"`
+
+	// KMS references a KMS-wrapped data key that is used as the keystore passphrase
+	// +optional
+	KMS *KMSDataKeySpec `json:"kms,omitempty"`
+}
+
+// FundingTarget defines the balance the wallet keeps on a single network
+type FundingTarget struct {
+	// NetworkRef references the Network resource on which the balance is maintained
+	// Defaults to the NetworkRef of the wallet
+	// +optional
+	NetworkRef string `json:"networkRef,omitempty"`
+
+	// MinBalance is the balance in wei below which the wallet is topped up
+	MinBalance string `json:"minBalance"`
+
+	// TargetBalance is the balance in wei the wallet is topped up to
+	TargetBalance string `json:"targetBalance"`
+}
+
+// FundingSpec defines the automatic top-up policy of a wallet
+type FundingSpec struct {
+	// FundFrom references the Wallet resource that sends the top-up transfers (e.g., treasury)
+	FundFrom string `json:"fundFrom"`
+
+	// DailyCap is the maximum amount in wei transferred to this wallet per network within 24 hours
+	// +optional
+	DailyCap string `json:"dailyCap,omitempty"`
+
+	// Targets lists the minimum and target balances per network
+	Targets []FundingTarget `json:"targets"`
+}
+
 // WalletSpec defines the desired state of Wallet
 type WalletSpec struct {
-	// WalletType specifies the type of wallet (e.g., EOA, Contract)
+	// WalletType specifies the type of wallet (e.g., EOA, Safe, SmartAccount)
 	WalletType string `json:"walletType"`
 
 	// NetworkRef references the Network resource where this wallet is used
@@ -20,6 +86,334 @@ type WalletSpec struct {
 
 	// ImportFrom specifies the details for importing an existing wallet
 	ImportFrom *ImportFromSpec `json:"importFrom,omitempty"`
+
+	// Keystore stores the wallet key as an encrypted V3 JSON keystore instead of a raw private key
+	// +optional
+	Keystore *KeystoreSpec `json:"keystore,omitempty"`
+
+	// Funding specifies the policy for automatically topping up the wallet balance
+	// +optional
+	Funding *FundingSpec `json:"funding,omitempty"`
+
+	// Rotation requests a rotation of the wallet key
+	// +optional
+	Rotation *RotationSpec `json:"rotation,omitempty"`
+
+	// Safe configures a Safe multisig wallet; required when WalletType is Safe
+	// +optional
+	Safe *SafeSpec `json:"safe,omitempty"`
+
+	// SmartAccount configures an ERC-4337 smart account wallet; required when WalletType is SmartAccount
+	// +optional
+	SmartAccount *SmartAccountSpec `json:"smartAccount,omitempty"`
+}
+
+// SafeSpec defines a Safe multisig that signs transactions through its owners
+type SafeSpec struct {
+	// Address is the address of the Safe contract on the wallet's network
+	Address string `json:"address"`
+
+	// Delegates lists EOA Wallet resources that are Safe owners and sign transactions proposed by the operator
+	// +kubebuilder:validation:MinItems=1
+	Delegates []string `json:"delegates"`
+
+	// TransactionService posts transactions to a Safe Transaction Service-compatible API for the remaining owners to confirm
+	// Without it, the operator executes a transaction once the delegates reach the threshold
+	// +optional
+	TransactionService *SafeTransactionServiceSpec `json:"transactionService,omitempty"`
+}
+
+// SafeTransactionServiceSpec defines a Safe Transaction Service-compatible API
+type SafeTransactionServiceSpec struct {
+	// URL is the base URL of the service (e.g., https://safe-transaction-sepolia.safe.global)
+	URL string `json:"url"`
+
+	// APIKeySecretRef references the Secret key that holds an API key sent as a bearer token
+	// +optional
+	APIKeySecretRef *SecretKeySelector `json:"apiKeySecretRef,omitempty"`
+}
+
+// SafeStatus defines the observed state of a Safe multisig
+type SafeStatus struct {
+	// Threshold is the number of owner signatures required to execute a transaction
+	Threshold int64 `json:"threshold"`
+
+	// Owners lists the owner addresses of the Safe
+	Owners []string `json:"owners,omitempty"`
+
+	// Conditions describe the state of the Safe; Available reports whether the Safe can be read
+	// and DelegatesReady whether every delegate is an owner of the Safe
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SmartAccountSpec defines an ERC-4337 smart account that sends calls as UserOperations through a bundler
+type SmartAccountSpec struct {
+	// Address is the address of the smart account
+	// Defaults to the counterfactual address returned by the factory
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// OwnerRef references the EOA Wallet resource that owns the account and signs its UserOperations
+	OwnerRef string `json:"ownerRef"`
+
+	// EntryPoint is the address of the ERC-4337 v0.7 EntryPoint contract
+	// Defaults to the canonical v0.7 EntryPoint (0x0000000071727De22E5E9d8BAf0edAc6f37da032)
+	// +optional
+	EntryPoint string `json:"entryPoint,omitempty"`
+
+	// BundlerRef references the RPCProvider resource that holds the ERC-4337 bundler endpoint
+	BundlerRef string `json:"bundlerRef"`
+
+	// Factory deploys the account with its first UserOperation
+	// +optional
+	Factory *SmartAccountFactorySpec `json:"factory,omitempty"`
+
+	// Paymaster pays the gas of UserOperations instead of the account
+	// +optional
+	Paymaster *PaymasterSpec `json:"paymaster,omitempty"`
+}
+
+// SmartAccountFactorySpec defines a SimpleAccountFactory-compatible factory (createAccount(owner, salt))
+type SmartAccountFactorySpec struct {
+	// Address is the address of the factory contract
+	Address string `json:"address"`
+
+	// Salt is the salt passed to the factory, as a decimal or 0x-prefixed hex number
+	// Defaults to 0
+	// +optional
+	Salt string `json:"salt,omitempty"`
+}
+
+// PaymasterSpec defines the paymaster of a smart account, either a fixed paymaster or an ERC-7677 paymaster service
+type PaymasterSpec struct {
+	// Address is the address of a paymaster contract that accepts Data as is
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// Data is the hex-encoded paymaster data sent with Address
+	// +optional
+	Data string `json:"data,omitempty"`
+
+	// ServiceRef references the RPCProvider resource that holds an ERC-7677 paymaster service endpoint
+	// +optional
+	ServiceRef string `json:"serviceRef,omitempty"`
+
+	// Context is passed to the paymaster service (e.g., a sponsorship policy ID)
+	// +optional
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// SmartAccountStatus defines the observed state of an ERC-4337 smart account
+type SmartAccountStatus struct {
+	// Owner is the address of the key that signs UserOperations
+	Owner string `json:"owner"`
+
+	// EntryPoint is the EntryPoint contract the account is used with
+	EntryPoint string `json:"entryPoint"`
+
+	// Deployed indicates whether the account contract exists on-chain
+	Deployed bool `json:"deployed"`
+}
+
+// WalletTransactionStatus reports the progress of a transaction signed by a Wallet
+type WalletTransactionStatus struct {
+	// State is the state of the transaction (Pending, AwaitingSignatures, Succeeded or Failed)
+	State string `json:"state"`
+
+	// TransactionHash is the hash of the transaction that was sent on-chain
+	// +optional
+	TransactionHash string `json:"transactionHash,omitempty"`
+
+	// SafeTxHash is the Safe transaction hash signed by the Safe owners
+	// +optional
+	SafeTxHash string `json:"safeTxHash,omitempty"`
+
+	// UserOpHash is the hash of the ERC-4337 UserOperation sent to the bundler
+	// +optional
+	UserOpHash string `json:"userOpHash,omitempty"`
+
+	// SafeNonce is the Safe nonce used by the transaction
+	// +optional
+	SafeNonce *int64 `json:"safeNonce,omitempty"`
+
+	// Confirmations is the number of owner signatures collected so far
+	// +optional
+	Confirmations int64 `json:"confirmations,omitempty"`
+
+	// Threshold is the number of owner signatures required
+	// +optional
+	Threshold int64 `json:"threshold,omitempty"`
+
+	// Message describes the current state
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// SubmittedAt is when the transaction was sent or proposed
+	// +optional
+	SubmittedAt metav1.Time `json:"submittedAt,omitempty"`
+}
+
+// RotationSpec defines a request to replace the wallet key and migrate on-chain permissions to the new key
+type RotationSpec struct {
+	// RequestID identifies the rotation; changing it starts a new rotation
+	// It is used in the name of the Secret that holds the new key
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=40
+	RequestID string `json:"requestID"`
+
+	// Roles lists the AccessControl roles migrated to the new key, by name (e.g., MINTER_ROLE) or 32 byte hex
+	// Defaults to DEFAULT_ADMIN_ROLE
+	// +optional
+	Roles []string `json:"roles,omitempty"`
+
+	// SweepFunds transfers the remaining native balance of the old key to the new key on every affected network
+	// +kubebuilder:default=true
+	// +optional
+	SweepFunds *bool `json:"sweepFunds,omitempty"`
+}
+
+// FundingTransfer records a single top-up of the wallet
+type FundingTransfer struct {
+	// NetworkRef is the Network on which the top-up happened
+	NetworkRef string `json:"networkRef"`
+
+	// Method is how the balance was raised (transfer or anvil_setBalance)
+	Method string `json:"method"`
+
+	// Amount is the amount in wei added to the wallet balance
+	Amount string `json:"amount"`
+
+	// TransactionHash is the hash of the transfer transaction (empty for anvil_setBalance)
+	// +optional
+	TransactionHash string `json:"transactionHash,omitempty"`
+
+	// Time is the timestamp of the top-up
+	Time metav1.Time `json:"time"`
+}
+
+// FundingNetworkStatus defines the observed funding state of the wallet on a single network
+type FundingNetworkStatus struct {
+	// NetworkRef is the Network this status refers to
+	NetworkRef string `json:"networkRef"`
+
+	// Balance is the last observed balance in wei
+	Balance string `json:"balance,omitempty"`
+
+	// PendingTransaction is the hash of a top-up transfer that is not mined yet
+	// +optional
+	PendingTransaction string `json:"pendingTransaction,omitempty"`
+
+	// PendingNonce is the nonce of the last top-up transfer of the funding source. It is recorded before the transfer
+	// is sent, so a transfer that has to be sent again reuses it and can only replace the first one.
+	// +optional
+	PendingNonce *uint64 `json:"pendingNonce,omitempty"`
+
+	// WindowStart is the start of the current 24 hour daily cap window
+	// +optional
+	WindowStart metav1.Time `json:"windowStart,omitempty"`
+
+	// FundedInWindow is the amount in wei transferred within the current daily cap window
+	// +optional
+	FundedInWindow string `json:"fundedInWindow,omitempty"`
+
+	// CapReached is set once the daily cap held back a top-up within the current window
+	// +optional
+	CapReached bool `json:"capReached,omitempty"`
+
+	// LastChecked is the timestamp of the last balance check
+	// +optional
+	LastChecked metav1.Time `json:"lastChecked,omitempty"`
+}
+
+// RotationStep records a single on-chain transaction of a key rotation
+type RotationStep struct {
+	// NetworkRef is the Network on which the step is executed
+	NetworkRef string `json:"networkRef"`
+
+	// Target is the resource the step applies to (e.g., ContractVersion/token-sepolia-version-1)
+	// +optional
+	Target string `json:"target,omitempty"`
+
+	// Address is the contract address, or the new key address for a sweep
+	Address string `json:"address"`
+
+	// Action is the operation performed (transferOwnership, acceptOwnership, grantRole, renounceRole or sweep)
+	Action string `json:"action"`
+
+	// Role is the AccessControl role for grantRole and renounceRole steps
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// State is the state of the step (Planned, Pending, Succeeded, Skipped or Failed)
+	State string `json:"state"`
+
+	// TransactionHash is the hash of the step transaction
+	// +optional
+	TransactionHash string `json:"transactionHash,omitempty"`
+
+	// Nonce is the nonce of the step transaction. It is recorded before the transaction is sent, so a step that
+	// has to be sent again reuses it and can only replace the first transaction.
+	// +optional
+	Nonce *uint64 `json:"nonce,omitempty"`
+
+	// Attempts is the number of times the step failed
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// RetryTime is when a failed step is sent again
+	// +optional
+	RetryTime *metav1.Time `json:"retryTime,omitempty"`
+
+	// Message describes why a step was skipped or failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RotationStatus defines the observed state of a key rotation
+type RotationStatus struct {
+	// RequestID is the rotation request this status refers to
+	RequestID string `json:"requestID"`
+
+	// Phase is the phase of the rotation (Migrating, Completed or Failed)
+	Phase string `json:"phase"`
+
+	// OldPublicKey is the address of the key being retired
+	OldPublicKey string `json:"oldPublicKey"`
+
+	// NewPublicKey is the address of the replacement key
+	NewPublicKey string `json:"newPublicKey"`
+
+	// NewSecretRef is the Secret that holds the replacement key
+	NewSecretRef string `json:"newSecretRef"`
+
+	// StartTime is when the rotation started
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the rotation completed or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Steps lists every transaction of the rotation
+	// +optional
+	Steps []RotationStep `json:"steps,omitempty"`
+}
+
+// RetiredKey records a wallet key that was replaced by a rotation
+type RetiredKey struct {
+	// PublicKey is the address of the retired key
+	PublicKey string `json:"publicKey"`
+
+	// SecretRef is the Secret that holds the retired key
+	SecretRef string `json:"secretRef"`
+
+	// RequestID is the rotation that retired the key
+	RequestID string `json:"requestID"`
+
+	// RetiredAt is when the key was retired
+	RetiredAt metav1.Time `json:"retiredAt"`
 }
 
 // WalletStatus defines the observed state of Wallet
@@ -29,6 +423,34 @@ type WalletStatus struct {
 
 	// SecretRef stores the reference to the Kubernetes Secret that contains the wallet's private key or mnemonic
 	SecretRef string `json:"secretRef"`
+
+	// KeyFormat is the format of the key stored in the Secret (PrivateKey or Keystore)
+	// +optional
+	KeyFormat string `json:"keyFormat,omitempty"`
+
+	// Funding reports the observed balance and daily cap usage per network
+	// +optional
+	Funding []FundingNetworkStatus `json:"funding,omitempty"`
+
+	// Transfers lists the most recent top-up transfers
+	// +optional
+	Transfers []FundingTransfer `json:"transfers,omitempty"`
+
+	// Rotation reports the progress of the current or last key rotation
+	// +optional
+	Rotation *RotationStatus `json:"rotation,omitempty"`
+
+	// RetiredKeys lists the keys replaced by previous rotations
+	// +optional
+	RetiredKeys []RetiredKey `json:"retiredKeys,omitempty"`
+
+	// Safe reports the threshold and owners of a Safe wallet
+	// +optional
+	Safe *SafeStatus `json:"safe,omitempty"`
+
+	// SmartAccount reports the owner and deployment state of an ERC-4337 smart account wallet
+	// +optional
+	SmartAccount *SmartAccountStatus `json:"smartAccount,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -22,6 +22,8 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -107,6 +109,11 @@ func (in *ActionSpec) DeepCopyInto(out *ActionSpec) {
 		*out = make([]ActionParameter, len(*in))
 		copy(*out, *in)
 	}
+	if in.Anvil != nil {
+		in, out := &in.Anvil, &out.Anvil
+		*out = new(AnvilActionSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActionSpec.
@@ -123,6 +130,11 @@ func (in *ActionSpec) DeepCopy() *ActionSpec {
 func (in *ActionStatus) DeepCopyInto(out *ActionStatus) {
 	*out = *in
 	in.LastExecution.DeepCopyInto(&out.LastExecution)
+	if in.Transaction != nil {
+		in, out := &in.Transaction, &out.Transaction
+		*out = new(WalletTransactionStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActionStatus.
@@ -136,205 +148,273 @@ func (in *ActionStatus) DeepCopy() *ActionStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BlockExplorer) DeepCopyInto(out *BlockExplorer) {
+func (in *AllowedNamespaces) DeepCopyInto(out *AllowedNamespaces) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorer.
-func (in *BlockExplorer) DeepCopy() *BlockExplorer {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedNamespaces.
+func (in *AllowedNamespaces) DeepCopy() *AllowedNamespaces {
 	if in == nil {
 		return nil
 	}
-	out := new(BlockExplorer)
+	out := new(AllowedNamespaces)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *BlockExplorer) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnvilAccountStatus) DeepCopyInto(out *AnvilAccountStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilAccountStatus.
+func (in *AnvilAccountStatus) DeepCopy() *AnvilAccountStatus {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(AnvilAccountStatus)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BlockExplorerList) DeepCopyInto(out *BlockExplorerList) {
+func (in *AnvilActionSpec) DeepCopyInto(out *AnvilActionSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]BlockExplorer, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Blocks != nil {
+		in, out := &in.Blocks, &out.Blocks
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Seconds != nil {
+		in, out := &in.Seconds, &out.Seconds
+		*out = new(int64)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerList.
-func (in *BlockExplorerList) DeepCopy() *BlockExplorerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilActionSpec.
+func (in *AnvilActionSpec) DeepCopy() *AnvilActionSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(BlockExplorerList)
+	out := new(AnvilActionSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *BlockExplorerList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BlockExplorerSecretRef) DeepCopyInto(out *BlockExplorerSecretRef) {
+func (in *AnvilPersistenceSpec) DeepCopyInto(out *AnvilPersistenceSpec) {
 	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		*out = new(resource.Quantity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.DumpIntervalSeconds != nil {
+		in, out := &in.DumpIntervalSeconds, &out.DumpIntervalSeconds
+		*out = new(int64)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerSecretRef.
-func (in *BlockExplorerSecretRef) DeepCopy() *BlockExplorerSecretRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilPersistenceSpec.
+func (in *AnvilPersistenceSpec) DeepCopy() *AnvilPersistenceSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(BlockExplorerSecretRef)
+	out := new(AnvilPersistenceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BlockExplorerSpec) DeepCopyInto(out *BlockExplorerSpec) {
+func (in *AnvilRestoreSpec) DeepCopyInto(out *AnvilRestoreSpec) {
 	*out = *in
-	out.SecretRef = in.SecretRef
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerSpec.
-func (in *BlockExplorerSpec) DeepCopy() *BlockExplorerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilRestoreSpec.
+func (in *AnvilRestoreSpec) DeepCopy() *AnvilRestoreSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(BlockExplorerSpec)
+	out := new(AnvilRestoreSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BlockExplorerStatus) DeepCopyInto(out *BlockExplorerStatus) {
+func (in *AnvilRestoreStatus) DeepCopyInto(out *AnvilRestoreStatus) {
 	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerStatus.
-func (in *BlockExplorerStatus) DeepCopy() *BlockExplorerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilRestoreStatus.
+func (in *AnvilRestoreStatus) DeepCopy() *AnvilRestoreStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(BlockExplorerStatus)
+	out := new(AnvilRestoreStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ConfigMapReference) DeepCopyInto(out *ConfigMapReference) {
+func (in *AnvilSnapshotStatus) DeepCopyInto(out *AnvilSnapshotStatus) {
 	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapReference.
-func (in *ConfigMapReference) DeepCopy() *ConfigMapReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilSnapshotStatus.
+func (in *AnvilSnapshotStatus) DeepCopy() *AnvilSnapshotStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ConfigMapReference)
+	out := new(AnvilSnapshotStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Contract) DeepCopyInto(out *Contract) {
+func (in *AnvilSpec) DeepCopyInto(out *AnvilSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	if in.BlockTimeSeconds != nil {
+		in, out := &in.BlockTimeSeconds, &out.BlockTimeSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.GasLimit != nil {
+		in, out := &in.GasLimit, &out.GasLimit
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Accounts != nil {
+		in, out := &in.Accounts, &out.Accounts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Balance != nil {
+		in, out := &in.Balance, &out.Balance
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ForkBlockNumber != nil {
+		in, out := &in.ForkBlockNumber, &out.ForkBlockNumber
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Persistence != nil {
+		in, out := &in.Persistence, &out.Persistence
+		*out = new(AnvilPersistenceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Restore != nil {
+		in, out := &in.Restore, &out.Restore
+		*out = new(AnvilRestoreSpec)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Contract.
-func (in *Contract) DeepCopy() *Contract {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilSpec.
+func (in *AnvilSpec) DeepCopy() *AnvilSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Contract)
+	out := new(AnvilSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Contract) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractList) DeepCopyInto(out *ContractList) {
+func (in *AnvilStatus) DeepCopyInto(out *AnvilStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Contract, len(*in))
+	if in.Accounts != nil {
+		in, out := &in.Accounts, &out.Accounts
+		*out = make([]AnvilAccountStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReset != nil {
+		in, out := &in.LastReset, &out.LastReset
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]AnvilSnapshotStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Restore != nil {
+		in, out := &in.Restore, &out.Restore
+		*out = new(AnvilRestoreStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractList.
-func (in *ContractList) DeepCopy() *ContractList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnvilStatus.
+func (in *AnvilStatus) DeepCopy() *AnvilStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractList)
+	out := new(AnvilStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ContractList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractProxy) DeepCopyInto(out *ContractProxy) {
+func (in *BlockExplorer) DeepCopyInto(out *BlockExplorer) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxy.
-func (in *ContractProxy) DeepCopy() *ContractProxy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorer.
+func (in *BlockExplorer) DeepCopy() *BlockExplorer {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractProxy)
+	out := new(BlockExplorer)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ContractProxy) DeepCopyObject() runtime.Object {
+func (in *BlockExplorer) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -342,31 +422,51 @@ func (in *ContractProxy) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractProxyList) DeepCopyInto(out *ContractProxyList) {
+func (in *BlockExplorerHealthCheckSpec) DeepCopyInto(out *BlockExplorerHealthCheckSpec) {
+	*out = *in
+	if in.IntervalSeconds != nil {
+		in, out := &in.IntervalSeconds, &out.IntervalSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerHealthCheckSpec.
+func (in *BlockExplorerHealthCheckSpec) DeepCopy() *BlockExplorerHealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BlockExplorerHealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlockExplorerList) DeepCopyInto(out *BlockExplorerList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ContractProxy, len(*in))
+		*out = make([]BlockExplorer, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxyList.
-func (in *ContractProxyList) DeepCopy() *ContractProxyList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerList.
+func (in *BlockExplorerList) DeepCopy() *BlockExplorerList {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractProxyList)
+	out := new(BlockExplorerList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ContractProxyList) DeepCopyObject() runtime.Object {
+func (in *BlockExplorerList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -374,87 +474,156 @@ func (in *ContractProxyList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractProxySpec) DeepCopyInto(out *ContractProxySpec) {
+func (in *BlockExplorerSecretRef) DeepCopyInto(out *BlockExplorerSecretRef) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxySpec.
-func (in *ContractProxySpec) DeepCopy() *ContractProxySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerSecretRef.
+func (in *BlockExplorerSecretRef) DeepCopy() *BlockExplorerSecretRef {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractProxySpec)
+	out := new(BlockExplorerSecretRef)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractProxyStatus) DeepCopyInto(out *ContractProxyStatus) {
+func (in *BlockExplorerSpec) DeepCopyInto(out *BlockExplorerSpec) {
 	*out = *in
+	if in.ChainID != nil {
+		in, out := &in.ChainID, &out.ChainID
+		*out = new(int64)
+		**out = **in
+	}
+	out.SecretRef = in.SecretRef
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(BlockExplorerHealthCheckSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxyStatus.
-func (in *ContractProxyStatus) DeepCopy() *ContractProxyStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerSpec.
+func (in *BlockExplorerSpec) DeepCopy() *BlockExplorerSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractProxyStatus)
+	out := new(BlockExplorerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractSpec) DeepCopyInto(out *ContractSpec) {
+func (in *BlockExplorerStatus) DeepCopyInto(out *BlockExplorerStatus) {
 	*out = *in
-	if in.NetworkRefs != nil {
-		in, out := &in.NetworkRefs, &out.NetworkRefs
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.LastChecked != nil {
+		in, out := &in.LastChecked, &out.LastChecked
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretVersions != nil {
+		in, out := &in.SecretVersions, &out.SecretVersions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	if in.ExternalModules != nil {
-		in, out := &in.ExternalModules, &out.ExternalModules
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockExplorerStatus.
+func (in *BlockExplorerStatus) DeepCopy() *BlockExplorerStatus {
+	if in == nil {
+		return nil
 	}
-	if in.LocalModules != nil {
-		in, out := &in.LocalModules, &out.LocalModules
-		*out = make([]ConfigMapReference, len(*in))
-		copy(*out, *in)
+	out := new(BlockExplorerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChainSpec) DeepCopyInto(out *ChainSpec) {
+	*out = *in
+	if in.CurrencyDecimals != nil {
+		in, out := &in.CurrencyDecimals, &out.CurrencyDecimals
+		*out = new(int32)
+		**out = **in
 	}
-	if in.InitParams != nil {
-		in, out := &in.InitParams, &out.InitParams
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.EIP1559 != nil {
+		in, out := &in.EIP1559, &out.EIP1559
+		*out = new(bool)
+		**out = **in
+	}
+	if in.BlockTimeMillis != nil {
+		in, out := &in.BlockTimeMillis, &out.BlockTimeMillis
+		*out = new(int64)
+		**out = **in
+	}
+	if in.FinalityDepth != nil {
+		in, out := &in.FinalityDepth, &out.FinalityDepth
+		*out = new(int64)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractSpec.
-func (in *ContractSpec) DeepCopy() *ContractSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChainSpec.
+func (in *ChainSpec) DeepCopy() *ChainSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractSpec)
+	out := new(ChainSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractStatus) DeepCopyInto(out *ContractStatus) {
+func (in *ChainStatus) DeepCopyInto(out *ChainStatus) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractStatus.
-func (in *ContractStatus) DeepCopy() *ContractStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChainStatus.
+func (in *ChainStatus) DeepCopy() *ChainStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractStatus)
+	out := new(ChainStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractVersion) DeepCopyInto(out *ContractVersion) {
+func (in *ChainTelemetryStatus) DeepCopyInto(out *ChainTelemetryStatus) {
+	*out = *in
+	in.LastChecked.DeepCopyInto(&out.LastChecked)
+	if in.HeadBlockTime != nil {
+		in, out := &in.HeadBlockTime, &out.HeadBlockTime
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SafeBlockNumber != nil {
+		in, out := &in.SafeBlockNumber, &out.SafeBlockNumber
+		*out = new(int64)
+		**out = **in
+	}
+	if in.FinalizedBlockNumber != nil {
+		in, out := &in.FinalizedBlockNumber, &out.FinalizedBlockNumber
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChainTelemetryStatus.
+func (in *ChainTelemetryStatus) DeepCopy() *ChainTelemetryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ChainTelemetryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetwork) DeepCopyInto(out *ClusterNetwork) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -462,18 +631,18 @@ func (in *ContractVersion) DeepCopyInto(out *ContractVersion) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersion.
-func (in *ContractVersion) DeepCopy() *ContractVersion {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetwork.
+func (in *ClusterNetwork) DeepCopy() *ClusterNetwork {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractVersion)
+	out := new(ClusterNetwork)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ContractVersion) DeepCopyObject() runtime.Object {
+func (in *ClusterNetwork) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -481,25 +650,502 @@ func (in *ContractVersion) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractVersionList) DeepCopyInto(out *ContractVersionList) {
+func (in *ClusterNetworkList) DeepCopyInto(out *ClusterNetworkList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ContractVersion, len(*in))
+		*out = make([]ClusterNetwork, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersionList.
-func (in *ContractVersionList) DeepCopy() *ContractVersionList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkList.
+func (in *ClusterNetworkList) DeepCopy() *ClusterNetworkList {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractVersionList)
+	out := new(ClusterNetworkList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterNetworkList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetworkSpec) DeepCopyInto(out *ClusterNetworkSpec) {
+	*out = *in
+	in.NetworkSpec.DeepCopyInto(&out.NetworkSpec)
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = new(AllowedNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkSpec.
+func (in *ClusterNetworkSpec) DeepCopy() *ClusterNetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetworkStatus) DeepCopyInto(out *ClusterNetworkStatus) {
+	*out = *in
+	in.NetworkStatus.DeepCopyInto(&out.NetworkStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkStatus.
+func (in *ClusterNetworkStatus) DeepCopy() *ClusterNetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterRPCProvider) DeepCopyInto(out *ClusterRPCProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterRPCProvider.
+func (in *ClusterRPCProvider) DeepCopy() *ClusterRPCProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRPCProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterRPCProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterRPCProviderList) DeepCopyInto(out *ClusterRPCProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterRPCProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterRPCProviderList.
+func (in *ClusterRPCProviderList) DeepCopy() *ClusterRPCProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRPCProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterRPCProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterRPCProviderSpec) DeepCopyInto(out *ClusterRPCProviderSpec) {
+	*out = *in
+	in.RPCProviderSpec.DeepCopyInto(&out.RPCProviderSpec)
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = new(AllowedNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterRPCProviderSpec.
+func (in *ClusterRPCProviderSpec) DeepCopy() *ClusterRPCProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRPCProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterRPCProviderStatus) DeepCopyInto(out *ClusterRPCProviderStatus) {
+	*out = *in
+	in.RPCProviderStatus.DeepCopyInto(&out.RPCProviderStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterRPCProviderStatus.
+func (in *ClusterRPCProviderStatus) DeepCopy() *ClusterRPCProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRPCProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapReference) DeepCopyInto(out *ConfigMapReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapReference.
+func (in *ConfigMapReference) DeepCopy() *ConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Contract) DeepCopyInto(out *Contract) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Contract.
+func (in *Contract) DeepCopy() *Contract {
+	if in == nil {
+		return nil
+	}
+	out := new(Contract)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Contract) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractList) DeepCopyInto(out *ContractList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Contract, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractList.
+func (in *ContractList) DeepCopy() *ContractList {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ContractList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractNetworkStatus) DeepCopyInto(out *ContractNetworkStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractNetworkStatus.
+func (in *ContractNetworkStatus) DeepCopy() *ContractNetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractNetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractProxy) DeepCopyInto(out *ContractProxy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxy.
+func (in *ContractProxy) DeepCopy() *ContractProxy {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractProxy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ContractProxy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractProxyList) DeepCopyInto(out *ContractProxyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ContractProxy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxyList.
+func (in *ContractProxyList) DeepCopy() *ContractProxyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractProxyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ContractProxyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractProxySpec) DeepCopyInto(out *ContractProxySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxySpec.
+func (in *ContractProxySpec) DeepCopy() *ContractProxySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractProxySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractProxyStatus) DeepCopyInto(out *ContractProxyStatus) {
+	*out = *in
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(WalletTransactionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractProxyStatus.
+func (in *ContractProxyStatus) DeepCopy() *ContractProxyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractProxyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractSpec) DeepCopyInto(out *ContractSpec) {
+	*out = *in
+	if in.NetworkRefs != nil {
+		in, out := &in.NetworkRefs, &out.NetworkRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExternalModules != nil {
+		in, out := &in.ExternalModules, &out.ExternalModules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LocalModules != nil {
+		in, out := &in.LocalModules, &out.LocalModules
+		*out = make([]ConfigMapReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.CodeRef != nil {
+		in, out := &in.CodeRef, &out.CodeRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+	if in.TestRef != nil {
+		in, out := &in.TestRef, &out.TestRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+	if in.InitParams != nil {
+		in, out := &in.InitParams, &out.InitParams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ScriptRef != nil {
+		in, out := &in.ScriptRef, &out.ScriptRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+	if in.FoundryConfigRef != nil {
+		in, out := &in.FoundryConfigRef, &out.FoundryConfigRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+	if in.RedeployOnSourceChange != nil {
+		in, out := &in.RedeployOnSourceChange, &out.RedeployOnSourceChange
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractSpec.
+func (in *ContractSpec) DeepCopy() *ContractSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractStatus) DeepCopyInto(out *ContractStatus) {
+	*out = *in
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]ContractNetworkStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractStatus.
+func (in *ContractStatus) DeepCopy() *ContractStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractVersion) DeepCopyInto(out *ContractVersion) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersion.
+func (in *ContractVersion) DeepCopy() *ContractVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ContractVersion) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractVersionList) DeepCopyInto(out *ContractVersionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ContractVersion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersionList.
+func (in *ContractVersionList) DeepCopy() *ContractVersionList {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractVersionList)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -513,87 +1159,573 @@ func (in *ContractVersionList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractVersionSpec) DeepCopyInto(out *ContractVersionSpec) {
+func (in *ContractVersionSpec) DeepCopyInto(out *ContractVersionSpec) {
+	*out = *in
+	if in.InitParams != nil {
+		in, out := &in.InitParams, &out.InitParams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExternalModules != nil {
+		in, out := &in.ExternalModules, &out.ExternalModules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LocalModules != nil {
+		in, out := &in.LocalModules, &out.LocalModules
+		*out = make([]ConfigMapReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersionSpec.
+func (in *ContractVersionSpec) DeepCopy() *ContractVersionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractVersionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContractVersionStatus) DeepCopyInto(out *ContractVersionStatus) {
+	*out = *in
+	in.DeploymentTime.DeepCopyInto(&out.DeploymentTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersionStatus.
+func (in *ContractVersionStatus) DeepCopy() *ContractVersionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ContractVersionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventFilter) DeepCopyInto(out *EventFilter) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventFilter.
+func (in *EventFilter) DeepCopy() *EventFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(EventFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventHook) DeepCopyInto(out *EventHook) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHook.
+func (in *EventHook) DeepCopy() *EventHook {
+	if in == nil {
+		return nil
+	}
+	out := new(EventHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EventHook) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventHookList) DeepCopyInto(out *EventHookList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EventHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHookList.
+func (in *EventHookList) DeepCopy() *EventHookList {
+	if in == nil {
+		return nil
+	}
+	out := new(EventHookList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EventHookList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventHookSpec) DeepCopyInto(out *EventHookSpec) {
+	*out = *in
+	out.Filter = in.Filter
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHookSpec.
+func (in *EventHookSpec) DeepCopy() *EventHookSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EventHookSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventHookStatus) DeepCopyInto(out *EventHookStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHookStatus.
+func (in *EventHookStatus) DeepCopy() *EventHookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EventHookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FundingNetworkStatus) DeepCopyInto(out *FundingNetworkStatus) {
+	*out = *in
+	if in.PendingNonce != nil {
+		in, out := &in.PendingNonce, &out.PendingNonce
+		*out = new(uint64)
+		**out = **in
+	}
+	in.WindowStart.DeepCopyInto(&out.WindowStart)
+	in.LastChecked.DeepCopyInto(&out.LastChecked)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FundingNetworkStatus.
+func (in *FundingNetworkStatus) DeepCopy() *FundingNetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FundingNetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FundingSpec) DeepCopyInto(out *FundingSpec) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]FundingTarget, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FundingSpec.
+func (in *FundingSpec) DeepCopy() *FundingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FundingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FundingTarget) DeepCopyInto(out *FundingTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FundingTarget.
+func (in *FundingTarget) DeepCopy() *FundingTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(FundingTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FundingTransfer) DeepCopyInto(out *FundingTransfer) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FundingTransfer.
+func (in *FundingTransfer) DeepCopy() *FundingTransfer {
+	if in == nil {
+		return nil
+	}
+	out := new(FundingTransfer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GasStrategy) DeepCopyInto(out *GasStrategy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategy.
+func (in *GasStrategy) DeepCopy() *GasStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(GasStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GasStrategy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GasStrategyList) DeepCopyInto(out *GasStrategyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GasStrategy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategyList.
+func (in *GasStrategyList) DeepCopy() *GasStrategyList {
+	if in == nil {
+		return nil
+	}
+	out := new(GasStrategyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GasStrategyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GasStrategySpec) DeepCopyInto(out *GasStrategySpec) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategySpec.
+func (in *GasStrategySpec) DeepCopy() *GasStrategySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GasStrategySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GasStrategyStatus) DeepCopyInto(out *GasStrategyStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategyStatus.
+func (in *GasStrategyStatus) DeepCopy() *GasStrategyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GasStrategyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImportFromSpec) DeepCopyInto(out *ImportFromSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportFromSpec.
+func (in *ImportFromSpec) DeepCopy() *ImportFromSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportFromSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KMSDataKeySpec) DeepCopyInto(out *KMSDataKeySpec) {
+	*out = *in
+	out.WrappedKeySecretRef = in.WrappedKeySecretRef
+	out.CredentialsSecretRef = in.CredentialsSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KMSDataKeySpec.
+func (in *KMSDataKeySpec) DeepCopy() *KMSDataKeySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KMSDataKeySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeystoreSpec) DeepCopyInto(out *KeystoreSpec) {
+	*out = *in
+	if in.PassphraseSecretRef != nil {
+		in, out := &in.PassphraseSecretRef, &out.PassphraseSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.KMS != nil {
+		in, out := &in.KMS, &out.KMS
+		*out = new(KMSDataKeySpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeystoreSpec.
+func (in *KeystoreSpec) DeepCopy() *KeystoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KeystoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Network) DeepCopyInto(out *Network) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
+func (in *Network) DeepCopy() *Network {
+	if in == nil {
+		return nil
+	}
+	out := new(Network)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Network) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkList) DeepCopyInto(out *NetworkList) {
 	*out = *in
-	if in.InitParams != nil {
-		in, out := &in.InitParams, &out.InitParams
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Network, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	if in.ExternalModules != nil {
-		in, out := &in.ExternalModules, &out.ExternalModules
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkList.
+func (in *NetworkList) DeepCopy() *NetworkList {
+	if in == nil {
+		return nil
 	}
-	if in.LocalModules != nil {
-		in, out := &in.LocalModules, &out.LocalModules
-		*out = make([]ConfigMapReference, len(*in))
+	out := new(NetworkList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NetworkList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
+	*out = *in
+	if in.Chain != nil {
+		in, out := &in.Chain, &out.Chain
+		*out = new(ChainSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	out.RPCProviderRef = in.RPCProviderRef
+	if in.RPCProviderRefs != nil {
+		in, out := &in.RPCProviderRefs, &out.RPCProviderRefs
+		*out = make([]v1.LocalObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.BlockExplorerRef != nil {
+		in, out := &in.BlockExplorerRef, &out.BlockExplorerRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Anvil != nil {
+		in, out := &in.Anvil, &out.Anvil
+		*out = new(AnvilSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TelemetryIntervalSeconds != nil {
+		in, out := &in.TelemetryIntervalSeconds, &out.TelemetryIntervalSeconds
+		*out = new(int64)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersionSpec.
-func (in *ContractVersionSpec) DeepCopy() *ContractVersionSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
+func (in *NetworkSpec) DeepCopy() *NetworkSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractVersionSpec)
+	out := new(NetworkSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContractVersionStatus) DeepCopyInto(out *ContractVersionStatus) {
+func (in *NetworkStatus) DeepCopyInto(out *NetworkStatus) {
 	*out = *in
-	in.DeploymentTime.DeepCopyInto(&out.DeploymentTime)
+	if in.Anvil != nil {
+		in, out := &in.Anvil, &out.Anvil
+		*out = new(AnvilStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Chain != nil {
+		in, out := &in.Chain, &out.Chain
+		*out = new(ChainStatus)
+		**out = **in
+	}
+	if in.Telemetry != nil {
+		in, out := &in.Telemetry, &out.Telemetry
+		*out = new(ChainTelemetryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExpiryWarning != nil {
+		in, out := &in.ExpiryWarning, &out.ExpiryWarning
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractVersionStatus.
-func (in *ContractVersionStatus) DeepCopy() *ContractVersionStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkStatus.
+func (in *NetworkStatus) DeepCopy() *NetworkStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ContractVersionStatus)
+	out := new(NetworkStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EventFilter) DeepCopyInto(out *EventFilter) {
+func (in *PaymasterSpec) DeepCopyInto(out *PaymasterSpec) {
 	*out = *in
+	if in.Context != nil {
+		in, out := &in.Context, &out.Context
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventFilter.
-func (in *EventFilter) DeepCopy() *EventFilter {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PaymasterSpec.
+func (in *PaymasterSpec) DeepCopy() *PaymasterSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(EventFilter)
+	out := new(PaymasterSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EventHook) DeepCopyInto(out *EventHook) {
+func (in *ProxyAdmin) DeepCopyInto(out *ProxyAdmin) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHook.
-func (in *EventHook) DeepCopy() *EventHook {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdmin.
+func (in *ProxyAdmin) DeepCopy() *ProxyAdmin {
 	if in == nil {
 		return nil
 	}
-	out := new(EventHook)
+	out := new(ProxyAdmin)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EventHook) DeepCopyObject() runtime.Object {
+func (in *ProxyAdmin) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -601,31 +1733,31 @@ func (in *EventHook) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EventHookList) DeepCopyInto(out *EventHookList) {
+func (in *ProxyAdminList) DeepCopyInto(out *ProxyAdminList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]EventHook, len(*in))
+		*out = make([]ProxyAdmin, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHookList.
-func (in *EventHookList) DeepCopy() *EventHookList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdminList.
+func (in *ProxyAdminList) DeepCopy() *ProxyAdminList {
 	if in == nil {
 		return nil
 	}
-	out := new(EventHookList)
+	out := new(ProxyAdminList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EventHookList) DeepCopyObject() runtime.Object {
+func (in *ProxyAdminList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -633,57 +1765,158 @@ func (in *EventHookList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EventHookSpec) DeepCopyInto(out *EventHookSpec) {
+func (in *ProxyAdminSpec) DeepCopyInto(out *ProxyAdminSpec) {
 	*out = *in
-	out.Filter = in.Filter
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHookSpec.
-func (in *EventHookSpec) DeepCopy() *EventHookSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdminSpec.
+func (in *ProxyAdminSpec) DeepCopy() *ProxyAdminSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(EventHookSpec)
+	out := new(ProxyAdminSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EventHookStatus) DeepCopyInto(out *EventHookStatus) {
+func (in *ProxyAdminStatus) DeepCopyInto(out *ProxyAdminStatus) {
 	*out = *in
+	if in.ContractProxyRefs != nil {
+		in, out := &in.ContractProxyRefs, &out.ContractProxyRefs
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHookStatus.
-func (in *EventHookStatus) DeepCopy() *EventHookStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdminStatus.
+func (in *ProxyAdminStatus) DeepCopy() *ProxyAdminStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(EventHookStatus)
+	out := new(ProxyAdminStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GasStrategy) DeepCopyInto(out *GasStrategy) {
+func (in *RPCAuthSpec) DeepCopyInto(out *RPCAuthSpec) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(RPCTLSSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCAuthSpec.
+func (in *RPCAuthSpec) DeepCopy() *RPCAuthSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RPCAuthSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RPCEndpoint) DeepCopyInto(out *RPCEndpoint) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(RPCAuthSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCEndpoint.
+func (in *RPCEndpoint) DeepCopy() *RPCEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(RPCEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RPCEndpointStatus) DeepCopyInto(out *RPCEndpointStatus) {
+	*out = *in
+	in.LastChecked.DeepCopyInto(&out.LastChecked)
+	if in.BlockTimestamp != nil {
+		in, out := &in.BlockTimestamp, &out.BlockTimestamp
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCEndpointStatus.
+func (in *RPCEndpointStatus) DeepCopy() *RPCEndpointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RPCEndpointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RPCHealthCheckSpec) DeepCopyInto(out *RPCHealthCheckSpec) {
+	*out = *in
+	if in.IntervalSeconds != nil {
+		in, out := &in.IntervalSeconds, &out.IntervalSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MaxBlockLag != nil {
+		in, out := &in.MaxBlockLag, &out.MaxBlockLag
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MaxLatencyMilliseconds != nil {
+		in, out := &in.MaxLatencyMilliseconds, &out.MaxLatencyMilliseconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MaxHeadAgeSeconds != nil {
+		in, out := &in.MaxHeadAgeSeconds, &out.MaxHeadAgeSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCHealthCheckSpec.
+func (in *RPCHealthCheckSpec) DeepCopy() *RPCHealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RPCHealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RPCProvider) DeepCopyInto(out *RPCProvider) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategy.
-func (in *GasStrategy) DeepCopy() *GasStrategy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProvider.
+func (in *RPCProvider) DeepCopy() *RPCProvider {
 	if in == nil {
 		return nil
 	}
-	out := new(GasStrategy)
+	out := new(RPCProvider)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GasStrategy) DeepCopyObject() runtime.Object {
+func (in *RPCProvider) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -691,108 +1924,214 @@ func (in *GasStrategy) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GasStrategyList) DeepCopyInto(out *GasStrategyList) {
+func (in *RPCProviderList) DeepCopyInto(out *RPCProviderList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]GasStrategy, len(*in))
+		*out = make([]RPCProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProviderList.
+func (in *RPCProviderList) DeepCopy() *RPCProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(RPCProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RPCProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RPCProviderSpec) DeepCopyInto(out *RPCProviderSpec) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]RPCEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(RPCAuthSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(RPCHealthCheckSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RPCRateLimitSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProviderSpec.
+func (in *RPCProviderSpec) DeepCopy() *RPCProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RPCProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RPCProviderStatus) DeepCopyInto(out *RPCProviderStatus) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]RPCEndpointStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Usage != nil {
+		in, out := &in.Usage, &out.Usage
+		*out = new(RPCUsageStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastChecked != nil {
+		in, out := &in.LastChecked, &out.LastChecked
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretVersions != nil {
+		in, out := &in.SecretVersions, &out.SecretVersions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategyList.
-func (in *GasStrategyList) DeepCopy() *GasStrategyList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProviderStatus.
+func (in *RPCProviderStatus) DeepCopy() *RPCProviderStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GasStrategyList)
+	out := new(RPCProviderStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GasStrategyList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RPCRateLimitSpec) DeepCopyInto(out *RPCRateLimitSpec) {
+	*out = *in
+	if in.RequestsPerSecond != nil {
+		in, out := &in.RequestsPerSecond, &out.RequestsPerSecond
+		*out = new(int32)
+		**out = **in
 	}
-	return nil
+	if in.Burst != nil {
+		in, out := &in.Burst, &out.Burst
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DailyRequests != nil {
+		in, out := &in.DailyRequests, &out.DailyRequests
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCRateLimitSpec.
+func (in *RPCRateLimitSpec) DeepCopy() *RPCRateLimitSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RPCRateLimitSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GasStrategySpec) DeepCopyInto(out *GasStrategySpec) {
+func (in *RPCTLSSpec) DeepCopyInto(out *RPCTLSSpec) {
 	*out = *in
-	if in.SecretRef != nil {
-		in, out := &in.SecretRef, &out.SecretRef
-		*out = new(v1.SecretReference)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategySpec.
-func (in *GasStrategySpec) DeepCopy() *GasStrategySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCTLSSpec.
+func (in *RPCTLSSpec) DeepCopy() *RPCTLSSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GasStrategySpec)
+	out := new(RPCTLSSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GasStrategyStatus) DeepCopyInto(out *GasStrategyStatus) {
+func (in *RPCUsageStatus) DeepCopyInto(out *RPCUsageStatus) {
 	*out = *in
+	if in.Workloads != nil {
+		in, out := &in.Workloads, &out.Workloads
+		*out = make([]RPCWorkloadUsage, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GasStrategyStatus.
-func (in *GasStrategyStatus) DeepCopy() *GasStrategyStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCUsageStatus.
+func (in *RPCUsageStatus) DeepCopy() *RPCUsageStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GasStrategyStatus)
+	out := new(RPCUsageStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ImportFromSpec) DeepCopyInto(out *ImportFromSpec) {
+func (in *RPCWorkloadUsage) DeepCopyInto(out *RPCWorkloadUsage) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportFromSpec.
-func (in *ImportFromSpec) DeepCopy() *ImportFromSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCWorkloadUsage.
+func (in *RPCWorkloadUsage) DeepCopy() *RPCWorkloadUsage {
 	if in == nil {
 		return nil
 	}
-	out := new(ImportFromSpec)
+	out := new(RPCWorkloadUsage)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Network) DeepCopyInto(out *Network) {
+func (in *ReferenceGrant) DeepCopyInto(out *ReferenceGrant) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
-func (in *Network) DeepCopy() *Network {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrant.
+func (in *ReferenceGrant) DeepCopy() *ReferenceGrant {
 	if in == nil {
 		return nil
 	}
-	out := new(Network)
+	out := new(ReferenceGrant)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Network) DeepCopyObject() runtime.Object {
+func (in *ReferenceGrant) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -800,31 +2139,46 @@ func (in *Network) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkList) DeepCopyInto(out *NetworkList) {
+func (in *ReferenceGrantFrom) DeepCopyInto(out *ReferenceGrantFrom) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantFrom.
+func (in *ReferenceGrantFrom) DeepCopy() *ReferenceGrantFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantList) DeepCopyInto(out *ReferenceGrantList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Network, len(*in))
+		*out = make([]ReferenceGrant, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkList.
-func (in *NetworkList) DeepCopy() *NetworkList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantList.
+func (in *ReferenceGrantList) DeepCopy() *ReferenceGrantList {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkList)
+	out := new(ReferenceGrantList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *NetworkList) DeepCopyObject() runtime.Object {
+func (in *ReferenceGrantList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -832,236 +2186,292 @@ func (in *NetworkList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
+func (in *ReferenceGrantSpec) DeepCopyInto(out *ReferenceGrantSpec) {
 	*out = *in
-	out.RPCProviderRef = in.RPCProviderRef
-	if in.BlockExplorerRef != nil {
-		in, out := &in.BlockExplorerRef, &out.BlockExplorerRef
-		*out = new(v1.LocalObjectReference)
-		**out = **in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]ReferenceGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]ReferenceGrantTo, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
-func (in *NetworkSpec) DeepCopy() *NetworkSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantSpec.
+func (in *ReferenceGrantSpec) DeepCopy() *ReferenceGrantSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkSpec)
+	out := new(ReferenceGrantSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkStatus) DeepCopyInto(out *NetworkStatus) {
+func (in *ReferenceGrantTo) DeepCopyInto(out *ReferenceGrantTo) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkStatus.
-func (in *NetworkStatus) DeepCopy() *NetworkStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantTo.
+func (in *ReferenceGrantTo) DeepCopy() *ReferenceGrantTo {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkStatus)
+	out := new(ReferenceGrantTo)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyAdmin) DeepCopyInto(out *ProxyAdmin) {
+func (in *RetiredKey) DeepCopyInto(out *RetiredKey) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	in.Status.DeepCopyInto(&out.Status)
+	in.RetiredAt.DeepCopyInto(&out.RetiredAt)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdmin.
-func (in *ProxyAdmin) DeepCopy() *ProxyAdmin {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetiredKey.
+func (in *RetiredKey) DeepCopy() *RetiredKey {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyAdmin)
+	out := new(RetiredKey)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ProxyAdmin) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RotationSpec) DeepCopyInto(out *RotationSpec) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SweepFunds != nil {
+		in, out := &in.SweepFunds, &out.SweepFunds
+		*out = new(bool)
+		**out = **in
 	}
-	return nil
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RotationSpec.
+func (in *RotationSpec) DeepCopy() *RotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RotationSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyAdminList) DeepCopyInto(out *ProxyAdminList) {
+func (in *RotationStatus) DeepCopyInto(out *RotationStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]ProxyAdmin, len(*in))
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]RotationStep, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdminList.
-func (in *ProxyAdminList) DeepCopy() *ProxyAdminList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RotationStatus.
+func (in *RotationStatus) DeepCopy() *RotationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyAdminList)
+	out := new(RotationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ProxyAdminList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyAdminSpec) DeepCopyInto(out *ProxyAdminSpec) {
+func (in *RotationStep) DeepCopyInto(out *RotationStep) {
 	*out = *in
+	if in.Nonce != nil {
+		in, out := &in.Nonce, &out.Nonce
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.RetryTime != nil {
+		in, out := &in.RetryTime, &out.RetryTime
+		*out = new(metav1.Time)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdminSpec.
-func (in *ProxyAdminSpec) DeepCopy() *ProxyAdminSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RotationStep.
+func (in *RotationStep) DeepCopy() *RotationStep {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyAdminSpec)
+	out := new(RotationStep)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyAdminStatus) DeepCopyInto(out *ProxyAdminStatus) {
+func (in *SafeSpec) DeepCopyInto(out *SafeSpec) {
 	*out = *in
-	if in.ContractProxyRefs != nil {
-		in, out := &in.ContractProxyRefs, &out.ContractProxyRefs
-		*out = make([]v1.LocalObjectReference, len(*in))
+	if in.Delegates != nil {
+		in, out := &in.Delegates, &out.Delegates
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.TransactionService != nil {
+		in, out := &in.TransactionService, &out.TransactionService
+		*out = new(SafeTransactionServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyAdminStatus.
-func (in *ProxyAdminStatus) DeepCopy() *ProxyAdminStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SafeSpec.
+func (in *SafeSpec) DeepCopy() *SafeSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyAdminStatus)
+	out := new(SafeSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RPCProvider) DeepCopyInto(out *RPCProvider) {
+func (in *SafeStatus) DeepCopyInto(out *SafeStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	if in.Owners != nil {
+		in, out := &in.Owners, &out.Owners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProvider.
-func (in *RPCProvider) DeepCopy() *RPCProvider {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SafeStatus.
+func (in *SafeStatus) DeepCopy() *SafeStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(RPCProvider)
+	out := new(SafeStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RPCProvider) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SafeTransactionServiceSpec) DeepCopyInto(out *SafeTransactionServiceSpec) {
+	*out = *in
+	if in.APIKeySecretRef != nil {
+		in, out := &in.APIKeySecretRef, &out.APIKeySecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
 	}
-	return nil
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SafeTransactionServiceSpec.
+func (in *SafeTransactionServiceSpec) DeepCopy() *SafeTransactionServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SafeTransactionServiceSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RPCProviderList) DeepCopyInto(out *RPCProviderList) {
+func (in *SecretKeyReference) DeepCopyInto(out *SecretKeyReference) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]RPCProvider, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProviderList.
-func (in *RPCProviderList) DeepCopy() *RPCProviderList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyReference.
+func (in *SecretKeyReference) DeepCopy() *SecretKeyReference {
 	if in == nil {
 		return nil
 	}
-	out := new(RPCProviderList)
+	out := new(SecretKeyReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RPCProviderList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeySelector) DeepCopyInto(out *SecretKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeySelector.
+func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(SecretKeySelector)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RPCProviderSpec) DeepCopyInto(out *RPCProviderSpec) {
+func (in *SmartAccountFactorySpec) DeepCopyInto(out *SmartAccountFactorySpec) {
 	*out = *in
-	out.SecretRef = in.SecretRef
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProviderSpec.
-func (in *RPCProviderSpec) DeepCopy() *RPCProviderSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmartAccountFactorySpec.
+func (in *SmartAccountFactorySpec) DeepCopy() *SmartAccountFactorySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(RPCProviderSpec)
+	out := new(SmartAccountFactorySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RPCProviderStatus) DeepCopyInto(out *RPCProviderStatus) {
+func (in *SmartAccountSpec) DeepCopyInto(out *SmartAccountSpec) {
 	*out = *in
+	if in.Factory != nil {
+		in, out := &in.Factory, &out.Factory
+		*out = new(SmartAccountFactorySpec)
+		**out = **in
+	}
+	if in.Paymaster != nil {
+		in, out := &in.Paymaster, &out.Paymaster
+		*out = new(PaymasterSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RPCProviderStatus.
-func (in *RPCProviderStatus) DeepCopy() *RPCProviderStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmartAccountSpec.
+func (in *SmartAccountSpec) DeepCopy() *SmartAccountSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(RPCProviderStatus)
+	out := new(SmartAccountSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SecretKeyReference) DeepCopyInto(out *SecretKeyReference) {
+func (in *SmartAccountStatus) DeepCopyInto(out *SmartAccountStatus) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyReference.
-func (in *SecretKeyReference) DeepCopy() *SecretKeyReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmartAccountStatus.
+func (in *SmartAccountStatus) DeepCopy() *SmartAccountStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(SecretKeyReference)
+	out := new(SmartAccountStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1072,7 +2482,7 @@ func (in *Wallet) DeepCopyInto(out *Wallet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Wallet.
@@ -1133,6 +2543,31 @@ func (in *WalletSpec) DeepCopyInto(out *WalletSpec) {
 		*out = new(ImportFromSpec)
 		**out = **in
 	}
+	if in.Keystore != nil {
+		in, out := &in.Keystore, &out.Keystore
+		*out = new(KeystoreSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Funding != nil {
+		in, out := &in.Funding, &out.Funding
+		*out = new(FundingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(RotationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Safe != nil {
+		in, out := &in.Safe, &out.Safe
+		*out = new(SafeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SmartAccount != nil {
+		in, out := &in.SmartAccount, &out.SmartAccount
+		*out = new(SmartAccountSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WalletSpec.
@@ -1148,6 +2583,42 @@ func (in *WalletSpec) DeepCopy() *WalletSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WalletStatus) DeepCopyInto(out *WalletStatus) {
 	*out = *in
+	if in.Funding != nil {
+		in, out := &in.Funding, &out.Funding
+		*out = make([]FundingNetworkStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Transfers != nil {
+		in, out := &in.Transfers, &out.Transfers
+		*out = make([]FundingTransfer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(RotationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetiredKeys != nil {
+		in, out := &in.RetiredKeys, &out.RetiredKeys
+		*out = make([]RetiredKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Safe != nil {
+		in, out := &in.Safe, &out.Safe
+		*out = new(SafeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SmartAccount != nil {
+		in, out := &in.SmartAccount, &out.SmartAccount
+		*out = new(SmartAccountStatus)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WalletStatus.
@@ -1159,3 +2630,24 @@ func (in *WalletStatus) DeepCopy() *WalletStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WalletTransactionStatus) DeepCopyInto(out *WalletTransactionStatus) {
+	*out = *in
+	if in.SafeNonce != nil {
+		in, out := &in.SafeNonce, &out.SafeNonce
+		*out = new(int64)
+		**out = **in
+	}
+	in.SubmittedAt.DeepCopyInto(&out.SubmittedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WalletTransactionStatus.
+func (in *WalletTransactionStatus) DeepCopy() *WalletTransactionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WalletTransactionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -17,9 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -58,6 +60,9 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var healthCheckWorkers int
+	var clusterResourceNamespace string
+	var deployGatewayAddr string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -69,6 +74,15 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.IntVar(&healthCheckWorkers, "health-check-workers", 4,
+		"The number of RPCProviders and BlockExplorers whose health is checked at the same time.")
+	flag.StringVar(&clusterResourceNamespace, "cluster-resource-namespace", "",
+		"The namespace of the Networks, RPCProviders, BlockExplorers and Secrets that serve ClusterNetworks and ClusterRPCProviders. "+
+			"Defaults to the namespace the manager runs in.")
+	flag.StringVar(&deployGatewayAddr, "deploy-gateway-bind-address", controller.DefaultDeployGatewayBindAddress,
+		"The address the deploy gateway binds to. Deployment Jobs reach the Networks and Wallets of other namespaces through it.")
+	flag.StringVar(&controller.DeployGatewayURL, "deploy-gateway-url", "",
+		"The URL deployment Jobs reach the deploy gateway at. Wallets of other namespaces cannot deploy without it.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -77,6 +91,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if clusterResourceNamespace == "" {
+		clusterResourceNamespace = managerNamespace()
+	}
+	controller.ClusterResourceNamespace = clusterResourceNamespace
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -144,16 +163,34 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = controller.SetupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up field indexes")
+		os.Exit(1)
+	}
+
+	// New-block subscriptions over WebSocket endpoints, shared by the controllers that track transactions
+	subscriptions := controller.NewSubscriptionManager(mgr.GetClient())
+	if err = mgr.Add(subscriptions); err != nil {
+		setupLog.Error(err, "unable to add WebSocket subscription manager")
+		os.Exit(1)
+	}
+	// JSON-RPC gateway that signs the transactions of deployment Jobs with Wallets of other namespaces
+	if err = mgr.Add(&controller.DeployGateway{Client: mgr.GetClient(), BindAddress: deployGatewayAddr}); err != nil {
+		setupLog.Error(err, "unable to add deploy gateway")
+		os.Exit(1)
+	}
 	if err = (&controller.RPCProviderReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		HealthCheckWorkers: healthCheckWorkers,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "RPCProvider")
 		os.Exit(1)
 	}
 	if err = (&controller.BlockExplorerReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		HealthCheckWorkers: healthCheckWorkers,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "BlockExplorer")
 		os.Exit(1)
@@ -180,8 +217,9 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&controller.ContractProxyReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Subscriptions: subscriptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ContractProxy")
 		os.Exit(1)
@@ -194,8 +232,9 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&controller.ActionReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Subscriptions: subscriptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Action")
 		os.Exit(1)
@@ -221,6 +260,20 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "ContractVersion")
 		os.Exit(1)
 	}
+	if err = (&controller.ClusterNetworkReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterNetwork")
+		os.Exit(1)
+	}
+	if err = (&controller.ClusterRPCProviderReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterRPCProvider")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -238,3 +291,12 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// managerNamespace returns the namespace the manager runs in, from its service account
+func managerNamespace() string {
+	namespace, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil || strings.TrimSpace(string(namespace)) == "" {
+		return controller.DefaultClusterResourceNamespace
+	}
+	return strings.TrimSpace(string(namespace))
+}
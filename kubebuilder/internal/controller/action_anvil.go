@@ -0,0 +1,232 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// Cheat code ActionTypes, named after the Anvil RPC method they call
+const (
+	actionTypeAnvilMine         = "anvil_mine"
+	actionTypeIncreaseTime      = "evm_increaseTime"
+	actionTypeAnvilSetBalance   = "anvil_setBalance"
+	actionTypeAnvilImpersonate  = "anvil_impersonateAccount"
+	actionTypeEVMSnapshot       = "evm_snapshot"
+	actionTypeEVMRevert         = "evm_revert"
+	actionTypeAnvilSetStorageAt = "anvil_setStorageAt"
+)
+
+// defaultAnvilMineBlocks is the number of blocks anvil_mine mines when the Action sets none
+const defaultAnvilMineBlocks int64 = 1
+
+// isAnvilCheatCode reports whether an ActionType is a cheat code of anvil Networks
+func isAnvilCheatCode(actionType string) bool {
+	switch actionType {
+	case actionTypeAnvilMine, actionTypeIncreaseTime, actionTypeAnvilSetBalance, actionTypeAnvilImpersonate,
+		actionTypeEVMSnapshot, actionTypeEVMRevert, actionTypeAnvilSetStorageAt:
+		return true
+	}
+	return false
+}
+
+// executeAnvilCheatCode calls the cheat code of the Action on its anvil Network and records the result
+// Invalid arguments fail the Action; RPC errors are returned so the call is retried
+func (r *ActionReconciler) executeAnvilCheatCode(ctx context.Context, action *kontractdeployerv1alpha1.Action) error {
+	transaction := action.Status.Transaction
+
+	network, err := getNetwork(ctx, r.Client, action.Namespace, action.Spec.NetworkRef)
+	if err != nil {
+		return fmt.Errorf("failed to get Network %s: %w", action.Spec.NetworkRef, err)
+	}
+	if network.Spec.NetworkName != anvilNetworkName {
+		failWalletTransaction(transaction, fmt.Sprintf("%s is only available on anvil Networks", action.Spec.ActionType))
+		return nil
+	}
+
+	// The snapshot of another Action may not be taken yet, so resolving it is retried
+	var snapshotID string
+	if action.Spec.ActionType == actionTypeEVMRevert {
+		if snapshotID, err = r.snapshotID(ctx, action); err != nil {
+			return err
+		}
+	}
+	params, err := anvilCheatCodeParams(action, snapshotID)
+	if err != nil {
+		failWalletTransaction(transaction, err.Error())
+		return nil
+	}
+
+	endpoints, err := networkEndpoints(ctx, r.Client, network)
+	if err != nil {
+		return err
+	}
+	var result json.RawMessage
+	if err := jsonRPCCall(ctx, anvilHTTPClient, endpoints[0].conn, action.Spec.ActionType, params, &result); err != nil {
+		return err
+	}
+
+	// Methods that report success as a boolean fail the Action when they return false
+	if string(result) == "false" {
+		failWalletTransaction(transaction, fmt.Sprintf("%s returned false", action.Spec.ActionType))
+		return nil
+	}
+	action.Status.Output = anvilCheatCodeOutput(result)
+	transaction.State = txStateSucceeded
+	transaction.Message = fmt.Sprintf("%s executed", action.Spec.ActionType)
+	return nil
+}
+
+// anvilCheatCodeParams builds the RPC parameters of a cheat code from the Action spec
+func anvilCheatCodeParams(action *kontractdeployerv1alpha1.Action, snapshotID string) ([]interface{}, error) {
+	spec := action.Spec.Anvil
+	if spec == nil {
+		spec = &kontractdeployerv1alpha1.AnvilActionSpec{}
+	}
+
+	switch action.Spec.ActionType {
+	case actionTypeAnvilMine:
+		blocks := defaultAnvilMineBlocks
+		if spec.Blocks != nil {
+			blocks = *spec.Blocks
+		}
+		return []interface{}{hexutil.Uint64(blocks)}, nil
+
+	case actionTypeIncreaseTime:
+		if spec.Seconds == nil {
+			return nil, fmt.Errorf("evm_increaseTime requires anvil.seconds")
+		}
+		return []interface{}{*spec.Seconds}, nil
+
+	case actionTypeAnvilSetBalance:
+		address, err := cheatCodeAddress(spec)
+		if err != nil {
+			return nil, err
+		}
+		balance, ok := math.ParseBig256(spec.Balance)
+		if spec.Balance == "" || !ok {
+			return nil, fmt.Errorf("anvil_setBalance requires anvil.balance in wei, got %q", spec.Balance)
+		}
+		return []interface{}{address, (*hexutil.Big)(balance)}, nil
+
+	case actionTypeAnvilImpersonate:
+		address, err := cheatCodeAddress(spec)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{address}, nil
+
+	case actionTypeEVMSnapshot:
+		return nil, nil
+
+	case actionTypeEVMRevert:
+		if snapshotID == "" {
+			return nil, fmt.Errorf("evm_revert requires anvil.snapshotID or anvil.snapshotActionRef")
+		}
+		return []interface{}{snapshotID}, nil
+
+	case actionTypeAnvilSetStorageAt:
+		address, err := cheatCodeAddress(spec)
+		if err != nil {
+			return nil, err
+		}
+		slot, err := storageWord(spec.Slot)
+		if err != nil {
+			return nil, fmt.Errorf("invalid anvil.slot: %w", err)
+		}
+		value, err := storageWord(spec.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid anvil.value: %w", err)
+		}
+		return []interface{}{address, slot, value}, nil
+	}
+	return nil, fmt.Errorf("unsupported cheat code %q", action.Spec.ActionType)
+}
+
+// snapshotID returns the EVM snapshot an evm_revert Action returns to, given directly or by an evm_snapshot Action
+// It returns an error while the referenced Action has not taken its snapshot
+func (r *ActionReconciler) snapshotID(ctx context.Context, action *kontractdeployerv1alpha1.Action) (string, error) {
+	spec := action.Spec.Anvil
+	switch {
+	case spec == nil:
+		return "", nil
+	case spec.SnapshotID != "" || spec.SnapshotActionRef == "":
+		return spec.SnapshotID, nil
+	}
+
+	snapshot := &kontractdeployerv1alpha1.Action{}
+	if err := r.Get(ctx, client.ObjectKey{Name: spec.SnapshotActionRef, Namespace: action.Namespace}, snapshot); err != nil {
+		return "", fmt.Errorf("failed to get snapshot Action %s: %w", spec.SnapshotActionRef, err)
+	}
+	if snapshot.Spec.ActionType != actionTypeEVMSnapshot || snapshot.Spec.NetworkRef != action.Spec.NetworkRef {
+		return "", fmt.Errorf("snapshot Action %s is not an evm_snapshot on Network %s", snapshot.Name, action.Spec.NetworkRef)
+	}
+	if snapshot.Status.Output == "" {
+		return "", fmt.Errorf("snapshot Action %s has not taken its snapshot yet", snapshot.Name)
+	}
+	return snapshot.Status.Output, nil
+}
+
+// cheatCodeAddress returns the account a cheat code applies to
+func cheatCodeAddress(spec *kontractdeployerv1alpha1.AnvilActionSpec) (common.Address, error) {
+	if !common.IsHexAddress(spec.Address) {
+		return common.Address{}, fmt.Errorf("invalid anvil.address %q", spec.Address)
+	}
+	return common.HexToAddress(spec.Address), nil
+}
+
+// storageWord parses a storage slot or value given as a number or as hex of up to 32 bytes
+func storageWord(value string) (common.Hash, error) {
+	if value == "" {
+		return common.Hash{}, fmt.Errorf("a value is required")
+	}
+	if strings.HasPrefix(value, "0x") && len(value) > 2+64 {
+		return common.Hash{}, fmt.Errorf("%q is longer than 32 bytes", value)
+	}
+	word, ok := math.ParseBig256(value)
+	if !ok {
+		return common.Hash{}, fmt.Errorf("%q is not a 32-byte number", value)
+	}
+	return common.BigToHash(word), nil
+}
+
+// anvilCheatCodeOutput renders the result of a cheat code for the Action status
+func anvilCheatCodeOutput(result json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(result, &text); err == nil {
+		return text
+	}
+	var number big.Int
+	if err := json.Unmarshal(result, &number); err == nil {
+		return number.String()
+	}
+	if string(result) == "null" || string(result) == "true" {
+		return ""
+	}
+	return string(result)
+}
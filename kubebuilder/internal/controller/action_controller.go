@@ -18,45 +18,195 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
 )
 
+const (
+	// actionTypeInvoke is the ActionType that sends a contract function call
+	actionTypeInvoke = "invoke"
+
+	// actionResultInvalid is the Result of an Action whose spec cannot be executed
+	actionResultInvalid = "Invalid"
+)
+
 // ActionReconciler reconciles a Action object
 type ActionReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+
+	// Subscriptions wakes up Actions with pending transactions when a block arrives; nil means polling only
+	Subscriptions *SubscriptionManager
 }
 
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=actions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=actions/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=actions/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contracts;contractversions;wallets;networks;rpcproviders;clusternetworks;clusterrpcproviders,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch;update
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Action object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.0/pkg/reconcile
+// Reconcile executes the Action once per generation and tracks its transaction until it is final
 func (r *ActionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	ctx = withRPCWorkload(ctx, "Action")
+	logger := log.FromContext(ctx)
+
+	// Fetch the Action instance
+	action := &kontractdeployerv1alpha1.Action{}
+	if err := r.Get(ctx, req.NamespacedName, action); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// An Action of an unknown type cannot run; it is reported as invalid until its spec is fixed
+	if err := validateActionType(action.Spec.ActionType); err != nil {
+		return ctrl.Result{}, r.reportInvalidAction(ctx, action, err)
+	}
+
+	// Start a new execution for every new generation of the Action
+	if action.Status.ObservedGeneration != action.Generation || action.Status.Transaction == nil {
+		action.Status.ObservedGeneration = action.Generation
+		action.Status.Transaction = &kontractdeployerv1alpha1.WalletTransactionStatus{}
+		action.Status.TransactionHash = ""
+		action.Status.Result = ""
+		action.Status.Output = ""
+	} else if walletTransactionDone(action.Status.Transaction) {
+		return ctrl.Result{}, nil
+	}
+	transaction := action.Status.Transaction
+	previousState := transaction.State
+
+	if err := r.executeAction(ctx, action); err != nil {
+		logger.Error(err, "Failed to execute Action", "ActionType", action.Spec.ActionType)
+		return ctrl.Result{}, err
+	}
+
+	action.Status.TransactionHash = transaction.TransactionHash
+	switch transaction.State {
+	case txStateSucceeded:
+		action.Status.Result = "Success"
+		action.Status.LastExecution = metav1.Now()
+		message := fmt.Sprintf("Action executed in transaction %s", transaction.TransactionHash)
+		if transaction.TransactionHash == "" {
+			message = transaction.Message
+		}
+		r.EventRecorder.Event(action, corev1.EventTypeNormal, "ActionSucceeded", message)
+	case txStateFailed:
+		action.Status.Result = "Failure"
+		action.Status.LastExecution = metav1.Now()
+		r.EventRecorder.Event(action, corev1.EventTypeWarning, "ActionFailed", transaction.Message)
+	default:
+		action.Status.Result = transaction.State
+		if transaction.State == txStateAwaitingSignatures && previousState != txStateAwaitingSignatures {
+			r.EventRecorder.Event(action, corev1.EventTypeNormal, "ActionProposed", fmt.Sprintf("Safe transaction %s proposed, %s", transaction.SafeTxHash, transaction.Message))
+		}
+	}
+
+	if err := r.Status().Update(ctx, action); err != nil {
+		logger.Error(err, "Failed to update Action status")
+		return ctrl.Result{}, err
+	}
+
+	if walletTransactionDone(transaction) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: r.Subscriptions.pendingTransactionInterval(action.Namespace, action.Spec.NetworkRef, transaction)}, nil
+}
 
-	// TODO(user): your logic here
+// validateActionType checks that the Action has a type the controller can execute
+func validateActionType(actionType string) error {
+	if actionType == "" {
+		return fmt.Errorf("actionType is not set")
+	}
+	if actionType != actionTypeInvoke && !isAnvilCheatCode(actionType) {
+		return fmt.Errorf("unsupported action type %q", actionType)
+	}
+	return nil
+}
+
+// reportInvalidAction marks the current generation of an Action as invalid, once per generation
+func (r *ActionReconciler) reportInvalidAction(ctx context.Context, action *kontractdeployerv1alpha1.Action, err error) error {
+	if action.Status.ObservedGeneration == action.Generation && action.Status.Result == actionResultInvalid {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Action is invalid", "Action.Name", action.Name, "error", err.Error())
+	r.EventRecorder.Event(action, corev1.EventTypeWarning, "InvalidAction", err.Error())
+	action.Status.ObservedGeneration = action.Generation
+	action.Status.Result = actionResultInvalid
+	action.Status.Transaction = nil
+	action.Status.TransactionHash = ""
+	action.Status.Output = ""
+	return r.Status().Update(ctx, action)
+}
+
+// executeAction starts or advances the transaction of the Action
+func (r *ActionReconciler) executeAction(ctx context.Context, action *kontractdeployerv1alpha1.Action) error {
+	transaction := action.Status.Transaction
+
+	if isAnvilCheatCode(action.Spec.ActionType) {
+		return r.executeAnvilCheatCode(ctx, action)
+	}
 
-	return ctrl.Result{}, nil
+	wallet := &kontractdeployerv1alpha1.Wallet{}
+	if err := r.Get(ctx, client.ObjectKey{Name: action.Spec.WalletRef, Namespace: action.Namespace}, wallet); err != nil {
+		return fmt.Errorf("failed to get Wallet %s: %w", action.Spec.WalletRef, err)
+	}
+
+	to, err := contractAddress(ctx, r.Client, action.Namespace, action.Spec.ContractRef, action.Spec.NetworkRef)
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeFunctionCall(action.Spec.FunctionName, action.Spec.Parameters)
+	if err != nil {
+		failWalletTransaction(transaction, err.Error())
+		return nil
+	}
+
+	return submitWalletCall(ctx, r.Client, wallet, action.Spec.NetworkRef, walletCall{to: to, data: data}, transaction)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ActionReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.EventRecorder = mgr.GetEventRecorderFor("action-controller")
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.Action{}).
-		Complete(r)
+		Watches(&kontractdeployerv1alpha1.Wallet{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.ActionList{}, walletRefField),
+			builder.WithPredicates(walletKeyPredicate()))
+	if r.Subscriptions != nil {
+		controllerBuilder = controllerBuilder.WatchesRawSource(source.Channel(r.Subscriptions.Heads(), handler.EnqueueRequestsFromMapFunc(r.pendingActionsOnNetwork)))
+	}
+	return controllerBuilder.Complete(r)
+}
+
+// pendingActionsOnNetwork maps a new block on a Network to the Actions waiting for a transaction on it
+func (r *ActionReconciler) pendingActionsOnNetwork(ctx context.Context, network client.Object) []reconcile.Request {
+	actions, err := listReferencing(ctx, r.Client, &kontractdeployerv1alpha1.ActionList{}, networkRefField, network)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Actions")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, object := range actions {
+		action := object.(*kontractdeployerv1alpha1.Action)
+		if action.Status.Transaction != nil && action.Status.Transaction.State == txStatePending {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(action)})
+		}
+	}
+	return requests
 }
@@ -18,11 +18,24 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -69,8 +82,9 @@ var _ = Describe("Action Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &ActionReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+				Client:        k8sClient,
+				Scheme:        k8sClient.Scheme(),
+				EventRecorder: record.NewFakeRecorder(10),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -82,3 +96,219 @@ var _ = Describe("Action Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Action call data", func() {
+	It("encodes a function call from its signature and parameters", func() {
+		data, err := encodeFunctionCall("transfer(address,uint256)", []kontractdeployerv1alpha1.ActionParameter{
+			{Name: "to", Value: "0x00000000000000000000000000000000000000aa"},
+			{Name: "amount", Value: "1000"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data[:4]).To(Equal(crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]))
+		Expect(common.BytesToAddress(data[4:36])).To(Equal(common.HexToAddress("0x00000000000000000000000000000000000000aa")))
+		Expect(new(big.Int).SetBytes(data[36:68]).Int64()).To(Equal(int64(1000)))
+	})
+
+	It("encodes small integers, booleans and fixed bytes", func() {
+		data, err := encodeFunctionCall("configure(uint8, bool, bytes32)", []kontractdeployerv1alpha1.ActionParameter{
+			{Name: "decimals", Value: "18"},
+			{Name: "enabled", Value: "true"},
+			{Name: "role", Value: crypto.Keccak256Hash([]byte("MINTER_ROLE")).Hex()},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data[:4]).To(Equal(crypto.Keccak256([]byte("configure(uint8,bool,bytes32)"))[:4]))
+		Expect(data[35]).To(Equal(byte(18)))
+		Expect(data[67]).To(Equal(byte(1)))
+		Expect(common.BytesToHash(data[68:100])).To(Equal(crypto.Keccak256Hash([]byte("MINTER_ROLE"))))
+	})
+
+	It("accepts a plain function name without parameters", func() {
+		data, err := encodeFunctionCall("pause", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(crypto.Keccak256([]byte("pause()"))[:4]))
+	})
+
+	It("rejects mismatched and invalid parameters", func() {
+		_, err := encodeFunctionCall("transfer(address,uint256)", []kontractdeployerv1alpha1.ActionParameter{{Name: "to", Value: "0x00000000000000000000000000000000000000aa"}})
+		Expect(err).To(HaveOccurred())
+
+		_, err = encodeFunctionCall("setFee(uint8)", []kontractdeployerv1alpha1.ActionParameter{{Name: "fee", Value: "256"}})
+		Expect(err).To(HaveOccurred())
+
+		_, err = encodeFunctionCall("setOwner(address)", []kontractdeployerv1alpha1.ActionParameter{{Name: "owner", Value: "alice"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Action validation", func() {
+	ctx := context.Background()
+
+	It("reports an Action without a supported type as invalid once per generation", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		action := &kontractdeployerv1alpha1.Action{ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default", Generation: 1}}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(action).
+			WithStatusSubresource(&kontractdeployerv1alpha1.Action{}).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &ActionReconciler{Client: c, Scheme: scheme, EventRecorder: recorder}
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "empty", Namespace: "default"}}
+
+		result, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(recorder.Events).To(Receive(ContainSubstring("actionType is not set")))
+
+		Expect(c.Get(ctx, request.NamespacedName, action)).To(Succeed())
+		Expect(action.Status.Result).To(Equal(actionResultInvalid))
+		Expect(action.Status.Transaction).To(BeNil())
+
+		_, err = r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(BeEmpty())
+
+		action.Spec.ActionType = "transfer"
+		action.Generation = 2
+		Expect(c.Update(ctx, action)).To(Succeed())
+		_, err = r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(`unsupported action type "transfer"`)))
+	})
+})
+
+var _ = Describe("Anvil cheat codes", func() {
+	ctx := context.Background()
+
+	type rpcRequest struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	var requests []rpcRequest
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var request rpcRequest
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			requests = append(requests, request)
+			results := map[string]string{
+				"evm_snapshot":       `"0x1"`,
+				"evm_revert":         `true`,
+				"evm_increaseTime":   `3600`,
+				"anvil_mine":         `null`,
+				"anvil_setBalance":   `null`,
+				"anvil_setStorageAt": `true`,
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + results[request.Method] + `}`))
+		}))
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newReconciler := func(objs ...client.Object) *ActionReconciler {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		objs = append(objs,
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+				Spec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: anvilNetworkName, ChainID: 31337},
+			},
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "default"},
+				Spec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: "Sepolia", ChainID: 11155111},
+			},
+			&kontractdeployerv1alpha1.RPCProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+				Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+					ProviderName: "Anvil",
+					SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "local-rpc-secret", URLKey: "url", TokenKey: "token"},
+					Auth:         &kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthBearer},
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "local-rpc-secret", Namespace: "default"},
+				Data:       map[string][]byte{"url": []byte(server.URL), "token": []byte("unused")},
+			},
+		)
+		return &ActionReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+				WithStatusSubresource(&kontractdeployerv1alpha1.Action{}).Build(),
+			Scheme:        scheme,
+			EventRecorder: record.NewFakeRecorder(10),
+		}
+	}
+	newAction := func(name, actionType, network string, anvil *kontractdeployerv1alpha1.AnvilActionSpec) *kontractdeployerv1alpha1.Action {
+		return &kontractdeployerv1alpha1.Action{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       kontractdeployerv1alpha1.ActionSpec{ActionType: actionType, NetworkRef: network, Anvil: anvil},
+		}
+	}
+	run := func(r *ActionReconciler, action *kontractdeployerv1alpha1.Action) (*kontractdeployerv1alpha1.Action, error) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(action)})
+		result := &kontractdeployerv1alpha1.Action{}
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(action), result)).To(Succeed())
+		return result, err
+	}
+
+	It("takes an EVM snapshot and reverts to it by Action reference", func() {
+		snapshot := newAction("snapshot", actionTypeEVMSnapshot, "local", nil)
+		revert := newAction("revert", actionTypeEVMRevert, "local", &kontractdeployerv1alpha1.AnvilActionSpec{SnapshotActionRef: "snapshot"})
+		r := newReconciler(snapshot, revert)
+
+		// The revert waits until the snapshot is taken
+		_, err := run(r, revert)
+		Expect(err).To(MatchError(ContainSubstring("has not taken its snapshot")))
+
+		result, err := run(r, snapshot)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Status.Result).To(Equal("Success"))
+		Expect(result.Status.Output).To(Equal("0x1"))
+
+		result, err = run(r, revert)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Status.Result).To(Equal("Success"))
+		Expect(string(requests[len(requests)-1].Params[0])).To(Equal(`"0x1"`))
+	})
+
+	It("sends the arguments of each cheat code and records the result", func() {
+		account := "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+		mine := newAction("mine", actionTypeAnvilMine, "local", &kontractdeployerv1alpha1.AnvilActionSpec{Blocks: ptr.To(int64(12))})
+		warp := newAction("warp", actionTypeIncreaseTime, "local", &kontractdeployerv1alpha1.AnvilActionSpec{Seconds: ptr.To(int64(3600))})
+		fund := newAction("fund", actionTypeAnvilSetBalance, "local", &kontractdeployerv1alpha1.AnvilActionSpec{Address: account, Balance: "1000000000000000000"})
+		store := newAction("store", actionTypeAnvilSetStorageAt, "local", &kontractdeployerv1alpha1.AnvilActionSpec{Address: account, Slot: "0", Value: "0x2a"})
+		r := newReconciler(mine, warp, fund, store)
+
+		for _, action := range []*kontractdeployerv1alpha1.Action{mine, warp, fund, store} {
+			result, err := run(r, action)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Status.Result).To(Equal("Success"), action.Name)
+		}
+		Expect(requests).To(HaveLen(4))
+		Expect(string(requests[0].Params[0])).To(Equal(`"0xc"`))
+		Expect(string(requests[2].Params[1])).To(Equal(`"0xde0b6b3a7640000"`))
+		Expect(string(requests[3].Params[2])).To(Equal(`"0x000000000000000000000000000000000000000000000000000000000000002a"`))
+
+		result, err := run(r, warp)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Status.Output).To(Equal("3600"))
+	})
+
+	It("refuses cheat codes on other networks and invalid arguments", func() {
+		remote := newAction("remote", actionTypeAnvilMine, "sepolia", nil)
+		invalid := newAction("invalid", actionTypeAnvilImpersonate, "local", &kontractdeployerv1alpha1.AnvilActionSpec{Address: "nobody"})
+		r := newReconciler(remote, invalid)
+
+		result, err := run(r, remote)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Status.Result).To(Equal("Failure"))
+		Expect(result.Status.Transaction.Message).To(ContainSubstring("only available on anvil Networks"))
+
+		result, err = run(r, invalid)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Status.Result).To(Equal("Failure"))
+		Expect(requests).To(BeEmpty())
+	})
+})
@@ -0,0 +1,447 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// defaultAnvilImage provides the anvil binary when the Network sets no image
+	defaultAnvilImage = "docker.io/expedio/kontract-foundry:latest"
+
+	// defaultAnvilAccounts is the number of dev accounts Anvil funds by default
+	defaultAnvilAccounts = 10
+
+	// anvilPort is the RPC port of Anvil
+	anvilPort = 8545
+
+	// anvilNetworkLabel marks the resources of the Anvil workload of a Network
+	anvilNetworkLabel = "kontract.expedio.xyz/network"
+
+	// anvilForkSourceAnnotation records the Network a fork Secret was resolved from
+	anvilForkSourceAnnotation = "kontract.expedio.xyz/fork-source"
+)
+
+// anvilWorkloadName is the name of the StatefulSet and Service that run the chain of a local Network
+func anvilWorkloadName(network *kontractdeployerv1alpha1.Network) string {
+	return network.Name + "-anvil"
+}
+
+// anvilRPCSecretName is the name of the Secret with the RPC URL of a local Network
+func anvilRPCSecretName(network *kontractdeployerv1alpha1.Network) string {
+	return network.Name + "-rpc-secret"
+}
+
+// anvilEndpoint is the in-cluster RPC URL of a local Network
+func anvilEndpoint(network *kontractdeployerv1alpha1.Network) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", anvilWorkloadName(network), network.Namespace, anvilPort)
+}
+
+// anvilForkSecretName is the name of the Secret with the fork URL and headers of a local Network
+func anvilForkSecretName(network *kontractdeployerv1alpha1.Network) string {
+	return network.Name + "-fork-secret"
+}
+
+// anvilForkHeaderKey is the fork Secret key and environment variable of the i-th fork header
+func anvilForkHeaderKey(i int) string {
+	return fmt.Sprintf("FORK_HEADER_%d", i)
+}
+
+// anvilFork is the fork source of a local Network, pinned to a block
+type anvilFork struct {
+	source      string
+	blockNumber int64
+	// headers is the number of FORK_HEADER_<i> keys in the fork Secret
+	headers int
+}
+
+// anvilLabels selects the Anvil pod of a Network
+func anvilLabels(network *kontractdeployerv1alpha1.Network) map[string]string {
+	return map[string]string{"app": "anvil", anvilNetworkLabel: network.Name}
+}
+
+// anvilCommand builds the anvil command line from the Network spec
+// The fork URL and headers carry credentials and are expanded from the environment by the kubelet
+func anvilCommand(network *kontractdeployerv1alpha1.Network, fork *anvilFork) []string {
+	spec := network.Spec.Anvil
+	if spec == nil {
+		spec = &kontractdeployerv1alpha1.AnvilSpec{}
+	}
+	accounts := int32(defaultAnvilAccounts)
+	if spec.Accounts != nil {
+		accounts = *spec.Accounts
+	}
+
+	command := []string{
+		"anvil",
+		"--host", "0.0.0.0",
+		"--port", strconv.Itoa(anvilPort),
+		"--chain-id", strconv.FormatInt(networkChainID(network), 10),
+		"--accounts", strconv.Itoa(int(accounts)),
+	}
+	if spec.BlockTimeSeconds != nil {
+		command = append(command, "--block-time", strconv.FormatInt(*spec.BlockTimeSeconds, 10))
+	}
+	if spec.GasLimit != nil {
+		command = append(command, "--gas-limit", strconv.FormatInt(*spec.GasLimit, 10))
+	}
+	if spec.Mnemonic != "" {
+		command = append(command, "--mnemonic", strings.Join(strings.Fields(spec.Mnemonic), " "))
+	}
+	if spec.Balance != nil {
+		command = append(command, "--balance", strconv.FormatInt(*spec.Balance, 10))
+	}
+	if fork != nil {
+		command = append(command, "--fork-url", "$(FORK_URL)", "--fork-block-number", strconv.FormatInt(fork.blockNumber, 10))
+		for i := 0; i < fork.headers; i++ {
+			command = append(command, "--fork-header", "$("+anvilForkHeaderKey(i)+")")
+		}
+	}
+	return command
+}
+
+// anvilForkEnv passes the fork URL and headers from the fork Secret
+func anvilForkEnv(network *kontractdeployerv1alpha1.Network, fork *anvilFork) []corev1.EnvVar {
+	if fork == nil {
+		return nil
+	}
+	fromSecret := func(name string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: anvilForkSecretName(network)},
+					Key:                  name,
+				},
+			},
+		}
+	}
+	envVars := []corev1.EnvVar{fromSecret("FORK_URL")}
+	for i := 0; i < fork.headers; i++ {
+		envVars = append(envVars, fromSecret(anvilForkHeaderKey(i)))
+	}
+	return envVars
+}
+
+// anvilPodSpec builds the pod template of the Anvil StatefulSet
+func anvilPodSpec(network *kontractdeployerv1alpha1.Network, fork *anvilFork) corev1.PodSpec {
+	image := defaultAnvilImage
+	var resources corev1.ResourceRequirements
+	if network.Spec.Anvil != nil {
+		if network.Spec.Anvil.Image != "" {
+			image = network.Spec.Anvil.Image
+		}
+		if network.Spec.Anvil.Resources != nil {
+			resources = *network.Spec.Anvil.Resources
+		}
+	}
+
+	pod := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:      "anvil",
+				Image:     image,
+				Command:   anvilCommand(network, fork),
+				Env:       anvilForkEnv(network, fork),
+				Resources: resources,
+				Ports: []corev1.ContainerPort{
+					{Name: "rpc", ContainerPort: anvilPort, Protocol: corev1.ProtocolTCP},
+				},
+				// Anvil only opens the RPC port once the chain is set up, so a TCP check works with any image
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(anvilPort)},
+					},
+					PeriodSeconds:  5,
+					TimeoutSeconds: 3,
+				},
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(anvilPort)},
+					},
+					InitialDelaySeconds: 10,
+					PeriodSeconds:       10,
+				},
+			},
+		},
+	}
+	if network.Spec.Anvil != nil && network.Spec.Anvil.Persistence != nil {
+		pod.Containers[0].Command = append(pod.Containers[0].Command, anvilStateArgs(network.Spec.Anvil.Persistence)...)
+		addAnvilStateVolume(network, &pod)
+	}
+	return pod
+}
+
+// reconcileAnvil runs the chain of a local Network as a StatefulSet behind a Service,
+// with an RPCProvider and a Wallet per dev account, all named after and owned by the Network
+func (r *NetworkReconciler) reconcileAnvil(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
+	logger := log.FromContext(ctx)
+
+	if err := r.removeLegacyAnvilResources(ctx, network); err != nil {
+		return err
+	}
+
+	fork, err := r.reconcileAnvilFork(ctx, network)
+	if err != nil {
+		return err
+	}
+
+	keys, err := anvilAccountKeys(network)
+	if err != nil {
+		return err
+	}
+
+	if network.Spec.Anvil != nil && network.Spec.Anvil.Persistence != nil {
+		if err := r.reconcileAnvilStateClaim(ctx, network); err != nil {
+			return err
+		}
+	}
+
+	// A single replica that is replaced only after it stopped, so two chains never serve the same Network
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: anvilWorkloadName(network), Namespace: network.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, statefulSet, func() error {
+		labels := anvilLabels(network)
+		statefulSet.Labels = labels
+		statefulSet.Spec.Replicas = ptr.To(int32(1))
+		statefulSet.Spec.ServiceName = anvilWorkloadName(network)
+		if statefulSet.Spec.Selector == nil {
+			statefulSet.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
+		}
+		statefulSet.Spec.Template.Labels = labels
+		statefulSet.Spec.Template.Spec = anvilPodSpec(network, fork)
+		return controllerutil.SetControllerReference(network, statefulSet, r.Scheme)
+	}); err != nil {
+		logger.Error(err, "failed to reconcile Anvil StatefulSet")
+		return fmt.Errorf("failed to reconcile Anvil StatefulSet: %w", err)
+	}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: anvilWorkloadName(network), Namespace: network.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		service.Labels = anvilLabels(network)
+		service.Spec.Selector = anvilLabels(network)
+		service.Spec.Ports = []corev1.ServicePort{
+			{Name: "rpc", Port: anvilPort, TargetPort: intstr.FromInt32(anvilPort), Protocol: corev1.ProtocolTCP},
+		}
+		return controllerutil.SetControllerReference(network, service, r.Scheme)
+	}); err != nil {
+		logger.Error(err, "failed to reconcile Anvil Service")
+		return fmt.Errorf("failed to reconcile Anvil Service: %w", err)
+	}
+
+	if err := r.reconcileAnvilRPCProvider(ctx, network); err != nil {
+		return err
+	}
+	accounts, err := r.reconcileAnvilAccounts(ctx, network, keys)
+	if err != nil {
+		return err
+	}
+
+	status := &kontractdeployerv1alpha1.AnvilStatus{}
+	if network.Status.Anvil != nil {
+		status = network.Status.Anvil.DeepCopy()
+	}
+	status.StatefulSet = statefulSet.Name
+	status.Endpoint = anvilEndpoint(network)
+	status.Accounts = accounts
+	status.ForkSource, status.ForkBlockNumber = "", 0
+	if fork != nil {
+		status.ForkSource = fork.source
+		status.ForkBlockNumber = fork.blockNumber
+	}
+	network.Status.Anvil = status
+
+	ready, err := r.trackAnvilInstance(ctx, network, status)
+	if err != nil {
+		return err
+	}
+	status.Ready = ready
+
+	// Snapshots and restores talk to the chain through its Service
+	conn := rpcConnection{url: status.Endpoint}
+	if err := r.reconcileAnvilSnapshots(ctx, network, status, conn, ready); err != nil {
+		return err
+	}
+	return r.reconcileAnvilRestore(ctx, network, status, conn, ready)
+}
+
+// reconcileAnvilFork resolves the fork source of a local Network into the fork Secret and pins the fork block.
+// The source is only dialed when the fork is first set up or its source changes,
+// so a restarted pod forks the same block with the credentials it was created with
+func (r *NetworkReconciler) reconcileAnvilFork(ctx context.Context, network *kontractdeployerv1alpha1.Network) (*anvilFork, error) {
+	if network.Spec.Anvil == nil || network.Spec.Anvil.ForkFrom == "" {
+		return nil, nil
+	}
+	source := network.Spec.Anvil.ForkFrom
+	if source == network.Name {
+		return nil, fmt.Errorf("network %s cannot fork itself", network.Name)
+	}
+
+	fork := &anvilFork{source: source}
+	if previous := network.Status.Anvil; previous != nil && previous.ForkSource == source {
+		fork.blockNumber = previous.ForkBlockNumber
+	}
+	if network.Spec.Anvil.ForkBlockNumber != nil {
+		fork.blockNumber = *network.Spec.Anvil.ForkBlockNumber
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: anvilForkSecretName(network), Namespace: network.Namespace}}
+	err := r.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, fmt.Errorf("failed to get fork Secret: %w", err)
+	}
+	if err == nil && secret.Annotations[anvilForkSourceAnnotation] == source && fork.blockNumber > 0 {
+		for len(secret.Data[anvilForkHeaderKey(fork.headers)]) > 0 {
+			fork.headers++
+		}
+		return fork, nil
+	}
+
+	// Resolve the first reachable endpoint of the source Network
+	conn, head, err := r.forkSourceConnection(withRPCWorkload(ctx, "Network"), network.Namespace, source)
+	if err != nil {
+		return nil, err
+	}
+	if fork.blockNumber == 0 {
+		fork.blockNumber = int64(head)
+	}
+
+	data := map[string][]byte{"FORK_URL": []byte(conn.url)}
+	names := make([]string, 0, len(conn.header))
+	for name := range conn.header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range conn.header.Values(name) {
+			data[anvilForkHeaderKey(fork.headers)] = []byte(name + ": " + value)
+			fork.headers++
+		}
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[anvilForkSourceAnnotation] = source
+		secret.Data = data
+		return controllerutil.SetControllerReference(network, secret, r.Scheme)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to reconcile fork Secret: %w", err)
+	}
+	log.FromContext(ctx).Info("Resolved Anvil fork source", "ForkFrom", source, "ForkBlockNumber", fork.blockNumber)
+	return fork, nil
+}
+
+// forkSourceConnection returns the connection of the first reachable endpoint of a Network and its head block
+func (r *NetworkReconciler) forkSourceConnection(ctx context.Context, namespace, name string) (rpcConnection, uint64, error) {
+	source, err := getNetwork(ctx, r.Client, namespace, name)
+	if err != nil {
+		return rpcConnection{}, 0, fmt.Errorf("failed to get fork source Network %s: %w", name, err)
+	}
+	endpoints, err := networkEndpoints(ctx, r.Client, source)
+	if err != nil {
+		return rpcConnection{}, 0, err
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		if endpoint.conn.tlsConfig != nil {
+			errs = append(errs, fmt.Errorf("endpoint %s/%s: mutual TLS endpoints cannot be forked", endpoint.provider, endpoint.name))
+			continue
+		}
+		ethClient, err := dialEndpoint(ctx, endpoint.conn)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("endpoint %s/%s: %w", endpoint.provider, endpoint.name, err))
+			continue
+		}
+		head, err := ethClient.BlockNumber(ctx)
+		ethClient.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("endpoint %s/%s: %w", endpoint.provider, endpoint.name, err))
+			continue
+		}
+		return endpoint.conn, head, nil
+	}
+	return rpcConnection{}, 0, fmt.Errorf("failed to connect to fork source Network %s: %w", name, errors.Join(errs...))
+}
+
+// reconcileAnvilRPCProvider points an RPCProvider named after the Network at its Anvil Service
+func (r *NetworkReconciler) reconcileAnvilRPCProvider(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: anvilRPCSecretName(network), Namespace: network.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Data = map[string][]byte{"urlKey": []byte(anvilEndpoint(network))}
+		return controllerutil.SetControllerReference(network, secret, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("failed to reconcile Anvil RPC Secret: %w", err)
+	}
+
+	rpcProvider := &kontractdeployerv1alpha1.RPCProvider{ObjectMeta: metav1.ObjectMeta{Name: network.Name, Namespace: network.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, rpcProvider, func() error {
+		rpcProvider.Spec.ProviderName = "Anvil"
+		rpcProvider.Spec.SecretRef = kontractdeployerv1alpha1.SecretKeyReference{Name: secret.Name, URLKey: "urlKey"}
+		return controllerutil.SetControllerReference(network, rpcProvider, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("failed to reconcile Anvil RPCProvider: %w", err)
+	}
+	return nil
+}
+
+// removeLegacyAnvilResources deletes the fixed-name resources earlier versions created for every anvil Network: the
+// Pod and Service bind host port 8545 and would keep answering on the old endpoint, and the RPCProvider, Wallet and
+// their Secrets point at that endpoint. Resources with an owner belong to the current layout and are kept.
+func (r *NetworkReconciler) removeLegacyAnvilResources(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
+	for _, obj := range []client.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "anvil-pod", Namespace: network.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "anvil-service", Namespace: network.Namespace}},
+		&kontractdeployerv1alpha1.RPCProvider{ObjectMeta: metav1.ObjectMeta{Name: "anvil", Namespace: network.Namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "anvil-rpc-secret", Namespace: network.Namespace}},
+		&kontractdeployerv1alpha1.Wallet{ObjectMeta: metav1.ObjectMeta{Name: "anvil-wallet", Namespace: network.Namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "anvil-wallet-secret", Namespace: network.Namespace}},
+	} {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("failed to get legacy Anvil resource %s: %w", obj.GetName(), err)
+			}
+			continue
+		}
+		if len(obj.GetOwnerReferences()) > 0 {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting legacy Anvil resource", "Name", obj.GetName())
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete legacy Anvil resource %s: %w", obj.GetName(), err)
+		}
+	}
+	return nil
+}
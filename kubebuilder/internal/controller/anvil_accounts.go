@@ -0,0 +1,218 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// defaultAnvilMnemonic is the mnemonic Anvil derives its dev accounts from when none is set
+	defaultAnvilMnemonic = "test test test test test test test test test test test junk"
+
+	// anvilAccountLabel marks the Wallet of a dev account with the index of the account
+	anvilAccountLabel = "kontract.expedio.xyz/anvil-account"
+
+	// hardenedKeyStart is the first index of a hardened BIP-32 child key
+	hardenedKeyStart = 0x80000000
+)
+
+// anvilDerivationPath is m/44'/60'/0'/0, the parent of the dev account keys Anvil derives
+var anvilDerivationPath = []uint32{hardenedKeyStart + 44, hardenedKeyStart + 60, hardenedKeyStart, 0}
+
+// anvilWalletName is the name of the Wallet of a dev account of a local Network
+// The first account keeps the name earlier versions gave the single dev Wallet
+func anvilWalletName(network *kontractdeployerv1alpha1.Network, index int) string {
+	if index == 0 {
+		return network.Name + "-wallet"
+	}
+	return fmt.Sprintf("%s-wallet-%d", network.Name, index)
+}
+
+// anvilAccountKeys derives the keys of the dev accounts of a local Network the same way Anvil does
+func anvilAccountKeys(network *kontractdeployerv1alpha1.Network) ([]*ecdsa.PrivateKey, error) {
+	spec := network.Spec.Anvil
+	if spec == nil {
+		spec = &kontractdeployerv1alpha1.AnvilSpec{}
+	}
+	mnemonic := defaultAnvilMnemonic
+	if spec.Mnemonic != "" {
+		mnemonic = strings.Join(strings.Fields(spec.Mnemonic), " ")
+	}
+	accounts := defaultAnvilAccounts
+	if spec.Accounts != nil {
+		accounts = int(*spec.Accounts)
+	}
+
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	parent := newMasterKey(seed)
+	for _, index := range anvilDerivationPath {
+		if parent, err = parent.child(index); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]*ecdsa.PrivateKey, 0, accounts)
+	for i := 0; i < accounts; i++ {
+		child, err := parent.child(uint32(i))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive account %d: %w", i, err)
+		}
+		key, err := crypto.ToECDSA(child.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive account %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// extendedKey is a BIP-32 private key with its chain code
+type extendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// newMasterKey returns the BIP-32 master key of a seed
+func newMasterKey(seed []byte) extendedKey {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return extendedKey{key: sum[:32], chainCode: sum[32:]}
+}
+
+// child derives the BIP-32 child private key at an index
+func (k extendedKey) child(index uint32) (extendedKey, error) {
+	var data []byte
+	if index >= hardenedKeyStart {
+		data = append([]byte{0}, k.key...)
+	} else {
+		parent, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return extendedKey{}, err
+		}
+		data = crypto.CompressPubkey(&parent.PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	order := crypto.S256().Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(order) >= 0 {
+		return extendedKey{}, errors.New("derived key is out of range")
+	}
+	key := tweak.Add(tweak, new(big.Int).SetBytes(k.key))
+	key.Mod(key, order)
+	if key.Sign() == 0 {
+		return extendedKey{}, errors.New("derived key is zero")
+	}
+	return extendedKey{key: math.PaddedBigBytes(key, 32), chainCode: sum[32:]}, nil
+}
+
+// reconcileAnvilAccounts creates a Wallet for each dev account of a local Network and removes the Wallets of accounts no longer derived
+func (r *NetworkReconciler) reconcileAnvilAccounts(ctx context.Context, network *kontractdeployerv1alpha1.Network, keys []*ecdsa.PrivateKey) ([]kontractdeployerv1alpha1.AnvilAccountStatus, error) {
+	logger := log.FromContext(ctx)
+
+	accounts := make([]kontractdeployerv1alpha1.AnvilAccountStatus, 0, len(keys))
+	for i, key := range keys {
+		name := anvilWalletName(network, i)
+		address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+		labels := map[string]string{anvilNetworkLabel: network.Name, anvilAccountLabel: strconv.Itoa(i)}
+
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name + "-secret", Namespace: network.Namespace}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+			secret.Labels = labels
+			secret.Data = map[string][]byte{
+				"privateKey": []byte(hex.EncodeToString(crypto.FromECDSA(key))),
+				"publicKey":  []byte(address),
+			}
+			return controllerutil.SetControllerReference(network, secret, r.Scheme)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to reconcile Secret of Anvil account %d: %w", i, err)
+		}
+
+		wallet := &kontractdeployerv1alpha1.Wallet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: network.Namespace}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, wallet, func() error {
+			wallet.Labels = labels
+			wallet.Spec.WalletType = "EOA"
+			wallet.Spec.NetworkRef = network.Name
+			wallet.Spec.ImportFrom = &kontractdeployerv1alpha1.ImportFromSpec{SecretRef: secret.Name}
+			return controllerutil.SetControllerReference(network, wallet, r.Scheme)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to reconcile Wallet of Anvil account %d: %w", i, err)
+		}
+
+		// A Wallet imports its key once, so a Wallet of an earlier mnemonic is replaced
+		if wallet.Status.PublicKey != "" && !strings.EqualFold(wallet.Status.PublicKey, address) {
+			logger.Info("Replacing Wallet of changed Anvil account", "Wallet", name, "Address", address)
+			if err := r.Delete(ctx, wallet); client.IgnoreNotFound(err) != nil {
+				return nil, fmt.Errorf("failed to replace Wallet of Anvil account %d: %w", i, err)
+			}
+		}
+
+		accounts = append(accounts, kontractdeployerv1alpha1.AnvilAccountStatus{Index: int32(i), Address: address, Wallet: name})
+	}
+
+	// Remove the Wallets and Secrets of accounts beyond the configured count
+	var wallets kontractdeployerv1alpha1.WalletList
+	if err := r.List(ctx, &wallets, client.InNamespace(network.Namespace), client.MatchingLabels{anvilNetworkLabel: network.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list Anvil account Wallets: %w", err)
+	}
+	for i := range wallets.Items {
+		wallet := &wallets.Items[i]
+		index, err := strconv.Atoi(wallet.Labels[anvilAccountLabel])
+		if err != nil || index < len(keys) {
+			continue
+		}
+		logger.Info("Deleting Wallet of removed Anvil account", "Wallet", wallet.Name)
+		if err := r.Delete(ctx, wallet); client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to delete Wallet %s: %w", wallet.Name, err)
+		}
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: wallet.Name + "-secret", Namespace: network.Namespace}}
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to delete Secret of Wallet %s: %w", wallet.Name, err)
+		}
+	}
+	return accounts, nil
+}
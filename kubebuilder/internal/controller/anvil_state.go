@@ -0,0 +1,352 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// anvilStateMountPath is where the state volume is mounted in the Anvil pod
+	anvilStateMountPath = "/data"
+
+	// defaultAnvilStateSize is the size of the state volume when the Network sets none
+	defaultAnvilStateSize = "1Gi"
+
+	// defaultAnvilDumpInterval is how often Anvil writes its state when the Network sets no interval
+	defaultAnvilDumpInterval = 30
+
+	// anvilSnapshotLabel marks the ConfigMap of a snapshot with the snapshot name
+	anvilSnapshotLabel = "kontract.expedio.xyz/snapshot"
+
+	// anvilSnapshotKey is the ConfigMap key that holds the compressed state of a snapshot
+	anvilSnapshotKey = "state"
+
+	// maxAnvilSnapshotSize keeps a snapshot within the 1 MiB limit of a ConfigMap
+	maxAnvilSnapshotSize = 1000 * 1024
+
+	// Phases of a restore request
+	restorePhaseCompleted = "Completed"
+	restorePhaseFailed    = "Failed"
+)
+
+// anvilHTTPClient sends the state dump and restore requests, which can be large
+var anvilHTTPClient = &http.Client{Timeout: time.Minute}
+
+// anvilStateClaimName is the name of the PersistentVolumeClaim that holds the state of a local Network
+func anvilStateClaimName(network *kontractdeployerv1alpha1.Network) string {
+	return network.Name + "-anvil-state"
+}
+
+// anvilSnapshotConfigMapName is the name of the ConfigMap that holds a snapshot of a local Network
+func anvilSnapshotConfigMapName(network *kontractdeployerv1alpha1.Network, snapshot string) string {
+	return network.Name + "-snapshot-" + snapshot
+}
+
+// anvilStateArgs makes Anvil load its state from the volume on start and write it back periodically and on shutdown
+func anvilStateArgs(persistence *kontractdeployerv1alpha1.AnvilPersistenceSpec) []string {
+	interval := int64(defaultAnvilDumpInterval)
+	if persistence.DumpIntervalSeconds != nil {
+		interval = *persistence.DumpIntervalSeconds
+	}
+	return []string{"--state", anvilStateMountPath + "/state.json", "--state-interval", strconv.FormatInt(interval, 10)}
+}
+
+// addAnvilStateVolume mounts the state volume into the Anvil pod.
+// The image runs as a non-root user, so an init container opens the fresh volume for writing.
+func addAnvilStateVolume(network *kontractdeployerv1alpha1.Network, pod *corev1.PodSpec) {
+	pod.Volumes = append(pod.Volumes, corev1.Volume{
+		Name: "state",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: anvilStateClaimName(network)},
+		},
+	})
+	mount := corev1.VolumeMount{Name: "state", MountPath: anvilStateMountPath}
+	pod.InitContainers = append(pod.InitContainers, corev1.Container{
+		Name:            "state-permissions",
+		Image:           pod.Containers[0].Image,
+		Command:         []string{"chmod", "a+rwx", anvilStateMountPath},
+		SecurityContext: &corev1.SecurityContext{RunAsUser: ptr.To(int64(0))},
+		VolumeMounts:    []corev1.VolumeMount{mount},
+	})
+	pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, mount)
+}
+
+// reconcileAnvilStateClaim creates the PersistentVolumeClaim of a persistent local Network
+// The claim is kept as created, so its size can only be raised by editing the claim directly
+func (r *NetworkReconciler) reconcileAnvilStateClaim(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
+	persistence := network.Spec.Anvil.Persistence
+	claim := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, client.ObjectKey{Name: anvilStateClaimName(network), Namespace: network.Namespace}, claim)
+	if err == nil || client.IgnoreNotFound(err) != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	size := resource.MustParse(defaultAnvilStateSize)
+	if persistence.Size != nil {
+		size = *persistence.Size
+	}
+	claim = &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      anvilStateClaimName(network),
+			Namespace: network.Namespace,
+			Labels:    anvilLabels(network),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: persistence.StorageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(network, claim, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, claim); err != nil {
+		return fmt.Errorf("failed to create Anvil state volume claim: %w", err)
+	}
+	return nil
+}
+
+// trackAnvilInstance records the running Anvil process and marks the chain as reset when it changed.
+// It reports whether the Anvil pod is ready.
+func (r *NetworkReconciler) trackAnvilInstance(ctx context.Context, network *kontractdeployerv1alpha1.Network, status *kontractdeployerv1alpha1.AnvilStatus) (bool, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(network.Namespace), client.MatchingLabels(anvilLabels(network))); err != nil {
+		return false, fmt.Errorf("failed to list Anvil pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		if !pod.DeletionTimestamp.IsZero() || len(pod.Status.ContainerStatuses) == 0 {
+			continue
+		}
+		container := pod.Status.ContainerStatuses[0]
+		if container.State.Running == nil {
+			continue
+		}
+		instance := fmt.Sprintf("%s/%d", pod.UID, container.RestartCount)
+		if status.Instance != "" && status.Instance != instance {
+			now := metav1.Now()
+			status.LastReset = &now
+			message := "Anvil restarted; the chain state was reset"
+			if network.Spec.Anvil != nil && network.Spec.Anvil.Persistence != nil {
+				message = "Anvil restarted; the chain state was reloaded from the state volume"
+			}
+			r.EventRecorder.Event(network, corev1.EventTypeNormal, "AnvilRestarted", message)
+		}
+		status.Instance = instance
+		return container.Ready, nil
+	}
+	return false, nil
+}
+
+// reconcileAnvilSnapshots takes the snapshots newly listed in the spec and deletes those no longer listed
+func (r *NetworkReconciler) reconcileAnvilSnapshots(ctx context.Context, network *kontractdeployerv1alpha1.Network, status *kontractdeployerv1alpha1.AnvilStatus, conn rpcConnection, ready bool) error {
+	var wanted []string
+	if network.Spec.Anvil != nil {
+		wanted = network.Spec.Anvil.Snapshots
+	}
+
+	taken := map[string]kontractdeployerv1alpha1.AnvilSnapshotStatus{}
+	for _, snapshot := range status.Snapshots {
+		taken[snapshot.Name] = snapshot
+	}
+
+	snapshots := make([]kontractdeployerv1alpha1.AnvilSnapshotStatus, 0, len(wanted))
+	for _, name := range wanted {
+		// A snapshot is taken once; remove and add its name again to retake it
+		if snapshot, ok := taken[name]; ok && (snapshot.ConfigMap != "" || snapshot.Message != "") {
+			snapshots = append(snapshots, snapshot)
+			continue
+		}
+		if !ready {
+			snapshots = append(snapshots, kontractdeployerv1alpha1.AnvilSnapshotStatus{Name: name})
+			continue
+		}
+		snapshots = append(snapshots, r.takeAnvilSnapshot(ctx, network, conn, name))
+	}
+	status.Snapshots = snapshots
+
+	// Delete the ConfigMaps of snapshots that are no longer listed
+	var configMaps corev1.ConfigMapList
+	if err := r.List(ctx, &configMaps, client.InNamespace(network.Namespace), client.MatchingLabels{anvilNetworkLabel: network.Name}); err != nil {
+		return fmt.Errorf("failed to list snapshot ConfigMaps: %w", err)
+	}
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		name, isSnapshot := configMap.Labels[anvilSnapshotLabel]
+		if !isSnapshot || containsString(wanted, name) {
+			continue
+		}
+		if err := r.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete snapshot %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// takeAnvilSnapshot dumps the chain state into the ConfigMap of a snapshot
+func (r *NetworkReconciler) takeAnvilSnapshot(ctx context.Context, network *kontractdeployerv1alpha1.Network, conn rpcConnection, name string) kontractdeployerv1alpha1.AnvilSnapshotStatus {
+	snapshot := kontractdeployerv1alpha1.AnvilSnapshotStatus{Name: name}
+	fail := func(err error) kontractdeployerv1alpha1.AnvilSnapshotStatus {
+		snapshot.Message = err.Error()
+		r.EventRecorder.Event(network, corev1.EventTypeWarning, "SnapshotFailed", fmt.Sprintf("Snapshot %s failed: %v", name, err))
+		return snapshot
+	}
+
+	state, blockNumber, err := dumpAnvilState(ctx, conn)
+	if err != nil {
+		return fail(err)
+	}
+	if len(state) > maxAnvilSnapshotSize {
+		return fail(fmt.Errorf("the compressed state is %d bytes, more than a ConfigMap can hold", len(state)))
+	}
+
+	labels := anvilLabels(network)
+	labels[anvilSnapshotLabel] = name
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      anvilSnapshotConfigMapName(network, name),
+			Namespace: network.Namespace,
+			Labels:    labels,
+		},
+		BinaryData: map[string][]byte{anvilSnapshotKey: state},
+	}
+	if err := controllerutil.SetControllerReference(network, configMap, r.Scheme); err != nil {
+		return fail(err)
+	}
+	if err := r.Create(ctx, configMap); err != nil {
+		return fail(fmt.Errorf("failed to store snapshot: %w", err))
+	}
+
+	now := metav1.Now()
+	snapshot.ConfigMap = configMap.Name
+	snapshot.BlockNumber = blockNumber
+	snapshot.Time = &now
+	r.EventRecorder.Event(network, corev1.EventTypeNormal, "SnapshotTaken", fmt.Sprintf("Snapshot %s taken at block %d", name, blockNumber))
+	log.FromContext(ctx).Info("Anvil snapshot taken", "Snapshot", name, "BlockNumber", blockNumber, "Size", len(state))
+	return snapshot
+}
+
+// reconcileAnvilRestore restores the chain from a snapshot once per restore request
+func (r *NetworkReconciler) reconcileAnvilRestore(ctx context.Context, network *kontractdeployerv1alpha1.Network, status *kontractdeployerv1alpha1.AnvilStatus, conn rpcConnection, ready bool) error {
+	if network.Spec.Anvil == nil || network.Spec.Anvil.Restore == nil {
+		return nil
+	}
+	request := network.Spec.Anvil.Restore
+	if status.Restore != nil && status.Restore.RequestID == request.RequestID {
+		return nil
+	}
+	if !ready {
+		return nil
+	}
+
+	now := metav1.Now()
+	status.Restore = &kontractdeployerv1alpha1.AnvilRestoreStatus{
+		RequestID:      request.RequestID,
+		Snapshot:       request.Snapshot,
+		Phase:          restorePhaseFailed,
+		CompletionTime: &now,
+	}
+
+	var configMap corev1.ConfigMap
+	err := r.Get(ctx, client.ObjectKey{Name: anvilSnapshotConfigMapName(network, request.Snapshot), Namespace: network.Namespace}, &configMap)
+	if err != nil {
+		err = fmt.Errorf("snapshot %s is not available: %w", request.Snapshot, err)
+	} else {
+		err = restoreAnvilState(ctx, conn, configMap.BinaryData[anvilSnapshotKey])
+	}
+	if err != nil {
+		status.Restore.Message = err.Error()
+		r.EventRecorder.Event(network, corev1.EventTypeWarning, "SnapshotRestoreFailed", err.Error())
+		return nil
+	}
+
+	status.Restore.Phase = restorePhaseCompleted
+	status.LastReset = &now
+	r.EventRecorder.Event(network, corev1.EventTypeNormal, "SnapshotRestored", fmt.Sprintf("Chain restored from snapshot %s", request.Snapshot))
+	return nil
+}
+
+// dumpAnvilState returns the compressed chain state and the head block of an Anvil node
+func dumpAnvilState(ctx context.Context, conn rpcConnection) ([]byte, int64, error) {
+	var blockNumber hexutil.Uint64
+	if err := jsonRPCCall(ctx, anvilHTTPClient, conn, "eth_blockNumber", nil, &blockNumber); err != nil {
+		return nil, 0, err
+	}
+	var state hexutil.Bytes
+	if err := jsonRPCCall(ctx, anvilHTTPClient, conn, "anvil_dumpState", nil, &state); err != nil {
+		return nil, 0, err
+	}
+	return state, int64(blockNumber), nil
+}
+
+// restoreAnvilState resets an Anvil node and loads a state dumped by dumpAnvilState
+func restoreAnvilState(ctx context.Context, conn rpcConnection, state []byte) error {
+	if len(state) == 0 {
+		return fmt.Errorf("the snapshot holds no state")
+	}
+	if err := jsonRPCCall(ctx, anvilHTTPClient, conn, "anvil_reset", nil, &struct{}{}); err != nil {
+		return err
+	}
+	var loaded bool
+	if err := jsonRPCCall(ctx, anvilHTTPClient, conn, "anvil_loadState", []interface{}{hexutil.Bytes(state)}, &loaded); err != nil {
+		return err
+	}
+	if !loaded {
+		return fmt.Errorf("anvil_loadState did not load the snapshot")
+	}
+	return nil
+}
+
+// anvilResetPredicate passes Network updates that record a new reset of the local chain
+func anvilResetPredicate() predicate.Funcs {
+	lastReset := func(obj client.Object) *metav1.Time {
+		network, ok := obj.(*kontractdeployerv1alpha1.Network)
+		if !ok || network.Status.Anvil == nil {
+			return nil
+		}
+		return network.Status.Anvil.LastReset
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			previous, current := lastReset(e.ObjectOld), lastReset(e.ObjectNew)
+			return current != nil && (previous == nil || !previous.Equal(current))
+		},
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	corev1 "k8s.io/api/core/v1"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// Explorer types of a BlockExplorer
+const (
+	explorerTypeEtherscan   = "Etherscan"
+	explorerTypeEtherscanV2 = "EtherscanV2"
+	explorerTypeBlockscout  = "Blockscout"
+	explorerTypeRoutescan   = "Routescan"
+	explorerTypeSourcify    = "Sourcify"
+)
+
+const (
+	// etherscanV2URL is the multichain Etherscan API, used when the Secret has no URL
+	etherscanV2URL = "https://api.etherscan.io/v2/api"
+
+	// sourcifyURL is the public Sourcify server, used when the Secret has no URL
+	sourcifyURL = "https://sourcify.dev/server"
+
+	// routescanVerifierKey is the placeholder API key Routescan accepts for verification without an account
+	routescanVerifierKey = "verifyContract"
+
+	// blockExplorerTimeout bounds every request to a block explorer API
+	blockExplorerTimeout = 10 * time.Second
+)
+
+// blockExplorerAPI is the API of one type of block explorer
+type blockExplorerAPI interface {
+	// checkHealth verifies that the API answers with the configured credentials
+	checkHealth(ctx context.Context) error
+
+	// contractABI returns the ABI of a verified contract
+	contractABI(ctx context.Context, address common.Address) (string, error)
+
+	// transaction looks up a transaction by hash
+	transaction(ctx context.Context, hash common.Hash) (*explorerTransaction, error)
+
+	// verifier returns the forge verifier and verifier URL deploy Jobs verify sources with
+	verifier() (name, verifierURL string)
+}
+
+// explorerTransaction is a transaction as reported by a block explorer
+type explorerTransaction struct {
+	Hash        common.Hash
+	From        common.Address
+	To          *common.Address
+	BlockNumber uint64 // 0 while the transaction is pending
+}
+
+// explorerType returns the explorer type of a BlockExplorer, defaulting to Etherscan
+func explorerType(explorer *kontractdeployerv1alpha1.BlockExplorer) string {
+	if explorer.Spec.ExplorerType == "" {
+		return explorerTypeEtherscan
+	}
+	return explorer.Spec.ExplorerType
+}
+
+// newBlockExplorerAPI returns the API of a BlockExplorer on the given chain, with the credentials from its Secret
+func newBlockExplorerAPI(explorer *kontractdeployerv1alpha1.BlockExplorer, secret *corev1.Secret, chainID int64) (blockExplorerAPI, error) {
+	var apiKey, apiURL string
+	if key := explorer.Spec.SecretRef.TokenKey; key != "" {
+		apiKey = string(secret.Data[key])
+	}
+	if key := explorer.Spec.SecretRef.URLKey; key != "" {
+		apiURL = strings.TrimRight(string(secret.Data[key]), "/")
+	}
+	httpClient := &http.Client{Timeout: blockExplorerTimeout}
+
+	switch kind := explorerType(explorer); kind {
+	case explorerTypeEtherscan, explorerTypeEtherscanV2:
+		if apiKey == "" {
+			return nil, fmt.Errorf("%s requires an API key in tokenKey", kind)
+		}
+		if kind == explorerTypeEtherscanV2 && apiURL == "" {
+			apiURL = etherscanV2URL
+		}
+		if apiURL == "" {
+			return nil, fmt.Errorf("%s requires an API URL in urlKey", kind)
+		}
+		return &etherscanAPI{kind: kind, url: apiURL, apiKey: apiKey, chainID: chainID, client: httpClient}, nil
+	case explorerTypeBlockscout, explorerTypeRoutescan:
+		if apiURL == "" {
+			return nil, fmt.Errorf("%s requires an API URL in urlKey", kind)
+		}
+		return &etherscanAPI{kind: kind, url: apiURL, apiKey: apiKey, chainID: chainID, client: httpClient}, nil
+	case explorerTypeSourcify:
+		if apiURL == "" {
+			apiURL = sourcifyURL
+		}
+		return &sourcifyAPI{url: apiURL, chainID: chainID, client: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unsupported explorer type %q", kind)
+	}
+}
+
+// etherscanAPI is the Etherscan module/action API, which Blockscout and Routescan implement as well
+type etherscanAPI struct {
+	kind    string
+	url     string
+	apiKey  string
+	chainID int64 // sent as the chainid parameter of the V2 API
+	client  *http.Client
+}
+
+// query sends an Etherscan API request and decodes its result into result
+func (e *etherscanAPI) query(ctx context.Context, params url.Values, result interface{}) error {
+	if e.apiKey != "" {
+		params.Set("apikey", e.apiKey)
+	}
+	if e.kind == explorerTypeEtherscanV2 {
+		params.Set("chainid", strconv.FormatInt(e.chainID, 10))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response: %d", resp.StatusCode)
+	}
+
+	// Regular modules answer with status/message/result, the proxy module with a JSON-RPC response
+	var response struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Result  json.RawMessage `json:"result"`
+		Error   *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", params.Get("action"), err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s failed: %s", params.Get("action"), response.Error.Message)
+	}
+	if response.Status == "0" {
+		var detail string
+		if json.Unmarshal(response.Result, &detail) != nil {
+			detail = string(response.Result)
+		}
+		return fmt.Errorf("%s failed: %s: %s", params.Get("action"), response.Message, detail)
+	}
+	if len(response.Result) == 0 {
+		return fmt.Errorf("invalid response to %s", params.Get("action"))
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// checkHealth requests the latest block number, which exists on every chain
+func (e *etherscanAPI) checkHealth(ctx context.Context) error {
+	params := url.Values{"module": {"proxy"}, "action": {"eth_blockNumber"}}
+	if e.kind == explorerTypeBlockscout {
+		params = url.Values{"module": {"block"}, "action": {"eth_block_number"}}
+	}
+	var head string
+	if err := e.query(ctx, params, &head); err != nil {
+		return err
+	}
+	if _, err := hexutil.DecodeUint64(head); err != nil {
+		return fmt.Errorf("invalid block number %q: %w", head, err)
+	}
+	return nil
+}
+
+// contractABI returns the ABI of a verified contract
+func (e *etherscanAPI) contractABI(ctx context.Context, address common.Address) (string, error) {
+	var contractABI string
+	params := url.Values{"module": {"contract"}, "action": {"getabi"}, "address": {address.Hex()}}
+	if err := e.query(ctx, params, &contractABI); err != nil {
+		return "", err
+	}
+	return contractABI, nil
+}
+
+// transaction looks up a transaction through the proxy module, or gettxinfo on Blockscout
+func (e *etherscanAPI) transaction(ctx context.Context, hash common.Hash) (*explorerTransaction, error) {
+	params := url.Values{"module": {"proxy"}, "action": {"eth_getTransactionByHash"}, "txhash": {hash.Hex()}}
+	if e.kind == explorerTypeBlockscout {
+		params = url.Values{"module": {"transaction"}, "action": {"gettxinfo"}, "txhash": {hash.Hex()}}
+	}
+	var tx *struct {
+		Hash        common.Hash     `json:"hash"`
+		From        common.Address  `json:"from"`
+		To          *common.Address `json:"to"`
+		BlockNumber *string         `json:"blockNumber"`
+	}
+	if err := e.query(ctx, params, &tx); err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %s not found", hash.Hex())
+	}
+
+	// The proxy module reports hex block numbers, gettxinfo decimal ones
+	result := &explorerTransaction{Hash: tx.Hash, From: tx.From, To: tx.To}
+	if tx.BlockNumber != nil && *tx.BlockNumber != "" {
+		var err error
+		if strings.HasPrefix(*tx.BlockNumber, "0x") {
+			result.BlockNumber, err = hexutil.DecodeUint64(*tx.BlockNumber)
+		} else {
+			result.BlockNumber, err = strconv.ParseUint(*tx.BlockNumber, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid block number %q: %w", *tx.BlockNumber, err)
+		}
+	}
+	return result, nil
+}
+
+// verifier returns the forge verifier for the explorer; the V2 API takes the chain as a URL parameter
+func (e *etherscanAPI) verifier() (string, string) {
+	switch e.kind {
+	case explorerTypeBlockscout:
+		return "blockscout", e.url
+	case explorerTypeEtherscanV2:
+		return "etherscan", fmt.Sprintf("%s?chainid=%d", e.url, e.chainID)
+	default:
+		return "etherscan", e.url
+	}
+}
+
+// sourcifyAPI is the REST API of a Sourcify server
+type sourcifyAPI struct {
+	url     string
+	chainID int64
+	client  *http.Client
+}
+
+// get sends a GET request to the Sourcify server and decodes the JSON response into result
+func (s *sourcifyAPI) get(ctx context.Context, path string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response to %s: %d", path, resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// checkHealth calls the health endpoint of the server
+func (s *sourcifyAPI) checkHealth(ctx context.Context) error {
+	return s.get(ctx, "/health", nil)
+}
+
+// contractABI returns the ABI of a contract verified on the chain of the API
+func (s *sourcifyAPI) contractABI(ctx context.Context, address common.Address) (string, error) {
+	var contract struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if err := s.get(ctx, fmt.Sprintf("/v2/contract/%d/%s?fields=abi", s.chainID, address.Hex()), &contract); err != nil {
+		return "", err
+	}
+	if len(contract.ABI) == 0 || string(contract.ABI) == "null" {
+		return "", fmt.Errorf("contract %s is not verified on chain %d", address.Hex(), s.chainID)
+	}
+	return string(contract.ABI), nil
+}
+
+// transaction is not supported; Sourcify only stores verified sources
+func (s *sourcifyAPI) transaction(context.Context, common.Hash) (*explorerTransaction, error) {
+	return nil, fmt.Errorf("transaction lookup is not supported by %s", explorerTypeSourcify)
+}
+
+// verifier returns the forge Sourcify verifier
+func (s *sourcifyAPI) verifier() (string, string) {
+	return "sourcify", s.url
+}
+
+// blockExplorerJobEnv returns the environment that lets a deploy Job verify sources on a BlockExplorer
+func blockExplorerJobEnv(explorer *kontractdeployerv1alpha1.BlockExplorer, secret *corev1.Secret, chainID int64) ([]corev1.EnvVar, error) {
+	api, err := newBlockExplorerAPI(explorer, secret, chainID)
+	if err != nil {
+		return nil, err
+	}
+	verifier, verifierURL := api.verifier()
+	envVars := []corev1.EnvVar{
+		{Name: "VERIFIER", Value: verifier},
+		{Name: "VERIFIER_URL", Value: verifierURL},
+	}
+
+	switch {
+	case explorer.Spec.SecretRef.TokenKey != "":
+		envVars = append(envVars, corev1.EnvVar{
+			Name: "ETHERSCAN_API_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: explorer.Spec.SecretRef.Name},
+					Key:                  explorer.Spec.SecretRef.TokenKey,
+				},
+			},
+		})
+	case explorerType(explorer) == explorerTypeRoutescan:
+		envVars = append(envVars, corev1.EnvVar{Name: "ETHERSCAN_API_KEY", Value: routescanVerifierKey})
+	}
+	return envVars, nil
+}
@@ -18,18 +18,19 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"strings"
+	"sort"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
@@ -40,167 +41,135 @@ type BlockExplorerReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder // Event recorder for logging events
+
+	// HealthCheckWorkers is the number of BlockExplorers checked at the same time
+	HealthCheckWorkers int
 }
 
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=blockexplorers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=blockexplorers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=blockexplorers/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=networks,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch;update
 
 // Reconcile is part of the main Kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
+// Each BlockExplorer is checked on its own interval by requeueing, so checks only run on the elected leader
+// and at most HealthCheckWorkers explorers are checked at the same time.
 func (r *BlockExplorerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
 	// Fetch the BlockExplorer instance
 	var blockExplorer kontractdeployerv1alpha1.BlockExplorer
 	if err := r.Get(ctx, req.NamespacedName, &blockExplorer); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
 		log.Error(err, "unable to fetch BlockExplorer")
-		r.Recorder.Event(&blockExplorer, corev1.EventTypeWarning, "FetchFailed", "Unable to fetch BlockExplorer")
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		return ctrl.Result{}, err
 	}
 
-	log.Info("Successfully fetched BlockExplorer", "BlockExplorer.Name", blockExplorer.Name)
-
-	// Perform reconciliation logic if needed
-	// (You can leave this part empty or perform some reconciliation logic if required)
-
-	return ctrl.Result{}, nil
-}
-
-// StartPeriodicHealthCheck starts a background goroutine that checks the health of all BlockExplorers every minute
-func (r *BlockExplorerReconciler) StartPeriodicHealthCheck(ctx context.Context) {
-	go func() {
-		ticker := time.NewTicker(1 * time.Minute)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				r.checkAllBlockExplorers(ctx)
-			}
-		}
-	}()
-}
-
-// checkAllBlockExplorers checks the health of all BlockExplorers and updates their statuses
-func (r *BlockExplorerReconciler) checkAllBlockExplorers(ctx context.Context) {
-	log := log.FromContext(ctx)
-
-	// List all BlockExplorers
-	var blockExplorers kontractdeployerv1alpha1.BlockExplorerList
-	if err := r.List(ctx, &blockExplorers); err != nil {
-		log.Error(err, "unable to list BlockExplorers")
-		return
+	// Status updates of the last check trigger a reconcile as well; wait for the interval to pass
+	var intervalSeconds *int64
+	if blockExplorer.Spec.HealthCheck != nil {
+		intervalSeconds = blockExplorer.Spec.HealthCheck.IntervalSeconds
+	}
+	interval := healthCheckInterval(intervalSeconds)
+	secrets := secretVersions(ctx, r.Client, blockExplorer.Namespace, []string{blockExplorer.Spec.SecretRef.Name})
+	if wait := nextHealthCheck(blockExplorer.Status.LastChecked, blockExplorer.Status.ObservedGeneration, blockExplorer.Generation, blockExplorer.Status.SecretVersions, secrets, interval, time.Now()); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
 	}
 
-	for _, blockExplorer := range blockExplorers.Items {
-		log := log.WithValues("BlockExplorer", blockExplorer.Name, "Namespace", blockExplorer.Namespace)
-
-		// Fetch the referenced secret
-		var secret corev1.Secret
-		secretName := types.NamespacedName{Namespace: blockExplorer.Namespace, Name: blockExplorer.Spec.SecretRef.Name}
-		if err := r.Get(ctx, secretName, &secret); err != nil {
-			log.Error(err, fmt.Sprintf("BlockExplorer (%s) - unable to fetch Secret", blockExplorer.Name), "Secret", secretName)
-			r.updateStatus(ctx, &blockExplorer, false, "")
-			r.Recorder.Event(&blockExplorer, corev1.EventTypeWarning, "SecretFetchFailed", "Unable to fetch Secret for BlockExplorer")
-			continue
-		}
-
-		// Extract API token and URL from the secret using the specified keys
-		token := string(secret.Data[blockExplorer.Spec.SecretRef.TokenKey])
-		apiEndpoint := string(secret.Data[blockExplorer.Spec.SecretRef.URLKey])
-
-		// Validate the existence of the token and URL
-		if token == "" || apiEndpoint == "" {
-			log.Error(fmt.Errorf("missing token or URL"), fmt.Sprintf("BlockExplorer (%s) - missing required data in Secret", blockExplorer.Name))
-			r.updateStatus(ctx, &blockExplorer, false, "")
-			continue
-		}
-
-		// Perform the health check
-		if err := r.checkAPIHealth(ctx, apiEndpoint, token, blockExplorer.Name); err != nil {
-			log.Error(err, fmt.Sprintf("BlockExplorer (%s) - API health check failed", blockExplorer.Name))
-			r.updateStatus(ctx, &blockExplorer, false, "")
-			r.Recorder.Event(&blockExplorer, corev1.EventTypeWarning, "APIHealthCheckFailed", "API health check failed")
-			continue
-		}
-
-		// Update the status to healthy: true without creating an event
-		r.updateStatus(ctx, &blockExplorer, true, apiEndpoint)
+	if err := r.checkBlockExplorer(ctx, &blockExplorer, secrets); err != nil {
+		return ctrl.Result{}, err
 	}
+	return ctrl.Result{RequeueAfter: interval}, nil
 }
 
-// checkAPIHealth sends a GET request to check the health of the BlockExplorer by verifying access to the API endpoint.
-func (r *BlockExplorerReconciler) checkAPIHealth(ctx context.Context, endpoint, token, blockExplorerName string) error {
-	log := log.FromContext(ctx)
-	client := http.Client{
-		Timeout: 10 * time.Second,
+// checkBlockExplorer checks the health of a BlockExplorer and updates its status
+// together with the version of the Secret that was read for the check
+func (r *BlockExplorerReconciler) checkBlockExplorer(ctx context.Context, blockExplorer *kontractdeployerv1alpha1.BlockExplorer, secrets map[string]string) error {
+	log := log.FromContext(ctx).WithValues("BlockExplorer", blockExplorer.Name, "Namespace", blockExplorer.Namespace)
+
+	// Fetch the referenced secret
+	var secret corev1.Secret
+	secretName := types.NamespacedName{Namespace: blockExplorer.Namespace, Name: blockExplorer.Spec.SecretRef.Name}
+	if err := r.Get(ctx, secretName, &secret); err != nil {
+		log.Error(err, fmt.Sprintf("BlockExplorer (%s) - unable to fetch Secret", blockExplorer.Name), "Secret", secretName)
+		r.Recorder.Event(blockExplorer, corev1.EventTypeWarning, "SecretFetchFailed", "Unable to fetch Secret for BlockExplorer")
+		return r.updateStatus(ctx, blockExplorer, secrets, false, "", "unable to fetch Secret")
 	}
 
-	// Construct the URL for the health check
-	url := fmt.Sprintf("%s?module=block&action=getblockreward&blockno=2165403&apikey=%s", strings.TrimRight(endpoint, "/"), token)
-	log.Info(fmt.Sprintf("BlockExplorer (%s) - Performing API health check", blockExplorerName))
-
-	// Perform the GET request
-	resp, err := client.Get(url)
+	// Build the API client of the explorer type
+	chainID, err := r.healthCheckChainID(ctx, blockExplorer)
 	if err != nil {
-		log.Error(err, fmt.Sprintf("BlockExplorer (%s) - Failed to send API health check request", blockExplorerName))
 		return err
 	}
-	defer resp.Body.Close()
-
-	log.Info(fmt.Sprintf("BlockExplorer (%s) - Received response from API health check", blockExplorerName), "statusCode", resp.StatusCode)
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received non-200 response: %d", resp.StatusCode)
+	api, err := newBlockExplorerAPI(blockExplorer, &secret, chainID)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("BlockExplorer (%s) - invalid configuration", blockExplorer.Name))
+		r.Recorder.Event(blockExplorer, corev1.EventTypeWarning, "InvalidConfiguration", err.Error())
+		return r.updateStatus(ctx, blockExplorer, secrets, false, "", err.Error())
 	}
-
-	// Decode the JSON response to verify it's a valid response
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Error(err, fmt.Sprintf("BlockExplorer (%s) - Failed to decode API health check response", blockExplorerName))
-		return err
+	_, apiEndpoint := api.verifier()
+
+	// Perform the health check
+	log.Info(fmt.Sprintf("BlockExplorer (%s) - Performing API health check", blockExplorer.Name), "ExplorerType", explorerType(blockExplorer), "ChainID", chainID)
+	if err := api.checkHealth(ctx); err != nil {
+		log.Error(err, fmt.Sprintf("BlockExplorer (%s) - API health check failed", blockExplorer.Name))
+		r.Recorder.Event(blockExplorer, corev1.EventTypeWarning, "APIHealthCheckFailed", fmt.Sprintf("API health check failed: %v", err))
+		return r.updateStatus(ctx, blockExplorer, secrets, false, apiEndpoint, err.Error())
 	}
 
-	log.Info(fmt.Sprintf("BlockExplorer (%s) - Decoded API health check response", blockExplorerName))
+	// Update the status to healthy: true without creating an event
+	return r.updateStatus(ctx, blockExplorer, secrets, true, apiEndpoint, "")
+}
 
-	// Check if the status is "1" and message is "OK"
-	if status, ok := result["status"].(string); !ok || status != "1" {
-		return fmt.Errorf("invalid status in response: %v", result)
+// healthCheckChainID returns the chain a multichain explorer is checked on:
+// the configured chain ID, else the chain of the first Network that references the explorer, else 1
+func (r *BlockExplorerReconciler) healthCheckChainID(ctx context.Context, blockExplorer *kontractdeployerv1alpha1.BlockExplorer) (int64, error) {
+	if blockExplorer.Spec.ChainID != nil {
+		return *blockExplorer.Spec.ChainID, nil
 	}
-
-	if message, ok := result["message"].(string); !ok || strings.ToUpper(message) != "OK" {
-		return fmt.Errorf("invalid message in response: %v", result)
+	var networks kontractdeployerv1alpha1.NetworkList
+	if err := r.List(ctx, &networks, client.InNamespace(blockExplorer.Namespace)); err != nil {
+		return 0, fmt.Errorf("failed to list Networks: %w", err)
 	}
-
-	log.Info(fmt.Sprintf("BlockExplorer (%s) - API health check succeeded", blockExplorerName))
-	return nil
+	sort.Slice(networks.Items, func(i, j int) bool { return networks.Items[i].Name < networks.Items[j].Name })
+	for _, network := range networks.Items {
+		if network.Spec.BlockExplorerRef != nil && network.Spec.BlockExplorerRef.Name == blockExplorer.Name {
+			return networkChainID(&network), nil
+		}
+	}
+	return 1, nil
 }
 
-// updateStatus updates the status of the BlockExplorer resource
-func (r *BlockExplorerReconciler) updateStatus(ctx context.Context, blockExplorer *kontractdeployerv1alpha1.BlockExplorer, healthy bool, apiEndpoint string) {
+// updateStatus patches the status of the BlockExplorer resource with the result of a health check
+func (r *BlockExplorerReconciler) updateStatus(ctx context.Context, blockExplorer *kontractdeployerv1alpha1.BlockExplorer, secrets map[string]string, healthy bool, apiEndpoint, message string) error {
+	patch := client.MergeFrom(blockExplorer.DeepCopy())
+	checked := metav1.Now()
 	blockExplorer.Status.Healthy = healthy
 	blockExplorer.Status.APIEndpoint = apiEndpoint
-	if err := r.Status().Update(ctx, blockExplorer); err != nil {
+	blockExplorer.Status.Message = message
+	blockExplorer.Status.LastChecked = &checked
+	blockExplorer.Status.ObservedGeneration = blockExplorer.Generation
+	blockExplorer.Status.SecretVersions = secrets
+	if err := r.Status().Patch(ctx, blockExplorer, patch); err != nil {
 		log.FromContext(ctx).Error(err, fmt.Sprintf("BlockExplorer (%s) - unable to update BlockExplorer status", blockExplorer.Name))
 		r.Recorder.Event(blockExplorer, corev1.EventTypeWarning, "StatusUpdateFailed", "Failed to update BlockExplorer status")
-	} else {
-		log.FromContext(ctx).Info("BlockExplorer status updated successfully", "BlockExplorer.Name", blockExplorer.Name)
+		return fmt.Errorf("failed to update BlockExplorer status: %w", err)
 	}
+	log.FromContext(ctx).Info("BlockExplorer status updated successfully", "BlockExplorer.Name", blockExplorer.Name)
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *BlockExplorerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Recorder = mgr.GetEventRecorderFor("blockexplorer-controller") // Initialize the event recorder
-	if err := ctrl.NewControllerManagedBy(mgr).
+	return ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.BlockExplorer{}).
-		Complete(r); err != nil {
-		return err
-	}
-	// Start the periodic health check
-	r.StartPeriodicHealthCheck(context.Background())
-	return nil
+		Watches(&corev1.Secret{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.BlockExplorerList{}, secretRefField)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: healthCheckWorkers(r.HealthCheckWorkers)}).
+		Complete(r)
 }
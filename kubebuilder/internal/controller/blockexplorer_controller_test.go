@@ -18,11 +18,21 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 
+	"github.com/ethereum/go-ethereum/common"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -69,8 +79,9 @@ var _ = Describe("BlockExplorer Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &BlockExplorerReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -82,3 +93,176 @@ var _ = Describe("BlockExplorer Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Block explorer types", func() {
+	ctx := context.Background()
+
+	explorer := func(explorerType string, tokenKey, urlKey string) *kontractdeployerv1alpha1.BlockExplorer {
+		return &kontractdeployerv1alpha1.BlockExplorer{
+			ObjectMeta: metav1.ObjectMeta{Name: "explorer"},
+			Spec: kontractdeployerv1alpha1.BlockExplorerSpec{
+				ExplorerName: explorerType,
+				ExplorerType: explorerType,
+				SecretRef:    kontractdeployerv1alpha1.BlockExplorerSecretRef{Name: "explorer", TokenKey: tokenKey, URLKey: urlKey},
+			},
+		}
+	}
+	secret := func(apiURL string) *corev1.Secret {
+		return &corev1.Secret{Data: map[string][]byte{"url": []byte(apiURL), "token": []byte("api-key")}}
+	}
+
+	It("checks Etherscan V2 with the chain ID and reports invalid keys", func() {
+		var queries []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			queries = append(queries, req.URL.RawQuery)
+			if req.URL.Query().Get("apikey") != "api-key" {
+				fmt.Fprint(w, `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`)
+				return
+			}
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":83,"result":"0x10d4f"}`)
+		}))
+		defer server.Close()
+
+		api, err := newBlockExplorerAPI(explorer(explorerTypeEtherscanV2, "token", "url"), secret(server.URL), 8453)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.checkHealth(ctx)).To(Succeed())
+		Expect(queries).To(ConsistOf("action=eth_blockNumber&apikey=api-key&chainid=8453&module=proxy"))
+		verifier, verifierURL := api.verifier()
+		Expect(verifier).To(Equal("etherscan"))
+		Expect(verifierURL).To(Equal(server.URL + "?chainid=8453"))
+
+		invalid := &corev1.Secret{Data: map[string][]byte{"url": []byte(server.URL), "token": []byte("wrong")}}
+		api, err = newBlockExplorerAPI(explorer(explorerTypeEtherscan, "token", "url"), invalid, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.checkHealth(ctx)).To(MatchError(ContainSubstring("Invalid API Key")))
+
+		// The V2 API defaults to the public endpoint, the V1 API needs a URL
+		api, err = newBlockExplorerAPI(explorer(explorerTypeEtherscanV2, "token", ""), secret(""), 1)
+		Expect(err).NotTo(HaveOccurred())
+		_, verifierURL = api.verifier()
+		Expect(verifierURL).To(Equal(etherscanV2URL + "?chainid=1"))
+		_, err = newBlockExplorerAPI(explorer(explorerTypeEtherscan, "token", ""), secret(""), 1)
+		Expect(err).To(MatchError(ContainSubstring("urlKey")))
+		_, err = newBlockExplorerAPI(explorer(explorerTypeEtherscan, "", "url"), secret(server.URL), 1)
+		Expect(err).To(MatchError(ContainSubstring("tokenKey")))
+	})
+
+	It("uses the Blockscout actions without an API key", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.URL.Query().Has("apikey")).To(BeFalse())
+			switch req.URL.Query().Get("action") {
+			case "eth_block_number":
+				fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x5"}`)
+			case "gettxinfo":
+				fmt.Fprint(w, `{"status":"1","message":"OK","result":{"hash":"0x00000000000000000000000000000000000000000000000000000000000000aa","from":"0x0000000000000000000000000000000000000001","to":"0x0000000000000000000000000000000000000002","blockNumber":"1234"}}`)
+			case "getabi":
+				fmt.Fprint(w, `{"status":"0","message":"Contract source code not verified","result":null}`)
+			default:
+				http.NotFound(w, req)
+			}
+		}))
+		defer server.Close()
+
+		api, err := newBlockExplorerAPI(explorer(explorerTypeBlockscout, "", "url"), secret(server.URL), 100)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.checkHealth(ctx)).To(Succeed())
+
+		tx, err := api.transaction(ctx, common.HexToHash("0xaa"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tx.BlockNumber).To(Equal(uint64(1234)))
+		Expect(tx.From).To(Equal(common.HexToAddress("0x1")))
+		Expect(*tx.To).To(Equal(common.HexToAddress("0x2")))
+
+		_, err = api.contractABI(ctx, common.HexToAddress("0x2"))
+		Expect(err).To(MatchError(ContainSubstring("not verified")))
+	})
+
+	It("fetches ABIs from Sourcify for the chain", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/health":
+				fmt.Fprint(w, "Alive and kicking!")
+			case "/v2/contract/11155111/0x0000000000000000000000000000000000000002":
+				fmt.Fprint(w, `{"abi":[{"type":"function","name":"value","inputs":[],"outputs":[]}]}`)
+			default:
+				http.NotFound(w, req)
+			}
+		}))
+		defer server.Close()
+
+		api, err := newBlockExplorerAPI(explorer(explorerTypeSourcify, "", "url"), secret(server.URL), 11155111)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.checkHealth(ctx)).To(Succeed())
+		abi, err := api.contractABI(ctx, common.HexToAddress("0x2"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(ContainSubstring(`"name":"value"`))
+		_, err = api.transaction(ctx, common.HexToHash("0xaa"))
+		Expect(err).To(MatchError(ContainSubstring("not supported")))
+	})
+
+	It("checks again right away when its Secret changes", func() {
+		checks := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			checks++
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x5"}`)
+		}))
+		defer server.Close()
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		blockExplorer := explorer(explorerTypeBlockscout, "", "url")
+		blockExplorer.Namespace = "default"
+		explorerSecret := secret(server.URL)
+		explorerSecret.ObjectMeta = metav1.ObjectMeta{Name: "explorer", Namespace: "default"}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(blockExplorer, explorerSecret).
+			WithStatusSubresource(&kontractdeployerv1alpha1.BlockExplorer{}).Build()
+		r := &BlockExplorerReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "explorer", Namespace: "default"}}
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks).To(Equal(1))
+		result, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(checks).To(Equal(1))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(explorerSecret), explorerSecret)).To(Succeed())
+		explorerSecret.Data["url"] = []byte(server.URL + "/")
+		Expect(c.Update(ctx, explorerSecret)).To(Succeed())
+		_, err = r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks).To(Equal(2))
+
+		Expect(c.Get(ctx, request.NamespacedName, blockExplorer)).To(Succeed())
+		Expect(blockExplorer.Status.SecretVersions).To(HaveKeyWithValue("explorer", explorerSecret.ResourceVersion))
+	})
+
+	It("passes the verifier of the explorer type to deploy Jobs", func() {
+		envValue := func(envVars []corev1.EnvVar, name string) string {
+			for _, env := range envVars {
+				if env.Name == name {
+					return env.Value
+				}
+			}
+			return ""
+		}
+
+		env, err := blockExplorerJobEnv(explorer(explorerTypeRoutescan, "", "url"), secret("https://api.routescan.io/v2/network/mainnet/evm/43114/etherscan"), 43114)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envValue(env, "VERIFIER")).To(Equal("etherscan"))
+		Expect(envValue(env, "VERIFIER_URL")).To(Equal("https://api.routescan.io/v2/network/mainnet/evm/43114/etherscan"))
+		Expect(envValue(env, "ETHERSCAN_API_KEY")).To(Equal(routescanVerifierKey))
+
+		env, err = blockExplorerJobEnv(explorer(explorerTypeEtherscanV2, "token", ""), secret(""), 137)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envValue(env, "VERIFIER_URL")).To(Equal(etherscanV2URL + "?chainid=137"))
+		Expect(env).To(ContainElement(HaveField("ValueFrom.SecretKeyRef.Key", "token")))
+
+		env, err = blockExplorerJobEnv(explorer(explorerTypeSourcify, "", ""), secret(""), 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envValue(env, "VERIFIER")).To(Equal("sourcify"))
+		Expect(envValue(env, "VERIFIER_URL")).To(Equal(sourcifyURL))
+	})
+})
@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// encodeFunctionCall encodes a call of a function given by its signature (e.g., transfer(address,uint256))
+// A plain function name is treated as a function without parameters
+func encodeFunctionCall(signature string, parameters []kontractdeployerv1alpha1.ActionParameter) ([]byte, error) {
+	signature = strings.ReplaceAll(signature, " ", "")
+	if !strings.Contains(signature, "(") {
+		signature += "()"
+	}
+	open := strings.Index(signature, "(")
+	if open == 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("invalid function signature %q", signature)
+	}
+
+	var typeNames []string
+	if inner := signature[open+1 : len(signature)-1]; inner != "" {
+		typeNames = strings.Split(inner, ",")
+	}
+	if len(typeNames) != len(parameters) {
+		return nil, fmt.Errorf("function %s expects %d parameters, got %d", signature, len(typeNames), len(parameters))
+	}
+
+	arguments := make(abi.Arguments, 0, len(typeNames))
+	values := make([]interface{}, 0, len(typeNames))
+	for i, typeName := range typeNames {
+		typ, err := abi.NewType(typeName, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported parameter type %q: %w", typeName, err)
+		}
+		value, err := parseABIValue(typ, parameters[i].Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for parameter %s: %w", parameters[i].Name, err)
+		}
+		arguments = append(arguments, abi.Argument{Type: typ})
+		values = append(values, value)
+	}
+
+	encoded, err := arguments.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode parameters of %s: %w", signature, err)
+	}
+	selector := crypto.Keccak256([]byte(signature))[:4]
+	return append(selector, encoded...), nil
+}
+
+// parseABIValue converts the string form of a parameter into the Go value the ABI encoder expects
+func parseABIValue(typ abi.Type, value string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	switch typ.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(value) {
+			return nil, fmt.Errorf("%q is not an address", value)
+		}
+		return common.HexToAddress(value), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(value)
+	case abi.StringTy:
+		return value, nil
+	case abi.BytesTy:
+		return hexutil.Decode(value)
+	case abi.FixedBytesTy:
+		decoded, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(decoded) != typ.Size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", typ.Size, len(decoded))
+		}
+		fixed := reflect.New(typ.GetType()).Elem()
+		reflect.Copy(fixed, reflect.ValueOf(decoded))
+		return fixed.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		number, ok := new(big.Int).SetString(value, 0)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		if typ.T == abi.UintTy && number.Sign() < 0 {
+			return nil, fmt.Errorf("%q is negative", value)
+		}
+		if number.BitLen() > typ.Size {
+			return nil, fmt.Errorf("%q does not fit in %s", value, typ.String())
+		}
+		// Sizes up to 64 bits are encoded from the matching Go integer types
+		goType := typ.GetType()
+		if goType == reflect.TypeOf(&big.Int{}) {
+			return number, nil
+		}
+		converted := reflect.New(goType).Elem()
+		if typ.T == abi.UintTy {
+			converted.SetUint(number.Uint64())
+		} else {
+			converted.SetInt(number.Int64())
+		}
+		return converted.Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported parameter type %s", typ.String())
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"time"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// chainInfo describes a chain the operator knows
+type chainInfo struct {
+	name             string
+	aliases          []string
+	chainID          int64
+	currencySymbol   string
+	currencyDecimals int32
+	explorerType     string
+	eip1559          bool
+	blockTime        time.Duration
+	finalityDepth    int64
+}
+
+// chainCatalog holds the built-in presets for the networkName of a Network
+// Finality depths are conservative confirmation counts, not protocol finality guarantees
+var chainCatalog = []chainInfo{
+	{name: "ethereum", aliases: []string{"EthereumMainnet", "mainnet", "eth"}, chainID: 1, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 12 * time.Second, finalityDepth: 64},
+	{name: "sepolia", aliases: []string{"EthereumSepolia"}, chainID: 11155111, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 12 * time.Second, finalityDepth: 64},
+	{name: "holesky", aliases: []string{"EthereumHolesky"}, chainID: 17000, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 12 * time.Second, finalityDepth: 64},
+	{name: "base", aliases: []string{"BaseMainnet"}, chainID: 8453, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 30},
+	{name: "base-sepolia", chainID: 84532, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 30},
+	{name: "optimism", aliases: []string{"OptimismMainnet", "op-mainnet"}, chainID: 10, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 30},
+	{name: "optimism-sepolia", aliases: []string{"op-sepolia"}, chainID: 11155420, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 30},
+	{name: "arbitrum", aliases: []string{"ArbitrumOne", "arbitrum-one"}, chainID: 42161, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 250 * time.Millisecond, finalityDepth: 240},
+	{name: "arbitrum-sepolia", chainID: 421614, currencySymbol: "ETH", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 250 * time.Millisecond, finalityDepth: 240},
+	{name: "polygon", aliases: []string{"PolygonMainnet", "matic"}, chainID: 137, currencySymbol: "POL", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 32},
+	{name: "polygon-amoy", aliases: []string{"amoy"}, chainID: 80002, currencySymbol: "POL", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 32},
+	{name: "bnb", aliases: []string{"bsc", "BNBSmartChain"}, chainID: 56, currencySymbol: "BNB", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: false, blockTime: 3 * time.Second, finalityDepth: 15},
+	{name: "bnb-testnet", aliases: []string{"bsc-testnet"}, chainID: 97, currencySymbol: "tBNB", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: false, blockTime: 3 * time.Second, finalityDepth: 15},
+	{name: "avalanche", aliases: []string{"AvalancheCChain", "avax"}, chainID: 43114, currencySymbol: "AVAX", currencyDecimals: 18, explorerType: explorerTypeRoutescan, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 1},
+	{name: "avalanche-fuji", aliases: []string{"fuji"}, chainID: 43113, currencySymbol: "AVAX", currencyDecimals: 18, explorerType: explorerTypeRoutescan, eip1559: true, blockTime: 2 * time.Second, finalityDepth: 1},
+	{name: "gnosis", aliases: []string{"GnosisChain", "xdai"}, chainID: 100, currencySymbol: "xDAI", currencyDecimals: 18, explorerType: explorerTypeEtherscanV2, eip1559: true, blockTime: 5 * time.Second, finalityDepth: 32},
+	{name: "gnosis-chiado", aliases: []string{"chiado"}, chainID: 10200, currencySymbol: "xDAI", currencyDecimals: 18, explorerType: explorerTypeBlockscout, eip1559: true, blockTime: 5 * time.Second, finalityDepth: 32},
+	{name: anvilNetworkName, chainID: 31337, currencySymbol: "ETH", currencyDecimals: 18, eip1559: true},
+}
+
+// catalogKey normalizes a network name, so "base-sepolia", "Base Sepolia" and "BaseSepolia" match
+func catalogKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// lookupChain returns the catalog entry of a network name
+func lookupChain(networkName string) (chainInfo, bool) {
+	key := catalogKey(networkName)
+	for _, chain := range chainCatalog {
+		if catalogKey(chain.name) == key {
+			return chain, true
+		}
+		for _, alias := range chain.aliases {
+			if catalogKey(alias) == key {
+				return chain, true
+			}
+		}
+	}
+	return chainInfo{}, false
+}
+
+// networkChain returns the chain properties of a Network: its catalog entry with the overrides of its spec applied
+func networkChain(network *kontractdeployerv1alpha1.Network) kontractdeployerv1alpha1.ChainStatus {
+	chain := kontractdeployerv1alpha1.ChainStatus{CurrencyDecimals: 18}
+	if info, ok := lookupChain(network.Spec.NetworkName); ok {
+		chain = kontractdeployerv1alpha1.ChainStatus{
+			Catalog:          info.name,
+			ChainID:          info.chainID,
+			CurrencySymbol:   info.currencySymbol,
+			CurrencyDecimals: info.currencyDecimals,
+			ExplorerType:     info.explorerType,
+			EIP1559:          info.eip1559,
+			BlockTimeMillis:  info.blockTime.Milliseconds(),
+			FinalityDepth:    info.finalityDepth,
+		}
+	}
+
+	if network.Spec.ChainID != 0 {
+		chain.ChainID = int64(network.Spec.ChainID)
+	}
+	override := network.Spec.Chain
+	if override == nil {
+		return chain
+	}
+	if override.CurrencySymbol != "" {
+		chain.CurrencySymbol = override.CurrencySymbol
+	}
+	if override.CurrencyDecimals != nil {
+		chain.CurrencyDecimals = *override.CurrencyDecimals
+	}
+	if override.ExplorerType != "" {
+		chain.ExplorerType = override.ExplorerType
+	}
+	if override.EIP1559 != nil {
+		chain.EIP1559 = *override.EIP1559
+	}
+	if override.BlockTimeMillis != nil {
+		chain.BlockTimeMillis = *override.BlockTimeMillis
+	}
+	if override.FinalityDepth != nil {
+		chain.FinalityDepth = *override.FinalityDepth
+	}
+	return chain
+}
+
+// networkChainID returns the chain ID of a Network, from its spec or its catalog entry
+// It is 0 when neither sets one
+func networkChainID(network *kontractdeployerv1alpha1.Network) int64 {
+	return networkChain(network).ChainID
+}
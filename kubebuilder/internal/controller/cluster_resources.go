@@ -0,0 +1,164 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// DefaultClusterResourceNamespace is the namespace of the resources that serve cluster-scoped resources, unless the manager sets another
+	DefaultClusterResourceNamespace = "kontract-system"
+
+	// clusterNetworkLabel marks the Network that serves a ClusterNetwork
+	clusterNetworkLabel = "kontract.expedio.xyz/cluster-network"
+
+	// clusterRPCProviderLabel marks the RPCProvider that serves a ClusterRPCProvider
+	clusterRPCProviderLabel = "kontract.expedio.xyz/cluster-rpcprovider"
+
+	// sharedSecretLabel marks a copy of a Secret of another namespace, which earlier versions made for deploy Jobs
+	sharedSecretLabel = "kontract.expedio.xyz/shared-secret"
+)
+
+// ClusterResourceNamespace holds the Networks and RPCProviders that serve ClusterNetworks and ClusterRPCProviders,
+// and the Secrets and BlockExplorers they reference
+var ClusterResourceNamespace = DefaultClusterResourceNamespace
+
+// getNetwork fetches the Network a resource in namespace references by name.
+// Without a Network of that name in the namespace, it returns the Network that serves the ClusterNetwork of that name,
+// provided the ClusterNetwork allows the namespace.
+func getNetwork(ctx context.Context, c client.Client, namespace, name string) (*kontractdeployerv1alpha1.Network, error) {
+	network := &kontractdeployerv1alpha1.Network{}
+	err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, network)
+	if !apierrors.IsNotFound(err) {
+		return network, err
+	}
+
+	clusterNetwork := &kontractdeployerv1alpha1.ClusterNetwork{}
+	if clusterErr := c.Get(ctx, client.ObjectKey{Name: name}, clusterNetwork); clusterErr != nil {
+		if apierrors.IsNotFound(clusterErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get ClusterNetwork %s: %w", name, clusterErr)
+	}
+	allowed, err := namespaceAllowed(ctx, c, clusterNetwork.Spec.AllowedNamespaces, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("ClusterNetwork %s does not allow namespace %s", name, namespace)
+	}
+
+	if err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: ClusterResourceNamespace}, network); err != nil {
+		return nil, fmt.Errorf("failed to get Network of ClusterNetwork %s: %w", name, err)
+	}
+	return network, nil
+}
+
+// getRPCProvider fetches the RPCProvider a Network in namespace references by name.
+// Without an RPCProvider of that name in the namespace, it returns the RPCProvider that serves the ClusterRPCProvider of that name,
+// provided the ClusterRPCProvider allows the namespace.
+func getRPCProvider(ctx context.Context, c client.Client, namespace, name string) (*kontractdeployerv1alpha1.RPCProvider, error) {
+	rpcProvider := &kontractdeployerv1alpha1.RPCProvider{}
+	err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, rpcProvider)
+	if !apierrors.IsNotFound(err) {
+		return rpcProvider, err
+	}
+
+	clusterRPCProvider := &kontractdeployerv1alpha1.ClusterRPCProvider{}
+	if clusterErr := c.Get(ctx, client.ObjectKey{Name: name}, clusterRPCProvider); clusterErr != nil {
+		if apierrors.IsNotFound(clusterErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get ClusterRPCProvider %s: %w", name, clusterErr)
+	}
+	allowed, err := namespaceAllowed(ctx, c, clusterRPCProvider.Spec.AllowedNamespaces, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("ClusterRPCProvider %s does not allow namespace %s", name, namespace)
+	}
+
+	if err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: ClusterResourceNamespace}, rpcProvider); err != nil {
+		return nil, fmt.Errorf("failed to get RPCProvider of ClusterRPCProvider %s: %w", name, err)
+	}
+	return rpcProvider, nil
+}
+
+// namespaceAllowed reports whether a cluster-scoped resource with the allowlist may be referenced from a namespace
+func namespaceAllowed(ctx context.Context, c client.Client, allowed *kontractdeployerv1alpha1.AllowedNamespaces, namespace string) (bool, error) {
+	if allowed == nil {
+		return false, nil
+	}
+	if containsString(allowed.Names, namespace) {
+		return true, nil
+	}
+	if allowed.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(allowed.Selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return false, fmt.Errorf("failed to get Namespace %s: %w", namespace, err)
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// claimServingObject makes owner the controller of an object it serves, such as the Network of a ClusterNetwork.
+// It refuses to take over an existing object that owner does not control already.
+func claimServingObject(owner, object client.Object, scheme *runtime.Scheme) error {
+	if object.GetResourceVersion() != "" {
+		if ref := metav1.GetControllerOf(object); ref == nil || ref.UID != owner.GetUID() {
+			return fmt.Errorf("%s/%s already exists and is not managed by %s", object.GetNamespace(), object.GetName(), owner.GetName())
+		}
+	}
+	return controllerutil.SetControllerReference(owner, object, scheme)
+}
+
+// removeSharedSecrets deletes the copies of Secrets of other namespaces that earlier versions made for owner.
+// Deploy Jobs reach those credentials through the deploy gateway instead.
+func removeSharedSecrets(ctx context.Context, c client.Client, owner client.Object) error {
+	secrets := &corev1.SecretList{}
+	if err := c.List(ctx, secrets, client.InNamespace(owner.GetNamespace()), client.HasLabels{sharedSecretLabel}); err != nil {
+		return fmt.Errorf("failed to list shared Secrets: %w", err)
+	}
+	for i := range secrets.Items {
+		if !metav1.IsControlledBy(&secrets.Items[i], owner) {
+			continue
+		}
+		if err := c.Delete(ctx, &secrets.Items[i]); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete shared Secret %s: %w", secrets.Items[i].Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// ClusterNetworkReconciler reconciles a ClusterNetwork object
+// Each ClusterNetwork is served by a Network of the same name in the cluster resource namespace,
+// which the Network controller reconciles like any other Network.
+type ClusterNetworkReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=clusternetworks,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=clusternetworks/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=clusternetworks/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=networks,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Reconcile keeps the serving Network in line with the ClusterNetwork and reports its status on the ClusterNetwork
+func (r *ClusterNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	clusterNetwork := &kontractdeployerv1alpha1.ClusterNetwork{}
+	if err := r.Get(ctx, req.NamespacedName, clusterNetwork); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !clusterNetwork.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// The serving Network expires at the time the ClusterNetwork does, after which the ClusterNetwork is deleted as well
+	expiry, err := networkExpiry(&kontractdeployerv1alpha1.Network{ObjectMeta: clusterNetwork.ObjectMeta, Spec: clusterNetwork.Spec.NetworkSpec})
+	if err != nil {
+		r.EventRecorder.Event(clusterNetwork, corev1.EventTypeWarning, "InvalidTTLExtension", err.Error())
+	}
+	if expiry != nil && !time.Now().Before(*expiry) {
+		r.EventRecorder.Event(clusterNetwork, corev1.EventTypeNormal, "NetworkExpired",
+			fmt.Sprintf("ClusterNetwork expired at %s and is being deleted", expiry.UTC().Format(time.RFC3339)))
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, clusterNetwork))
+	}
+
+	network := &kontractdeployerv1alpha1.Network{ObjectMeta: metav1.ObjectMeta{Name: clusterNetwork.Name, Namespace: ClusterResourceNamespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, network, func() error {
+		if err := claimServingObject(clusterNetwork, network, r.Scheme); err != nil {
+			return err
+		}
+		if network.Labels == nil {
+			network.Labels = map[string]string{}
+		}
+		network.Labels[clusterNetworkLabel] = clusterNetwork.Name
+		network.Spec = *clusterNetwork.Spec.NetworkSpec.DeepCopy()
+		network.Spec.TTL = nil
+		network.Spec.ExpiresAt = nil
+		if expiry != nil {
+			network.Spec.ExpiresAt = &metav1.Time{Time: *expiry}
+		}
+		return nil
+	}); err != nil {
+		logger.Error(err, "failed to reconcile Network of ClusterNetwork")
+		r.EventRecorder.Event(clusterNetwork, corev1.EventTypeWarning, "NetworkReconcileFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	clusterNetwork.Status.NetworkStatus = network.Status
+	clusterNetwork.Status.Network = ClusterResourceNamespace + "/" + network.Name
+	if err := r.Status().Update(ctx, clusterNetwork); err != nil {
+		logger.Error(err, "unable to update ClusterNetwork status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.EventRecorder = mgr.GetEventRecorderFor("clusternetwork-controller")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kontractdeployerv1alpha1.ClusterNetwork{}).
+		Owns(&kontractdeployerv1alpha1.Network{}).
+		Complete(r)
+}
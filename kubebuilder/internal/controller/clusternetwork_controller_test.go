@@ -0,0 +1,164 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+var _ = Describe("Cluster networks", func() {
+	ctx := context.Background()
+
+	newScheme := func() *runtime.Scheme {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return scheme
+	}
+	newClusterNetwork := func(allowed *kontractdeployerv1alpha1.AllowedNamespaces) *kontractdeployerv1alpha1.ClusterNetwork {
+		return &kontractdeployerv1alpha1.ClusterNetwork{
+			ObjectMeta: metav1.ObjectMeta{Name: "sepolia", UID: "cluster-network-uid", CreationTimestamp: metav1.Now()},
+			Spec: kontractdeployerv1alpha1.ClusterNetworkSpec{
+				NetworkSpec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: "sepolia", ChainID: 11155111, RPCProviderRef: corev1.LocalObjectReference{Name: "infura"}},
+				AllowedNamespaces: allowed,
+			},
+		}
+	}
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	servingNetwork := &kontractdeployerv1alpha1.Network{
+		ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: ClusterResourceNamespace},
+		Spec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: "sepolia", ChainID: 11155111},
+	}
+
+	It("allows namespaces by name or label selector and denies all without an allowlist", func() {
+		c := fake.NewClientBuilder().WithScheme(newScheme()).WithObjects(
+			newNamespace("team-a", map[string]string{"kontract.expedio.xyz/networks": "shared"}),
+			newNamespace("team-b", nil),
+		).Build()
+
+		Expect(namespaceAllowed(ctx, c, nil, "team-a")).To(BeFalse())
+		Expect(namespaceAllowed(ctx, c, &kontractdeployerv1alpha1.AllowedNamespaces{Names: []string{"team-b"}}, "team-b")).To(BeTrue())
+		Expect(namespaceAllowed(ctx, c, &kontractdeployerv1alpha1.AllowedNamespaces{Names: []string{"team-b"}}, "team-a")).To(BeFalse())
+
+		selector := &kontractdeployerv1alpha1.AllowedNamespaces{Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"kontract.expedio.xyz/networks": "shared"},
+		}}
+		Expect(namespaceAllowed(ctx, c, selector, "team-a")).To(BeTrue())
+		Expect(namespaceAllowed(ctx, c, selector, "team-b")).To(BeFalse())
+
+		// An empty selector allows every namespace
+		Expect(namespaceAllowed(ctx, c, &kontractdeployerv1alpha1.AllowedNamespaces{Selector: &metav1.LabelSelector{}}, "team-b")).To(BeTrue())
+	})
+
+	It("resolves Network references to the serving Network of an allowing ClusterNetwork", func() {
+		local := &kontractdeployerv1alpha1.Network{
+			ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "team-c"},
+			Spec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: "local-sepolia", ChainID: 11155111},
+		}
+		c := fake.NewClientBuilder().WithScheme(newScheme()).WithObjects(
+			newClusterNetwork(&kontractdeployerv1alpha1.AllowedNamespaces{Names: []string{"team-a", "team-c"}}),
+			servingNetwork.DeepCopy(), local, newNamespace("team-b", nil),
+		).Build()
+
+		network, err := getNetwork(ctx, c, "team-a", "sepolia")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(network.Namespace).To(Equal(ClusterResourceNamespace))
+
+		// A Network of the namespace takes precedence over the ClusterNetwork
+		network, err = getNetwork(ctx, c, "team-c", "sepolia")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(network.Spec.NetworkName).To(Equal("local-sepolia"))
+
+		_, err = getNetwork(ctx, c, "team-b", "sepolia")
+		Expect(err).To(MatchError(ContainSubstring("does not allow namespace team-b")))
+
+		_, err = getNetwork(ctx, c, "team-a", "mainnet")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("creates the serving Network and mirrors its status", func() {
+		scheme := newScheme()
+		clusterNetwork := newClusterNetwork(nil)
+		clusterNetwork.Spec.TTL = &metav1.Duration{Duration: time.Hour}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusterNetwork).
+			WithStatusSubresource(&kontractdeployerv1alpha1.ClusterNetwork{}).Build()
+		r := &ClusterNetworkReconciler{Client: c, Scheme: scheme, EventRecorder: record.NewFakeRecorder(10)}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "sepolia"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		network := &kontractdeployerv1alpha1.Network{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "sepolia", Namespace: ClusterResourceNamespace}, network)).To(Succeed())
+		Expect(network.Labels).To(HaveKeyWithValue(clusterNetworkLabel, "sepolia"))
+		Expect(network.Spec.RPCProviderRef.Name).To(Equal("infura"))
+		Expect(network.Spec.TTL).To(BeNil())
+		Expect(network.Spec.ExpiresAt).NotTo(BeNil())
+		Expect(network.Spec.ExpiresAt.Time).To(BeTemporally("~", time.Now().Add(time.Hour), 2*time.Second))
+		Expect(metav1.IsControlledBy(network, clusterNetwork)).To(BeTrue())
+
+		Expect(c.Get(ctx, client.ObjectKey{Name: "sepolia"}, clusterNetwork)).To(Succeed())
+		Expect(clusterNetwork.Status.Network).To(Equal(ClusterResourceNamespace + "/sepolia"))
+	})
+
+	It("refuses to take over a Network it does not manage", func() {
+		scheme := newScheme()
+		recorder := record.NewFakeRecorder(10)
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newClusterNetwork(nil), servingNetwork.DeepCopy()).
+			WithStatusSubresource(&kontractdeployerv1alpha1.ClusterNetwork{}).Build()
+		r := &ClusterNetworkReconciler{Client: c, Scheme: scheme, EventRecorder: recorder}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "sepolia"}})
+		Expect(err).To(MatchError(ContainSubstring("is not managed by sepolia")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("NetworkReconcileFailed")))
+
+		network := &kontractdeployerv1alpha1.Network{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "sepolia", Namespace: ClusterResourceNamespace}, network)).To(Succeed())
+		Expect(network.Spec.RPCProviderRef.Name).To(BeEmpty())
+	})
+
+	It("deletes an expired ClusterNetwork", func() {
+		scheme := newScheme()
+		clusterNetwork := newClusterNetwork(nil)
+		clusterNetwork.Spec.ExpiresAt = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusterNetwork).Build()
+		r := &ClusterNetworkReconciler{Client: c, Scheme: scheme, EventRecorder: record.NewFakeRecorder(10)}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "sepolia"}})
+		Expect(err).NotTo(HaveOccurred())
+		err = c.Get(ctx, client.ObjectKey{Name: "sepolia"}, &kontractdeployerv1alpha1.ClusterNetwork{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
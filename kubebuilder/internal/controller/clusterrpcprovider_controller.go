@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// ClusterRPCProviderReconciler reconciles a ClusterRPCProvider object
+// Each ClusterRPCProvider is served by an RPCProvider of the same name in the cluster resource namespace,
+// which the RPCProvider controller health checks like any other RPCProvider.
+type ClusterRPCProviderReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=clusterrpcproviders,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=clusterrpcproviders/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=clusterrpcproviders/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=rpcproviders,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Reconcile keeps the serving RPCProvider in line with the ClusterRPCProvider and reports its status on the ClusterRPCProvider
+func (r *ClusterRPCProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	clusterRPCProvider := &kontractdeployerv1alpha1.ClusterRPCProvider{}
+	if err := r.Get(ctx, req.NamespacedName, clusterRPCProvider); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !clusterRPCProvider.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	rpcProvider := &kontractdeployerv1alpha1.RPCProvider{ObjectMeta: metav1.ObjectMeta{Name: clusterRPCProvider.Name, Namespace: ClusterResourceNamespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, rpcProvider, func() error {
+		if err := claimServingObject(clusterRPCProvider, rpcProvider, r.Scheme); err != nil {
+			return err
+		}
+		if rpcProvider.Labels == nil {
+			rpcProvider.Labels = map[string]string{}
+		}
+		rpcProvider.Labels[clusterRPCProviderLabel] = clusterRPCProvider.Name
+		rpcProvider.Spec = *clusterRPCProvider.Spec.RPCProviderSpec.DeepCopy()
+		return nil
+	}); err != nil {
+		logger.Error(err, "failed to reconcile RPCProvider of ClusterRPCProvider")
+		r.EventRecorder.Event(clusterRPCProvider, corev1.EventTypeWarning, "RPCProviderReconcileFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	clusterRPCProvider.Status.RPCProviderStatus = rpcProvider.Status
+	clusterRPCProvider.Status.RPCProvider = ClusterResourceNamespace + "/" + rpcProvider.Name
+	if err := r.Status().Update(ctx, clusterRPCProvider); err != nil {
+		logger.Error(err, "unable to update ClusterRPCProvider status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterRPCProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.EventRecorder = mgr.GetEventRecorderFor("clusterrpcprovider-controller")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kontractdeployerv1alpha1.ClusterRPCProvider{}).
+		Owns(&kontractdeployerv1alpha1.RPCProvider{}).
+		Complete(r)
+}
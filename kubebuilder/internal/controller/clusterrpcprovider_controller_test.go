@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+var _ = Describe("Cluster RPC providers", func() {
+	ctx := context.Background()
+
+	newScheme := func() *runtime.Scheme {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return scheme
+	}
+	providerSpec := kontractdeployerv1alpha1.RPCProviderSpec{
+		ProviderName: "infura",
+		SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "infura-rpc", URLKey: "url"},
+	}
+	newClusterRPCProvider := func() *kontractdeployerv1alpha1.ClusterRPCProvider {
+		return &kontractdeployerv1alpha1.ClusterRPCProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "infura", UID: "cluster-rpcprovider-uid"},
+			Spec: kontractdeployerv1alpha1.ClusterRPCProviderSpec{
+				RPCProviderSpec:   providerSpec,
+				AllowedNamespaces: &kontractdeployerv1alpha1.AllowedNamespaces{Names: []string{"team-a"}},
+			},
+		}
+	}
+	providerSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "infura-rpc", Namespace: ClusterResourceNamespace},
+		Data:       map[string][]byte{"url": []byte("https://sepolia.infura.io/v3/key")},
+	}
+
+	It("creates the serving RPCProvider and mirrors its status", func() {
+		scheme := newScheme()
+		clusterRPCProvider := newClusterRPCProvider()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusterRPCProvider).
+			WithStatusSubresource(&kontractdeployerv1alpha1.ClusterRPCProvider{}).Build()
+		r := &ClusterRPCProviderReconciler{Client: c, Scheme: scheme, EventRecorder: record.NewFakeRecorder(10)}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "infura"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		rpcProvider := &kontractdeployerv1alpha1.RPCProvider{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "infura", Namespace: ClusterResourceNamespace}, rpcProvider)).To(Succeed())
+		Expect(rpcProvider.Labels).To(HaveKeyWithValue(clusterRPCProviderLabel, "infura"))
+		Expect(rpcProvider.Spec.SecretRef.Name).To(Equal("infura-rpc"))
+		Expect(metav1.IsControlledBy(rpcProvider, clusterRPCProvider)).To(BeTrue())
+
+		Expect(c.Get(ctx, client.ObjectKey{Name: "infura"}, clusterRPCProvider)).To(Succeed())
+		Expect(clusterRPCProvider.Status.RPCProvider).To(Equal(ClusterResourceNamespace + "/infura"))
+	})
+
+	It("resolves endpoints of an allowing ClusterRPCProvider with the Secrets of the cluster resource namespace", func() {
+		rpcProvider := &kontractdeployerv1alpha1.RPCProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "infura", Namespace: ClusterResourceNamespace},
+			Spec:       providerSpec,
+		}
+		c := fake.NewClientBuilder().WithScheme(newScheme()).WithObjects(newClusterRPCProvider(), rpcProvider, providerSecret.DeepCopy()).Build()
+
+		endpoints, err := resolveEndpoints(ctx, c, "team-a", []string{"infura"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(endpoints).To(HaveLen(1))
+		Expect(endpoints[0].namespace).To(Equal(ClusterResourceNamespace))
+		Expect(endpoints[0].conn.url).To(Equal("https://sepolia.infura.io/v3/key"))
+
+		_, err = resolveEndpoints(ctx, c, "team-b", []string{"infura"})
+		Expect(err).To(MatchError(ContainSubstring("does not allow namespace team-b")))
+	})
+
+	It("removes the Secrets that earlier versions copied for a consumer", func() {
+		owner := &kontractdeployerv1alpha1.ContractVersion{ObjectMeta: metav1.ObjectMeta{Name: "token-v1", Namespace: "team-a", UID: "contract-version-uid"}}
+		shared := func(name string, controlled bool) *corev1.Secret {
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "team-a",
+				Labels:    map[string]string{sharedSecretLabel: "infura-rpc"},
+			}}
+			if controlled {
+				secret.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(owner, kontractdeployerv1alpha1.GroupVersion.WithKind("ContractVersion"))}
+			}
+			return secret
+		}
+		c := fake.NewClientBuilder().WithScheme(newScheme()).WithObjects(shared("token-v1-infura-rpc", true), shared("other-infura-rpc", false)).Build()
+
+		Expect(removeSharedSecrets(ctx, c, owner)).To(Succeed())
+		secrets := &corev1.SecretList{}
+		Expect(c.List(ctx, secrets, client.InNamespace("team-a"))).To(Succeed())
+		Expect(secrets.Items).To(HaveLen(1))
+		Expect(secrets.Items[0].Name).To(Equal("other-infura-rpc"))
+	})
+})
@@ -18,11 +18,16 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -30,11 +35,21 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
 )
 
+const (
+	// sourceHashAnnotation records on a ContractVersion the hash of the inputs it was created from
+	sourceHashAnnotation = "kontract.expedio.xyz/source-hash"
+
+	// versionHashLength is the number of hex digits of the input hash in the name of a ContractVersion
+	versionHashLength = 10
+)
+
 // ContractReconciler reconciles a Contract object
 type ContractReconciler struct {
 	client.Client
@@ -47,7 +62,8 @@ type ContractReconciler struct {
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contracts/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contracts/finalizers,verbs=update
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=referencegrants,verbs=get;list;watch
 
 func (r *ContractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -116,12 +132,51 @@ func (r *ContractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		}
 	}
 
-	// Iterate over each network reference and create a ContractVersion
+	// References to other namespaces need a ReferenceGrant there; no ContractVersion is created until they are permitted
+	refErr := r.checkReferences(ctx, contract)
+	resolvedRefs := resolvedRefsCondition(contract.Generation, refErr)
+	meta.SetStatusCondition(&contract.Status.Conditions, resolvedRefs)
+	if refErr != nil {
+		logger.Info("Contract references are not resolved", "Contract.Name", contract.Name, "error", refErr.Error())
+		r.EventRecorder.Event(contract, corev1.EventTypeWarning, resolvedRefs.Reason, refErr.Error())
+		if err := r.Status().Update(ctx, contract); err != nil {
+			logger.Error(err, "Failed to update Contract status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Versions are named after the hash of the inputs that affect the bytecode, so only Networks that are
+	// not on the current inputs yet get a new ContractVersion
+	sourceHash, err := r.sourceHash(ctx, contract, test, contract.Spec.ContractName, code, script, foundryConfig)
+	if err != nil {
+		logger.Error(err, "Failed to hash Contract sources")
+		return ctrl.Result{}, err
+	}
+
+	// Without redeployment on source changes, Networks stay on their version until the Contract is edited
+	keepVersions := contract.Spec.RedeployOnSourceChange != nil && !*contract.Spec.RedeployOnSourceChange &&
+		contract.Generation == contract.Status.ObservedGeneration
+	if keepVersions && sourceHash != contract.Status.SourceHash {
+		logger.Info("Contract sources changed, redeployment is disabled", "Contract.Name", contract.Name)
+	} else if sourceHash != contract.Status.SourceHash && contract.Status.SourceHash != "" {
+		r.EventRecorder.Event(contract, corev1.EventTypeNormal, "SourceChanged",
+			fmt.Sprintf("Inputs changed, creating version %s", contractVersionName(contract.Name, "", sourceHash)))
+	}
+
+	networks := make([]kontractdeployerv1alpha1.ContractNetworkStatus, 0, len(contract.Spec.NetworkRefs))
 	for _, networkRef := range contract.Spec.NetworkRefs {
+		current := r.networkVersion(ctx, contract, networkRef, sourceHash)
+		if current != nil && (current.SourceHash == sourceHash || keepVersions) {
+			networks = append(networks, *current)
+			continue
+		}
+
 		contractVersion := &kontractdeployerv1alpha1.ContractVersion{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("%s-%s-version-%d", contract.Name, networkRef, contract.Generation),
-				Namespace: req.Namespace,
+				Name:        contractVersionName(contract.Name, networkRef, sourceHash),
+				Namespace:   req.Namespace,
+				Annotations: map[string]string{sourceHashAnnotation: sourceHash},
 			},
 			Spec: kontractdeployerv1alpha1.ContractVersionSpec{
 				ContractName:    contract.Spec.ContractName,
@@ -143,7 +198,7 @@ func (r *ContractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 			return ctrl.Result{}, err
 		}
 
-		// Create the ContractVersion
+		// Create the ContractVersion; a version of earlier inputs that exists already is used again
 		if err := r.Create(ctx, contractVersion); err != nil {
 			if !errors.IsAlreadyExists(err) {
 				logger.Error(err, "Failed to create ContractVersion", "ContractVersion.Name", contractVersion.Name)
@@ -153,10 +208,20 @@ func (r *ContractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		} else {
 			r.EventRecorder.Event(contract, corev1.EventTypeNormal, "ContractVersionCreated", fmt.Sprintf("ContractVersion %s created successfully", contractVersion.Name))
 		}
+		networks = append(networks, kontractdeployerv1alpha1.ContractNetworkStatus{
+			Network:    networkRef,
+			Version:    contractVersion.Name,
+			SourceHash: sourceHash,
+		})
 	}
 
 	// Update the Contract status with the current version
-	contract.Status.CurrentVersion = fmt.Sprintf("%s-version-%d", contract.Name, contract.Generation)
+	if !keepVersions || contract.Status.SourceHash == "" {
+		contract.Status.SourceHash = sourceHash
+	}
+	contract.Status.CurrentVersion = contractVersionName(contract.Name, "", contract.Status.SourceHash)
+	contract.Status.ObservedGeneration = contract.Generation
+	contract.Status.Networks = networks
 	if err := r.Status().Update(ctx, contract); err != nil {
 		logger.Error(err, "Failed to update Contract status")
 		r.EventRecorder.Event(contract, corev1.EventTypeWarning, "StatusUpdateFailed", "Failed to update Contract status")
@@ -168,6 +233,112 @@ func (r *ContractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return ctrl.Result{}, nil
 }
 
+// checkReferences checks that the Wallet and Network references of a Contract are valid and permitted
+func (r *ContractReconciler) checkReferences(ctx context.Context, contract *kontractdeployerv1alpha1.Contract) error {
+	if _, err := referenceKey(ctx, r.Client, "Contract", contract.Namespace, "Wallet", contract.Spec.WalletRef); err != nil {
+		return err
+	}
+	for _, networkRef := range contract.Spec.NetworkRefs {
+		if _, err := referenceKey(ctx, r.Client, "Contract", contract.Namespace, "Network", networkRef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceHash returns the hash of the inputs of a Contract that affect the deployed bytecode: the resolved sources,
+// the content of its local modules, its external modules, its init params and the image that compiles it.
+// The resolved test is hashed as well, since a deployment only runs once its tests pass.
+func (r *ContractReconciler) sourceHash(ctx context.Context, contract *kontractdeployerv1alpha1.Contract, test string, sources ...string) (string, error) {
+	hash := sha256.New()
+	write := func(value string) {
+		// Each value is prefixed with its length, so that moving content between inputs changes the hash
+		fmt.Fprintf(hash, "%d:%s", len(value), value)
+	}
+	for _, source := range sources {
+		write(source)
+	}
+	write(foundryImage)
+	write(strings.Join(contract.Spec.ExternalModules, "\n"))
+	write(strings.Join(contract.Spec.InitParams, "\n"))
+	// Contracts without a test keep the hash of earlier releases, which did not hash tests
+	if test != "" {
+		write("test")
+		write(test)
+	}
+
+	for _, module := range contract.Spec.LocalModules {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: module.Name, Namespace: contract.Namespace}, configMap); err != nil {
+			return "", fmt.Errorf("failed to get LocalModule ConfigMap %s: %w", module.Name, err)
+		}
+		keys := make([]string, 0, len(configMap.Data))
+		for key := range configMap.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		write(module.Name)
+		for _, key := range keys {
+			write(key)
+			write(configMap.Data[key])
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// networkVersion returns the version a Network of a Contract is on, or nil if it has none yet.
+// A Contract without recorded Network versions adopts the ContractVersions named after its generation, as created
+// by earlier releases of the operator, assuming they were created from the current inputs.
+func (r *ContractReconciler) networkVersion(ctx context.Context, contract *kontractdeployerv1alpha1.Contract, networkRef, sourceHash string) *kontractdeployerv1alpha1.ContractNetworkStatus {
+	for i := range contract.Status.Networks {
+		if contract.Status.Networks[i].Network == networkRef {
+			return &contract.Status.Networks[i]
+		}
+	}
+	if contract.Status.Networks != nil || contract.Status.CurrentVersion == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s-%s-version-%d", contract.Name, strings.ReplaceAll(networkRef, "/", "-"), contract.Generation)
+	legacy := &kontractdeployerv1alpha1.ContractVersion{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: contract.Namespace}, legacy); err != nil || !metav1.IsControlledBy(legacy, contract) {
+		return nil
+	}
+	return &kontractdeployerv1alpha1.ContractNetworkStatus{Network: networkRef, Version: name, SourceHash: sourceHash}
+}
+
+// contractVersionName returns the name of the ContractVersion of a Contract on a Network for the hash of its inputs.
+// The slash of a reference to another namespace is replaced, as names cannot contain it; without a Network, it names
+// the version of the Contract as a whole.
+func contractVersionName(contractName, networkRef, sourceHash string) string {
+	version := sourceHash
+	if len(version) > versionHashLength {
+		version = version[:versionHashLength]
+	}
+	if networkRef == "" {
+		return fmt.Sprintf("%s-version-%s", contractName, version)
+	}
+	return fmt.Sprintf("%s-%s-version-%s", contractName, strings.ReplaceAll(networkRef, "/", "-"), version)
+}
+
+// contractsForReferenceGrant maps a ReferenceGrant to the Contracts it may permit to reference its namespace
+func (r *ContractReconciler) contractsForReferenceGrant(ctx context.Context, grant client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, namespace := range grantedNamespaces(grant, "Contract") {
+		var contracts kontractdeployerv1alpha1.ContractList
+		if err := r.List(ctx, &contracts, client.InNamespace(namespace)); err != nil {
+			log.FromContext(ctx).Error(err, "unable to list Contracts")
+			continue
+		}
+		for _, contract := range contracts.Items {
+			if referencesNamespace(grant.GetNamespace(), contract.Spec.WalletRef) || referencesNamespace(grant.GetNamespace(), contract.Spec.NetworkRefs...) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&contract)})
+			}
+		}
+	}
+	return requests
+}
+
 // getConfigMapData fetches the data from a ConfigMap based on the provided reference
 func (r *ContractReconciler) getConfigMapData(ctx context.Context, namespace string, ref *kontractdeployerv1alpha1.ConfigMapKeyReference) (string, error) {
 	if ref == nil {
@@ -192,5 +363,7 @@ func (r *ContractReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.EventRecorder = mgr.GetEventRecorderFor("contract-controller")
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.Contract{}).
+		Watches(&kontractdeployerv1alpha1.ReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(r.contractsForReferenceGrant)).
+		Watches(&corev1.ConfigMap{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.ContractList{}, configMapRefField)).
 		Complete(r)
 }
@@ -21,8 +21,17 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -82,3 +91,291 @@ var _ = Describe("Contract Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Reference grants", func() {
+	ctx := context.Background()
+
+	newScheme := func() *runtime.Scheme {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return scheme
+	}
+	treasuryGrant := func() *kontractdeployerv1alpha1.ReferenceGrant {
+		return &kontractdeployerv1alpha1.ReferenceGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-a", Namespace: "treasury"},
+			Spec: kontractdeployerv1alpha1.ReferenceGrantSpec{
+				From: []kontractdeployerv1alpha1.ReferenceGrantFrom{{Kind: "Contract", Namespace: "app-a"}},
+				To: []kontractdeployerv1alpha1.ReferenceGrantTo{
+					{Kind: "Wallet", Name: "deployer"},
+					{Kind: "Network"},
+				},
+			},
+		}
+	}
+
+	It("accepts references to other namespaces only when a grant permits them", func() {
+		c := fake.NewClientBuilder().WithScheme(newScheme()).WithObjects(treasuryGrant()).Build()
+
+		key, err := referenceKey(ctx, c, "Contract", "app-a", "Wallet", "local")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(client.ObjectKey{Name: "local", Namespace: "app-a"}))
+
+		key, err = referenceKey(ctx, c, "Contract", "app-a", "Wallet", "treasury/deployer")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(client.ObjectKey{Name: "deployer", Namespace: "treasury"}))
+		_, err = referenceKey(ctx, c, "Contract", "app-a", "Network", "treasury/sepolia")
+		Expect(err).NotTo(HaveOccurred())
+
+		// The grant names a single Wallet, other namespaces and other kinds
+		_, err = referenceKey(ctx, c, "Contract", "app-a", "Wallet", "treasury/hot")
+		Expect(isReferenceDenied(err)).To(BeTrue())
+		_, err = referenceKey(ctx, c, "Contract", "app-b", "Network", "treasury/sepolia")
+		Expect(isReferenceDenied(err)).To(BeTrue())
+		_, err = referenceKey(ctx, c, "ContractVersion", "app-a", "Network", "treasury/sepolia")
+		Expect(isReferenceDenied(err)).To(BeTrue())
+
+		_, err = referenceKey(ctx, c, "Contract", "app-a", "Wallet", "treasury/deployer/extra")
+		Expect(err).To(HaveOccurred())
+		Expect(isReferenceDenied(err)).To(BeFalse())
+	})
+
+	It("reports denied references as a condition and creates ContractVersions once they are granted", func() {
+		scheme := newScheme()
+		contract := &kontractdeployerv1alpha1.Contract{
+			ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "app-a", Generation: 1},
+			Spec: kontractdeployerv1alpha1.ContractSpec{
+				ContractName: "Token",
+				NetworkRefs:  []string{"treasury/sepolia"},
+				WalletRef:    "treasury/deployer",
+				Code:         "contract Token {}",
+			},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(contract).
+			WithStatusSubresource(&kontractdeployerv1alpha1.Contract{}).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &ContractReconciler{Client: c, Scheme: scheme, EventRecorder: recorder}
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "token", Namespace: "app-a"}}
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, request.NamespacedName, contract)).To(Succeed())
+		condition := meta.FindStatusCondition(contract.Status.Conditions, conditionResolvedRefs)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(reasonRefNotPermitted))
+		Expect(condition.Message).To(ContainSubstring("namespace treasury"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(reasonRefNotPermitted)))
+
+		var versions kontractdeployerv1alpha1.ContractVersionList
+		Expect(c.List(ctx, &versions)).To(Succeed())
+		Expect(versions.Items).To(BeEmpty())
+
+		grant := treasuryGrant()
+		Expect(c.Create(ctx, grant)).To(Succeed())
+		Expect(r.contractsForReferenceGrant(ctx, grant)).To(ConsistOf(request))
+
+		_, err = r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, request.NamespacedName, contract)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(contract.Status.Conditions, conditionResolvedRefs)).To(BeTrue())
+
+		Expect(contract.Status.Networks).To(HaveLen(1))
+		Expect(contract.Status.Networks[0].Version).To(HavePrefix("token-treasury-sepolia-version-"))
+		version := &kontractdeployerv1alpha1.ContractVersion{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: contract.Status.Networks[0].Version, Namespace: "app-a"}, version)).To(Succeed())
+		Expect(version.Spec.NetworkRef).To(Equal("treasury/sepolia"))
+		Expect(contractVersionReferrer(version)).To(Equal("Contract"))
+	})
+})
+
+var _ = Describe("Contract versions", func() {
+	ctx := context.Background()
+
+	newScheme := func() *runtime.Scheme {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return scheme
+	}
+	newContract := func() *kontractdeployerv1alpha1.Contract {
+		return &kontractdeployerv1alpha1.Contract{
+			ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default", Generation: 1},
+			Spec: kontractdeployerv1alpha1.ContractSpec{
+				ContractName: "Token",
+				NetworkRefs:  []string{"sepolia"},
+				WalletRef:    "deployer",
+				CodeRef:      &kontractdeployerv1alpha1.ConfigMapKeyReference{Name: "token-code", Key: "Token.sol"},
+				LocalModules: []kontractdeployerv1alpha1.ConfigMapReference{{Name: "token-lib"}},
+			},
+		}
+	}
+	newConfigMaps := func() []client.Object {
+		return []client.Object{
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "token-code", Namespace: "default"}, Data: map[string]string{"Token.sol": "contract Token {}"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "token-lib", Namespace: "default"}, Data: map[string]string{"Math.sol": "library Math {}"}},
+		}
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "token", Namespace: "default"}}
+	newReconciler := func(objects ...client.Object) (*ContractReconciler, client.Client, *record.FakeRecorder) {
+		scheme := newScheme()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(newConfigMaps(), objects...)...).
+			WithStatusSubresource(&kontractdeployerv1alpha1.Contract{}).Build()
+		recorder := record.NewFakeRecorder(20)
+		return &ContractReconciler{Client: c, Scheme: scheme, EventRecorder: recorder}, c, recorder
+	}
+	reconcileContract := func(r *ContractReconciler, c client.Client) *kontractdeployerv1alpha1.Contract {
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		contract := &kontractdeployerv1alpha1.Contract{}
+		Expect(c.Get(ctx, request.NamespacedName, contract)).To(Succeed())
+		return contract
+	}
+	editContract := func(c client.Client, edit func(contract *kontractdeployerv1alpha1.Contract)) {
+		contract := &kontractdeployerv1alpha1.Contract{}
+		Expect(c.Get(ctx, request.NamespacedName, contract)).To(Succeed())
+		edit(contract)
+		contract.Generation++
+		Expect(c.Update(ctx, contract)).To(Succeed())
+	}
+	updateConfigMap := func(c client.Client, name, key, value string) {
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, configMap)).To(Succeed())
+		configMap.Data[key] = value
+		Expect(c.Update(ctx, configMap)).To(Succeed())
+	}
+	versionNames := func(c client.Client) []string {
+		var versions kontractdeployerv1alpha1.ContractVersionList
+		Expect(c.List(ctx, &versions)).To(Succeed())
+		var names []string
+		for _, version := range versions.Items {
+			names = append(names, version.Name)
+		}
+		return names
+	}
+	networkVersions := func(contract *kontractdeployerv1alpha1.Contract) map[string]string {
+		versions := map[string]string{}
+		for _, network := range contract.Status.Networks {
+			versions[network.Network] = network.Version
+		}
+		return versions
+	}
+
+	It("names versions after the hash of the inputs and deploys only where they changed", func() {
+		r, c, _ := newReconciler(newContract())
+
+		contract := reconcileContract(r, c)
+		Expect(contract.Status.SourceHash).To(HaveLen(64))
+		first := contractVersionName("token", "sepolia", contract.Status.SourceHash)
+		Expect(first).To(Equal("token-sepolia-version-" + contract.Status.SourceHash[:versionHashLength]))
+		Expect(contract.Status.CurrentVersion).To(Equal("token-version-" + contract.Status.SourceHash[:versionHashLength]))
+		Expect(networkVersions(contract)).To(Equal(map[string]string{"sepolia": first}))
+
+		version := &kontractdeployerv1alpha1.ContractVersion{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: first, Namespace: "default"}, version)).To(Succeed())
+		Expect(version.Annotations).To(HaveKeyWithValue(sourceHashAnnotation, contract.Status.SourceHash))
+
+		// An edit that changes neither the bytecode nor the tests deploys nothing
+		editContract(c, func(contract *kontractdeployerv1alpha1.Contract) {
+			contract.Labels = map[string]string{"team": "tokens"}
+		})
+		reconcileContract(r, c)
+		Expect(versionNames(c)).To(ConsistOf(first))
+
+		// Adding a Network deploys only there
+		editContract(c, func(contract *kontractdeployerv1alpha1.Contract) {
+			contract.Spec.NetworkRefs = append(contract.Spec.NetworkRefs, "holesky")
+		})
+		contract = reconcileContract(r, c)
+		onHolesky := contractVersionName("token", "holesky", contract.Status.SourceHash)
+		Expect(versionNames(c)).To(ConsistOf(first, onHolesky))
+		Expect(networkVersions(contract)).To(Equal(map[string]string{"sepolia": first, "holesky": onHolesky}))
+
+		// Changing the init params redeploys everywhere
+		editContract(c, func(contract *kontractdeployerv1alpha1.Contract) { contract.Spec.InitParams = []string{"1000"} })
+		contract = reconcileContract(r, c)
+		Expect(versionNames(c)).To(HaveLen(4))
+		Expect(networkVersions(contract)["sepolia"]).NotTo(Equal(first))
+	})
+
+	It("creates new versions when a referenced ConfigMap changes", func() {
+		r, c, recorder := newReconciler(newContract())
+		first := reconcileContract(r, c).Status.SourceHash
+		for len(recorder.Events) > 0 {
+			<-recorder.Events
+		}
+
+		updateConfigMap(c, "token-lib", "Math.sol", "library Math { uint constant ONE = 1; }")
+		contract := reconcileContract(r, c)
+		Expect(contract.Status.SourceHash).NotTo(Equal(first))
+		Expect(recorder.Events).To(Receive(ContainSubstring("SourceChanged")))
+		Expect(versionNames(c)).To(ConsistOf(
+			contractVersionName("token", "sepolia", first),
+			contractVersionName("token", "sepolia", contract.Status.SourceHash),
+		))
+
+		// Going back to earlier inputs uses the existing version again
+		updateConfigMap(c, "token-lib", "Math.sol", "library Math {}")
+		contract = reconcileContract(r, c)
+		Expect(contract.Status.SourceHash).To(Equal(first))
+		Expect(versionNames(c)).To(HaveLen(2))
+		Expect(networkVersions(contract)["sepolia"]).To(Equal(contractVersionName("token", "sepolia", first)))
+	})
+
+	It("creates new versions when the test changes", func() {
+		contract := newContract()
+		r, c, _ := newReconciler(contract, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-test", Namespace: "default"},
+			Data:       map[string]string{"Token.t.sol": "contract TokenTest {}"},
+		})
+		withoutTest := reconcileContract(r, c).Status.SourceHash
+
+		editContract(c, func(contract *kontractdeployerv1alpha1.Contract) {
+			contract.Spec.TestRef = &kontractdeployerv1alpha1.ConfigMapKeyReference{Name: "token-test", Key: "Token.t.sol"}
+		})
+		withTest := reconcileContract(r, c).Status.SourceHash
+		Expect(withTest).NotTo(Equal(withoutTest))
+
+		updateConfigMap(c, "token-test", "Token.t.sol", "contract TokenTest { function testSupply() public {} }")
+		contract = reconcileContract(r, c)
+		Expect(contract.Status.SourceHash).NotTo(Equal(withTest))
+		version := &kontractdeployerv1alpha1.ContractVersion{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: networkVersions(contract)["sepolia"], Namespace: "default"}, version)).To(Succeed())
+		Expect(version.Spec.Test).To(ContainSubstring("testSupply"))
+	})
+
+	It("keeps the current versions on source changes when redeployment is disabled", func() {
+		contract := newContract()
+		contract.Spec.RedeployOnSourceChange = ptr.To(false)
+		r, c, _ := newReconciler(contract)
+		first := reconcileContract(r, c).Status.SourceHash
+
+		updateConfigMap(c, "token-code", "Token.sol", "contract Token { uint x; }")
+		contract = reconcileContract(r, c)
+		Expect(versionNames(c)).To(ConsistOf(contractVersionName("token", "sepolia", first)))
+		Expect(contract.Status.SourceHash).To(Equal(first))
+
+		// The next edit of the Contract picks up the changed sources
+		editContract(c, func(contract *kontractdeployerv1alpha1.Contract) { contract.Spec.InitParams = []string{"1000"} })
+		contract = reconcileContract(r, c)
+		Expect(contract.Status.SourceHash).NotTo(Equal(first))
+		Expect(versionNames(c)).To(HaveLen(2))
+	})
+
+	It("adopts the generation-named versions of earlier releases", func() {
+		contract := newContract()
+		contract.UID = "contract-uid"
+		contract.Generation = 3
+		contract.Status.CurrentVersion = "token-version-3"
+		legacy := &kontractdeployerv1alpha1.ContractVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-sepolia-version-3", Namespace: "default"},
+			Spec:       kontractdeployerv1alpha1.ContractVersionSpec{NetworkRef: "sepolia"},
+		}
+		Expect(controllerutil.SetControllerReference(contract, legacy, newScheme())).To(Succeed())
+		r, c, _ := newReconciler(contract, legacy)
+
+		contract = reconcileContract(r, c)
+		Expect(versionNames(c)).To(ConsistOf("token-sepolia-version-3"))
+		Expect(networkVersions(contract)).To(Equal(map[string]string{"sepolia": "token-sepolia-version-3"}))
+	})
+})
@@ -18,45 +18,195 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
 )
 
+const (
+	// proxyCheckInterval is how often a proxy is checked against its implementation Contract
+	proxyCheckInterval = time.Minute
+)
+
+// eip1967ImplementationSlot is the storage slot that holds the implementation of an EIP-1967 proxy
+var eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+// proxyAdminABI covers the ProxyAdmin function used to upgrade a transparent proxy
+var proxyAdminABI = mustParseABI(`[
+	{"type":"function","name":"upgradeAndCall","stateMutability":"payable","inputs":[{"name":"proxy","type":"address"},{"name":"implementation","type":"address"},{"name":"data","type":"bytes"}],"outputs":[]}
+]`)
+
 // ContractProxyReconciler reconciles a ContractProxy object
 type ContractProxyReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+
+	// Subscriptions wakes up proxies with pending upgrades when a block arrives; nil means polling only
+	Subscriptions *SubscriptionManager
 }
 
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contractproxies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contractproxies/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contractproxies/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contracts;contractversions;proxyadmins;wallets;networks;rpcproviders,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch;update
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the ContractProxy object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.0/pkg/reconcile
+// Reconcile upgrades the proxy through its ProxyAdmin whenever the implementation Contract has a new address
 func (r *ContractProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	ctx = withRPCWorkload(ctx, "ContractProxy")
+	logger := log.FromContext(ctx)
+
+	// Fetch the ContractProxy instance
+	proxy := &kontractdeployerv1alpha1.ContractProxy{}
+	if err := r.Get(ctx, req.NamespacedName, proxy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if proxy.Spec.ProxyAddress != "" {
+		proxy.Status.ProxyAddress = proxy.Spec.ProxyAddress
+	}
+	if !common.IsHexAddress(proxy.Status.ProxyAddress) {
+		logger.Info("ContractProxy has no proxy address yet")
+		return ctrl.Result{}, nil
+	}
+	proxyAddress := common.HexToAddress(proxy.Status.ProxyAddress)
+
+	implementation, err := contractAddress(ctx, r.Client, proxy.Namespace, proxy.Spec.ImplementationRef, proxy.Spec.NetworkRef)
+	if err != nil {
+		logger.Info("Implementation is not deployed yet", "ImplementationRef", proxy.Spec.ImplementationRef, "Reason", err.Error())
+		return ctrl.Result{RequeueAfter: proxyCheckInterval}, nil
+	}
+
+	// Start an upgrade when the implementation moved, unless the last attempt for it already failed
+	if proxy.Status.UpgradeTarget != implementation.Hex() || proxy.Status.Upgrade == nil {
+		current, err := r.currentImplementation(ctx, proxy.Namespace, proxy.Spec.NetworkRef, proxyAddress)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		proxy.Status.ImplementationAddress = current.Hex()
+		if current == implementation {
+			if err := r.Status().Update(ctx, proxy); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: proxyCheckInterval}, nil
+		}
+		proxy.Status.UpgradeTarget = implementation.Hex()
+		proxy.Status.Upgrade = &kontractdeployerv1alpha1.WalletTransactionStatus{}
+	} else if walletTransactionDone(proxy.Status.Upgrade) {
+		return ctrl.Result{RequeueAfter: proxyCheckInterval}, nil
+	}
+
+	upgrade := proxy.Status.Upgrade
+	previousState := upgrade.State
+	if err := r.upgradeProxy(ctx, proxy, proxyAddress, implementation); err != nil {
+		logger.Error(err, "Failed to upgrade proxy", "Implementation", implementation.Hex())
+		return ctrl.Result{}, err
+	}
+
+	switch upgrade.State {
+	case txStateSucceeded:
+		proxy.Status.ImplementationAddress = implementation.Hex()
+		r.EventRecorder.Event(proxy, corev1.EventTypeNormal, "ProxyUpgraded", fmt.Sprintf("Proxy upgraded to %s in transaction %s", implementation.Hex(), upgrade.TransactionHash))
+	case txStateFailed:
+		r.EventRecorder.Event(proxy, corev1.EventTypeWarning, "ProxyUpgradeFailed", fmt.Sprintf("Upgrade to %s failed: %s", implementation.Hex(), upgrade.Message))
+	case txStateAwaitingSignatures:
+		if previousState != txStateAwaitingSignatures {
+			r.EventRecorder.Event(proxy, corev1.EventTypeNormal, "ProxyUpgradeProposed", fmt.Sprintf("Safe transaction %s proposed to upgrade to %s, %s", upgrade.SafeTxHash, implementation.Hex(), upgrade.Message))
+		}
+	}
 
-	// TODO(user): your logic here
+	if err := r.Status().Update(ctx, proxy); err != nil {
+		logger.Error(err, "Failed to update ContractProxy status")
+		return ctrl.Result{}, err
+	}
 
-	return ctrl.Result{}, nil
+	if walletTransactionDone(upgrade) {
+		return ctrl.Result{RequeueAfter: proxyCheckInterval}, nil
+	}
+	return ctrl.Result{RequeueAfter: r.Subscriptions.pendingTransactionInterval(proxy.Namespace, proxy.Spec.NetworkRef, upgrade)}, nil
+}
+
+// currentImplementation reads the implementation address from the EIP-1967 storage slot of the proxy
+func (r *ContractProxyReconciler) currentImplementation(ctx context.Context, namespace, networkRef string, proxyAddress common.Address) (common.Address, error) {
+	ethClient, _, err := dialNetwork(ctx, r.Client, namespace, networkRef)
+	if err != nil {
+		return common.Address{}, err
+	}
+	defer ethClient.Close()
+
+	value, err := ethClient.StorageAt(ctx, proxyAddress, eip1967ImplementationSlot, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to read implementation slot of %s: %w", proxyAddress.Hex(), err)
+	}
+	return common.BytesToAddress(value), nil
+}
+
+// upgradeProxy starts or advances the ProxyAdmin upgradeAndCall transaction signed by the proxy's Wallet
+func (r *ContractProxyReconciler) upgradeProxy(ctx context.Context, proxy *kontractdeployerv1alpha1.ContractProxy, proxyAddress, implementation common.Address) error {
+	proxyAdmin := &kontractdeployerv1alpha1.ProxyAdmin{}
+	if err := r.Get(ctx, client.ObjectKey{Name: proxy.Spec.ProxyAdminRef, Namespace: proxy.Namespace}, proxyAdmin); err != nil {
+		return fmt.Errorf("failed to get ProxyAdmin %s: %w", proxy.Spec.ProxyAdminRef, err)
+	}
+	if !common.IsHexAddress(proxyAdmin.Spec.AdminAddress) {
+		failWalletTransaction(proxy.Status.Upgrade, fmt.Sprintf("ProxyAdmin %s has no valid admin address", proxyAdmin.Name))
+		return nil
+	}
+
+	wallet := &kontractdeployerv1alpha1.Wallet{}
+	if err := r.Get(ctx, client.ObjectKey{Name: proxy.Spec.WalletRef, Namespace: proxy.Namespace}, wallet); err != nil {
+		return fmt.Errorf("failed to get Wallet %s: %w", proxy.Spec.WalletRef, err)
+	}
+
+	data, err := proxyAdminABI.Pack("upgradeAndCall", proxyAddress, implementation, []byte{})
+	if err != nil {
+		return err
+	}
+	call := walletCall{to: common.HexToAddress(proxyAdmin.Spec.AdminAddress), data: data}
+	return submitWalletCall(ctx, r.Client, wallet, proxy.Spec.NetworkRef, call, proxy.Status.Upgrade)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ContractProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.EventRecorder = mgr.GetEventRecorderFor("contractproxy-controller")
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.ContractProxy{}).
-		Complete(r)
+		Watches(&kontractdeployerv1alpha1.Wallet{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.ContractProxyList{}, walletRefField),
+			builder.WithPredicates(walletKeyPredicate()))
+	if r.Subscriptions != nil {
+		controllerBuilder = controllerBuilder.WatchesRawSource(source.Channel(r.Subscriptions.Heads(), handler.EnqueueRequestsFromMapFunc(r.pendingUpgradesOnNetwork)))
+	}
+	return controllerBuilder.Complete(r)
+}
+
+// pendingUpgradesOnNetwork maps a new block on a Network to the proxies waiting for an upgrade transaction on it
+func (r *ContractProxyReconciler) pendingUpgradesOnNetwork(ctx context.Context, network client.Object) []reconcile.Request {
+	proxies, err := listReferencing(ctx, r.Client, &kontractdeployerv1alpha1.ContractProxyList{}, networkRefField, network)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ContractProxies")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, object := range proxies {
+		proxy := object.(*kontractdeployerv1alpha1.ContractProxy)
+		if proxy.Status.Upgrade != nil && proxy.Status.Upgrade.State == txStatePending {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(proxy)})
+		}
+	}
+	return requests
 }
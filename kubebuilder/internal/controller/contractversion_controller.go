@@ -27,6 +27,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -36,13 +37,19 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
 )
 
+// foundryImage compiles and deploys ContractVersions; it is part of the input hash that names them
+const foundryImage = "docker.io/expedio/kontract-foundry:latest"
+
 // ContractVersionReconciler reconciles a ContractVersion object
 type ContractVersionReconciler struct {
 	client.Client
@@ -58,6 +65,7 @@ type ContractVersionReconciler struct {
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;create;update;delete
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=referencegrants,verbs=get;list;watch
 
 func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -74,32 +82,25 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
-	// Fetch the Network instance
-	network := &kontractdeployerv1alpha1.Network{}
-	if err := r.Get(ctx, types.NamespacedName{Name: contractVersion.Spec.NetworkRef, Namespace: req.Namespace}, network); err != nil {
-		logger.Error(err, "Failed to get Network")
-		return ctrl.Result{}, err
-	}
-
-	// Fetch the RPCProvider referenced by the Network
-	rpcProvider := &kontractdeployerv1alpha1.RPCProvider{}
-	if err := r.Get(ctx, types.NamespacedName{Name: network.Spec.RPCProviderRef.Name, Namespace: req.Namespace}, rpcProvider); err != nil {
-		logger.Error(err, "Failed to get RPCProvider")
-		return ctrl.Result{}, err
+	// Fetch the Network instance; references to other namespaces need a ReferenceGrant there
+	referrer := contractVersionReferrer(contractVersion)
+	network, err := getReferencedNetwork(ctx, r.Client, referrer, req.Namespace, contractVersion.Spec.NetworkRef)
+	if err != nil {
+		return r.referenceFailed(ctx, contractVersion, "Failed to get Network", err)
 	}
 
-	// Fetch the Secret referenced by the RPCProvider
-	rpcProviderSecret := &corev1.Secret{}
-	if err := r.Get(ctx, types.NamespacedName{Name: rpcProvider.Spec.SecretRef.Name, Namespace: req.Namespace}, rpcProviderSecret); err != nil {
-		logger.Error(err, "Failed to get RPCProvider Secret")
+	// Pick the preferred endpoint of the Network's RPCProviders for the deployment job
+	endpoints, err := networkEndpoints(ctx, r.Client, network)
+	if err != nil {
+		logger.Error(err, "Failed to resolve RPC endpoint")
 		return ctrl.Result{}, err
 	}
+	rpcEndpoint := endpoints[0]
 
 	// Fetch the Wallet instance
-	wallet := &kontractdeployerv1alpha1.Wallet{}
-	if err := r.Get(ctx, types.NamespacedName{Name: contractVersion.Spec.WalletRef, Namespace: req.Namespace}, wallet); err != nil {
-		logger.Error(err, "Failed to get Wallet")
-		return ctrl.Result{}, err
+	wallet, err := getReferencedWallet(ctx, r.Client, referrer, req.Namespace, contractVersion.Spec.WalletRef)
+	if err != nil {
+		return r.referenceFailed(ctx, contractVersion, "Failed to get Wallet", err)
 	}
 
 	// Fetch the Wallet Secret
@@ -110,12 +111,29 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	}
 
 	walletSecret := &corev1.Secret{}
-	if err := r.Get(ctx, types.NamespacedName{Name: wallet.Status.SecretRef, Namespace: req.Namespace}, walletSecret); err != nil {
+	if err := r.Get(ctx, types.NamespacedName{Name: wallet.Status.SecretRef, Namespace: wallet.Namespace}, walletSecret); err != nil {
 		logger.Error(err, "Failed to get Wallet Secret")
 		r.EventRecorder.Event(contractVersion, corev1.EventTypeWarning, "WalletSecretNotFound", "Failed to get Wallet Secret")
 		return ctrl.Result{}, err
 	}
 
+	// Keys and credentials of other namespaces stay there: the deploy Job reaches the Network and its BlockExplorer
+	// through a session of the deploy gateway, which also signs the transactions of a Wallet of another namespace
+	var gatewaySession *corev1.Secret
+	signInGateway := wallet.Namespace != contractVersion.Namespace
+	if signInGateway || rpcEndpoint.namespace != contractVersion.Namespace || network.Namespace != contractVersion.Namespace {
+		gatewaySession, err = ensureGatewaySession(ctx, r.Client, r.Scheme, contractVersion)
+		if err != nil {
+			logger.Error(err, "Failed to create deploy gateway session")
+			r.EventRecorder.Event(contractVersion, corev1.EventTypeWarning, "GatewaySessionFailed", err.Error())
+			return ctrl.Result{}, err
+		}
+	}
+	if err := removeSharedSecrets(ctx, r.Client, contractVersion); err != nil {
+		logger.Error(err, "Failed to remove shared Secrets")
+		return ctrl.Result{}, err
+	}
+
 	// Create a ConfigMap for the contract code, tests, script, and foundry.toml
 	configMapName := fmt.Sprintf("%s-contract", contractVersion.Name)
 	configMapData := map[string]string{
@@ -275,28 +293,6 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 	// Define environment variables for the job
 	envVars := []corev1.EnvVar{
-		{
-			Name: "RPC_URL",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: rpcProvider.Spec.SecretRef.Name,
-					},
-					Key: rpcProvider.Spec.SecretRef.URLKey,
-				},
-			},
-		},
-		{
-			Name: "WALLET_PRV_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: wallet.Status.SecretRef,
-					},
-					Key: "privateKey",
-				},
-			},
-		},
 		{
 			Name:  "CONTRACT_NAME",
 			Value: contractVersion.Spec.ContractName,
@@ -311,58 +307,83 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		},
 		{
 			Name:  "CHAIN_ID",
-			Value: fmt.Sprintf("%d", network.Spec.ChainID),
+			Value: fmt.Sprintf("%d", networkChainID(network)),
 		},
 	}
 
-	// Add RPC_KEY if specified
-	if rpcProvider.Spec.SecretRef.TokenKey != "" {
+	// Pass the wallet key as an encrypted keystore file or as a raw private key; the gateway only needs the address
+	_, isKeystore := walletSecret.Data[walletKeystoreKey]
+	switch {
+	case signInGateway:
+		envVars = append(envVars, corev1.EnvVar{Name: "WALLET_ADDRESS", Value: wallet.Status.PublicKey})
+	case isKeystore:
+		keystoreEnv, err := walletKeystoreEnv(wallet)
+		if err != nil {
+			logger.Error(err, "Invalid keystore configuration", "Wallet.Name", wallet.Name)
+			r.EventRecorder.Event(contractVersion, corev1.EventTypeWarning, "InvalidKeystoreConfig", err.Error())
+			return ctrl.Result{}, err
+		}
+		envVars = append(envVars, keystoreEnv...)
+
+		volumes = append(volumes, walletKeystoreVolume(wallet))
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      "wallet-keystore",
+			MountPath: walletKeystoreDir,
+			ReadOnly:  true,
+		})
+	default:
 		envVars = append(envVars, corev1.EnvVar{
-			Name: "RPC_KEY",
+			Name: "WALLET_PRV_KEY",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: rpcProvider.Spec.SecretRef.Name,
+						Name: wallet.Status.SecretRef,
 					},
-					Key: rpcProvider.Spec.SecretRef.TokenKey,
+					Key: "privateKey",
 				},
 			},
 		})
 	}
 
+	// Pass the RPC endpoint and its credentials; a Job can only use the Secrets of its own namespace
+	if gatewaySession != nil {
+		envVars = append(envVars, gatewayJobEnv(contractVersion, gatewaySession)...)
+	} else {
+		rpcEnv, rpcVolumes, rpcVolumeMounts := rpcJobEnv(rpcEndpoint)
+		envVars = append(envVars, rpcEnv...)
+		volumes = append(volumes, rpcVolumes...)
+		volumeMounts = append(volumeMounts, rpcVolumeMounts...)
+	}
+
 	// Fetch the BlockExplorer if referenced by the Network
 	var blockExplorer *kontractdeployerv1alpha1.BlockExplorer
 	if network.Spec.BlockExplorerRef != nil {
 		blockExplorer = &kontractdeployerv1alpha1.BlockExplorer{}
-		if err := r.Get(ctx, types.NamespacedName{Name: network.Spec.BlockExplorerRef.Name, Namespace: req.Namespace}, blockExplorer); err != nil {
+		if err := r.Get(ctx, types.NamespacedName{Name: network.Spec.BlockExplorerRef.Name, Namespace: network.Namespace}, blockExplorer); err != nil {
 			logger.Error(err, "Failed to get BlockExplorer")
 			return ctrl.Result{}, err
 		}
 	}
 
-	// Add BlockExplorer details to the environment variables if it exists
+	// Add the source verifier of the BlockExplorer to the environment variables if it exists
 	if blockExplorer != nil {
-		envVars = append(envVars, corev1.EnvVar{
-			Name: "ETHERSCAN_API_URL",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: blockExplorer.Spec.SecretRef.Name,
-					},
-					Key: blockExplorer.Spec.SecretRef.URLKey,
-				},
-			},
-		}, corev1.EnvVar{
-			Name: "ETHERSCAN_API_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: blockExplorer.Spec.SecretRef.Name,
-					},
-					Key: blockExplorer.Spec.SecretRef.TokenKey,
-				},
-			},
-		})
+		var explorerSecret corev1.Secret
+		if err := r.Get(ctx, types.NamespacedName{Name: blockExplorer.Spec.SecretRef.Name, Namespace: network.Namespace}, &explorerSecret); err != nil {
+			logger.Error(err, "Failed to get BlockExplorer Secret")
+			return ctrl.Result{}, err
+		}
+		var explorerEnv []corev1.EnvVar
+		if network.Namespace != contractVersion.Namespace {
+			explorerEnv, err = gatewayVerifierEnv(contractVersion, gatewaySession, blockExplorer, &explorerSecret, networkChainID(network))
+		} else {
+			explorerEnv, err = blockExplorerJobEnv(blockExplorer, &explorerSecret, networkChainID(network))
+		}
+		if err != nil {
+			logger.Error(err, "Invalid BlockExplorer configuration", "BlockExplorer.Name", blockExplorer.Name)
+			r.EventRecorder.Event(contractVersion, corev1.EventTypeWarning, "InvalidBlockExplorerConfig", err.Error())
+			return ctrl.Result{}, err
+		}
+		envVars = append(envVars, explorerEnv...)
 	}
 
 	// Convert InitParams to JSON if not empty
@@ -391,7 +412,7 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 					Containers: []corev1.Container{
 						{
 							Name:         "foundry",
-							Image:        "docker.io/expedio/kontract-foundry:latest",
+							Image:        foundryImage,
 							Env:          envVars,
 							VolumeMounts: volumeMounts,
 						},
@@ -477,9 +498,23 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		transactionHash := extractTransactionHashFromLogs(podLogs)
 
 		// Update the ContractVersion status
+		previousState := contractVersion.Status.State
 		contractVersion.Status.ContractAddress = contractAddress
 		contractVersion.Status.TransactionHash = transactionHash
 		contractVersion.Status.State = "deployed"
+		contractVersion.Status.SourceHash = contractVersion.Annotations[sourceHashAnnotation]
+
+		// A reset of a local chain after the deployment may have removed the contract
+		if codeMissing, err := r.deploymentLost(ctx, network, contractVersion); err != nil {
+			logger.Error(err, "Failed to validate the deployed contract", "ContractAddress", contractAddress)
+			return ctrl.Result{}, err
+		} else if codeMissing {
+			contractVersion.Status.State = "stale"
+			if previousState != "stale" {
+				r.EventRecorder.Event(contractVersion, corev1.EventTypeWarning, "ContractCodeMissing",
+					fmt.Sprintf("No code at %s after the chain of Network %s was reset", contractAddress, network.Name))
+			}
+		}
 
 		if err := r.Status().Update(ctx, contractVersion); err != nil {
 			logger.Error(err, "Failed to update ContractVersion status")
@@ -489,6 +524,7 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		// Job failed, update the ContractVersion status
 		r.EventRecorder.Event(contractVersion, corev1.EventTypeWarning, "DeploymentFailed", "ContractVersion deployment failed")
 		contractVersion.Status.State = "failed"
+		contractVersion.Status.SourceHash = contractVersion.Annotations[sourceHashAnnotation]
 		if err := r.Status().Update(ctx, contractVersion); err != nil {
 			logger.Error(err, "Failed to update ContractVersion status")
 			return ctrl.Result{}, err
@@ -501,6 +537,56 @@ func (r *ContractVersionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	return ctrl.Result{}, nil
 }
 
+// referenceFailed handles a Network or Wallet reference of a ContractVersion that cannot be resolved.
+// A reference that no ReferenceGrant permits is not retried; a change to the grants reconciles the ContractVersion again.
+func (r *ContractVersionReconciler) referenceFailed(ctx context.Context, contractVersion *kontractdeployerv1alpha1.ContractVersion, msg string, err error) (ctrl.Result, error) {
+	if isReferenceDenied(err) {
+		log.FromContext(ctx).Info(msg, "error", err.Error())
+		r.EventRecorder.Event(contractVersion, corev1.EventTypeWarning, reasonRefNotPermitted, err.Error())
+		return ctrl.Result{}, nil
+	}
+	log.FromContext(ctx).Error(err, msg)
+	return ctrl.Result{}, err
+}
+
+// deploymentLost reports whether the chain of a local Network was reset after the deployment and the contract code is gone
+func (r *ContractVersionReconciler) deploymentLost(ctx context.Context, network *kontractdeployerv1alpha1.Network, contractVersion *kontractdeployerv1alpha1.ContractVersion) (bool, error) {
+	if network.Status.Anvil == nil || network.Status.Anvil.LastReset == nil || contractVersion.Status.ContractAddress == "" {
+		return false, nil
+	}
+	if !network.Status.Anvil.LastReset.After(contractVersion.Status.DeploymentTime.Time) {
+		return false, nil
+	}
+
+	ethClient, _, err := dialNetwork(ctx, r.Client, network.Namespace, network.Name)
+	if err != nil {
+		return false, err
+	}
+	defer ethClient.Close()
+	code, err := ethClient.CodeAt(ctx, common.HexToAddress(contractVersion.Status.ContractAddress), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get code: %w", err)
+	}
+	return len(code) == 0, nil
+}
+
+// contractVersionsOnNetwork maps a Network to the deployed ContractVersions on it, to validate them after a chain reset
+func (r *ContractVersionReconciler) contractVersionsOnNetwork(ctx context.Context, network client.Object) []reconcile.Request {
+	contractVersions, err := listReferencing(ctx, r.Client, &kontractdeployerv1alpha1.ContractVersionList{}, networkRefField, network)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ContractVersions")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, object := range contractVersions {
+		if object.(*kontractdeployerv1alpha1.ContractVersion).Status.ContractAddress != "" {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(object)})
+		}
+	}
+	return requests
+}
+
 // Helper function to extract the contract address from logs
 func extractContractAddressFromLogs(logs string) string {
 	re := regexp.MustCompile(`Contract Address: (0x[a-fA-F0-9]{40})`)
@@ -521,6 +607,24 @@ func extractTransactionHashFromLogs(logs string) string {
 	return ""
 }
 
+// contractVersionsForReferenceGrant maps a ReferenceGrant to the ContractVersions it may permit to reference its namespace
+func (r *ContractVersionReconciler) contractVersionsForReferenceGrant(ctx context.Context, grant client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, namespace := range grantedNamespaces(grant, "Contract", "ContractVersion") {
+		var contractVersions kontractdeployerv1alpha1.ContractVersionList
+		if err := r.List(ctx, &contractVersions, client.InNamespace(namespace)); err != nil {
+			log.FromContext(ctx).Error(err, "unable to list ContractVersions")
+			continue
+		}
+		for _, contractVersion := range contractVersions.Items {
+			if referencesNamespace(grant.GetNamespace(), contractVersion.Spec.NetworkRef, contractVersion.Spec.WalletRef) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&contractVersion)})
+			}
+		}
+	}
+	return requests
+}
+
 // createOrUpdateConfigMap creates or updates a ConfigMap
 func (r *ContractVersionReconciler) createOrUpdateConfigMap(ctx context.Context, cm *corev1.ConfigMap) error {
 	logger := log.FromContext(ctx)
@@ -563,5 +667,10 @@ func (r *ContractVersionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.ContractVersion{}).
 		Owns(&batchv1.Job{}).
+		Watches(&kontractdeployerv1alpha1.Network{}, handler.EnqueueRequestsFromMapFunc(r.contractVersionsOnNetwork),
+			builder.WithPredicates(anvilResetPredicate())).
+		Watches(&kontractdeployerv1alpha1.ReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(r.contractVersionsForReferenceGrant)).
+		Watches(&kontractdeployerv1alpha1.Wallet{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.ContractVersionList{}, walletRefField),
+			builder.WithPredicates(walletKeyPredicate())).
 		Complete(r)
 }
@@ -18,11 +18,18 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -82,3 +89,76 @@ var _ = Describe("ContractVersion Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Chain reset validation", func() {
+	ctx := context.Background()
+
+	It("detects contracts whose code is gone after a reset of a local Network", func() {
+		code := `"0x6080"`
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var request struct {
+				Method string `json:"method"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			results := map[string]string{"eth_chainId": `"0x7a69"`, "eth_getCode": code}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + results[request.Method] + `}`))
+		}))
+		defer server.Close()
+
+		deployed := metav1.NewTime(time.Now().Add(-time.Hour))
+		network := &kontractdeployerv1alpha1.Network{
+			ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+			Spec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: anvilNetworkName, ChainID: 31337},
+			Status: kontractdeployerv1alpha1.NetworkStatus{
+				Anvil: &kontractdeployerv1alpha1.AnvilStatus{LastReset: &metav1.Time{Time: time.Now()}},
+			},
+		}
+		provider := &kontractdeployerv1alpha1.RPCProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+				ProviderName: "Anvil",
+				SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "local-rpc-secret", URLKey: "url", TokenKey: "token"},
+				Auth:         &kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthBearer},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "local-rpc-secret", Namespace: "default"},
+			Data:       map[string][]byte{"url": []byte(server.URL), "token": []byte("unused")},
+		}
+		contractVersion := &kontractdeployerv1alpha1.ContractVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-v1", Namespace: "default"},
+			Spec:       kontractdeployerv1alpha1.ContractVersionSpec{NetworkRef: "local"},
+			Status: kontractdeployerv1alpha1.ContractVersionStatus{
+				ContractAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+				DeploymentTime:  deployed,
+			},
+		}
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		r := &ContractVersionReconciler{
+			Client: newIndexedClientBuilder(scheme).WithObjects(network, provider, secret, contractVersion).Build(),
+			Scheme: scheme,
+		}
+
+		lost, err := r.deploymentLost(ctx, network, contractVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lost).To(BeFalse())
+
+		code = `"0x"`
+		lost, err = r.deploymentLost(ctx, network, contractVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lost).To(BeTrue())
+
+		// Deployments made after the reset are not checked
+		contractVersion.Status.DeploymentTime = metav1.NewTime(time.Now().Add(time.Hour))
+		lost, err = r.deploymentLost(ctx, network, contractVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lost).To(BeFalse())
+
+		Expect(r.contractVersionsOnNetwork(ctx, network)).To(ConsistOf(reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: "default", Name: "token-v1"},
+		}))
+	})
+})
@@ -0,0 +1,637 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// DefaultDeployGatewayBindAddress is the address the deploy gateway listens on, unless the manager sets another
+	DefaultDeployGatewayBindAddress = ":8555"
+
+	// gatewaySessionTokenKey is the key of the gateway session Secret that holds the token of the session
+	gatewaySessionTokenKey = "token"
+
+	// deployGatewayRequestLimit bounds the size of a JSON-RPC request to the deploy gateway
+	deployGatewayRequestLimit = 8 << 20
+
+	// deployGatewayTimeout bounds the handling of a single request, including the upstream calls
+	deployGatewayTimeout = 2 * time.Minute
+
+	// verifierKeyParam is the query or form parameter that carries the API key of a source verification request
+	verifierKeyParam = "apikey"
+
+	// JSON-RPC error codes returned by the deploy gateway itself
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCInternalError  = -32603
+)
+
+// DeployGatewayURL is the URL under which deploy Jobs reach the deploy gateway of the manager.
+// Without it, ContractVersions that use a Wallet, Network or RPCProvider of another namespace cannot be deployed.
+var DeployGatewayURL string
+
+// DeployGateway serves the deploy Jobs of ContractVersions that use a Wallet, Network or RPCProvider of another namespace.
+// It forwards JSON-RPC calls over the operator's own connection to the Network and signs the transactions of the Wallet
+// itself, and adds the API key of the BlockExplorer to source verification requests. The keys and credentials therefore
+// never leave their namespace. Each ContractVersion has its own session, authenticated by a token in a Secret of its
+// namespace, which only accepts requests until the deployment has finished.
+type DeployGateway struct {
+	Client      client.Client
+	BindAddress string
+}
+
+// NeedLeaderElection lets every replica of the manager serve the gateway, as it keeps no state of its own
+func (g *DeployGateway) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the gateway until the context is cancelled
+func (g *DeployGateway) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              g.BindAddress,
+		Handler:           g,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.FromContext(ctx).Info("Starting deploy gateway", "BindAddress", g.BindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("deploy gateway failed: %w", err)
+	}
+	return nil
+}
+
+// gatewayMessage is a JSON-RPC request
+type gatewayMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// gatewayResponse is a JSON-RPC response
+type gatewayResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *gatewayError   `json:"error,omitempty"`
+}
+
+// gatewayError is the error of a JSON-RPC response
+type gatewayError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// gatewaySession serves the requests of the deploy Job of a single ContractVersion
+type gatewaySession struct {
+	client          client.Client
+	contractVersion *kontractdeployerv1alpha1.ContractVersion
+	wallet          *kontractdeployerv1alpha1.Wallet
+	ethClient       *ethclient.Client
+}
+
+// ServeHTTP serves JSON-RPC under /rpc/<namespace>/<contractversion>/<token> and
+// source verification under /verify/<namespace>/<contractversion>
+func (g *DeployGateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "rpc":
+		g.serveRPC(w, req, parts[1], parts[2], parts[3])
+	case len(parts) == 3 && parts[0] == "verify":
+		g.serveVerifier(w, req, parts[1], parts[2])
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveRPC handles a single or batched JSON-RPC request of a session
+func (g *DeployGateway) serveRPC(w http.ResponseWriter, req *http.Request, namespace, name, token string) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), deployGatewayTimeout)
+	defer cancel()
+
+	session, status, err := g.openSession(ctx, namespace, name, token)
+	if err != nil {
+		log.FromContext(ctx).Info("Rejected deploy gateway request", "Path", redactGatewayPath(req.URL.Path), "Reason", err.Error())
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer session.ethClient.Close()
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, deployGatewayRequestLimit))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	var response any
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		var messages []gatewayMessage
+		if err := json.Unmarshal(body, &messages); err != nil {
+			response = gatewayErrorResponse(nil, jsonRPCInvalidRequest, "invalid batch request")
+		} else {
+			responses := make([]gatewayResponse, 0, len(messages))
+			for _, message := range messages {
+				responses = append(responses, session.handle(ctx, message))
+			}
+			response = responses
+		}
+	} else {
+		var message gatewayMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			response = gatewayErrorResponse(nil, jsonRPCInvalidRequest, "invalid request")
+		} else {
+			response = session.handle(ctx, message)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// serveVerifier forwards a source verification request of a deploy Job to the BlockExplorer of its Network.
+// The Job sends its session token as API key, which the gateway replaces with the API key of the BlockExplorer.
+func (g *DeployGateway) serveVerifier(w http.ResponseWriter, req *http.Request, namespace, name string) {
+	ctx, cancel := context.WithTimeout(req.Context(), deployGatewayTimeout)
+	defer cancel()
+	logger := log.FromContext(ctx)
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, deployGatewayRequestLimit))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	query := req.URL.Query()
+	var form url.Values
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if form, err = url.ParseQuery(string(body)); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+	}
+	token := query.Get(verifierKeyParam)
+	if token == "" && form != nil {
+		token = form.Get(verifierKeyParam)
+	}
+
+	contractVersion, status, err := g.authenticate(ctx, namespace, name, token)
+	if err == nil {
+		var verifierURL, apiKey string
+		if verifierURL, apiKey, status, err = g.verifierUpstream(ctx, contractVersion); err == nil {
+			err = forwardVerification(ctx, w, req, verifierURL, apiKey, query, form, body)
+			status = http.StatusBadGateway
+		}
+	}
+	if err != nil {
+		logger.Info("Rejected deploy gateway request", "Path", req.URL.Path, "Reason", err.Error())
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// verifierUpstream returns the verifier URL and API key of the BlockExplorer of the Network of a ContractVersion
+func (g *DeployGateway) verifierUpstream(ctx context.Context, contractVersion *kontractdeployerv1alpha1.ContractVersion) (string, string, int, error) {
+	network, err := getReferencedNetwork(ctx, g.Client, contractVersionReferrer(contractVersion), contractVersion.Namespace, contractVersion.Spec.NetworkRef)
+	if err != nil {
+		return "", "", http.StatusForbidden, err
+	}
+	if network.Spec.BlockExplorerRef == nil {
+		return "", "", http.StatusNotFound, fmt.Errorf("network %s has no BlockExplorer", network.Name)
+	}
+	explorer := &kontractdeployerv1alpha1.BlockExplorer{}
+	if err := g.Client.Get(ctx, client.ObjectKey{Name: network.Spec.BlockExplorerRef.Name, Namespace: network.Namespace}, explorer); err != nil {
+		return "", "", http.StatusInternalServerError, fmt.Errorf("failed to get BlockExplorer: %w", err)
+	}
+	secret := &corev1.Secret{}
+	if err := g.Client.Get(ctx, client.ObjectKey{Name: explorer.Spec.SecretRef.Name, Namespace: network.Namespace}, secret); err != nil {
+		return "", "", http.StatusInternalServerError, fmt.Errorf("failed to get BlockExplorer Secret: %w", err)
+	}
+	api, err := newBlockExplorerAPI(explorer, secret, networkChainID(network))
+	if err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+	apiKey := string(secret.Data[explorer.Spec.SecretRef.TokenKey])
+	if explorer.Spec.SecretRef.TokenKey == "" || apiKey == "" {
+		return "", "", http.StatusNotFound, fmt.Errorf("BlockExplorer %s has no API key", explorer.Name)
+	}
+	_, verifierURL := api.verifier()
+	return verifierURL, apiKey, http.StatusOK, nil
+}
+
+// forwardVerification sends a verification request to the verifier URL with the API key in place of the session token
+// and copies the response. Errors are returned only when the verifier could not be reached.
+func forwardVerification(ctx context.Context, w http.ResponseWriter, req *http.Request, verifierURL, apiKey string, query, form url.Values, body []byte) error {
+	upstream, err := url.Parse(verifierURL)
+	if err != nil {
+		return fmt.Errorf("invalid verifier URL: %w", err)
+	}
+	upstreamQuery := upstream.Query()
+	for key, values := range query {
+		upstreamQuery[key] = values
+	}
+	if upstreamQuery.Has(verifierKeyParam) {
+		upstreamQuery.Set(verifierKeyParam, apiKey)
+	}
+	upstream.RawQuery = upstreamQuery.Encode()
+	if form.Has(verifierKeyParam) {
+		form.Set(verifierKeyParam, apiKey)
+		body = []byte(form.Encode())
+	}
+
+	upstreamReq, err := http.NewRequestWithContext(ctx, req.Method, upstream.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid verification request: %w", err)
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		upstreamReq.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(upstreamReq)
+	if err != nil {
+		// The error names the upstream URL, which holds the API key
+		return fmt.Errorf("failed to reach the verifier of %s", upstream.Host)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, io.LimitReader(resp.Body, deployGatewayRequestLimit))
+	return nil
+}
+
+// authenticate returns the ContractVersion of a session whose deployment is still running
+func (g *DeployGateway) authenticate(ctx context.Context, namespace, name, token string) (*kontractdeployerv1alpha1.ContractVersion, int, error) {
+	contractVersion := &kontractdeployerv1alpha1.ContractVersion{}
+	if err := g.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, contractVersion); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, http.StatusUnauthorized, errors.New("unknown session")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	secret := &corev1.Secret{}
+	if err := g.Client.Get(ctx, client.ObjectKey{Name: gatewaySessionName(contractVersion), Namespace: namespace}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, http.StatusUnauthorized, errors.New("unknown session")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	expected := secret.Data[gatewaySessionTokenKey]
+	if ref := metav1.GetControllerOf(secret); ref == nil || ref.UID != contractVersion.UID || len(expected) == 0 ||
+		subtle.ConstantTimeCompare(expected, []byte(token)) != 1 {
+		return nil, http.StatusUnauthorized, errors.New("unknown session")
+	}
+	if deploymentFinished(contractVersion) {
+		return nil, http.StatusForbidden, fmt.Errorf("the deployment of ContractVersion %s has finished", name)
+	}
+	return contractVersion, http.StatusOK, nil
+}
+
+// openSession authenticates a JSON-RPC request and connects to the Network of its ContractVersion
+func (g *DeployGateway) openSession(ctx context.Context, namespace, name, token string) (*gatewaySession, int, error) {
+	contractVersion, status, err := g.authenticate(ctx, namespace, name, token)
+	if err != nil {
+		return nil, status, err
+	}
+
+	// The session resolves the references the same way, and with the same grants, as the ContractVersion controller
+	referrer := contractVersionReferrer(contractVersion)
+	network, err := getReferencedNetwork(ctx, g.Client, referrer, namespace, contractVersion.Spec.NetworkRef)
+	if err != nil {
+		return nil, http.StatusForbidden, err
+	}
+	wallet, err := getReferencedWallet(ctx, g.Client, referrer, namespace, contractVersion.Spec.WalletRef)
+	if err != nil {
+		return nil, http.StatusForbidden, err
+	}
+	ethClient, err := dialNetworkEndpoints(ctx, g.Client, network)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return &gatewaySession{client: g.Client, contractVersion: contractVersion, wallet: wallet, ethClient: ethClient}, http.StatusOK, nil
+}
+
+// handle answers a single JSON-RPC request.
+// Transactions are signed with the key of the Wallet, other signing methods are refused, and read calls are forwarded.
+func (s *gatewaySession) handle(ctx context.Context, message gatewayMessage) gatewayResponse {
+	var params []json.RawMessage
+	if len(message.Params) > 0 && string(message.Params) != "null" {
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			return gatewayErrorResponse(message.ID, jsonRPCInvalidParams, "params must be an array")
+		}
+	}
+
+	switch message.Method {
+	case "eth_accounts", "eth_requestAccounts":
+		return gatewayResult(message.ID, []string{common.HexToAddress(s.wallet.Status.PublicKey).Hex()})
+	case "eth_sendTransaction":
+		if len(params) != 1 {
+			return gatewayErrorResponse(message.ID, jsonRPCInvalidParams, "eth_sendTransaction takes a single transaction")
+		}
+		hash, err := s.sendTransaction(ctx, params[0])
+		if err != nil {
+			return gatewayErrorResponse(message.ID, jsonRPCInternalError, err.Error())
+		}
+		return gatewayResult(message.ID, hash)
+	}
+
+	// Only the namespaces forge needs are forwarded, so the session cannot sign messages or control a node
+	if !strings.HasPrefix(message.Method, "eth_") && !strings.HasPrefix(message.Method, "net_") && !strings.HasPrefix(message.Method, "web3_") ||
+		strings.HasPrefix(message.Method, "eth_sign") {
+		return gatewayErrorResponse(message.ID, jsonRPCMethodNotFound, fmt.Sprintf("method %s is not available through the deploy gateway", message.Method))
+	}
+
+	args := make([]any, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	var result json.RawMessage
+	if err := s.ethClient.Client().CallContext(ctx, &result, message.Method, args...); err != nil {
+		response := gatewayErrorResponse(message.ID, jsonRPCInternalError, err.Error())
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			response.Error.Code = rpcErr.ErrorCode()
+		}
+		var dataErr rpc.DataError
+		if errors.As(err, &dataErr) {
+			response.Error.Data = dataErr.ErrorData()
+		}
+		return response
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return gatewayResponse{JSONRPC: "2.0", ID: message.ID, Result: result}
+}
+
+// gatewayTransaction holds the fields of an eth_sendTransaction request
+type gatewayTransaction struct {
+	From                 *common.Address `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
+	Data                 *hexutil.Bytes  `json:"data"`
+	Input                *hexutil.Bytes  `json:"input"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// sendTransaction fills in the missing fields of a transaction, signs it with the key of the Wallet and sends it
+func (s *gatewaySession) sendTransaction(ctx context.Context, raw json.RawMessage) (common.Hash, error) {
+	var args gatewayTransaction
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid transaction: %w", err)
+	}
+	from := common.HexToAddress(s.wallet.Status.PublicKey)
+	if args.From != nil && *args.From != from {
+		return common.Hash{}, fmt.Errorf("the session can only send transactions from %s", from.Hex())
+	}
+
+	chainID, err := s.ethClient.ChainID(ctx)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	if args.ChainID != nil && args.ChainID.ToInt().Cmp(chainID) != 0 {
+		return common.Hash{}, fmt.Errorf("transaction is for chain %s but the Network has chain %s", args.ChainID.ToInt(), chainID)
+	}
+
+	var data []byte
+	if args.Input != nil {
+		data = *args.Input
+	} else if args.Data != nil {
+		data = *args.Data
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+
+	var nonce uint64
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	} else if nonce, err = s.ethClient.PendingNonceAt(ctx, from); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	var gas uint64
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	} else if gas, err = s.ethClient.EstimateGas(ctx, ethereum.CallMsg{From: from, To: args.To, Value: value, Data: data}); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+
+	var tx *types.Transaction
+	switch {
+	case args.MaxFeePerGas != nil:
+		tipCap := args.MaxPriorityFeePerGas.ToInt()
+		if args.MaxPriorityFeePerGas == nil {
+			if tipCap, err = s.ethClient.SuggestGasTipCap(ctx); err != nil {
+				return common.Hash{}, fmt.Errorf("failed to get gas tip: %w", err)
+			}
+		}
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			To:        args.To,
+			Value:     value,
+			Gas:       gas,
+			GasFeeCap: args.MaxFeePerGas.ToInt(),
+			GasTipCap: tipCap,
+			Data:      data,
+		})
+	default:
+		gasPrice := args.GasPrice.ToInt()
+		if args.GasPrice == nil {
+			if gasPrice, err = s.ethClient.SuggestGasPrice(ctx); err != nil {
+				return common.Hash{}, fmt.Errorf("failed to get gas price: %w", err)
+			}
+		}
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			To:       args.To,
+			Value:    value,
+			Gas:      gas,
+			GasPrice: gasPrice,
+			Data:     data,
+		})
+	}
+
+	privateKey, err := walletPrivateKey(ctx, s.client, s.wallet)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), privateKey)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	if err := s.ethClient.SendTransaction(ctx, signedTx); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	log.FromContext(ctx).Info("Deploy gateway sent transaction", "ContractVersion", s.contractVersion.Name,
+		"Namespace", s.contractVersion.Namespace, "Wallet", s.wallet.Name, "TransactionHash", signedTx.Hash().Hex())
+	return signedTx.Hash(), nil
+}
+
+// gatewayResult returns a successful JSON-RPC response
+func gatewayResult(id json.RawMessage, result any) gatewayResponse {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return gatewayErrorResponse(id, jsonRPCInternalError, err.Error())
+	}
+	return gatewayResponse{JSONRPC: "2.0", ID: id, Result: encoded}
+}
+
+// gatewayErrorResponse returns a JSON-RPC error response
+func gatewayErrorResponse(id json.RawMessage, code int, message string) gatewayResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return gatewayResponse{JSONRPC: "2.0", ID: id, Error: &gatewayError{Code: code, Message: message}}
+}
+
+// redactGatewayPath removes the session token from a gateway path before it is logged
+func redactGatewayPath(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 && strings.Count(path, "/") >= 4 {
+		return path[:i+1] + "***"
+	}
+	return path
+}
+
+// deploymentFinished reports whether the deploy Job of a ContractVersion has completed, successfully or not
+func deploymentFinished(contractVersion *kontractdeployerv1alpha1.ContractVersion) bool {
+	switch contractVersion.Status.State {
+	case "deployed", "failed", "stale":
+		return true
+	}
+	return false
+}
+
+// gatewaySessionName is the name of the Secret that holds the gateway session of a ContractVersion
+func gatewaySessionName(contractVersion *kontractdeployerv1alpha1.ContractVersion) string {
+	return contractVersion.Name + "-gateway"
+}
+
+// ensureGatewaySession creates the gateway session of a ContractVersion, keeping the token of an existing one.
+// It refuses to take over a Secret of the same name that the ContractVersion does not control.
+func ensureGatewaySession(ctx context.Context, c client.Client, scheme *runtime.Scheme, contractVersion *kontractdeployerv1alpha1.ContractVersion) (*corev1.Secret, error) {
+	if DeployGatewayURL == "" {
+		return nil, errors.New("the deploy gateway of the operator is not configured")
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: gatewaySessionName(contractVersion), Namespace: contractVersion.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, secret, func() error {
+		if err := claimServingObject(contractVersion, secret, scheme); err != nil {
+			return err
+		}
+		if len(secret.Data[gatewaySessionTokenKey]) == 0 {
+			token := make([]byte, 32)
+			if _, err := rand.Read(token); err != nil {
+				return fmt.Errorf("failed to generate session token: %w", err)
+			}
+			secret.Data = map[string][]byte{gatewaySessionTokenKey: []byte(hex.EncodeToString(token))}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create gateway session: %w", err)
+	}
+	return secret, nil
+}
+
+// gatewayJobEnv returns the environment variables that point a deploy Job at its gateway session
+// The token is appended to the URL as a path key, so the entrypoint keeps it out of the Job logs
+func gatewayJobEnv(contractVersion *kontractdeployerv1alpha1.ContractVersion, session *corev1.Secret) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "RPC_URL", Value: gatewayURL("rpc", contractVersion)},
+		{Name: "RPC_AUTH_TYPE", Value: rpcAuthPath},
+		{Name: "RPC_KEY", ValueFrom: gatewayTokenSource(session)},
+	}
+}
+
+// gatewayVerifierEnv returns the environment that lets a deploy Job verify sources through its gateway session.
+// Only BlockExplorers with an API key need the gateway; the Job verifies on the others directly.
+func gatewayVerifierEnv(contractVersion *kontractdeployerv1alpha1.ContractVersion, session *corev1.Secret, explorer *kontractdeployerv1alpha1.BlockExplorer, secret *corev1.Secret, chainID int64) ([]corev1.EnvVar, error) {
+	envVars, err := blockExplorerJobEnv(explorer, secret, chainID)
+	if err != nil || explorer.Spec.SecretRef.TokenKey == "" {
+		return envVars, err
+	}
+	for i := range envVars {
+		switch envVars[i].Name {
+		case "VERIFIER_URL":
+			envVars[i].Value = gatewayURL("verify", contractVersion)
+		case "ETHERSCAN_API_KEY":
+			envVars[i].ValueFrom = gatewayTokenSource(session)
+		}
+	}
+	return envVars, nil
+}
+
+// gatewayURL returns the URL of a gateway service for the session of a ContractVersion
+func gatewayURL(service string, contractVersion *kontractdeployerv1alpha1.ContractVersion) string {
+	return fmt.Sprintf("%s/%s/%s/%s", strings.TrimRight(DeployGatewayURL, "/"), service, contractVersion.Namespace, contractVersion.Name)
+}
+
+// gatewayTokenSource reads the token of a gateway session into an environment variable
+func gatewayTokenSource(session *corev1.Secret) *corev1.EnvVarSource {
+	return &corev1.EnvVarSource{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: session.Name},
+			Key:                  gatewaySessionTokenKey,
+		},
+	}
+}
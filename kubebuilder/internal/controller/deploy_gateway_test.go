@@ -0,0 +1,301 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+var _ = Describe("Deploy gateway", func() {
+	ctx := context.Background()
+	var (
+		upstream *httptest.Server
+		mu       sync.Mutex
+		rawTxs   []string
+	)
+
+	BeforeEach(func() {
+		DeployGatewayURL = "http://deploy-gateway.kontract-system.svc:8555"
+		rawTxs = nil
+		upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var request struct {
+				Method string            `json:"method"`
+				Params []json.RawMessage `json:"params"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			result := `"0x7a69"`
+			switch request.Method {
+			case "eth_getTransactionCount":
+				result = `"0x5"`
+			case "eth_estimateGas":
+				result = `"0x5208"`
+			case "eth_gasPrice":
+				result = `"0x3b9aca00"`
+			case "eth_blockNumber":
+				result = `"0x10"`
+			case "eth_sendRawTransaction":
+				var raw string
+				_ = json.Unmarshal(request.Params[0], &raw)
+				mu.Lock()
+				rawTxs = append(rawTxs, raw)
+				mu.Unlock()
+				result = fmt.Sprintf(`"0x%064x"`, 1)
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+		}))
+	})
+	AfterEach(func() {
+		upstream.Close()
+		DeployGatewayURL = ""
+	})
+
+	newScheme := func() *runtime.Scheme {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return scheme
+	}
+	newContractVersion := func() *kontractdeployerv1alpha1.ContractVersion {
+		return &kontractdeployerv1alpha1.ContractVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-v1", Namespace: "app-a", UID: "token-v1-uid"},
+			Spec: kontractdeployerv1alpha1.ContractVersionSpec{
+				ContractName: "Token",
+				NetworkRef:   "sepolia",
+				WalletRef:    "treasury/deployer",
+			},
+		}
+	}
+	// newGateway returns a gateway for a ContractVersion in app-a that deploys with a Wallet of the treasury namespace
+	newGateway := func(contractVersion *kontractdeployerv1alpha1.ContractVersion) (*DeployGateway, client.Client, string) {
+		privateKey, err := crypto.GenerateKey()
+		Expect(err).NotTo(HaveOccurred())
+		scheme := newScheme()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			contractVersion,
+			&kontractdeployerv1alpha1.ReferenceGrant{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-a", Namespace: "treasury"},
+				Spec: kontractdeployerv1alpha1.ReferenceGrantSpec{
+					From: []kontractdeployerv1alpha1.ReferenceGrantFrom{{Kind: "ContractVersion", Namespace: "app-a"}},
+					To:   []kontractdeployerv1alpha1.ReferenceGrantTo{{Kind: "Wallet", Name: "deployer"}},
+				},
+			},
+			&kontractdeployerv1alpha1.Wallet{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "treasury"},
+				Status: kontractdeployerv1alpha1.WalletStatus{
+					PublicKey: crypto.PubkeyToAddress(privateKey.PublicKey).Hex(),
+					SecretRef: "deployer-key",
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer-key", Namespace: "treasury"},
+				Data:       map[string][]byte{"privateKey": []byte(hex.EncodeToString(crypto.FromECDSA(privateKey)))},
+			},
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "app-a"},
+				Spec: kontractdeployerv1alpha1.NetworkSpec{
+					NetworkName:    "sepolia",
+					ChainID:        31337,
+					RPCProviderRef: corev1.LocalObjectReference{Name: "infura"},
+				},
+			},
+			&kontractdeployerv1alpha1.RPCProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "infura", Namespace: "app-a"},
+				Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+					ProviderName: "Infura",
+					SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "infura-rpc", URLKey: "url"},
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "infura-rpc", Namespace: "app-a"},
+				Data:       map[string][]byte{"url": []byte(upstream.URL)},
+			},
+		).Build()
+
+		session, err := ensureGatewaySession(ctx, c, scheme, contractVersion)
+		Expect(err).NotTo(HaveOccurred())
+		return &DeployGateway{Client: c}, c, string(session.Data[gatewaySessionTokenKey])
+	}
+	call := func(gateway *DeployGateway, token, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/rpc/app-a/token-v1/"+token, strings.NewReader(body)))
+		return recorder
+	}
+	decode := func(recorder *httptest.ResponseRecorder) gatewayResponse {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		var response gatewayResponse
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		return response
+	}
+
+	It("keeps the token of a session and refuses to take over a Secret it does not own", func() {
+		contractVersion := newContractVersion()
+		_, c, token := newGateway(contractVersion)
+		Expect(token).To(HaveLen(64))
+
+		session, err := ensureGatewaySession(ctx, c, newScheme(), contractVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(session.Data[gatewaySessionTokenKey])).To(Equal(token))
+
+		env := gatewayJobEnv(contractVersion, session)
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: "RPC_URL", Value: "http://deploy-gateway.kontract-system.svc:8555/rpc/app-a/token-v1"}))
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: "RPC_AUTH_TYPE", Value: rpcAuthPath}))
+
+		foreign := newContractVersion()
+		foreign.Name = "vault-v1"
+		Expect(c.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "vault-v1-gateway", Namespace: "app-a"}})).To(Succeed())
+		_, err = ensureGatewaySession(ctx, c, newScheme(), foreign)
+		Expect(err).To(MatchError(ContainSubstring("not managed by")))
+	})
+
+	It("signs the transactions of the Wallet and forwards read calls", func() {
+		gateway, c, token := newGateway(newContractVersion())
+		wallet := &kontractdeployerv1alpha1.Wallet{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "deployer", Namespace: "treasury"}, wallet)).To(Succeed())
+
+		response := decode(call(gateway, token, `{"jsonrpc":"2.0","id":1,"method":"eth_accounts","params":[]}`))
+		Expect(string(response.Result)).To(ContainSubstring(wallet.Status.PublicKey))
+
+		response = decode(call(gateway, token, fmt.Sprintf(
+			`{"jsonrpc":"2.0","id":2,"method":"eth_sendTransaction","params":[{"from":"%s","data":"0x6000"}]}`, wallet.Status.PublicKey)))
+		Expect(response.Error).To(BeNil())
+		Expect(rawTxs).To(HaveLen(1))
+		tx := new(types.Transaction)
+		Expect(tx.UnmarshalBinary(hexutil.MustDecode(rawTxs[0]))).To(Succeed())
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sender.Hex()).To(Equal(wallet.Status.PublicKey))
+		Expect(tx.To()).To(BeNil())
+		Expect(tx.Nonce()).To(Equal(uint64(5)))
+
+		var batch []gatewayResponse
+		recorder := call(gateway, token, `[{"jsonrpc":"2.0","id":3,"method":"eth_blockNumber"},{"jsonrpc":"2.0","id":4,"method":"personal_sign","params":["0x00","0x00"]}]`)
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &batch)).To(Succeed())
+		Expect(batch).To(HaveLen(2))
+		Expect(string(batch[0].Result)).To(Equal(`"0x10"`))
+		Expect(batch[1].Error.Code).To(Equal(jsonRPCMethodNotFound))
+
+		// Messages are never signed, and only the Wallet can send
+		for _, body := range []string{
+			`{"jsonrpc":"2.0","id":5,"method":"eth_sign","params":["0x00","0x00"]}`,
+			`{"jsonrpc":"2.0","id":6,"method":"anvil_setBalance","params":["0x00","0x00"]}`,
+			`{"jsonrpc":"2.0","id":7,"method":"eth_sendTransaction","params":[{"from":"0x00000000000000000000000000000000000000aA","data":"0x"}]}`,
+		} {
+			Expect(decode(call(gateway, token, body)).Error).NotTo(BeNil())
+		}
+		Expect(rawTxs).To(HaveLen(1))
+	})
+
+	It("rejects unknown tokens and finished deployments", func() {
+		gateway, c, token := newGateway(newContractVersion())
+		body := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`
+
+		Expect(call(gateway, "wrong", body).Code).To(Equal(http.StatusUnauthorized))
+		Expect(call(gateway, token, body).Code).To(Equal(http.StatusOK))
+
+		contractVersion := &kontractdeployerv1alpha1.ContractVersion{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "token-v1", Namespace: "app-a"}, contractVersion)).To(Succeed())
+		contractVersion.Status.State = "deployed"
+		Expect(c.Update(ctx, contractVersion)).To(Succeed())
+		Expect(call(gateway, token, body).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("forwards source verification with the API key of the BlockExplorer", func() {
+		var forwarded []url.Values
+		verifier := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.ParseForm()).To(Succeed())
+			mu.Lock()
+			forwarded = append(forwarded, req.Form)
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"status":"1","result":"guid"}`))
+		}))
+		defer verifier.Close()
+
+		contractVersion := newContractVersion()
+		gateway, c, token := newGateway(contractVersion)
+		explorer := &kontractdeployerv1alpha1.BlockExplorer{
+			ObjectMeta: metav1.ObjectMeta{Name: "etherscan", Namespace: "app-a"},
+			Spec: kontractdeployerv1alpha1.BlockExplorerSpec{
+				ExplorerName: "Etherscan",
+				ExplorerType: explorerTypeEtherscanV2,
+				SecretRef:    kontractdeployerv1alpha1.BlockExplorerSecretRef{Name: "etherscan-api", TokenKey: "token", URLKey: "url"},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "etherscan-api", Namespace: "app-a"},
+			Data:       map[string][]byte{"token": []byte("platform-key"), "url": []byte(verifier.URL)},
+		}
+		Expect(c.Create(ctx, explorer)).To(Succeed())
+		Expect(c.Create(ctx, secret)).To(Succeed())
+		network := &kontractdeployerv1alpha1.Network{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "sepolia", Namespace: "app-a"}, network)).To(Succeed())
+		network.Spec.BlockExplorerRef = &corev1.LocalObjectReference{Name: "etherscan"}
+		Expect(c.Update(ctx, network)).To(Succeed())
+
+		session, err := ensureGatewaySession(ctx, c, newScheme(), contractVersion)
+		Expect(err).NotTo(HaveOccurred())
+		env, err := gatewayVerifierEnv(contractVersion, session, explorer, secret, 31337)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: "VERIFIER_URL", Value: "http://deploy-gateway.kontract-system.svc:8555/verify/app-a/token-v1"}))
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: "ETHERSCAN_API_KEY", ValueFrom: gatewayTokenSource(session)}))
+
+		verify := func(apiKey string) *httptest.ResponseRecorder {
+			form := url.Values{"module": {"contract"}, "action": {"verifysourcecode"}, "apikey": {apiKey}}
+			request := httptest.NewRequest(http.MethodPost, "/verify/app-a/token-v1", strings.NewReader(form.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			recorder := httptest.NewRecorder()
+			gateway.ServeHTTP(recorder, request)
+			return recorder
+		}
+		Expect(verify("wrong").Code).To(Equal(http.StatusUnauthorized))
+		Expect(forwarded).To(BeEmpty())
+
+		recorder := verify(token)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring("guid"))
+		Expect(forwarded).To(HaveLen(1))
+		Expect(forwarded[0].Get("apikey")).To(Equal("platform-key"))
+		Expect(forwarded[0].Get("chainid")).To(Equal("31337"))
+		Expect(forwarded[0].Get("action")).To(Equal("verifysourcecode"))
+	})
+
+	It("redacts the token of a session path", func() {
+		Expect(redactGatewayPath("/rpc/app-a/token-v1/secret")).To(Equal("/rpc/app-a/token-v1/***"))
+	})
+})
@@ -0,0 +1,229 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// anvilNetworkName is the NetworkName that marks a local Anvil network
+	anvilNetworkName = "anvil"
+
+	// nativeTransferGas is the gas used by a plain value transfer
+	nativeTransferGas = 21000
+)
+
+// dialNetwork fetches the named Network and returns an Ethereum client connected to its first reachable endpoint
+// Endpoints are tried in routing order, so an unreachable endpoint fails over to the next one
+func dialNetwork(ctx context.Context, c client.Client, namespace, networkName string) (*ethclient.Client, *kontractdeployerv1alpha1.Network, error) {
+	network, err := getNetwork(ctx, c, namespace, networkName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get Network %s: %w", networkName, err)
+	}
+	ethClient, err := dialNetworkEndpoints(ctx, c, network)
+	return ethClient, network, err
+}
+
+// dialNetworkEndpoints returns an Ethereum client connected to the first reachable endpoint of a Network
+func dialNetworkEndpoints(ctx context.Context, c client.Client, network *kontractdeployerv1alpha1.Network) (*ethclient.Client, error) {
+	endpoints, err := networkEndpoints(ctx, c, network)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		ethClient, err := dialEndpoint(ctx, endpoint.conn)
+		if err == nil {
+			return ethClient, nil
+		}
+		errs = append(errs, fmt.Errorf("endpoint %s/%s: %w", endpoint.provider, endpoint.name, err))
+	}
+	return nil, fmt.Errorf("failed to connect to Network %s: %w", network.Name, errors.Join(errs...))
+}
+
+// walletPrivateKey loads the private key of a Wallet from the Secret referenced in its status
+func walletPrivateKey(ctx context.Context, c client.Client, wallet *kontractdeployerv1alpha1.Wallet) (*ecdsa.PrivateKey, error) {
+	if wallet.Status.SecretRef == "" {
+		return nil, fmt.Errorf("wallet %s has no secret reference", wallet.Name)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Name: wallet.Status.SecretRef, Namespace: wallet.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get Wallet Secret %s: %w", wallet.Status.SecretRef, err)
+	}
+
+	// Keystore wallets are decrypted in memory and never written back as a raw key
+	if keyJSON, ok := secret.Data[walletKeystoreKey]; ok {
+		passphrase, err := keystorePassphrase(ctx, c, wallet)
+		if err != nil {
+			return nil, err
+		}
+		return decryptKeystore(keyJSON, passphrase)
+	}
+
+	privateKeyHex := strings.TrimPrefix(strings.TrimSpace(string(secret.Data["privateKey"])), "0x")
+	if privateKeyHex == "" {
+		return nil, fmt.Errorf("privateKey not found in the secret: %s", secret.Name)
+	}
+
+	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid privateKey in the secret %s: %w", secret.Name, err)
+	}
+	return privateKey, nil
+}
+
+// signNativeTransfer signs a plain value transfer with the given nonce without sending it
+func signNativeTransfer(ctx context.Context, ethClient *ethclient.Client, privateKey *ecdsa.PrivateKey, nonce uint64, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	gasPrice, err := ethClient.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	return signLegacyTransaction(ctx, ethClient, privateKey, nonce, to, amount, nil, nativeTransferGas, gasPrice)
+}
+
+// sendContractTransaction signs and sends a contract call with estimated gas and returns its transaction hash
+func sendContractTransaction(ctx context.Context, ethClient *ethclient.Client, privateKey *ecdsa.PrivateKey, to common.Address, value *big.Int, data []byte) (common.Hash, error) {
+	nonce, err := ethClient.PendingNonceAt(ctx, crypto.PubkeyToAddress(privateKey.PublicKey))
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	signedTx, err := signContractTransaction(ctx, ethClient, privateKey, nonce, to, value, data)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := ethClient.SendTransaction(ctx, signedTx); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return signedTx.Hash(), nil
+}
+
+// signContractTransaction signs a contract call with estimated gas and the given nonce without sending it
+func signContractTransaction(ctx context.Context, ethClient *ethclient.Client, privateKey *ecdsa.PrivateKey, nonce uint64, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	gasPrice, err := ethClient.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	gas, err := ethClient.EstimateGas(ctx, ethereum.CallMsg{
+		From:  crypto.PubkeyToAddress(privateKey.PublicKey),
+		To:    &to,
+		Value: value,
+		Data:  data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	return signLegacyTransaction(ctx, ethClient, privateKey, nonce, to, value, data, gas, gasPrice)
+}
+
+// signLegacyTransaction signs a legacy transaction for the chain of ethClient
+func signLegacyTransaction(ctx context.Context, ethClient *ethclient.Client, privateKey *ecdsa.PrivateKey, nonce uint64, to common.Address, value *big.Int, data []byte, gas uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	chainID, err := ethClient.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &to,
+		Value:    value,
+		Gas:      gas,
+		GasPrice: gasPrice,
+		Data:     data,
+	})
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return signedTx, nil
+}
+
+// reusableNonce returns the nonce for a transaction that replaces an earlier one which may not have been mined.
+// The earlier nonce is reused while it is not mined yet, so at most one of the two transactions can be mined.
+func reusableNonce(ctx context.Context, ethClient *ethclient.Client, from common.Address, earlier *uint64) (uint64, error) {
+	if earlier != nil {
+		mined, err := ethClient.NonceAt(ctx, from, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get nonce: %w", err)
+		}
+		if *earlier >= mined {
+			return *earlier, nil
+		}
+	}
+	nonce, err := ethClient.PendingNonceAt(ctx, from)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// parseWei parses a decimal amount of wei
+func parseWei(value string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(strings.TrimSpace(value), 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid wei amount %q", value)
+	}
+	return amount, nil
+}
+
+// contractAddress resolves the address of a Contract on a network, from its import address or its current ContractVersion
+func contractAddress(ctx context.Context, c client.Client, namespace, contractName, networkRef string) (common.Address, error) {
+	contract := &kontractdeployerv1alpha1.Contract{}
+	if err := c.Get(ctx, client.ObjectKey{Name: contractName, Namespace: namespace}, contract); err != nil {
+		return common.Address{}, fmt.Errorf("failed to get Contract %s: %w", contractName, err)
+	}
+
+	address := contract.Spec.ImportContractAddress
+	if !contract.Spec.Import {
+		var versionName string
+		for _, network := range contract.Status.Networks {
+			if network.Network == networkRef {
+				versionName = network.Version
+			}
+		}
+		if versionName == "" {
+			return common.Address{}, fmt.Errorf("contract %s has no version on network %s yet", contractName, networkRef)
+		}
+		contractVersion := &kontractdeployerv1alpha1.ContractVersion{}
+		if err := c.Get(ctx, client.ObjectKey{Name: versionName, Namespace: namespace}, contractVersion); err != nil {
+			return common.Address{}, fmt.Errorf("failed to get ContractVersion %s: %w", versionName, err)
+		}
+		address = contractVersion.Status.ContractAddress
+	}
+
+	if !common.IsHexAddress(address) {
+		return common.Address{}, fmt.Errorf("contract %s has no address on network %s yet", contractName, networkRef)
+	}
+	return common.HexToAddress(address), nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultHealthCheckInterval is how often RPCProviders and BlockExplorers are checked unless their spec sets an interval
+	defaultHealthCheckInterval = time.Minute
+
+	// defaultHealthCheckWorkers bounds how many RPCProviders or BlockExplorers are checked at the same time
+	defaultHealthCheckWorkers = 4
+)
+
+// healthCheckInterval returns the check interval configured in a spec, defaulting to one minute
+func healthCheckInterval(seconds *int64) time.Duration {
+	if seconds == nil || *seconds <= 0 {
+		return defaultHealthCheckInterval
+	}
+	return time.Duration(*seconds) * time.Second
+}
+
+// healthCheckWorkers returns the number of concurrent health checks, defaulting to defaultHealthCheckWorkers
+func healthCheckWorkers(workers int) int {
+	if workers < 1 {
+		return defaultHealthCheckWorkers
+	}
+	return workers
+}
+
+// nextHealthCheck returns how long to wait before the next check of an object, or 0 when a check is due.
+// Objects that were never checked, or whose spec or Secrets changed since the last check, are due right away.
+func nextHealthCheck(lastChecked *metav1.Time, observedGeneration, generation int64, checkedSecrets, secrets map[string]string, interval time.Duration, now time.Time) time.Duration {
+	if lastChecked == nil || observedGeneration != generation || !maps.Equal(checkedSecrets, secrets) {
+		return 0
+	}
+	if wait := lastChecked.Add(interval).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// secretVersions returns the resourceVersion of each named Secret of a namespace
+// A Secret that cannot be read has an empty version, so creating it later forces a check as well
+func secretVersions(ctx context.Context, c client.Client, namespace string, names []string) map[string]string {
+	versions := map[string]string{}
+	for _, name := range names {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, secret); err != nil {
+			versions[name] = ""
+			continue
+		}
+		versions[name] = secret.ResourceVersion
+	}
+	return versions
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// Field indexes of the references between resources. Each value is a referenced object as namespace/name;
+// bare references are also indexed by name, as they may resolve to a ClusterNetwork or ClusterRPCProvider.
+const (
+	networkRefField       = "spec.networkRef"
+	walletRefField        = "spec.walletRef"
+	rpcProviderRefField   = "spec.rpcProviderRef"
+	blockExplorerRefField = "spec.blockExplorerRef"
+	secretRefField        = "spec.secretRef"
+	configMapRefField     = "spec.configMapRef"
+)
+
+// referenceIndex indexes the resources of a kind by the objects of another kind they reference
+type referenceIndex struct {
+	object client.Object
+	field  string
+	refs   func(obj client.Object) []string
+}
+
+// referenceIndexes lists the field indexes of the references between resources
+var referenceIndexes = []referenceIndex{
+	{&kontractdeployerv1alpha1.Contract{}, networkRefField, func(obj client.Object) []string {
+		return obj.(*kontractdeployerv1alpha1.Contract).Spec.NetworkRefs
+	}},
+	{&kontractdeployerv1alpha1.ContractVersion{}, networkRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.ContractVersion).Spec.NetworkRef}
+	}},
+	{&kontractdeployerv1alpha1.Action{}, networkRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.Action).Spec.NetworkRef}
+	}},
+	{&kontractdeployerv1alpha1.ContractProxy{}, networkRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.ContractProxy).Spec.NetworkRef}
+	}},
+	{&kontractdeployerv1alpha1.ProxyAdmin{}, networkRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.ProxyAdmin).Spec.NetworkRef}
+	}},
+	{&kontractdeployerv1alpha1.Wallet{}, networkRefField, func(obj client.Object) []string {
+		wallet := obj.(*kontractdeployerv1alpha1.Wallet)
+		refs := []string{wallet.Spec.NetworkRef}
+		if wallet.Spec.Funding != nil {
+			for _, target := range wallet.Spec.Funding.Targets {
+				refs = append(refs, target.NetworkRef)
+			}
+		}
+		return refs
+	}},
+
+	{&kontractdeployerv1alpha1.Contract{}, walletRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.Contract).Spec.WalletRef}
+	}},
+	{&kontractdeployerv1alpha1.ContractVersion{}, walletRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.ContractVersion).Spec.WalletRef}
+	}},
+	{&kontractdeployerv1alpha1.Action{}, walletRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.Action).Spec.WalletRef}
+	}},
+	{&kontractdeployerv1alpha1.ContractProxy{}, walletRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.ContractProxy).Spec.WalletRef}
+	}},
+	{&kontractdeployerv1alpha1.ProxyAdmin{}, walletRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.ProxyAdmin).Spec.WalletRef}
+	}},
+	{&kontractdeployerv1alpha1.Wallet{}, walletRefField, func(obj client.Object) []string {
+		wallet := obj.(*kontractdeployerv1alpha1.Wallet)
+		var refs []string
+		if wallet.Spec.Funding != nil {
+			refs = append(refs, wallet.Spec.Funding.FundFrom)
+		}
+		if wallet.Spec.Safe != nil {
+			refs = append(refs, wallet.Spec.Safe.Delegates...)
+		}
+		if wallet.Spec.SmartAccount != nil {
+			refs = append(refs, wallet.Spec.SmartAccount.OwnerRef)
+		}
+		return refs
+	}},
+
+	{&kontractdeployerv1alpha1.Network{}, rpcProviderRefField, func(obj client.Object) []string {
+		return networkProviderNames(obj.(*kontractdeployerv1alpha1.Network))
+	}},
+	{&kontractdeployerv1alpha1.Wallet{}, rpcProviderRefField, func(obj client.Object) []string {
+		wallet := obj.(*kontractdeployerv1alpha1.Wallet)
+		var refs []string
+		if account := wallet.Spec.SmartAccount; account != nil {
+			refs = append(refs, account.BundlerRef)
+			if account.Paymaster != nil {
+				refs = append(refs, account.Paymaster.ServiceRef)
+			}
+		}
+		return refs
+	}},
+
+	{&kontractdeployerv1alpha1.Network{}, blockExplorerRefField, func(obj client.Object) []string {
+		if ref := obj.(*kontractdeployerv1alpha1.Network).Spec.BlockExplorerRef; ref != nil {
+			return []string{ref.Name}
+		}
+		return nil
+	}},
+
+	{&kontractdeployerv1alpha1.RPCProvider{}, secretRefField, func(obj client.Object) []string {
+		return providerSecretNames(obj.(*kontractdeployerv1alpha1.RPCProvider))
+	}},
+	{&kontractdeployerv1alpha1.BlockExplorer{}, secretRefField, func(obj client.Object) []string {
+		return []string{obj.(*kontractdeployerv1alpha1.BlockExplorer).Spec.SecretRef.Name}
+	}},
+	{&kontractdeployerv1alpha1.Wallet{}, secretRefField, func(obj client.Object) []string {
+		wallet := obj.(*kontractdeployerv1alpha1.Wallet)
+		refs := []string{wallet.Status.SecretRef}
+		if wallet.Spec.ImportFrom != nil {
+			refs = append(refs, wallet.Spec.ImportFrom.SecretRef)
+		}
+		if keystore := wallet.Spec.Keystore; keystore != nil {
+			if keystore.PassphraseSecretRef != nil {
+				refs = append(refs, keystore.PassphraseSecretRef.Name)
+			}
+			if keystore.KMS != nil {
+				refs = append(refs, keystore.KMS.WrappedKeySecretRef.Name, keystore.KMS.CredentialsSecretRef.Name)
+			}
+		}
+		if safe := wallet.Spec.Safe; safe != nil && safe.TransactionService != nil && safe.TransactionService.APIKeySecretRef != nil {
+			refs = append(refs, safe.TransactionService.APIKeySecretRef.Name)
+		}
+		return refs
+	}},
+	{&kontractdeployerv1alpha1.GasStrategy{}, secretRefField, func(obj client.Object) []string {
+		ref := obj.(*kontractdeployerv1alpha1.GasStrategy).Spec.SecretRef
+		if ref == nil {
+			return nil
+		}
+		if ref.Namespace != "" {
+			return []string{ref.Namespace + "/" + ref.Name}
+		}
+		return []string{ref.Name}
+	}},
+
+	{&kontractdeployerv1alpha1.Contract{}, configMapRefField, func(obj client.Object) []string {
+		contract := obj.(*kontractdeployerv1alpha1.Contract)
+		var refs []string
+		for _, ref := range []*kontractdeployerv1alpha1.ConfigMapKeyReference{contract.Spec.CodeRef, contract.Spec.TestRef, contract.Spec.ScriptRef, contract.Spec.FoundryConfigRef} {
+			if ref != nil {
+				refs = append(refs, ref.Name)
+			}
+		}
+		for _, module := range contract.Spec.LocalModules {
+			refs = append(refs, module.Name)
+		}
+		return refs
+	}},
+}
+
+// indexValues returns the index values of the references of an object
+func (i referenceIndex) indexValues(obj client.Object) []string {
+	var values []string
+	for _, ref := range i.refs(obj) {
+		if ref == "" {
+			continue
+		}
+		key, err := splitReference(obj.GetNamespace(), ref)
+		if err != nil {
+			continue
+		}
+		values = append(values, key.String())
+		if key.Name == ref {
+			values = append(values, ref)
+		}
+	}
+	return values
+}
+
+// SetupIndexes registers the field indexes of the references between resources, which the controllers use to
+// reconcile the resources that reference a changed object
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, index := range referenceIndexes {
+		if err := indexer.IndexField(ctx, index.object, index.field, index.indexValues); err != nil {
+			return fmt.Errorf("failed to index %T by %s: %w", index.object, index.field, err)
+		}
+	}
+	return nil
+}
+
+// listReferencing returns the resources of the list type that reference obj through the indexed field
+func listReferencing(ctx context.Context, c client.Client, list client.ObjectList, field string, obj client.Object) ([]client.Object, error) {
+	values := []string{client.ObjectKeyFromObject(obj).String()}
+	if servesClusterResource(obj) {
+		values = append(values, obj.GetName())
+	}
+
+	seen := map[client.ObjectKey]bool{}
+	var objects []client.Object
+	for _, value := range values {
+		items := list.DeepCopyObject().(client.ObjectList)
+		if err := c.List(ctx, items, client.MatchingFields{field: value}); err != nil {
+			return nil, fmt.Errorf("failed to list resources by %s: %w", field, err)
+		}
+		_ = meta.EachListItem(items, func(item runtime.Object) error {
+			object := item.(client.Object)
+			if key := client.ObjectKeyFromObject(object); !seen[key] {
+				seen[key] = true
+				objects = append(objects, object)
+			}
+			return nil
+		})
+	}
+	return objects, nil
+}
+
+// referencesObject reports whether a reference made in namespace resolves to obj the way listReferencing matches it
+func referencesObject(namespace, ref string, obj client.Object) bool {
+	key, err := splitReference(namespace, ref)
+	if err != nil {
+		return false
+	}
+	return key == client.ObjectKeyFromObject(obj) || (servesClusterResource(obj) && ref == obj.GetName())
+}
+
+// referencingRequests returns requests for the resources of the list type that reference obj through the indexed field
+func referencingRequests(ctx context.Context, c client.Client, list client.ObjectList, field string, obj client.Object) []reconcile.Request {
+	objects, err := listReferencing(ctx, c, list, field, obj)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list referencing resources")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(objects))
+	for _, object := range objects {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(object)})
+	}
+	return requests
+}
+
+// enqueueReferencing enqueues the resources of the list type that reference the changed object through the indexed field
+func enqueueReferencing(c client.Client, list client.ObjectList, field string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return referencingRequests(ctx, c, list, field, obj)
+	})
+}
+
+// servesClusterResource reports whether an object serves a ClusterNetwork or ClusterRPCProvider,
+// which other namespaces reference by bare name
+func servesClusterResource(obj client.Object) bool {
+	if obj.GetNamespace() != ClusterResourceNamespace {
+		return false
+	}
+	labels := obj.GetLabels()
+	_, network := labels[clusterNetworkLabel]
+	_, rpcProvider := labels[clusterRPCProviderLabel]
+	return network || rpcProvider
+}
+
+// walletKeyPredicate passes Wallet changes that matter to the resources signing with it:
+// changes to its spec, its key or its address. Funding and other status updates are filtered out.
+func walletKeyPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			previous, okPrevious := e.ObjectOld.(*kontractdeployerv1alpha1.Wallet)
+			current, okCurrent := e.ObjectNew.(*kontractdeployerv1alpha1.Wallet)
+			if !okPrevious || !okCurrent {
+				return false
+			}
+			return previous.Generation != current.Generation ||
+				previous.Status.SecretRef != current.Status.SecretRef ||
+				previous.Status.PublicKey != current.Status.PublicKey ||
+				!reflect.DeepEqual(previous.Status.SmartAccount, current.Status.SmartAccount)
+		},
+	}
+}
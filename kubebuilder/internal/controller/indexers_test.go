@@ -0,0 +1,183 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// newIndexedClientBuilder returns a fake client builder with the field indexes of the manager
+func newIndexedClientBuilder(scheme *runtime.Scheme) *fake.ClientBuilder {
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, index := range referenceIndexes {
+		builder = builder.WithIndex(index.object, index.field, index.indexValues)
+	}
+	return builder
+}
+
+var _ = Describe("Dependency watches", func() {
+	ctx := context.Background()
+
+	newScheme := func() *runtime.Scheme {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return scheme
+	}
+	request := func(namespace, name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+	}
+
+	It("maps an RPCProvider to the Networks that use it", func() {
+		c := newIndexedClientBuilder(newScheme()).WithObjects(
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "default"},
+				Spec:       kontractdeployerv1alpha1.NetworkSpec{RPCProviderRef: corev1.LocalObjectReference{Name: "infura"}},
+			},
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "mainnet", Namespace: "default"},
+				Spec: kontractdeployerv1alpha1.NetworkSpec{
+					RPCProviderRef:  corev1.LocalObjectReference{Name: "alchemy"},
+					RPCProviderRefs: []corev1.LocalObjectReference{{Name: "infura"}},
+				},
+			},
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "holesky", Namespace: "default"},
+				Spec:       kontractdeployerv1alpha1.NetworkSpec{RPCProviderRef: corev1.LocalObjectReference{Name: "alchemy"}},
+			},
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "team-a"},
+				Spec:       kontractdeployerv1alpha1.NetworkSpec{RPCProviderRef: corev1.LocalObjectReference{Name: "infura"}},
+			},
+		).Build()
+		provider := &kontractdeployerv1alpha1.RPCProvider{ObjectMeta: metav1.ObjectMeta{Name: "infura", Namespace: "default"}}
+
+		Expect(referencingRequests(ctx, c, &kontractdeployerv1alpha1.NetworkList{}, rpcProviderRefField, provider)).To(ConsistOf(
+			request("default", "sepolia"), request("default", "mainnet"),
+		))
+	})
+
+	It("maps the RPCProvider of a ClusterRPCProvider to the Networks of every namespace that use it", func() {
+		c := newIndexedClientBuilder(newScheme()).WithObjects(
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "team-a"},
+				Spec:       kontractdeployerv1alpha1.NetworkSpec{RPCProviderRef: corev1.LocalObjectReference{Name: "infura"}},
+			},
+			&kontractdeployerv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "team-b"},
+				Spec:       kontractdeployerv1alpha1.NetworkSpec{RPCProviderRef: corev1.LocalObjectReference{Name: "infura"}},
+			},
+		).Build()
+		provider := &kontractdeployerv1alpha1.RPCProvider{ObjectMeta: metav1.ObjectMeta{
+			Name: "infura", Namespace: ClusterResourceNamespace,
+			Labels: map[string]string{clusterRPCProviderLabel: "infura"},
+		}}
+
+		Expect(referencingRequests(ctx, c, &kontractdeployerv1alpha1.NetworkList{}, rpcProviderRefField, provider)).To(ConsistOf(
+			request("team-a", "sepolia"), request("team-b", "sepolia"),
+		))
+
+		// An unlabelled RPCProvider of the cluster resource namespace only serves its own namespace
+		provider.Labels = nil
+		Expect(referencingRequests(ctx, c, &kontractdeployerv1alpha1.NetworkList{}, rpcProviderRefField, provider)).To(BeEmpty())
+	})
+
+	It("maps a ConfigMap to the Contracts that use it", func() {
+		c := newIndexedClientBuilder(newScheme()).WithObjects(
+			&kontractdeployerv1alpha1.Contract{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default"},
+				Spec: kontractdeployerv1alpha1.ContractSpec{
+					CodeRef: &kontractdeployerv1alpha1.ConfigMapKeyReference{Name: "token-code", Key: "Token.sol"},
+				},
+			},
+			&kontractdeployerv1alpha1.Contract{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "default"},
+				Spec: kontractdeployerv1alpha1.ContractSpec{
+					CodeRef: &kontractdeployerv1alpha1.ConfigMapKeyReference{Name: "vault-code", Key: "Vault.sol"},
+				},
+			},
+		).Build()
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "token-code", Namespace: "default"}}
+
+		Expect(referencingRequests(ctx, c, &kontractdeployerv1alpha1.ContractList{}, configMapRefField, configMap)).To(ConsistOf(
+			request("default", "token"),
+		))
+	})
+
+	It("maps a Secret to the RPCProviders that read it", func() {
+		c := newIndexedClientBuilder(newScheme()).WithObjects(
+			&kontractdeployerv1alpha1.RPCProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "infura", Namespace: "default"},
+				Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+					SecretRef: kontractdeployerv1alpha1.SecretKeyReference{Name: "infura-rpc", URLKey: "url"},
+				},
+			},
+		).Build()
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "infura-rpc", Namespace: "default"}}
+
+		Expect(referencingRequests(ctx, c, &kontractdeployerv1alpha1.RPCProviderList{}, secretRefField, secret)).To(ConsistOf(
+			request("default", "infura"),
+		))
+		Expect(referencingRequests(ctx, c, &kontractdeployerv1alpha1.BlockExplorerList{}, secretRefField, secret)).To(BeEmpty())
+	})
+
+	It("maps a Wallet to the Contracts that reference it from another namespace", func() {
+		c := newIndexedClientBuilder(newScheme()).WithObjects(
+			&kontractdeployerv1alpha1.Contract{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "team-a"},
+				Spec:       kontractdeployerv1alpha1.ContractSpec{WalletRef: "treasury/deployer"},
+			},
+		).Build()
+		wallet := &kontractdeployerv1alpha1.Wallet{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "treasury"}}
+
+		Expect(referencingRequests(ctx, c, &kontractdeployerv1alpha1.ContractList{}, walletRefField, wallet)).To(ConsistOf(
+			request("team-a", "token"),
+		))
+	})
+
+	It("passes Wallet updates that change its key and filters out the rest", func() {
+		previous := &kontractdeployerv1alpha1.Wallet{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default", Generation: 1},
+			Status:     kontractdeployerv1alpha1.WalletStatus{PublicKey: "0x1111111111111111111111111111111111111111"},
+		}
+		funded := previous.DeepCopy()
+		funded.ResourceVersion = "2"
+		Expect(walletKeyPredicate().Update(event.UpdateEvent{ObjectOld: previous, ObjectNew: funded})).To(BeFalse())
+
+		rotated := previous.DeepCopy()
+		rotated.Status.PublicKey = "0x2222222222222222222222222222222222222222"
+		Expect(walletKeyPredicate().Update(event.UpdateEvent{ObjectOld: previous, ObjectNew: rotated})).To(BeTrue())
+
+		edited := previous.DeepCopy()
+		edited.Generation = 2
+		Expect(walletKeyPredicate().Update(event.UpdateEvent{ObjectOld: previous, ObjectNew: edited})).To(BeTrue())
+	})
+})
@@ -0,0 +1,268 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// walletKeystoreKey is the Secret key that holds an encrypted V3 JSON keystore
+	walletKeystoreKey = "keystore"
+
+	keyFormatPrivateKey = "PrivateKey"
+	keyFormatKeystore   = "Keystore"
+
+	kmsProviderVaultTransit = "VaultTransit"
+
+	// walletKeystoreDir is where deploy Jobs find the keystore file and its passphrase file
+	walletKeystoreDir = "/home/foundryuser/wallet"
+)
+
+// externalHTTPClient is used for calls to external APIs such as the KMS and the Safe Transaction Service
+var externalHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// secretKeyValue reads a single key of a Secret in the given namespace
+func secretKeyValue(ctx context.Context, c client.Client, namespace string, selector kontractdeployerv1alpha1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Name: selector.Name, Namespace: namespace}, secret); err != nil {
+		return "", fmt.Errorf("failed to get Secret %s: %w", selector.Name, err)
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in the secret: %s", selector.Key, selector.Name)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// keystorePassphrase resolves the passphrase of a keystore wallet from its Secret or KMS data key
+func keystorePassphrase(ctx context.Context, c client.Client, wallet *kontractdeployerv1alpha1.Wallet) (string, error) {
+	spec := wallet.Spec.Keystore
+	switch {
+	case spec == nil:
+		return "", fmt.Errorf("wallet %s has no keystore configuration", wallet.Name)
+	case spec.KMS != nil:
+		return unwrapDataKey(ctx, c, wallet.Namespace, spec.KMS)
+	case spec.PassphraseSecretRef != nil:
+		return secretKeyValue(ctx, c, wallet.Namespace, *spec.PassphraseSecretRef)
+	default:
+		return "", fmt.Errorf("wallet %s keystore has neither a passphrase nor a KMS data key", wallet.Name)
+	}
+}
+
+// unwrapDataKey asks the KMS to decrypt the wrapped data key used as keystore passphrase
+func unwrapDataKey(ctx context.Context, c client.Client, namespace string, kms *kontractdeployerv1alpha1.KMSDataKeySpec) (string, error) {
+	if kms.Provider != kmsProviderVaultTransit {
+		return "", fmt.Errorf("unsupported KMS provider %s", kms.Provider)
+	}
+
+	wrappedKey, err := secretKeyValue(ctx, c, namespace, kms.WrappedKeySecretRef)
+	if err != nil {
+		return "", err
+	}
+	token, err := secretKeyValue(ctx, c, namespace, kms.CredentialsSecretRef)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]string{"ciphertext": wrappedKey})
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("%s/v1/transit/decrypt/%s", strings.TrimRight(kms.Address, "/"), kms.KeyName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create KMS request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := externalHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call KMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("KMS returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode KMS response: %w", err)
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(result.Data.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode data key: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// encryptKeystore encrypts a private key into a V3 JSON keystore
+func encryptKeystore(privateKey *ecdsa.PrivateKey, passphrase string) ([]byte, error) {
+	key := &keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}
+	// Light scrypt parameters keep decryption within the memory limits of the operator and deploy Jobs
+	keyJSON, err := keystore.EncryptKey(key, passphrase, keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt keystore: %w", err)
+	}
+	return keyJSON, nil
+}
+
+// decryptKeystore decrypts a V3 JSON keystore in memory
+func decryptKeystore(keyJSON []byte, passphrase string) (*ecdsa.PrivateKey, error) {
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore: %w", err)
+	}
+	return key.PrivateKey, nil
+}
+
+// keystoreAddress returns the address recorded in a V3 JSON keystore, if any
+func keystoreAddress(keyJSON []byte) string {
+	var header struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(keyJSON, &header); err != nil || !common.IsHexAddress(header.Address) {
+		return ""
+	}
+	return common.HexToAddress(header.Address).Hex()
+}
+
+// keystoreKeyAddresses remembers the address of the key decrypted from each revision of a keystore Secret,
+// so imported keystores are decrypted once per revision and not on every reconcile
+var keystoreKeyAddresses sync.Map
+
+// keystoreKeyAddress decrypts the keystore of a wallet Secret and returns the address of the key it holds
+func keystoreKeyAddress(ctx context.Context, c client.Client, wallet *kontractdeployerv1alpha1.Wallet, secret *corev1.Secret) (string, error) {
+	revision := fmt.Sprintf("%s/%s", secret.UID, secret.ResourceVersion)
+	if address, ok := keystoreKeyAddresses.Load(revision); ok {
+		return address.(string), nil
+	}
+
+	passphrase, err := keystorePassphrase(ctx, c, wallet)
+	if err != nil {
+		return "", err
+	}
+	privateKey, err := decryptKeystore(secret.Data[walletKeystoreKey], passphrase)
+	if err != nil {
+		return "", err
+	}
+	address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+	keystoreKeyAddresses.Store(revision, address)
+	return address, nil
+}
+
+// secretKeyEnv returns an environment variable that is read from a Secret key
+func secretKeyEnv(name string, selector kontractdeployerv1alpha1.SecretKeySelector) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: selector.Name,
+				},
+				Key: selector.Key,
+			},
+		},
+	}
+}
+
+// walletKeystoreEnv returns the environment variables a deploy Job needs to unlock a keystore wallet
+func walletKeystoreEnv(wallet *kontractdeployerv1alpha1.Wallet) ([]corev1.EnvVar, error) {
+	envVars := []corev1.EnvVar{
+		{
+			Name:  "WALLET_KEYSTORE",
+			Value: walletKeystoreDir + "/keystore.json",
+		},
+	}
+
+	spec := wallet.Spec.Keystore
+	switch {
+	case spec == nil:
+		return nil, fmt.Errorf("wallet %s stores a keystore but has no keystore configuration", wallet.Name)
+	case spec.KMS != nil:
+		if spec.KMS.Provider != kmsProviderVaultTransit {
+			return nil, fmt.Errorf("unsupported KMS provider %s", spec.KMS.Provider)
+		}
+		envVars = append(envVars,
+			corev1.EnvVar{Name: "KMS_ADDRESS", Value: strings.TrimRight(spec.KMS.Address, "/")},
+			corev1.EnvVar{Name: "KMS_KEY_NAME", Value: spec.KMS.KeyName},
+			secretKeyEnv("KMS_WRAPPED_KEY", spec.KMS.WrappedKeySecretRef),
+			secretKeyEnv("KMS_TOKEN", spec.KMS.CredentialsSecretRef),
+		)
+	case spec.PassphraseSecretRef != nil:
+		// The passphrase is mounted as a file by walletKeystoreVolume, so it stays off the command line of forge
+		envVars = append(envVars, corev1.EnvVar{Name: "WALLET_KEYSTORE_PASSWORD_FILE", Value: walletKeystoreDir + "/passphrase"})
+	default:
+		return nil, fmt.Errorf("wallet %s keystore has neither a passphrase nor a KMS data key", wallet.Name)
+	}
+	return envVars, nil
+}
+
+// walletKeystoreVolume returns the volume that mounts the keystore of a wallet, and its passphrase when it is
+// kept in a Secret, into walletKeystoreDir of a deploy Job
+func walletKeystoreVolume(wallet *kontractdeployerv1alpha1.Wallet) corev1.Volume {
+	sources := []corev1.VolumeProjection{{
+		Secret: &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: wallet.Status.SecretRef},
+			Items:                []corev1.KeyToPath{{Key: walletKeystoreKey, Path: "keystore.json"}},
+		},
+	}}
+	if spec := wallet.Spec.Keystore; spec != nil && spec.KMS == nil && spec.PassphraseSecretRef != nil {
+		sources = append(sources, corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: spec.PassphraseSecretRef.Name},
+				Items:                []corev1.KeyToPath{{Key: spec.PassphraseSecretRef.Key, Path: "passphrase"}},
+			},
+		})
+	}
+	return corev1.Volume{
+		Name: "wallet-keystore",
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+		},
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -28,7 +30,6 @@ import (
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // NetworkReconciler reconciles a Network object
@@ -42,22 +43,31 @@ type NetworkReconciler struct {
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=networks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=networks/finalizers,verbs=update
 
-// Grant permissions to create Pods
+// Grant permissions to run Anvil and remove the Pods of earlier versions
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=pods/status,verbs=get;update;patch
 
 // Grant permissions to create Services
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 
+// Grant permissions to keep Anvil state and snapshots
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
+
 // Grant permissions to create Secrets
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // Grant permissions to manage Wallets
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=wallets,verbs=get;list;watch;create;update;patch;delete
 
+// Grant permissions to delete the ContractVersions of expired Networks
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contractversions,verbs=get;list;watch;delete
+
 // Grant permissions to manage RPCProviders
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=rpcproviders,verbs=get;list;watch;create;update;patch;delete
 
+// networkFinalizer was added by earlier versions and is removed from existing Networks
 const networkFinalizer = "kontract.expedio.xyz/network-finalizer"
 
 // Reconcile is part of the main Kubernetes reconciliation loop which aims to
@@ -68,6 +78,9 @@ func (r *NetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	// Fetch the Network instance
 	var network kontractdeployerv1alpha1.Network
 	if err := r.Get(ctx, req.NamespacedName, &network); err != nil {
+		if apierrors.IsNotFound(err) {
+			forgetNetworkMetrics(req.Namespace, req.Name)
+		}
 		logger.Error(err, "unable to fetch Network")
 		r.EventRecorder.Event(&network, corev1.EventTypeWarning, "FetchFailed", "Unable to fetch Network")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -75,53 +88,65 @@ func (r *NetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	logger.Info("Successfully fetched Network", "Network.Name", network.Name)
 
-	// Check if the Network instance is marked for deletion
-	if network.ObjectMeta.DeletionTimestamp.IsZero() {
-		// The object is not being deleted, so if it doesn't have our finalizer, add it
-		if !containsString(network.ObjectMeta.Finalizers, networkFinalizer) {
-			network.ObjectMeta.Finalizers = append(network.ObjectMeta.Finalizers, networkFinalizer)
-			if err := r.Update(ctx, &network); err != nil {
-				return ctrl.Result{}, err
-			}
-		}
-	} else {
-		// The object is being deleted
-		if containsString(network.ObjectMeta.Finalizers, networkFinalizer) {
-			// Our finalizer is present, so let's handle any external dependency
-			if err := r.deleteAnvilResources(ctx, &network); err != nil {
-				return ctrl.Result{}, err
-			}
-
-			// Remove our finalizer from the list and update it
-			network.ObjectMeta.Finalizers = removeString(network.ObjectMeta.Finalizers, networkFinalizer)
-			if err := r.Update(ctx, &network); err != nil {
-				return ctrl.Result{}, err
-			}
+	// Earlier versions cleaned up Anvil resources with a finalizer; they are owned by the Network now
+	if containsString(network.ObjectMeta.Finalizers, networkFinalizer) {
+		network.ObjectMeta.Finalizers = removeString(network.ObjectMeta.Finalizers, networkFinalizer)
+		if err := r.Update(ctx, &network); err != nil {
+			return ctrl.Result{}, err
 		}
+	}
+	if !network.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
 
-		// Stop reconciliation as the item is being deleted
+	// Delete ephemeral Networks once they expire
+	expired, recheckExpiry, err := r.reconcileExpiry(ctx, &network)
+	if err != nil {
+		logger.Error(err, "failed to delete expired Network")
+		return ctrl.Result{}, err
+	}
+	if expired {
+		forgetNetworkMetrics(network.Namespace, network.Name)
 		return ctrl.Result{}, nil
 	}
 
-	// Handle Anvil network creation
-	if network.Spec.NetworkName == "anvil" {
-		if err := r.createAnvilResources(ctx, &network); err != nil {
-			logger.Error(err, "failed to create Anvil resources")
-			return ctrl.Result{}, err
-		}
-		if err := r.createAnvilWallet(ctx, &network); err != nil {
-			logger.Error(err, "failed to create Anvil wallet")
+	// Resolve the chain properties from the catalog and the overrides in the spec
+	chain := networkChain(&network)
+	network.Status.Chain = &chain
+	if chain.ChainID == 0 {
+		r.EventRecorder.Event(&network, corev1.EventTypeWarning, "UnknownChain",
+			fmt.Sprintf("Network name %q is not in the chain catalog and no chainID is set", network.Spec.NetworkName))
+	}
+
+	// Run the local chain of an Anvil network
+	if network.Spec.NetworkName == anvilNetworkName {
+		if err := r.reconcileAnvil(ctx, &network); err != nil {
+			logger.Error(err, "failed to reconcile Anvil resources")
+			r.EventRecorder.Event(&network, corev1.EventTypeWarning, "AnvilReconcileFailed", err.Error())
 			return ctrl.Result{}, err
 		}
 	}
 
-	// Fetch the referenced RPCProvider
-	var rpcProvider kontractdeployerv1alpha1.RPCProvider
-	rpcProviderKey := client.ObjectKey{Name: network.Spec.RPCProviderRef.Name, Namespace: network.Namespace}
-	if err := r.Get(ctx, rpcProviderKey, &rpcProvider); err != nil {
-		logger.Error(err, "unable to fetch RPCProvider", "RPCProviderKey", rpcProviderKey)
-		r.EventRecorder.Event(&network, "Warning", "MissingRPCProvider", "RPCProvider is specified but missing")
-		return ctrl.Result{}, err
+	// Fetch the referenced RPCProviders in order of priority
+	providerNames := networkProviderNames(&network)
+	if len(providerNames) == 0 {
+		r.EventRecorder.Event(&network, "Warning", "MissingRPCProvider", "Neither rpcProviderRef nor rpcProviderRefs is specified")
+		return ctrl.Result{RequeueAfter: recheckExpiry}, nil
+	}
+	rpcHealthy := false
+	rpcEndpoint := ""
+	for _, name := range providerNames {
+		rpcProvider, err := getRPCProvider(ctx, r.Client, network.Namespace, name)
+		if err != nil {
+			logger.Error(err, "unable to fetch RPCProvider", "RPCProvider", name)
+			r.EventRecorder.Event(&network, "Warning", "MissingRPCProvider", fmt.Sprintf("RPCProvider %s is specified but missing", name))
+			return ctrl.Result{}, err
+		}
+		// The endpoint of the first healthy provider is the one requests are routed to
+		if rpcEndpoint == "" || (!rpcHealthy && rpcProvider.Status.Healthy) {
+			rpcEndpoint = rpcProvider.Status.APIEndpoint
+		}
+		rpcHealthy = rpcHealthy || rpcProvider.Status.Healthy
 	}
 
 	// Fetch the referenced BlockExplorer if it exists
@@ -134,13 +159,19 @@ func (r *NetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return ctrl.Result{}, err
 		}
 		network.Status.BlockExplorerEndpoint = blockExplorer.Status.APIEndpoint
-		network.Status.Healthy = rpcProvider.Status.Healthy && blockExplorer.Status.Healthy
+		network.Status.Healthy = rpcHealthy && blockExplorer.Status.Healthy
 	} else {
-		network.Status.Healthy = rpcProvider.Status.Healthy
+		network.Status.Healthy = rpcHealthy
 	}
 
 	// Update Network status with RPC endpoint
-	network.Status.RPCEndpoint = rpcProvider.Status.APIEndpoint
+	network.Status.RPCEndpoint = rpcEndpoint
+
+	// Refresh the head block, finality and fees of the chain
+	requeueAfter := r.reconcileTelemetry(ctx, &network)
+	if recheckExpiry > 0 && recheckExpiry < requeueAfter {
+		requeueAfter = recheckExpiry
+	}
 
 	// Update the Network status
 	if err := r.Status().Update(ctx, &network); err != nil {
@@ -151,314 +182,7 @@ func (r *NetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		logger.Info("Network status updated successfully", "Network.Name", network.Name)
 	}
 
-	return ctrl.Result{}, nil
-}
-
-// createAnvilResources creates a Pod and Service for the Anvil network
-func (r *NetworkReconciler) createAnvilResources(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
-	logger := log.FromContext(ctx)
-
-	// Check if the Anvil Pod already exists
-	pod := &corev1.Pod{}
-	err := r.Get(ctx, client.ObjectKey{Name: "anvil-pod", Namespace: network.Namespace}, pod)
-	if err == nil {
-		logger.Info("Anvil Pod already exists")
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Pod")
-		return err
-	} else {
-		// Define the Anvil Pod
-		pod = &corev1.Pod{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "anvil-pod",
-				Namespace: network.Namespace,
-				Labels:    map[string]string{"app": "anvil"},
-			},
-			Spec: corev1.PodSpec{
-				Containers: []corev1.Container{
-					{
-						Name:    "anvil",
-						Image:   "docker.io/expedio/kontract-foundry:latest",
-						Command: []string{"anvil", "--chain-id", fmt.Sprintf("%d", network.Spec.ChainID), "--host", "0.0.0.0"},
-						Ports: []corev1.ContainerPort{
-							{
-								ContainerPort: 8545,
-								HostPort:      8545,
-							},
-						},
-					},
-				},
-			},
-		}
-
-		// Create the Pod
-		if err := r.Create(ctx, pod); err != nil {
-			logger.Error(err, "failed to create Anvil Pod")
-			return err
-		}
-	}
-
-	// Check if the Anvil Service already exists
-	service := &corev1.Service{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil-service", Namespace: network.Namespace}, service)
-	if err == nil {
-		logger.Info("Anvil Service already exists")
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Service")
-		return err
-	} else {
-		// Define the Anvil Service
-		service = &corev1.Service{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "anvil-service",
-				Namespace: network.Namespace,
-				Labels:    map[string]string{"app": "anvil"},
-			},
-			Spec: corev1.ServiceSpec{
-				Selector: map[string]string{"app": "anvil"},
-				Ports: []corev1.ServicePort{
-					{
-						Port:     8545,
-						Protocol: corev1.ProtocolTCP,
-					},
-				},
-			},
-		}
-
-		// Create the Service
-		if err := r.Create(ctx, service); err != nil {
-			logger.Error(err, "failed to create Anvil Service")
-			return err
-		}
-	}
-
-	// Create the RPCProvider and Secret for Anvil
-	if err := r.createAnvilRPCProvider(ctx, network); err != nil {
-		logger.Error(err, "failed to create Anvil RPCProvider and Secret")
-		return err
-	}
-
-	return nil
-}
-
-// createAnvilRPCProvider creates an RPCProvider and Secret for the Anvil network
-func (r *NetworkReconciler) createAnvilRPCProvider(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
-	logger := log.FromContext(ctx)
-
-	// Check if the Anvil RPC Secret already exists
-	secret := &corev1.Secret{}
-	err := r.Get(ctx, client.ObjectKey{Name: "anvil-rpc-secret", Namespace: network.Namespace}, secret)
-	if err == nil {
-		logger.Info("Anvil RPC Secret already exists")
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil RPC Secret")
-		return err
-	} else {
-		// Define the Secret
-		secret = &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "anvil-rpc-secret",
-				Namespace: network.Namespace,
-			},
-			StringData: map[string]string{
-				"urlKey": fmt.Sprintf("http://anvil-service.%s.svc.cluster.local:8545", network.Namespace),
-			},
-		}
-
-		// Create the Secret
-		if err := r.Create(ctx, secret); err != nil {
-			logger.Error(err, "failed to create Anvil RPC Secret")
-			return err
-		}
-	}
-
-	// Check if the Anvil RPCProvider already exists
-	rpcProvider := &kontractdeployerv1alpha1.RPCProvider{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil", Namespace: network.Namespace}, rpcProvider)
-	if err == nil {
-		logger.Info("Anvil RPCProvider already exists")
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil RPCProvider")
-		return err
-	} else {
-		// Define the RPCProvider
-		rpcProvider = &kontractdeployerv1alpha1.RPCProvider{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "anvil",
-				Namespace: network.Namespace,
-			},
-			Spec: kontractdeployerv1alpha1.RPCProviderSpec{
-				ProviderName: "Anvil",
-				SecretRef: kontractdeployerv1alpha1.SecretKeyReference{
-					Name:   "anvil-rpc-secret",
-					URLKey: "urlKey",
-				},
-			},
-		}
-
-		// Create the RPCProvider
-		if err := r.Create(ctx, rpcProvider); err != nil {
-			logger.Error(err, "failed to create Anvil RPCProvider")
-			return err
-		}
-	}
-
-	return nil
-}
-
-// createAnvilWallet creates a Wallet and Secret for the Anvil network
-func (r *NetworkReconciler) createAnvilWallet(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
-	logger := log.FromContext(ctx)
-
-	// Check if the Anvil Wallet Secret already exists
-	secret := &corev1.Secret{}
-	err := r.Get(ctx, client.ObjectKey{Name: "anvil-wallet-secret", Namespace: network.Namespace}, secret)
-	if err == nil {
-		logger.Info("Anvil Wallet Secret already exists")
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Wallet Secret")
-		return err
-	} else {
-		// Define the Secret for the Anvil wallet
-		secret = &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "anvil-wallet-secret",
-				Namespace: network.Namespace,
-			},
-			StringData: map[string]string{
-				"privateKey": "***REMOVED***",
-				"publicKey":  "***REMOVED***",
-			},
-		}
-
-		// Create the Secret
-		if err := r.Create(ctx, secret); err != nil {
-			logger.Error(err, "failed to create Anvil Wallet Secret")
-			return err
-		}
-	}
-
-	// Check if the Anvil Wallet already exists
-	wallet := &kontractdeployerv1alpha1.Wallet{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil-wallet", Namespace: network.Namespace}, wallet)
-	if err == nil {
-		logger.Info("Anvil Wallet already exists")
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Wallet")
-		return err
-	} else {
-		// Define the Wallet
-		wallet = &kontractdeployerv1alpha1.Wallet{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "anvil-wallet",
-				Namespace: network.Namespace,
-			},
-			Spec: kontractdeployerv1alpha1.WalletSpec{
-				WalletType: "EOA",
-				NetworkRef: network.Name,
-				ImportFrom: &kontractdeployerv1alpha1.ImportFromSpec{
-					SecretRef: "anvil-wallet-secret",
-				},
-			},
-			Status: kontractdeployerv1alpha1.WalletStatus{
-				PublicKey: "***REMOVED***",
-				SecretRef: "anvil-wallet-secret",
-			},
-		}
-
-		// Create the Wallet
-		if err := r.Create(ctx, wallet); err != nil {
-			logger.Error(err, "failed to create Anvil Wallet")
-			return err
-		}
-	}
-
-	return nil
-}
-
-// deleteAnvilResources deletes all resources related to the Anvil network
-func (r *NetworkReconciler) deleteAnvilResources(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
-	logger := log.FromContext(ctx)
-
-	// Delete the Anvil Pod
-	pod := &corev1.Pod{}
-	err := r.Get(ctx, client.ObjectKey{Name: "anvil-pod", Namespace: network.Namespace}, pod)
-	if err == nil {
-		if err := r.Delete(ctx, pod); err != nil {
-			logger.Error(err, "failed to delete Anvil Pod")
-			return err
-		}
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Pod")
-		return err
-	}
-
-	// Delete the Anvil Service
-	service := &corev1.Service{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil-service", Namespace: network.Namespace}, service)
-	if err == nil {
-		if err := r.Delete(ctx, service); err != nil {
-			logger.Error(err, "failed to delete Anvil Service")
-			return err
-		}
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Service")
-		return err
-	}
-
-	// Delete the Anvil Wallet Secret
-	secret := &corev1.Secret{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil-wallet-secret", Namespace: network.Namespace}, secret)
-	if err == nil {
-		if err := r.Delete(ctx, secret); err != nil {
-			logger.Error(err, "failed to delete Anvil Wallet Secret")
-			return err
-		}
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Wallet Secret")
-		return err
-	}
-
-	// Delete the Anvil Wallet
-	wallet := &kontractdeployerv1alpha1.Wallet{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil-wallet", Namespace: network.Namespace}, wallet)
-	if err == nil {
-		if err := r.Delete(ctx, wallet); err != nil {
-			logger.Error(err, "failed to delete Anvil Wallet")
-			return err
-		}
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil Wallet")
-		return err
-	}
-
-	// Delete the Anvil RPCProvider Secret
-	rpcSecret := &corev1.Secret{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil-rpc-secret", Namespace: network.Namespace}, rpcSecret)
-	if err == nil {
-		if err := r.Delete(ctx, rpcSecret); err != nil {
-			logger.Error(err, "failed to delete Anvil RPC Secret")
-			return err
-		}
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil RPC Secret")
-		return err
-	}
-
-	// Delete the Anvil RPCProvider
-	rpcProvider := &kontractdeployerv1alpha1.RPCProvider{}
-	err = r.Get(ctx, client.ObjectKey{Name: "anvil", Namespace: network.Namespace}, rpcProvider)
-	if err == nil {
-		if err := r.Delete(ctx, rpcProvider); err != nil {
-			logger.Error(err, "failed to delete Anvil RPCProvider")
-			return err
-		}
-	} else if client.IgnoreNotFound(err) != nil {
-		logger.Error(err, "failed to get Anvil RPCProvider")
-		return err
-	}
-
-	return nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 // Helper functions to manage finalizers
@@ -486,5 +210,10 @@ func (r *NetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.EventRecorder = mgr.GetEventRecorderFor("network-controller")
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.Network{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&kontractdeployerv1alpha1.RPCProvider{}).
+		Owns(&kontractdeployerv1alpha1.Wallet{}).
+		Watches(&kontractdeployerv1alpha1.RPCProvider{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.NetworkList{}, rpcProviderRefField)).
+		Watches(&kontractdeployerv1alpha1.BlockExplorer{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.NetworkList{}, blockExplorerRefField)).
 		Complete(r)
 }
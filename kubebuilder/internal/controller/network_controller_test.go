@@ -18,11 +18,26 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -82,3 +97,582 @@ var _ = Describe("Network Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Anvil workload", func() {
+	ctx := context.Background()
+
+	newAnvilNetwork := func(name string, anvil *kontractdeployerv1alpha1.AnvilSpec) *kontractdeployerv1alpha1.Network {
+		return &kontractdeployerv1alpha1.Network{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+			Spec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: anvilNetworkName, ChainID: 31337, Anvil: anvil},
+		}
+	}
+	newReconciler := func(objs ...client.Object) *NetworkReconciler {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return &NetworkReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+			Scheme:        scheme,
+			EventRecorder: record.NewFakeRecorder(10),
+		}
+	}
+
+	It("builds the anvil command from the Network spec", func() {
+		network := newAnvilNetwork("local", nil)
+		Expect(anvilCommand(network, nil)).To(Equal([]string{"anvil", "--host", "0.0.0.0", "--port", "8545", "--chain-id", "31337", "--accounts", "10"}))
+
+		network.Spec.Anvil = &kontractdeployerv1alpha1.AnvilSpec{
+			Image:            "ghcr.io/foundry-rs/foundry:stable",
+			BlockTimeSeconds: ptr.To(int64(2)),
+			GasLimit:         ptr.To(int64(60000000)),
+			Accounts:         ptr.To(int32(3)),
+		}
+		Expect(anvilCommand(network, nil)).To(ContainElements("--block-time", "2", "--gas-limit", "60000000"))
+		Expect(anvilCommand(network, nil)).To(ContainElements("--accounts", "3"))
+
+		pod := anvilPodSpec(network, nil)
+		Expect(pod.Containers[0].Image).To(Equal("ghcr.io/foundry-rs/foundry:stable"))
+		Expect(pod.Containers[0].ReadinessProbe.TCPSocket).NotTo(BeNil())
+		Expect(pod.Containers[0].ReadinessProbe.Exec).To(BeNil())
+		Expect(pod.Containers[0].Ports[0].HostPort).To(BeZero())
+	})
+
+	It("runs each Network in its own owned StatefulSet and Service", func() {
+		first, second := newAnvilNetwork("first", nil), newAnvilNetwork("second", nil)
+		r := newReconciler(first, second)
+		Expect(r.reconcileAnvil(ctx, first)).To(Succeed())
+		Expect(r.reconcileAnvil(ctx, second)).To(Succeed())
+
+		for _, network := range []*kontractdeployerv1alpha1.Network{first, second} {
+			var statefulSet appsv1.StatefulSet
+			Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: network.Name + "-anvil"}, &statefulSet)).To(Succeed())
+			Expect(statefulSet.OwnerReferences).To(ConsistOf(HaveField("Name", network.Name)))
+			Expect(*statefulSet.Spec.Replicas).To(Equal(int32(1)))
+
+			var service corev1.Service
+			Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: network.Name + "-anvil"}, &service)).To(Succeed())
+			Expect(service.Spec.Selector).To(HaveKeyWithValue(anvilNetworkLabel, network.Name))
+
+			var provider kontractdeployerv1alpha1.RPCProvider
+			Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: network.Name}, &provider)).To(Succeed())
+			Expect(provider.OwnerReferences).To(ConsistOf(HaveField("Name", network.Name)))
+
+			var wallet kontractdeployerv1alpha1.Wallet
+			Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: network.Name + "-wallet"}, &wallet)).To(Succeed())
+			Expect(wallet.Spec.NetworkRef).To(Equal(network.Name))
+
+			Expect(network.Status.Anvil.Endpoint).To(Equal("http://" + network.Name + "-anvil.default.svc.cluster.local:8545"))
+			Expect(network.Status.Anvil.Ready).To(BeFalse())
+			Expect(networkProviderNames(network)).To(Equal([]string{network.Name}))
+		}
+	})
+
+	It("forks another Network at a pinned block with its credentials", func() {
+		var authorization []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			authorization = append(authorization, req.Header.Get("Authorization"))
+			var request struct {
+				Method string `json:"method"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			results := map[string]string{"eth_chainId": `"0x1"`, "eth_blockNumber": `"0x1312d00"`}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + results[request.Method] + `}`))
+		}))
+		defer server.Close()
+
+		mainnet := &kontractdeployerv1alpha1.Network{
+			ObjectMeta: metav1.ObjectMeta{Name: "mainnet", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.NetworkSpec{
+				NetworkName:    "EthereumMainnet",
+				ChainID:        1,
+				RPCProviderRef: corev1.LocalObjectReference{Name: "mainnet-rpc"},
+			},
+		}
+		provider := &kontractdeployerv1alpha1.RPCProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "mainnet-rpc", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+				ProviderName: "Provider",
+				SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "mainnet-rpc", URLKey: "url", TokenKey: "token"},
+				Auth:         &kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthBearer},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "mainnet-rpc", Namespace: "default"},
+			Data:       map[string][]byte{"url": []byte(server.URL), "token": []byte("secret-token")},
+		}
+		rehearsal := newAnvilNetwork("rehearsal", &kontractdeployerv1alpha1.AnvilSpec{ForkFrom: "mainnet"})
+		rehearsal.Spec.ChainID = 1
+		r := newReconciler(mainnet, provider, secret, rehearsal)
+
+		Expect(r.reconcileAnvil(ctx, rehearsal)).To(Succeed())
+		Expect(authorization).To(ContainElement("Bearer secret-token"))
+		Expect(rehearsal.Status.Anvil.ForkSource).To(Equal("mainnet"))
+		Expect(rehearsal.Status.Anvil.ForkBlockNumber).To(Equal(int64(20000000)))
+
+		var forkSecret corev1.Secret
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "rehearsal-fork-secret"}, &forkSecret)).To(Succeed())
+		Expect(string(forkSecret.Data["FORK_URL"])).To(Equal(server.URL))
+		Expect(string(forkSecret.Data["FORK_HEADER_0"])).To(Equal("Authorization: Bearer secret-token"))
+
+		var statefulSet appsv1.StatefulSet
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "rehearsal-anvil"}, &statefulSet)).To(Succeed())
+		container := statefulSet.Spec.Template.Spec.Containers[0]
+		Expect(container.Command).To(ContainElements("--fork-url", "$(FORK_URL)", "--fork-block-number", "20000000", "--fork-header", "$(FORK_HEADER_0)"))
+		Expect(container.Env).To(ContainElement(HaveField("ValueFrom.SecretKeyRef.Key", "FORK_HEADER_0")))
+
+		// The pinned block survives without the source being reachable
+		server.Close()
+		Expect(r.reconcileAnvil(ctx, rehearsal)).To(Succeed())
+		Expect(rehearsal.Status.Anvil.ForkBlockNumber).To(Equal(int64(20000000)))
+
+		rehearsal.Spec.Anvil.ForkFrom = "rehearsal"
+		Expect(r.reconcileAnvil(ctx, rehearsal)).To(MatchError(ContainSubstring("cannot fork itself")))
+	})
+
+	It("derives the dev accounts from the mnemonic and creates a Wallet for each", func() {
+		keys, err := anvilAccountKeys(newAnvilNetwork("local", nil))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(10))
+		Expect(crypto.PubkeyToAddress(keys[0].PublicKey).Hex()).To(Equal("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"))
+		Expect(crypto.PubkeyToAddress(keys[1].PublicKey).Hex()).To(Equal("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"))
+
+		network := newAnvilNetwork("suite", &kontractdeployerv1alpha1.AnvilSpec{
+			Mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			Accounts: ptr.To(int32(3)),
+			Balance:  ptr.To(int64(500)),
+		})
+		Expect(anvilCommand(network, nil)).To(ContainElements("--mnemonic", network.Spec.Anvil.Mnemonic, "--balance", "500"))
+
+		r := newReconciler(network)
+		Expect(r.reconcileAnvil(ctx, network)).To(Succeed())
+		Expect(network.Status.Anvil.Accounts).To(HaveLen(3))
+		Expect(network.Status.Anvil.Accounts[0].Wallet).To(Equal("suite-wallet"))
+		Expect(network.Status.Anvil.Accounts[0].Address).To(Equal("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"))
+		Expect(network.Status.Anvil.Accounts[2].Wallet).To(Equal("suite-wallet-2"))
+
+		var secret corev1.Secret
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "suite-wallet-2-secret"}, &secret)).To(Succeed())
+		Expect(string(secret.Data["publicKey"])).To(Equal(network.Status.Anvil.Accounts[2].Address))
+
+		network.Spec.Anvil.Accounts = ptr.To(int32(1))
+		Expect(r.reconcileAnvil(ctx, network)).To(Succeed())
+		var wallets kontractdeployerv1alpha1.WalletList
+		Expect(r.List(ctx, &wallets)).To(Succeed())
+		Expect(wallets.Items).To(ConsistOf(HaveField("Name", "suite-wallet")))
+		err = r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "suite-wallet-2-secret"}, &corev1.Secret{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		network.Spec.Anvil.Mnemonic = "not a valid mnemonic"
+		Expect(r.reconcileAnvil(ctx, network)).To(MatchError(ContainSubstring("invalid mnemonic")))
+	})
+
+	It("keeps the chain state on a volume when persistence is enabled", func() {
+		network := newAnvilNetwork("persistent", &kontractdeployerv1alpha1.AnvilSpec{
+			Persistence: &kontractdeployerv1alpha1.AnvilPersistenceSpec{DumpIntervalSeconds: ptr.To(int64(10))},
+		})
+		r := newReconciler(network)
+		Expect(r.reconcileAnvil(ctx, network)).To(Succeed())
+
+		var claim corev1.PersistentVolumeClaim
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "persistent-anvil-state"}, &claim)).To(Succeed())
+		Expect(claim.Spec.Resources.Requests.Storage().String()).To(Equal("1Gi"))
+
+		var statefulSet appsv1.StatefulSet
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "persistent-anvil"}, &statefulSet)).To(Succeed())
+		pod := statefulSet.Spec.Template.Spec
+		Expect(pod.Containers[0].Command).To(ContainElements("--state", "/data/state.json", "--state-interval", "10"))
+		Expect(pod.Containers[0].VolumeMounts).To(ContainElement(HaveField("MountPath", "/data")))
+		Expect(pod.Volumes).To(ContainElement(HaveField("PersistentVolumeClaim.ClaimName", "persistent-anvil-state")))
+		Expect(pod.InitContainers).To(HaveLen(1))
+	})
+
+	It("records a reset when the Anvil process restarts", func() {
+		network := newAnvilNetwork("local", nil)
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "local-anvil-0", Namespace: "default", UID: "pod-1", Labels: anvilLabels(network)},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "anvil",
+				Ready: true,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}}},
+		}
+		r := newReconciler(network, pod)
+
+		status := &kontractdeployerv1alpha1.AnvilStatus{}
+		ready, err := r.trackAnvilInstance(ctx, network, status)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeTrue())
+		Expect(status.Instance).To(Equal("pod-1/0"))
+		Expect(status.LastReset).To(BeNil())
+
+		pod.Status.ContainerStatuses[0].RestartCount = 1
+		Expect(r.Status().Update(ctx, pod)).To(Succeed())
+		_, err = r.trackAnvilInstance(ctx, network, status)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.Instance).To(Equal("pod-1/1"))
+		Expect(status.LastReset).NotTo(BeNil())
+
+		old := network.DeepCopy()
+		network.Status.Anvil = status
+		Expect(anvilResetPredicate().Update(event.UpdateEvent{ObjectOld: old, ObjectNew: network})).To(BeTrue())
+		Expect(anvilResetPredicate().Update(event.UpdateEvent{ObjectOld: network, ObjectNew: network})).To(BeFalse())
+	})
+
+	It("takes named snapshots and restores the chain from them", func() {
+		var methods []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var request struct {
+				Method string `json:"method"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			methods = append(methods, request.Method)
+			results := map[string]string{
+				"eth_blockNumber": `"0x2a"`,
+				"anvil_dumpState": `"0x1f8b0800"`,
+				"anvil_reset":     `null`,
+				"anvil_loadState": `true`,
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + results[request.Method] + `}`))
+		}))
+		defer server.Close()
+		conn := rpcConnection{url: server.URL}
+
+		network := newAnvilNetwork("local", &kontractdeployerv1alpha1.AnvilSpec{Snapshots: []string{"seeded"}})
+		r := newReconciler(network)
+		status := &kontractdeployerv1alpha1.AnvilStatus{}
+
+		// Snapshots wait for Anvil to be ready
+		Expect(r.reconcileAnvilSnapshots(ctx, network, status, conn, false)).To(Succeed())
+		Expect(status.Snapshots).To(ConsistOf(HaveField("ConfigMap", "")))
+
+		Expect(r.reconcileAnvilSnapshots(ctx, network, status, conn, true)).To(Succeed())
+		Expect(status.Snapshots).To(HaveLen(1))
+		Expect(status.Snapshots[0].ConfigMap).To(Equal("local-snapshot-seeded"))
+		Expect(status.Snapshots[0].BlockNumber).To(Equal(int64(42)))
+
+		var configMap corev1.ConfigMap
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "local-snapshot-seeded"}, &configMap)).To(Succeed())
+		Expect(configMap.BinaryData[anvilSnapshotKey]).To(Equal([]byte{0x1f, 0x8b, 0x08, 0x00}))
+
+		network.Spec.Anvil.Restore = &kontractdeployerv1alpha1.AnvilRestoreSpec{RequestID: "1", Snapshot: "seeded"}
+		Expect(r.reconcileAnvilRestore(ctx, network, status, conn, true)).To(Succeed())
+		Expect(status.Restore.Phase).To(Equal(restorePhaseCompleted))
+		Expect(status.LastReset).NotTo(BeNil())
+		Expect(methods).To(ContainElements("anvil_dumpState", "anvil_reset", "anvil_loadState"))
+
+		// A restore request runs once
+		methods = nil
+		Expect(r.reconcileAnvilRestore(ctx, network, status, conn, true)).To(Succeed())
+		Expect(methods).To(BeEmpty())
+
+		network.Spec.Anvil.Restore = &kontractdeployerv1alpha1.AnvilRestoreSpec{RequestID: "2", Snapshot: "missing"}
+		Expect(r.reconcileAnvilRestore(ctx, network, status, conn, true)).To(Succeed())
+		Expect(status.Restore.Phase).To(Equal(restorePhaseFailed))
+		Expect(status.Restore.Message).To(ContainSubstring("missing"))
+
+		// Unlisted snapshots are deleted
+		network.Spec.Anvil.Snapshots = nil
+		Expect(r.reconcileAnvilSnapshots(ctx, network, status, conn, true)).To(Succeed())
+		Expect(status.Snapshots).To(BeEmpty())
+		err := r.Get(ctx, client.ObjectKeyFromObject(&configMap), &corev1.ConfigMap{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("removes the fixed-name resources of earlier versions", func() {
+		legacy := []client.Object{
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "anvil-pod", Namespace: "default"}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "anvil-service", Namespace: "default"}},
+			&kontractdeployerv1alpha1.RPCProvider{ObjectMeta: metav1.ObjectMeta{Name: "anvil", Namespace: "default"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "anvil-rpc-secret", Namespace: "default"}},
+			&kontractdeployerv1alpha1.Wallet{ObjectMeta: metav1.ObjectMeta{Name: "anvil-wallet", Namespace: "default"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "anvil-wallet-secret", Namespace: "default"}},
+		}
+		network := newAnvilNetwork("devnet", nil)
+		r := newReconciler(append(legacy, network)...)
+		Expect(r.reconcileAnvil(ctx, network)).To(Succeed())
+		for _, obj := range legacy {
+			err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+			Expect(errors.IsNotFound(err)).To(BeTrue(), obj.GetName())
+		}
+	})
+
+	It("keeps the owned resources of a Network named anvil", func() {
+		network := newAnvilNetwork("anvil", nil)
+		r := newReconciler(network)
+		Expect(r.reconcileAnvil(ctx, network)).To(Succeed())
+		Expect(r.reconcileAnvil(ctx, network)).To(Succeed())
+		Expect(r.Get(ctx, client.ObjectKey{Name: "anvil", Namespace: "default"}, &kontractdeployerv1alpha1.RPCProvider{})).To(Succeed())
+		Expect(r.Get(ctx, client.ObjectKey{Name: "anvil-rpc-secret", Namespace: "default"}, &corev1.Secret{})).To(Succeed())
+		Expect(r.Get(ctx, client.ObjectKey{Name: "anvil-wallet", Namespace: "default"}, &kontractdeployerv1alpha1.Wallet{})).To(Succeed())
+	})
+})
+
+var _ = Describe("Network expiry", func() {
+	ctx := context.Background()
+
+	newReconciler := func(objs ...client.Object) (*NetworkReconciler, *record.FakeRecorder) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		recorder := record.NewFakeRecorder(10)
+		return &NetworkReconciler{
+			Client: newIndexedClientBuilder(scheme).WithObjects(objs...).
+				WithStatusSubresource(&kontractdeployerv1alpha1.Contract{}).Build(),
+			Scheme:        scheme,
+			EventRecorder: recorder,
+		}, recorder
+	}
+	newPreview := func(age, ttl time.Duration) *kontractdeployerv1alpha1.Network {
+		return &kontractdeployerv1alpha1.Network{
+			ObjectMeta: metav1.ObjectMeta{Name: "preview", Namespace: "default", CreationTimestamp: metav1.NewTime(time.Now().Add(-age))},
+			Spec:       kontractdeployerv1alpha1.NetworkSpec{NetworkName: anvilNetworkName, ChainID: 31337, TTL: &metav1.Duration{Duration: ttl}},
+		}
+	}
+
+	It("computes the expiry from the TTL, expiresAt and the extension annotation", func() {
+		network := newPreview(time.Hour, 4*time.Hour)
+		expiry, err := networkExpiry(network)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*expiry).To(BeTemporally("~", time.Now().Add(3*time.Hour), time.Second))
+
+		network.Spec.ExpiresAt = &metav1.Time{Time: time.Now().Add(time.Hour)}
+		expiry, _ = networkExpiry(network)
+		Expect(*expiry).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+
+		network.Annotations = map[string]string{networkExtendTTLAnnotation: "2h"}
+		expiry, _ = networkExpiry(network)
+		Expect(*expiry).To(BeTemporally("~", time.Now().Add(3*time.Hour), time.Second))
+
+		// An invalid extension keeps the configured expiry
+		network.Annotations[networkExtendTTLAnnotation] = "soon"
+		expiry, err = networkExpiry(network)
+		Expect(err).To(HaveOccurred())
+		Expect(*expiry).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+
+		network.Spec.TTL, network.Spec.ExpiresAt = nil, nil
+		Expect(networkExpiry(network)).To(BeNil())
+	})
+
+	It("warns once before the Network expires", func() {
+		network := newPreview(time.Hour, time.Hour+10*time.Minute)
+		r, recorder := newReconciler(network)
+
+		expired, recheck, err := r.reconcileExpiry(ctx, network)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expired).To(BeFalse())
+		Expect(recheck).To(BeNumerically("~", 10*time.Minute, time.Second))
+		Expect(recorder.Events).To(Receive(ContainSubstring("ExpiringSoon")))
+
+		_, _, err = r.reconcileExpiry(ctx, network)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).NotTo(Receive())
+
+		// Extending the TTL moves the next check to the new warning time
+		network.Annotations = map[string]string{networkExtendTTLAnnotation: "1h"}
+		_, recheck, _ = r.reconcileExpiry(ctx, network)
+		Expect(recheck).To(BeNumerically("~", 55*time.Minute, time.Second))
+	})
+
+	It("deletes an expired Network with the Wallets and ContractVersions on it", func() {
+		network := newPreview(5*time.Hour, 4*time.Hour)
+		onPreview := &kontractdeployerv1alpha1.ContractVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-preview-version-1", Namespace: "default"},
+			Spec:       kontractdeployerv1alpha1.ContractVersionSpec{NetworkRef: "preview"},
+		}
+		onSepolia := &kontractdeployerv1alpha1.ContractVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-sepolia-version-1", Namespace: "default"},
+			Spec:       kontractdeployerv1alpha1.ContractVersionSpec{NetworkRef: "sepolia"},
+		}
+		wallet := &kontractdeployerv1alpha1.Wallet{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default"},
+			Spec:       kontractdeployerv1alpha1.WalletSpec{WalletType: "EOA", NetworkRef: "preview"},
+		}
+		// Resources of another namespace reference the Network by namespace/name
+		otherVersion := &kontractdeployerv1alpha1.ContractVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "vault-default-preview-version-1", Namespace: "app-a"},
+			Spec:       kontractdeployerv1alpha1.ContractVersionSpec{NetworkRef: "default/preview"},
+		}
+		otherWallet := &kontractdeployerv1alpha1.Wallet{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "app-a"},
+			Spec:       kontractdeployerv1alpha1.WalletSpec{WalletType: "EOA", NetworkRef: "default/preview"},
+		}
+		// A Wallet that only funds itself on the Network stays
+		funded := &kontractdeployerv1alpha1.Wallet{
+			ObjectMeta: metav1.ObjectMeta{Name: "treasury", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.WalletSpec{WalletType: "EOA", NetworkRef: "sepolia", Funding: &kontractdeployerv1alpha1.FundingSpec{
+				Targets: []kontractdeployerv1alpha1.FundingTarget{{NetworkRef: "preview"}},
+			}},
+		}
+		contract := &kontractdeployerv1alpha1.Contract{
+			ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "app-a"},
+			Spec:       kontractdeployerv1alpha1.ContractSpec{NetworkRefs: []string{"default/preview", "sepolia"}},
+			Status: kontractdeployerv1alpha1.ContractStatus{Networks: []kontractdeployerv1alpha1.ContractNetworkStatus{
+				{Network: "default/preview", Version: otherVersion.Name},
+				{Network: "sepolia", Version: "vault-sepolia-version-1"},
+			}},
+		}
+		r, recorder := newReconciler(network, onPreview, onSepolia, wallet, otherVersion, otherWallet, funded, contract)
+
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(network)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(recorder.Events).To(Receive(ContainSubstring("NetworkExpired")))
+
+		for _, obj := range []client.Object{network, onPreview, wallet, otherVersion, otherWallet} {
+			err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+			Expect(errors.IsNotFound(err)).To(BeTrue(), obj.GetName())
+		}
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(onSepolia), onSepolia)).To(Succeed())
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(funded), funded)).To(Succeed())
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(contract), contract)).To(Succeed())
+		Expect(contract.Status.Networks).To(Equal([]kontractdeployerv1alpha1.ContractNetworkStatus{{Network: "sepolia", Version: "vault-sepolia-version-1"}}))
+	})
+})
+
+var _ = Describe("Chain catalog", func() {
+	It("fills in the chain properties from the network name", func() {
+		for _, name := range []string{"base-sepolia", "Base Sepolia", "BaseSepolia"} {
+			network := &kontractdeployerv1alpha1.Network{Spec: kontractdeployerv1alpha1.NetworkSpec{NetworkName: name}}
+			chain := networkChain(network)
+			Expect(chain.Catalog).To(Equal("base-sepolia"))
+			Expect(chain.ChainID).To(Equal(int64(84532)))
+			Expect(chain.CurrencySymbol).To(Equal("ETH"))
+			Expect(chain.BlockTimeMillis).To(Equal(int64(2000)))
+		}
+
+		mainnet := &kontractdeployerv1alpha1.Network{Spec: kontractdeployerv1alpha1.NetworkSpec{NetworkName: "EthereumMainnet"}}
+		Expect(networkChainID(mainnet)).To(Equal(int64(1)))
+
+		bnb := networkChain(&kontractdeployerv1alpha1.Network{Spec: kontractdeployerv1alpha1.NetworkSpec{NetworkName: "bsc"}})
+		Expect(bnb.CurrencySymbol).To(Equal("BNB"))
+		Expect(bnb.EIP1559).To(BeFalse())
+
+		local := &kontractdeployerv1alpha1.Network{ObjectMeta: metav1.ObjectMeta{Name: "local"}, Spec: kontractdeployerv1alpha1.NetworkSpec{NetworkName: anvilNetworkName}}
+		Expect(anvilCommand(local, nil)).To(ContainElements("--chain-id", "31337"))
+	})
+
+	It("applies the overrides of the spec and describes unknown chains", func() {
+		network := &kontractdeployerv1alpha1.Network{Spec: kontractdeployerv1alpha1.NetworkSpec{
+			NetworkName: "polygon",
+			Chain: &kontractdeployerv1alpha1.ChainSpec{
+				FinalityDepth: ptr.To(int64(256)),
+				ExplorerType:  "Blockscout",
+			},
+		}}
+		chain := networkChain(network)
+		Expect(chain.ChainID).To(Equal(int64(137)))
+		Expect(chain.CurrencySymbol).To(Equal("POL"))
+		Expect(chain.FinalityDepth).To(Equal(int64(256)))
+		Expect(chain.ExplorerType).To(Equal("Blockscout"))
+
+		custom := &kontractdeployerv1alpha1.Network{Spec: kontractdeployerv1alpha1.NetworkSpec{
+			NetworkName: "internal-devnet",
+			ChainID:     424242,
+			Chain:       &kontractdeployerv1alpha1.ChainSpec{CurrencySymbol: "DEV", EIP1559: ptr.To(true)},
+		}}
+		chain = networkChain(custom)
+		Expect(chain.Catalog).To(BeEmpty())
+		Expect(chain.ChainID).To(Equal(int64(424242)))
+		Expect(chain.CurrencyDecimals).To(Equal(int32(18)))
+		Expect(chain.EIP1559).To(BeTrue())
+
+		custom.Spec.ChainID = 0
+		Expect(networkChainID(custom)).To(BeZero())
+	})
+})
+
+var _ = Describe("Chain telemetry", func() {
+	ctx := context.Background()
+
+	It("records the head, finality, fees and block time of the chain in status and metrics", func() {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			var request struct {
+				Method string        `json:"method"`
+				Params []interface{} `json:"params"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			result := `null`
+			switch request.Method {
+			case "eth_maxPriorityFeePerGas":
+				result = `"0x3b9aca00"`
+			case "eth_getBlockByNumber":
+				switch request.Params[0] {
+				case "latest":
+					result = `{"number":"0x64","timestamp":"0x66f00000","baseFeePerGas":"0x2540be400"}`
+				case "safe":
+					result = `{"number":"0x60","timestamp":"0x66efffd0"}`
+				case "finalized":
+					_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"unknown block"}}`))
+					return
+				case "0x50":
+					result = `{"number":"0x50","timestamp":"0x66efff10"}`
+				}
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+		}))
+		defer server.Close()
+
+		network := &kontractdeployerv1alpha1.Network{
+			ObjectMeta: metav1.ObjectMeta{Name: "sepolia", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.NetworkSpec{
+				NetworkName:    "sepolia",
+				RPCProviderRef: corev1.LocalObjectReference{Name: "sepolia-rpc"},
+			},
+		}
+		provider := &kontractdeployerv1alpha1.RPCProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "sepolia-rpc", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+				ProviderName: "Provider",
+				SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "sepolia-rpc", URLKey: "url"},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sepolia-rpc", Namespace: "default"},
+			Data:       map[string][]byte{"url": []byte(server.URL)},
+		}
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		r := &NetworkReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(network, provider, secret).Build(),
+			Scheme:        scheme,
+			EventRecorder: record.NewFakeRecorder(10),
+		}
+		defer forgetNetworkMetrics("default", "sepolia")
+
+		Expect(r.reconcileTelemetry(ctx, network)).To(Equal(defaultTelemetryInterval))
+		telemetry := network.Status.Telemetry
+		Expect(telemetry.Message).To(BeEmpty())
+		Expect(telemetry.Endpoint).To(Equal("sepolia-rpc/" + defaultEndpointName))
+		Expect(telemetry.HeadBlockNumber).To(Equal(int64(100)))
+		Expect(telemetry.HeadBlockTime.Unix()).To(Equal(int64(0x66f00000)))
+		Expect(telemetry.SafeBlockNumber).To(Equal(ptr.To(int64(96))))
+		Expect(telemetry.FinalizedBlockNumber).To(BeNil())
+		Expect(telemetry.BaseFeeWei).To(Equal("10000000000"))
+		Expect(telemetry.PriorityFeeWei).To(Equal("1000000000"))
+		Expect(telemetry.BlockTimeMillis).To(Equal(int64(12000)))
+
+		Expect(testutil.ToFloat64(networkHeadBlock.WithLabelValues("default", "sepolia", "11155111"))).To(Equal(100.0))
+		Expect(testutil.ToFloat64(networkBaseFee.WithLabelValues("default", "sepolia", "11155111"))).To(Equal(1e10))
+		Expect(testutil.ToFloat64(networkBlockTime.WithLabelValues("default", "sepolia", "11155111"))).To(Equal(12.0))
+		Expect(testutil.CollectAndCount(networkFinalizedBlock)).To(BeZero())
+
+		// A refresh within the interval is skipped
+		queried := requests
+		Expect(r.reconcileTelemetry(ctx, network)).To(BeNumerically("<=", defaultTelemetryInterval))
+		Expect(requests).To(Equal(queried))
+
+		// A failed refresh keeps the last values
+		server.Close()
+		network.Status.Telemetry.LastChecked = metav1.NewTime(time.Now().Add(-time.Minute))
+		r.reconcileTelemetry(ctx, network)
+		Expect(network.Status.Telemetry.Message).NotTo(BeEmpty())
+		Expect(network.Status.Telemetry.HeadBlockNumber).To(Equal(int64(100)))
+
+		forgetNetworkMetrics("default", "sepolia")
+		Expect(testutil.CollectAndCount(networkHeadBlock)).To(BeZero())
+	})
+})
@@ -0,0 +1,235 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// defaultTelemetryInterval is how often the telemetry of a Network is refreshed when it sets no interval
+	defaultTelemetryInterval = 30 * time.Second
+
+	// telemetryBlockWindow is the number of recent blocks the observed block time is averaged over
+	telemetryBlockWindow int64 = 20
+)
+
+var (
+	networkHeadBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_network_head_block",
+		Help: "Latest block number of the chain of a Network",
+	}, []string{"namespace", "network", "chain_id"})
+	networkHeadTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_network_head_timestamp_seconds",
+		Help: "Unix timestamp of the latest block of the chain of a Network",
+	}, []string{"namespace", "network", "chain_id"})
+	networkSafeBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_network_safe_block",
+		Help: "Latest safe block number of the chain of a Network",
+	}, []string{"namespace", "network", "chain_id"})
+	networkFinalizedBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_network_finalized_block",
+		Help: "Latest finalized block number of the chain of a Network",
+	}, []string{"namespace", "network", "chain_id"})
+	networkBaseFee = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_network_base_fee_wei",
+		Help: "Base fee of the latest block of the chain of a Network",
+	}, []string{"namespace", "network", "chain_id"})
+	networkPriorityFee = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_network_priority_fee_wei",
+		Help: "Suggested priority fee on the chain of a Network",
+	}, []string{"namespace", "network", "chain_id"})
+	networkBlockTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_network_block_time_seconds",
+		Help: "Average time between the recent blocks of the chain of a Network",
+	}, []string{"namespace", "network", "chain_id"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(networkHeadBlock, networkHeadTimestamp, networkSafeBlock, networkFinalizedBlock, networkBaseFee, networkPriorityFee, networkBlockTime)
+}
+
+// telemetryBlock is the part of a block the telemetry reads
+type telemetryBlock struct {
+	Number        hexutil.Big    `json:"number"`
+	Timestamp     hexutil.Uint64 `json:"timestamp"`
+	BaseFeePerGas *hexutil.Big   `json:"baseFeePerGas"`
+}
+
+// telemetryInterval returns how often the telemetry of a Network is refreshed
+func telemetryInterval(network *kontractdeployerv1alpha1.Network) time.Duration {
+	if network.Spec.TelemetryIntervalSeconds == nil || *network.Spec.TelemetryIntervalSeconds <= 0 {
+		return defaultTelemetryInterval
+	}
+	return time.Duration(*network.Spec.TelemetryIntervalSeconds) * time.Second
+}
+
+// reconcileTelemetry refreshes the telemetry of a Network once its interval passed and returns when to refresh it again.
+// Status updates trigger a reconcile as well, so a refresh within the interval is skipped.
+func (r *NetworkReconciler) reconcileTelemetry(ctx context.Context, network *kontractdeployerv1alpha1.Network) time.Duration {
+	interval := telemetryInterval(network)
+	now := time.Now()
+	if telemetry := network.Status.Telemetry; telemetry != nil {
+		if wait := telemetry.LastChecked.Add(interval).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+
+	telemetry := &kontractdeployerv1alpha1.ChainTelemetryStatus{}
+	if network.Status.Telemetry != nil {
+		telemetry = network.Status.Telemetry.DeepCopy()
+	}
+	telemetry.LastChecked = metav1.NewTime(now)
+	if err := r.queryTelemetry(ctx, network, telemetry); err != nil {
+		log.FromContext(ctx).Info("Failed to refresh chain telemetry", "Network", network.Name, "error", err.Error())
+		telemetry.Message = err.Error()
+	} else {
+		telemetry.Message = ""
+	}
+	network.Status.Telemetry = telemetry
+	recordNetworkMetrics(network)
+	return interval
+}
+
+// queryTelemetry reads the telemetry from the first endpoint of the Network that answers
+func (r *NetworkReconciler) queryTelemetry(ctx context.Context, network *kontractdeployerv1alpha1.Network, telemetry *kontractdeployerv1alpha1.ChainTelemetryStatus) error {
+	endpoints, err := networkEndpoints(ctx, r.Client, network)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		update := *telemetry
+		if err := queryEndpointTelemetry(ctx, endpoint.conn, &update); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", endpoint.provider, endpoint.name, err))
+			continue
+		}
+		update.Endpoint = endpoint.provider + "/" + endpoint.name
+		*telemetry = update
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// queryEndpointTelemetry reads the head block, finality and fees of a chain from one endpoint.
+// Finality tags and eth_maxPriorityFeePerGas are not supported by every chain, so their failures are tolerated.
+func queryEndpointTelemetry(ctx context.Context, conn rpcConnection, telemetry *kontractdeployerv1alpha1.ChainTelemetryStatus) error {
+	httpClient := conn.httpClient(rpcHealthHTTPClient)
+
+	var head *telemetryBlock
+	if err := jsonRPCCall(ctx, httpClient, conn, "eth_getBlockByNumber", []interface{}{"latest", false}, &head); err != nil {
+		return err
+	}
+	if head == nil {
+		return fmt.Errorf("eth_getBlockByNumber returned no latest block")
+	}
+	headNumber := head.Number.ToInt().Int64()
+	headTime := metav1.NewTime(time.Unix(int64(head.Timestamp), 0))
+	telemetry.HeadBlockNumber = headNumber
+	telemetry.HeadBlockTime = &headTime
+
+	telemetry.BaseFeeWei = ""
+	if head.BaseFeePerGas != nil {
+		telemetry.BaseFeeWei = head.BaseFeePerGas.ToInt().String()
+	}
+
+	telemetry.SafeBlockNumber = taggedBlockNumber(ctx, conn, "safe")
+	telemetry.FinalizedBlockNumber = taggedBlockNumber(ctx, conn, "finalized")
+
+	telemetry.PriorityFeeWei = ""
+	var priorityFee hexutil.Big
+	if err := jsonRPCCall(ctx, httpClient, conn, "eth_maxPriorityFeePerGas", nil, &priorityFee); err == nil {
+		telemetry.PriorityFeeWei = priorityFee.ToInt().String()
+	}
+
+	// Average the block time over the recent blocks
+	telemetry.BlockTimeMillis = 0
+	window := telemetryBlockWindow
+	if headNumber < window {
+		window = headNumber
+	}
+	if window > 0 {
+		var earlier *telemetryBlock
+		if err := jsonRPCCall(ctx, httpClient, conn, "eth_getBlockByNumber", []interface{}{hexutil.Uint64(headNumber - window), false}, &earlier); err == nil && earlier != nil && head.Timestamp >= earlier.Timestamp {
+			telemetry.BlockTimeMillis = int64(head.Timestamp-earlier.Timestamp) * 1000 / window
+		}
+	}
+	return nil
+}
+
+// taggedBlockNumber returns the number of the block with a tag such as "safe", or nil when the chain does not support the tag
+func taggedBlockNumber(ctx context.Context, conn rpcConnection, tag string) *int64 {
+	var block *telemetryBlock
+	if err := jsonRPCCall(ctx, conn.httpClient(rpcHealthHTTPClient), conn, "eth_getBlockByNumber", []interface{}{tag, false}, &block); err != nil || block == nil {
+		return nil
+	}
+	number := block.Number.ToInt().Int64()
+	return &number
+}
+
+// networkMetrics lists the telemetry metrics, which all start with the namespace and Network labels
+func networkMetrics() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{networkHeadBlock, networkHeadTimestamp, networkSafeBlock, networkFinalizedBlock, networkBaseFee, networkPriorityFee, networkBlockTime}
+}
+
+// forgetNetworkMetrics removes the telemetry metrics of a Network
+func forgetNetworkMetrics(namespace, name string) {
+	for _, gauge := range networkMetrics() {
+		gauge.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "network": name})
+	}
+}
+
+// recordNetworkMetrics replaces the telemetry metrics of a Network with its last telemetry
+func recordNetworkMetrics(network *kontractdeployerv1alpha1.Network) {
+	forgetNetworkMetrics(network.Namespace, network.Name)
+
+	telemetry := network.Status.Telemetry
+	if telemetry == nil || telemetry.HeadBlockTime == nil {
+		return
+	}
+	labels := []string{network.Namespace, network.Name, strconv.FormatInt(networkChainID(network), 10)}
+	networkHeadBlock.WithLabelValues(labels...).Set(float64(telemetry.HeadBlockNumber))
+	networkHeadTimestamp.WithLabelValues(labels...).Set(float64(telemetry.HeadBlockTime.Unix()))
+	if telemetry.SafeBlockNumber != nil {
+		networkSafeBlock.WithLabelValues(labels...).Set(float64(*telemetry.SafeBlockNumber))
+	}
+	if telemetry.FinalizedBlockNumber != nil {
+		networkFinalizedBlock.WithLabelValues(labels...).Set(float64(*telemetry.FinalizedBlockNumber))
+	}
+	if fee, err := strconv.ParseFloat(telemetry.BaseFeeWei, 64); err == nil {
+		networkBaseFee.WithLabelValues(labels...).Set(fee)
+	}
+	if fee, err := strconv.ParseFloat(telemetry.PriorityFeeWei, 64); err == nil {
+		networkPriorityFee.WithLabelValues(labels...).Set(fee)
+	}
+	if telemetry.BlockTimeMillis > 0 {
+		networkBlockTime.WithLabelValues(labels...).Set(float64(telemetry.BlockTimeMillis) / 1000)
+	}
+}
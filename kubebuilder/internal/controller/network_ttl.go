@@ -0,0 +1,158 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// networkExtendTTLAnnotation extends the expiry of a Network by a duration, e.g. "2h"
+	// Raising the value extends the Network further
+	networkExtendTTLAnnotation = "kontract.expedio.xyz/extend-ttl"
+
+	// networkExpiryWarning is how long before its expiry the ExpiringSoon event is emitted for a Network
+	networkExpiryWarning = 15 * time.Minute
+)
+
+// networkExpiry returns when a Network expires, or nil when it does not expire
+func networkExpiry(network *kontractdeployerv1alpha1.Network) (*time.Time, error) {
+	var expiry *time.Time
+	if network.Spec.TTL != nil {
+		t := network.CreationTimestamp.Add(network.Spec.TTL.Duration)
+		expiry = &t
+	}
+	if network.Spec.ExpiresAt != nil && (expiry == nil || network.Spec.ExpiresAt.Time.Before(*expiry)) {
+		t := network.Spec.ExpiresAt.Time
+		expiry = &t
+	}
+	if expiry == nil {
+		return nil, nil
+	}
+
+	if value, ok := network.Annotations[networkExtendTTLAnnotation]; ok {
+		extension, err := time.ParseDuration(value)
+		if err != nil || extension < 0 {
+			return expiry, fmt.Errorf("invalid %s annotation %q: the Network expires at %s", networkExtendTTLAnnotation, value, expiry.UTC().Format(time.RFC3339))
+		}
+		t := expiry.Add(extension)
+		expiry = &t
+	}
+	return expiry, nil
+}
+
+// reconcileExpiry records the expiry of a Network, warns before it and deletes the Network once it expired.
+// It returns whether the Network expired and when to check the expiry again.
+func (r *NetworkReconciler) reconcileExpiry(ctx context.Context, network *kontractdeployerv1alpha1.Network) (bool, time.Duration, error) {
+	expiry, err := networkExpiry(network)
+	if err != nil {
+		r.EventRecorder.Event(network, corev1.EventTypeWarning, "InvalidTTLExtension", err.Error())
+	}
+	if expiry == nil {
+		network.Status.ExpiresAt = nil
+		network.Status.ExpiryWarning = nil
+		return false, 0, nil
+	}
+	network.Status.ExpiresAt = &metav1.Time{Time: *expiry}
+
+	remaining := time.Until(*expiry)
+	if remaining <= 0 {
+		return true, 0, r.expireNetwork(ctx, network)
+	}
+
+	if remaining > networkExpiryWarning {
+		return false, remaining - networkExpiryWarning, nil
+	}
+	if network.Status.ExpiryWarning == nil || !network.Status.ExpiryWarning.Equal(network.Status.ExpiresAt) {
+		network.Status.ExpiryWarning = network.Status.ExpiresAt.DeepCopy()
+		r.EventRecorder.Event(network, corev1.EventTypeWarning, "ExpiringSoon",
+			fmt.Sprintf("Network expires at %s; set the %s annotation to extend it", expiry.UTC().Format(time.RFC3339), networkExtendTTLAnnotation))
+	}
+	return false, remaining, nil
+}
+
+// expireNetwork deletes the ContractVersions and Wallets on an expired Network, then the Network itself.
+// Resources of other namespaces that reference the Network are deleted as well, and Contracts forget their versions
+// on it. Jobs and ConfigMaps of the ContractVersions and the Anvil workload are removed with their owners.
+func (r *NetworkReconciler) expireNetwork(ctx context.Context, network *kontractdeployerv1alpha1.Network) error {
+	logger := log.FromContext(ctx)
+	r.EventRecorder.Event(network, corev1.EventTypeNormal, "NetworkExpired",
+		fmt.Sprintf("Network expired at %s and is being deleted", network.Status.ExpiresAt.UTC().Format(time.RFC3339)))
+
+	contractVersions, err := listReferencing(ctx, r.Client, &kontractdeployerv1alpha1.ContractVersionList{}, networkRefField, network)
+	if err != nil {
+		return err
+	}
+	for _, obj := range contractVersions {
+		logger.Info("Deleting ContractVersion of expired Network", "ContractVersion", obj.GetName(), "Namespace", obj.GetNamespace())
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete ContractVersion %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+	}
+
+	// Wallets are indexed by their funding targets too, but only Wallets used on the Network are deleted
+	wallets, err := listReferencing(ctx, r.Client, &kontractdeployerv1alpha1.WalletList{}, networkRefField, network)
+	if err != nil {
+		return err
+	}
+	for _, obj := range wallets {
+		wallet := obj.(*kontractdeployerv1alpha1.Wallet)
+		if !referencesObject(wallet.Namespace, wallet.Spec.NetworkRef, network) {
+			continue
+		}
+		logger.Info("Deleting Wallet of expired Network", "Wallet", wallet.Name, "Namespace", wallet.Namespace)
+		if err := r.Delete(ctx, wallet); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete Wallet %s/%s: %w", wallet.Namespace, wallet.Name, err)
+		}
+	}
+
+	// Without their entry, Contracts deploy a new version once a Network of the same name is created again
+	contracts, err := listReferencing(ctx, r.Client, &kontractdeployerv1alpha1.ContractList{}, networkRefField, network)
+	if err != nil {
+		return err
+	}
+	for _, obj := range contracts {
+		contract := obj.(*kontractdeployerv1alpha1.Contract)
+		networks := make([]kontractdeployerv1alpha1.ContractNetworkStatus, 0, len(contract.Status.Networks))
+		for _, entry := range contract.Status.Networks {
+			if !referencesObject(contract.Namespace, entry.Network, network) {
+				networks = append(networks, entry)
+			}
+		}
+		if len(networks) == len(contract.Status.Networks) {
+			continue
+		}
+		contract.Status.Networks = networks
+		if err := r.Status().Update(ctx, contract); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to update status of Contract %s/%s: %w", contract.Namespace, contract.Name, err)
+		}
+	}
+
+	if err := r.Delete(ctx, network); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete expired Network: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// conditionResolvedRefs reports whether the references of a resource are valid and permitted
+	conditionResolvedRefs = "ResolvedRefs"
+
+	reasonResolved        = "Resolved"
+	reasonInvalidRef      = "InvalidRef"
+	reasonRefNotPermitted = "RefNotPermitted"
+)
+
+// referenceDeniedError reports a reference to another namespace that no ReferenceGrant there permits
+type referenceDeniedError struct {
+	fromKind      string
+	fromNamespace string
+	toKind        string
+	to            client.ObjectKey
+}
+
+func (e *referenceDeniedError) Error() string {
+	return fmt.Sprintf("no ReferenceGrant in namespace %s permits %s in namespace %s to reference %s %s",
+		e.to.Namespace, e.fromKind, e.fromNamespace, e.toKind, e.to.Name)
+}
+
+// splitReference splits a reference of the form namespace/name; a bare name refers to a resource in namespace
+func splitReference(namespace, ref string) (client.ObjectKey, error) {
+	refNamespace, name, found := strings.Cut(ref, "/")
+	if !found {
+		return client.ObjectKey{Name: ref, Namespace: namespace}, nil
+	}
+	if refNamespace == "" || name == "" || strings.Contains(name, "/") {
+		return client.ObjectKey{}, fmt.Errorf("invalid reference %q, expected name or namespace/name", ref)
+	}
+	return client.ObjectKey{Name: name, Namespace: refNamespace}, nil
+}
+
+// referenceKey resolves a reference of a resource of kind fromKind in namespace to a resource of kind toKind.
+// A reference to another namespace is only accepted when a ReferenceGrant in that namespace permits it.
+func referenceKey(ctx context.Context, c client.Client, fromKind, namespace, toKind, ref string) (client.ObjectKey, error) {
+	key, err := splitReference(namespace, ref)
+	if err != nil || key.Namespace == namespace {
+		return key, err
+	}
+
+	var grants kontractdeployerv1alpha1.ReferenceGrantList
+	if err := c.List(ctx, &grants, client.InNamespace(key.Namespace)); err != nil {
+		return key, fmt.Errorf("failed to list ReferenceGrants in namespace %s: %w", key.Namespace, err)
+	}
+	for i := range grants.Items {
+		if referenceGranted(&grants.Items[i], fromKind, namespace, toKind, key.Name) {
+			return key, nil
+		}
+	}
+	return key, &referenceDeniedError{fromKind: fromKind, fromNamespace: namespace, toKind: toKind, to: key}
+}
+
+// referenceGranted reports whether a ReferenceGrant permits a resource of kind fromKind in fromNamespace to reference a resource of its namespace
+func referenceGranted(grant *kontractdeployerv1alpha1.ReferenceGrant, fromKind, fromNamespace, toKind, toName string) bool {
+	from := false
+	for _, f := range grant.Spec.From {
+		if f.Kind == fromKind && f.Namespace == fromNamespace {
+			from = true
+			break
+		}
+	}
+	if !from {
+		return false
+	}
+	for _, to := range grant.Spec.To {
+		if to.Kind == toKind && (to.Name == "" || to.Name == toName) {
+			return true
+		}
+	}
+	return false
+}
+
+// grantedNamespaces returns the namespaces from which a ReferenceGrant permits resources of the kinds to reference its namespace
+func grantedNamespaces(grant client.Object, kinds ...string) []string {
+	referenceGrant, ok := grant.(*kontractdeployerv1alpha1.ReferenceGrant)
+	if !ok {
+		return nil
+	}
+	var namespaces []string
+	for _, from := range referenceGrant.Spec.From {
+		if containsString(kinds, from.Kind) && !containsString(namespaces, from.Namespace) {
+			namespaces = append(namespaces, from.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// referencesNamespace reports whether any of the references names a resource in namespace as namespace/name
+func referencesNamespace(namespace string, refs ...string) bool {
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, namespace+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// getReferencedNetwork fetches the Network a resource of kind fromKind in namespace references
+func getReferencedNetwork(ctx context.Context, c client.Client, fromKind, namespace, ref string) (*kontractdeployerv1alpha1.Network, error) {
+	key, err := referenceKey(ctx, c, fromKind, namespace, "Network", ref)
+	if err != nil {
+		return nil, err
+	}
+	return getNetwork(ctx, c, key.Namespace, key.Name)
+}
+
+// getReferencedWallet fetches the Wallet a resource of kind fromKind in namespace references
+func getReferencedWallet(ctx context.Context, c client.Client, fromKind, namespace, ref string) (*kontractdeployerv1alpha1.Wallet, error) {
+	key, err := referenceKey(ctx, c, fromKind, namespace, "Wallet", ref)
+	if err != nil {
+		return nil, err
+	}
+	wallet := &kontractdeployerv1alpha1.Wallet{}
+	if err := c.Get(ctx, key, wallet); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// contractVersionReferrer returns the kind whose ReferenceGrants apply to the references of a ContractVersion,
+// which is the Contract that created it
+func contractVersionReferrer(contractVersion *kontractdeployerv1alpha1.ContractVersion) string {
+	if owner := metav1.GetControllerOf(contractVersion); owner != nil && owner.Kind == "Contract" {
+		return "Contract"
+	}
+	return "ContractVersion"
+}
+
+// resolvedRefsCondition returns the ResolvedRefs condition of a resource from the error resolving its references
+func resolvedRefsCondition(generation int64, err error) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               conditionResolvedRefs,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonResolved,
+		Message:            "All references are permitted",
+		ObservedGeneration: generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonInvalidRef
+		condition.Message = err.Error()
+		if isReferenceDenied(err) {
+			condition.Reason = reasonRefNotPermitted
+		}
+	}
+	return condition
+}
+
+// isReferenceDenied reports whether an error is caused by a reference that no ReferenceGrant permits
+func isReferenceDenied(err error) bool {
+	var denied *referenceDeniedError
+	return errors.As(err, &denied)
+}
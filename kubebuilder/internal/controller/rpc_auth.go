@@ -0,0 +1,324 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// Ways of sending the token of an RPC endpoint
+const (
+	rpcAuthPath   = "Path"
+	rpcAuthBearer = "Bearer"
+	rpcAuthHeader = "Header"
+	rpcAuthBasic  = "Basic"
+	rpcAuthQuery  = "Query"
+	rpcAuthNone   = "None"
+)
+
+const (
+	// rpcTLSCAKey is the optional key of the client certificate Secret holding the CA of the endpoint
+	rpcTLSCAKey = "ca.crt"
+
+	// rpcTLSMountPath is where deploy Jobs find the client certificate of their endpoint
+	rpcTLSMountPath = "/home/foundryuser/rpc-tls"
+)
+
+// rpcConnection is what a client needs to call an endpoint: its URL and the credentials sent with every request
+type rpcConnection struct {
+	url       string
+	header    http.Header
+	tlsConfig *tls.Config
+	// limiter enforces the rate limit of the RPCProvider over HTTP; WebSocket connections are not limited
+	limiter *rpcLimiter
+}
+
+// rpcAuthType returns the auth type of an endpoint, defaulting to a token in the URL path
+func rpcAuthType(auth *kontractdeployerv1alpha1.RPCAuthSpec) string {
+	if auth == nil || auth.Type == "" {
+		return rpcAuthPath
+	}
+	return auth.Type
+}
+
+// newRPCConnection builds the connection of an endpoint from its Secret and, for mutual TLS, its client certificate Secret
+func newRPCConnection(auth *kontractdeployerv1alpha1.RPCAuthSpec, ref kontractdeployerv1alpha1.SecretKeyReference, secret, tlsSecret *corev1.Secret) (rpcConnection, error) {
+	endpoint := string(secret.Data[ref.URLKey])
+	if endpoint == "" {
+		return rpcConnection{}, fmt.Errorf("missing URL key %s in Secret %s", ref.URLKey, secret.Name)
+	}
+	conn := rpcConnection{url: strings.TrimRight(endpoint, "/"), header: http.Header{}}
+
+	token := ""
+	if ref.TokenKey != "" {
+		token = string(secret.Data[ref.TokenKey])
+	}
+	requireToken := func() error {
+		if token == "" {
+			return fmt.Errorf("auth type %s requires the token key %q in Secret %s", rpcAuthType(auth), ref.TokenKey, secret.Name)
+		}
+		return nil
+	}
+
+	switch rpcAuthType(auth) {
+	case rpcAuthPath:
+		if token != "" {
+			conn.url = fmt.Sprintf("%s/%s", conn.url, token)
+		}
+	case rpcAuthBearer:
+		if err := requireToken(); err != nil {
+			return rpcConnection{}, err
+		}
+		conn.header.Set("Authorization", "Bearer "+token)
+	case rpcAuthHeader:
+		if auth.HeaderName == "" {
+			return rpcConnection{}, errors.New("auth type Header requires headerName")
+		}
+		if err := requireToken(); err != nil {
+			return rpcConnection{}, err
+		}
+		conn.header.Set(auth.HeaderName, token)
+	case rpcAuthBasic:
+		username := ""
+		if ref.UsernameKey != "" {
+			username = string(secret.Data[ref.UsernameKey])
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + token))
+		conn.header.Set("Authorization", "Basic "+credentials)
+	case rpcAuthQuery:
+		if auth.QueryParameter == "" {
+			return rpcConnection{}, errors.New("auth type Query requires queryParameter")
+		}
+		if err := requireToken(); err != nil {
+			return rpcConnection{}, err
+		}
+		parsed, err := url.Parse(conn.url)
+		if err != nil {
+			return rpcConnection{}, fmt.Errorf("invalid URL in Secret %s", secret.Name)
+		}
+		query := parsed.Query()
+		query.Set(auth.QueryParameter, token)
+		parsed.RawQuery = query.Encode()
+		conn.url = parsed.String()
+	case rpcAuthNone:
+	default:
+		return rpcConnection{}, fmt.Errorf("unsupported auth type %s", auth.Type)
+	}
+
+	if auth != nil && auth.TLS != nil {
+		if tlsSecret == nil {
+			return rpcConnection{}, fmt.Errorf("missing client certificate Secret %s", auth.TLS.SecretName)
+		}
+		tlsConfig, err := rpcTLSConfig(tlsSecret)
+		if err != nil {
+			return rpcConnection{}, err
+		}
+		conn.tlsConfig = tlsConfig
+	}
+	return conn, nil
+}
+
+// rpcTLSConfig loads the client certificate, and the CA if present, from a kubernetes.io/tls Secret
+func rpcTLSConfig(secret *corev1.Secret) (*tls.Config, error) {
+	certificate, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate in Secret %s: %w", secret.Name, err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	if ca, ok := secret.Data[rpcTLSCAKey]; ok {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid %s in Secret %s", rpcTLSCAKey, secret.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// httpClient returns base, or a copy of it that presents the client certificate and applies the rate limit of the connection
+func (c rpcConnection) httpClient(base *http.Client) *http.Client {
+	if c.tlsConfig == nil && c.limiter == nil {
+		return base
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if c.tlsConfig != nil {
+		tlsTransport := http.DefaultTransport.(*http.Transport).Clone()
+		tlsTransport.TLSClientConfig = c.tlsConfig
+		transport = tlsTransport
+	}
+	if c.limiter != nil {
+		transport = &rpcLimitedTransport{base: transport, limiter: c.limiter}
+	}
+	return &http.Client{Timeout: base.Timeout, Transport: transport}
+}
+
+// identity returns a digest of the URL and credentials of the connection, which tells endpoints apart without holding their secrets
+func (c rpcConnection) identity() string {
+	digest := sha256.New()
+	fmt.Fprintf(digest, "%s\n", c.url)
+	names := make([]string, 0, len(c.header))
+	for name := range c.header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(digest, "%s: %s\n", name, strings.Join(c.header[name], ","))
+	}
+	if c.tlsConfig != nil {
+		for _, certificate := range c.tlsConfig.Certificates {
+			for _, der := range certificate.Certificate {
+				digest.Write(der)
+			}
+		}
+	}
+	return fmt.Sprintf("%x", digest.Sum(nil))
+}
+
+// dial opens a JSON-RPC client over HTTP or WebSocket that sends the credentials of the connection
+func (c rpcConnection) dial(ctx context.Context) (*rpc.Client, error) {
+	options := []rpc.ClientOption{rpc.WithHeaders(c.header), rpc.WithHTTPClient(c.httpClient(&http.Client{}))}
+	if c.tlsConfig != nil {
+		options = append(options,
+			rpc.WithWebsocketDialer(websocket.Dialer{
+				Proxy:            http.ProxyFromEnvironment,
+				HandshakeTimeout: endpointProbeTimeout,
+				TLSClientConfig:  c.tlsConfig,
+			}),
+		)
+	}
+	return rpc.DialOptions(ctx, c.url, options...)
+}
+
+// loadRPCConnection reads the Secrets of an endpoint and builds its connection; secrets caches Secrets by name
+func loadRPCConnection(ctx context.Context, c client.Client, namespace string, endpoint kontractdeployerv1alpha1.RPCEndpoint, secrets map[string]*corev1.Secret) (rpcConnection, error) {
+	secret, tlsSecret, err := endpointSecrets(ctx, c, namespace, endpoint, secrets)
+	if err != nil {
+		return rpcConnection{}, err
+	}
+	return newRPCConnection(endpoint.Auth, endpoint.SecretRef, secret, tlsSecret)
+}
+
+// loadWebSocketConnection builds the WebSocket connection of an endpoint, or returns nil when it has no WebSocket URL
+func loadWebSocketConnection(ctx context.Context, c client.Client, namespace string, endpoint kontractdeployerv1alpha1.RPCEndpoint, secrets map[string]*corev1.Secret) (*rpcConnection, error) {
+	if endpoint.SecretRef.WebSocketURLKey == "" {
+		return nil, nil
+	}
+	secret, tlsSecret, err := endpointSecrets(ctx, c, namespace, endpoint, secrets)
+	if err != nil {
+		return nil, err
+	}
+	ref := endpoint.SecretRef
+	ref.URLKey = ref.WebSocketURLKey
+	conn, err := newRPCConnection(endpoint.Auth, ref, secret, tlsSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// endpointSecrets fetches the Secret of an endpoint and, for mutual TLS, its client certificate Secret
+func endpointSecrets(ctx context.Context, c client.Client, namespace string, endpoint kontractdeployerv1alpha1.RPCEndpoint, secrets map[string]*corev1.Secret) (*corev1.Secret, *corev1.Secret, error) {
+	getSecret := func(name string) (*corev1.Secret, error) {
+		if secret, ok := secrets[name]; ok {
+			return secret, nil
+		}
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, secret); err != nil {
+			return nil, err
+		}
+		secrets[name] = secret
+		return secret, nil
+	}
+
+	secret, err := getSecret(endpoint.SecretRef.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get RPCProvider Secret %s: %w", endpoint.SecretRef.Name, err)
+	}
+	var tlsSecret *corev1.Secret
+	if endpoint.Auth != nil && endpoint.Auth.TLS != nil {
+		if tlsSecret, err = getSecret(endpoint.Auth.TLS.SecretName); err != nil {
+			return nil, nil, fmt.Errorf("failed to get client certificate Secret %s: %w", endpoint.Auth.TLS.SecretName, err)
+		}
+	}
+	return secret, tlsSecret, nil
+}
+
+// rpcJobEnv passes an endpoint and its credentials to a deploy Job, which applies the auth type in entrypoint.sh.
+// Credentials sent as headers and client certificates go through a local forwarder in the Job, since forge cannot send them itself.
+func rpcJobEnv(endpoint resolvedEndpoint) ([]corev1.EnvVar, []corev1.Volume, []corev1.VolumeMount) {
+	secretEnv := func(name, key string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: endpoint.secretRef.Name},
+					Key:                  key,
+				},
+			},
+		}
+	}
+
+	envVars := []corev1.EnvVar{
+		secretEnv("RPC_URL", endpoint.secretRef.URLKey),
+		{Name: "RPC_AUTH_TYPE", Value: rpcAuthType(endpoint.auth)},
+	}
+	if endpoint.secretRef.TokenKey != "" {
+		envVars = append(envVars, secretEnv("RPC_KEY", endpoint.secretRef.TokenKey))
+	}
+	if endpoint.secretRef.UsernameKey != "" {
+		envVars = append(envVars, secretEnv("RPC_USERNAME", endpoint.secretRef.UsernameKey))
+	}
+	if endpoint.auth == nil {
+		return envVars, nil, nil
+	}
+	if endpoint.auth.HeaderName != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "RPC_AUTH_HEADER", Value: endpoint.auth.HeaderName})
+	}
+	if endpoint.auth.QueryParameter != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "RPC_AUTH_QUERY_PARAM", Value: endpoint.auth.QueryParameter})
+	}
+	if endpoint.auth.TLS == nil {
+		return envVars, nil, nil
+	}
+
+	envVars = append(envVars, corev1.EnvVar{Name: "RPC_TLS_DIR", Value: rpcTLSMountPath})
+	volumes := []corev1.Volume{{
+		Name: "rpc-tls",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: endpoint.auth.TLS.SecretName},
+		},
+	}}
+	volumeMounts := []corev1.VolumeMount{{Name: "rpc-tls", MountPath: rpcTLSMountPath, ReadOnly: true}}
+	return envVars, volumes, volumeMounts
+}
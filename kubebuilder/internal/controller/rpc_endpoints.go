@@ -0,0 +1,300 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	rpcStrategyWeighted = "Weighted"
+
+	// defaultEndpointName names the single endpoint of an RPCProvider configured through spec.secretRef
+	defaultEndpointName = "default"
+
+	// endpointProbeTimeout bounds the check that an endpoint answers before it is used
+	endpointProbeTimeout = 5 * time.Second
+)
+
+// Routing groups, in the order endpoints are tried
+const (
+	endpointHealthy = iota
+	endpointDegraded
+	endpointUnchecked
+	endpointUnhealthy
+)
+
+// resolvedEndpoint is an RPCProvider endpoint with its connection built from the Secret
+type resolvedEndpoint struct {
+	provider string
+	// namespace holds the RPCProvider and its Secrets, which differs from the namespace of the Network for ClusterRPCProviders
+	namespace string
+	name      string
+	secretRef kontractdeployerv1alpha1.SecretKeyReference
+	auth      *kontractdeployerv1alpha1.RPCAuthSpec
+	conn      rpcConnection
+	// ws is the WebSocket connection of the endpoint, if it has a WebSocket URL
+	ws *rpcConnection
+}
+
+// providerSecretNames returns the names of the Secrets the endpoints of an RPCProvider read
+func providerSecretNames(rpcProvider *kontractdeployerv1alpha1.RPCProvider) []string {
+	var names []string
+	for _, endpoint := range providerEndpoints(rpcProvider) {
+		names = append(names, endpoint.SecretRef.Name)
+		if endpoint.Auth != nil && endpoint.Auth.TLS != nil {
+			names = append(names, endpoint.Auth.TLS.SecretName)
+		}
+	}
+	return names
+}
+
+// providerEndpoints returns the endpoints of an RPCProvider, treating spec.secretRef as a single endpoint.
+// Endpoints without their own auth use the auth of the provider.
+func providerEndpoints(rpcProvider *kontractdeployerv1alpha1.RPCProvider) []kontractdeployerv1alpha1.RPCEndpoint {
+	if len(rpcProvider.Spec.Endpoints) == 0 {
+		return []kontractdeployerv1alpha1.RPCEndpoint{{Name: defaultEndpointName, SecretRef: rpcProvider.Spec.SecretRef, Weight: 1, Auth: rpcProvider.Spec.Auth}}
+	}
+	endpoints := make([]kontractdeployerv1alpha1.RPCEndpoint, 0, len(rpcProvider.Spec.Endpoints))
+	for _, endpoint := range rpcProvider.Spec.Endpoints {
+		if endpoint.Auth == nil {
+			endpoint.Auth = rpcProvider.Spec.Auth
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// endpointGroup returns the routing group of an endpoint from the last health check
+func endpointGroup(rpcProvider *kontractdeployerv1alpha1.RPCProvider, name string) int {
+	for _, status := range rpcProvider.Status.Endpoints {
+		if status.Name != name {
+			continue
+		}
+		switch {
+		case status.Healthy:
+			return endpointHealthy
+		case status.State == rpcStateDegraded:
+			return endpointDegraded
+		}
+		return endpointUnhealthy
+	}
+	// Providers checked before they had per-endpoint status report a single health flag
+	if len(rpcProvider.Status.Endpoints) == 0 && rpcProvider.Status.APIEndpoint != "" {
+		if rpcProvider.Status.Healthy {
+			return endpointHealthy
+		}
+		return endpointUnhealthy
+	}
+	return endpointUnchecked
+}
+
+// weightedOrder shuffles endpoints so that each comes first with a probability proportional to its weight
+func weightedOrder(endpoints []kontractdeployerv1alpha1.RPCEndpoint, random *rand.Rand) []kontractdeployerv1alpha1.RPCEndpoint {
+	remaining := append([]kontractdeployerv1alpha1.RPCEndpoint{}, endpoints...)
+	ordered := make([]kontractdeployerv1alpha1.RPCEndpoint, 0, len(endpoints))
+	for len(remaining) > 0 {
+		total := 0
+		for _, endpoint := range remaining {
+			total += endpointWeight(endpoint)
+		}
+		pick := random.Intn(total)
+		for i, endpoint := range remaining {
+			if pick -= endpointWeight(endpoint); pick < 0 {
+				ordered = append(ordered, endpoint)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return ordered
+}
+
+// endpointWeight returns the weight of an endpoint, defaulting to 1
+func endpointWeight(endpoint kontractdeployerv1alpha1.RPCEndpoint) int {
+	if endpoint.Weight < 1 {
+		return 1
+	}
+	return int(endpoint.Weight)
+}
+
+// routedEndpoint is an endpoint together with its routing priority
+type routedEndpoint struct {
+	endpoint      kontractdeployerv1alpha1.RPCEndpoint
+	provider      *kontractdeployerv1alpha1.RPCProvider
+	group         int
+	providerIndex int
+}
+
+// routeEndpoints orders the endpoints of providers listed by priority.
+// Healthy endpoints come first, then degraded endpoints, then endpoints without health results, then unhealthy endpoints as a last resort.
+// Within a group, providers keep their priority and each provider orders its endpoints by its strategy.
+func routeEndpoints(providers []*kontractdeployerv1alpha1.RPCProvider, random *rand.Rand) []routedEndpoint {
+	var routed []routedEndpoint
+	for providerIndex, rpcProvider := range providers {
+		endpoints := providerEndpoints(rpcProvider)
+		if rpcProvider.Spec.Strategy == rpcStrategyWeighted {
+			endpoints = weightedOrder(endpoints, random)
+		}
+		for _, endpoint := range endpoints {
+			routed = append(routed, routedEndpoint{
+				endpoint:      endpoint,
+				provider:      rpcProvider,
+				group:         endpointGroup(rpcProvider, endpoint.Name),
+				providerIndex: providerIndex,
+			})
+		}
+	}
+	sort.SliceStable(routed, func(i, j int) bool {
+		if routed[i].group != routed[j].group {
+			return routed[i].group < routed[j].group
+		}
+		return routed[i].providerIndex < routed[j].providerIndex
+	})
+	return routed
+}
+
+// networkProviderNames returns the names of the RPCProviders of a Network in order of priority
+func networkProviderNames(network *kontractdeployerv1alpha1.Network) []string {
+	var names []string
+	if network.Spec.RPCProviderRef.Name != "" {
+		names = append(names, network.Spec.RPCProviderRef.Name)
+	}
+	for _, ref := range network.Spec.RPCProviderRefs {
+		if ref.Name != "" && !containsString(names, ref.Name) {
+			names = append(names, ref.Name)
+		}
+	}
+	// Anvil networks default to the RPCProvider the operator creates for them
+	if len(names) == 0 && network.Spec.NetworkName == anvilNetworkName {
+		names = append(names, network.Name)
+	}
+	return names
+}
+
+// resolveEndpoints fetches the named RPCProviders and returns their endpoints in routing order with their URLs
+// Names without an RPCProvider in the namespace resolve to the ClusterRPCProviders that allow the namespace
+func resolveEndpoints(ctx context.Context, c client.Client, namespace string, providerNames []string) ([]resolvedEndpoint, error) {
+	if len(providerNames) == 0 {
+		return nil, errors.New("no RPCProvider configured")
+	}
+
+	var errs []error
+	providers := make([]*kontractdeployerv1alpha1.RPCProvider, 0, len(providerNames))
+	for _, name := range providerNames {
+		rpcProvider, err := getRPCProvider(ctx, c, namespace, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get RPCProvider %s: %w", name, err))
+			continue
+		}
+		providers = append(providers, rpcProvider)
+	}
+
+	// Secrets are cached per namespace, as ClusterRPCProviders keep theirs in the cluster resource namespace
+	namespaceSecrets := map[string]map[string]*corev1.Secret{}
+	var resolved []resolvedEndpoint
+	for _, routed := range routeEndpoints(providers, rand.New(rand.NewSource(time.Now().UnixNano()))) {
+		providerNamespace := routed.provider.Namespace
+		secrets, ok := namespaceSecrets[providerNamespace]
+		if !ok {
+			secrets = map[string]*corev1.Secret{}
+			namespaceSecrets[providerNamespace] = secrets
+		}
+		conn, err := loadRPCConnection(ctx, c, providerNamespace, routed.endpoint, secrets)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conn.limiter = rpcLimiters.forProvider(routed.provider)
+		// A WebSocket URL that cannot be used only disables subscriptions for the endpoint
+		ws, err := loadWebSocketConnection(ctx, c, providerNamespace, routed.endpoint, secrets)
+		if err != nil {
+			ws = nil
+		}
+		resolved = append(resolved, resolvedEndpoint{
+			provider:  routed.provider.Name,
+			namespace: providerNamespace,
+			name:      routed.endpoint.Name,
+			secretRef: routed.endpoint.SecretRef,
+			auth:      routed.endpoint.Auth,
+			conn:      conn,
+			ws:        ws,
+		})
+	}
+
+	if len(resolved) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return resolved, nil
+}
+
+// networkEndpoints returns the endpoints of all RPCProviders of a Network in routing order
+func networkEndpoints(ctx context.Context, c client.Client, network *kontractdeployerv1alpha1.Network) ([]resolvedEndpoint, error) {
+	endpoints, err := resolveEndpoints(ctx, c, network.Namespace, networkProviderNames(network))
+	if err != nil {
+		return nil, fmt.Errorf("no usable endpoint for Network %s: %w", network.Name, err)
+	}
+	return endpoints, nil
+}
+
+// rpcProviderConnection returns the connection of the preferred endpoint of the named RPCProvider
+func rpcProviderConnection(ctx context.Context, c client.Client, namespace, name string) (rpcConnection, error) {
+	endpoints, err := resolveEndpoints(ctx, c, namespace, []string{name})
+	if err != nil {
+		return rpcConnection{}, err
+	}
+	return endpoints[0].conn, nil
+}
+
+// endpointChainIDs remembers the chain ID each endpoint answered with, keyed by the identity of its connection,
+// so endpoints are checked once and not on every dial.
+// A failed health check forgets the endpoint, which is then checked again on its next dial.
+var endpointChainIDs sync.Map
+
+// dialEndpoint connects to an endpoint and checks that it answers, unless it answered before
+func dialEndpoint(ctx context.Context, conn rpcConnection) (*ethclient.Client, error) {
+	rpcClient, err := conn.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ethClient := ethclient.NewClient(rpcClient)
+	if _, ok := endpointChainIDs.Load(conn.identity()); ok {
+		return ethClient, nil
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, endpointProbeTimeout)
+	defer cancel()
+	chainID, err := ethClient.ChainID(probeCtx)
+	if err != nil {
+		ethClient.Close()
+		return nil, err
+	}
+	endpointChainIDs.Store(conn.identity(), chainID.Int64())
+	return ethClient, nil
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	rpcStateHealthy   = "Healthy"
+	rpcStateDegraded  = "Degraded"
+	rpcStateUnhealthy = "Unhealthy"
+
+	defaultMaxBlockLag            = 5
+	defaultMaxLatencyMilliseconds = 2000
+)
+
+// rpcHealthHTTPClient is used for endpoint health checks
+var rpcHealthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	rpcEndpointState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_rpc_endpoint_state",
+		Help: "Health state of an RPC endpoint, 1 for the current state and 0 otherwise",
+	}, []string{"namespace", "rpcprovider", "endpoint", "state"})
+	rpcEndpointLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_rpc_endpoint_latency_seconds",
+		Help: "Response time of the head block request of an RPC endpoint",
+	}, []string{"namespace", "rpcprovider", "endpoint"})
+	rpcEndpointHeadBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_rpc_endpoint_head_block",
+		Help: "Latest block number reported by an RPC endpoint",
+	}, []string{"namespace", "rpcprovider", "endpoint", "chain_id"})
+	rpcEndpointHeadAge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_rpc_endpoint_head_age_seconds",
+		Help: "Age of the latest block reported by an RPC endpoint at the time of the check",
+	}, []string{"namespace", "rpcprovider", "endpoint", "chain_id"})
+	rpcEndpointBlockLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_rpc_endpoint_block_lag",
+		Help: "Blocks an RPC endpoint trails the highest head seen for the same chain",
+	}, []string{"namespace", "rpcprovider", "endpoint", "chain_id"})
+	rpcEndpointSyncing = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_rpc_endpoint_syncing",
+		Help: "1 if eth_syncing reports an RPC endpoint as syncing",
+	}, []string{"namespace", "rpcprovider", "endpoint", "chain_id"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rpcEndpointState, rpcEndpointLatency, rpcEndpointHeadBlock, rpcEndpointHeadAge, rpcEndpointBlockLag, rpcEndpointSyncing)
+}
+
+// rpcHealthThresholds are the health check thresholds of an RPCProvider with defaults applied
+type rpcHealthThresholds struct {
+	maxBlockLag            int64
+	maxLatencyMilliseconds int64
+	maxHeadAgeSeconds      int64
+}
+
+// healthThresholds applies the defaults to the health check thresholds of an RPCProvider
+func healthThresholds(spec *kontractdeployerv1alpha1.RPCHealthCheckSpec) rpcHealthThresholds {
+	thresholds := rpcHealthThresholds{maxBlockLag: defaultMaxBlockLag, maxLatencyMilliseconds: defaultMaxLatencyMilliseconds}
+	if spec == nil {
+		return thresholds
+	}
+	if spec.MaxBlockLag != nil {
+		thresholds.maxBlockLag = *spec.MaxBlockLag
+	}
+	if spec.MaxLatencyMilliseconds != nil {
+		thresholds.maxLatencyMilliseconds = *spec.MaxLatencyMilliseconds
+	}
+	if spec.MaxHeadAgeSeconds != nil {
+		thresholds.maxHeadAgeSeconds = *spec.MaxHeadAgeSeconds
+	}
+	return thresholds
+}
+
+// jsonRPCCall sends a single JSON-RPC request with the credentials of conn and decodes its result
+func jsonRPCCall(ctx context.Context, httpClient *http.Client, conn rpcConnection, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	payload, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conn.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for name, values := range conn.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response to %s: %d", method, resp.StatusCode)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s failed: %s", method, response.Error.Message)
+	}
+	if len(response.Result) == 0 {
+		return fmt.Errorf("invalid JSON-RPC response to %s", method)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// probeEndpoint queries the chain ID, sync state and head block of an endpoint into status
+func probeEndpoint(ctx context.Context, conn rpcConnection, status *kontractdeployerv1alpha1.RPCEndpointStatus) error {
+	if err := queryEndpoint(ctx, conn, status); err != nil {
+		endpointChainIDs.Delete(conn.identity())
+		return err
+	}
+	endpointChainIDs.Store(conn.identity(), status.ChainID)
+	return nil
+}
+
+// queryEndpoint runs the JSON-RPC calls of probeEndpoint
+func queryEndpoint(ctx context.Context, conn rpcConnection, status *kontractdeployerv1alpha1.RPCEndpointStatus) error {
+	httpClient := conn.httpClient(rpcHealthHTTPClient)
+	var chainID hexutil.Big
+	if err := jsonRPCCall(ctx, httpClient, conn, "eth_chainId", nil, &chainID); err != nil {
+		return err
+	}
+
+	// eth_syncing returns false or an object describing the sync progress
+	var syncing json.RawMessage
+	if err := jsonRPCCall(ctx, httpClient, conn, "eth_syncing", nil, &syncing); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var head *struct {
+		Number    hexutil.Big    `json:"number"`
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+	if err := jsonRPCCall(ctx, httpClient, conn, "eth_getBlockByNumber", []interface{}{"latest", false}, &head); err != nil {
+		return err
+	}
+	latency := time.Since(start)
+	if head == nil {
+		return fmt.Errorf("eth_getBlockByNumber returned no latest block")
+	}
+
+	blockTime := metav1.NewTime(time.Unix(int64(head.Timestamp), 0))
+	status.ChainID = chainID.ToInt().Int64()
+	status.Syncing = strings.TrimSpace(string(syncing)) != "false"
+	status.BlockNumber = head.Number.ToInt().Int64()
+	status.BlockTimestamp = &blockTime
+	status.LatencyMilliseconds = latency.Milliseconds()
+	return nil
+}
+
+// highestHeads returns the highest block number seen per chain ID among the probed endpoints
+func highestHeads(results [][]kontractdeployerv1alpha1.RPCEndpointStatus) map[int64]int64 {
+	heads := map[int64]int64{}
+	for _, statuses := range results {
+		for _, status := range statuses {
+			if status.State == rpcStateUnhealthy || status.ChainID == 0 {
+				continue
+			}
+			if status.BlockNumber > heads[status.ChainID] {
+				heads[status.ChainID] = status.BlockNumber
+			}
+		}
+	}
+	return heads
+}
+
+// evaluateEndpoint decides the state of a probed endpoint from the thresholds and the heads of the other endpoints
+func evaluateEndpoint(status *kontractdeployerv1alpha1.RPCEndpointStatus, thresholds rpcHealthThresholds, heads map[int64]int64, expectedChainIDs []int64, now time.Time) {
+	if status.State == rpcStateUnhealthy {
+		status.Healthy = false
+		return
+	}
+
+	if len(expectedChainIDs) > 0 && !containsInt64(expectedChainIDs, status.ChainID) {
+		status.State = rpcStateUnhealthy
+		status.Healthy = false
+		status.Message = fmt.Sprintf("serves chain %d, Networks expect %v", status.ChainID, expectedChainIDs)
+		return
+	}
+
+	status.BlockLag = 0
+	if head := heads[status.ChainID]; head > status.BlockNumber {
+		status.BlockLag = head - status.BlockNumber
+	}
+
+	var reasons []string
+	if status.Syncing {
+		reasons = append(reasons, "node is syncing")
+	}
+	if status.BlockLag > thresholds.maxBlockLag {
+		reasons = append(reasons, fmt.Sprintf("%d blocks behind, at most %d allowed", status.BlockLag, thresholds.maxBlockLag))
+	}
+	if status.LatencyMilliseconds > thresholds.maxLatencyMilliseconds {
+		reasons = append(reasons, fmt.Sprintf("latency %dms above %dms", status.LatencyMilliseconds, thresholds.maxLatencyMilliseconds))
+	}
+	if thresholds.maxHeadAgeSeconds > 0 && status.BlockTimestamp != nil {
+		if age := now.Sub(status.BlockTimestamp.Time); age > time.Duration(thresholds.maxHeadAgeSeconds)*time.Second {
+			reasons = append(reasons, fmt.Sprintf("latest block is %s old, at most %ds allowed", age.Round(time.Second), thresholds.maxHeadAgeSeconds))
+		}
+	}
+
+	if len(reasons) > 0 {
+		status.State = rpcStateDegraded
+		status.Healthy = false
+		status.Message = strings.Join(reasons, "; ")
+		return
+	}
+	status.State = rpcStateHealthy
+	status.Healthy = true
+	status.Message = ""
+}
+
+// providerState summarizes the states of the endpoints of an RPCProvider
+func providerState(statuses []kontractdeployerv1alpha1.RPCEndpointStatus) string {
+	state := rpcStateUnhealthy
+	for _, status := range statuses {
+		switch status.State {
+		case rpcStateHealthy:
+			return rpcStateHealthy
+		case rpcStateDegraded:
+			state = rpcStateDegraded
+		}
+	}
+	return state
+}
+
+// containsInt64 reports whether values contains value
+func containsInt64(values []int64, value int64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// rpcMetrics lists the endpoint metrics, which all start with the namespace and RPCProvider labels
+func rpcMetrics() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{rpcEndpointState, rpcEndpointLatency, rpcEndpointHeadBlock, rpcEndpointHeadAge, rpcEndpointBlockLag, rpcEndpointSyncing}
+}
+
+// forgetRPCMetrics removes the endpoint metrics of an RPCProvider
+func forgetRPCMetrics(namespace, name string) {
+	for _, gauge := range rpcMetrics() {
+		gauge.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "rpcprovider": name})
+	}
+}
+
+// recordRPCMetrics replaces the endpoint metrics of an RPCProvider with the statuses of its last health check
+func recordRPCMetrics(rpcProvider *kontractdeployerv1alpha1.RPCProvider, now time.Time) {
+	forgetRPCMetrics(rpcProvider.Namespace, rpcProvider.Name)
+
+	for _, status := range rpcProvider.Status.Endpoints {
+		labels := []string{rpcProvider.Namespace, rpcProvider.Name, status.Name}
+		for _, state := range []string{rpcStateHealthy, rpcStateDegraded, rpcStateUnhealthy} {
+			value := 0.0
+			if status.State == state {
+				value = 1
+			}
+			rpcEndpointState.WithLabelValues(append(labels, state)...).Set(value)
+		}
+		if status.State == rpcStateUnhealthy && status.ChainID == 0 {
+			continue
+		}
+
+		chainLabels := append(labels, fmt.Sprintf("%d", status.ChainID))
+		rpcEndpointLatency.WithLabelValues(labels...).Set(float64(status.LatencyMilliseconds) / 1000)
+		rpcEndpointHeadBlock.WithLabelValues(chainLabels...).Set(float64(status.BlockNumber))
+		rpcEndpointBlockLag.WithLabelValues(chainLabels...).Set(float64(status.BlockLag))
+		syncing := 0.0
+		if status.Syncing {
+			syncing = 1
+		}
+		rpcEndpointSyncing.WithLabelValues(chainLabels...).Set(syncing)
+		if status.BlockTimestamp != nil {
+			rpcEndpointHeadAge.WithLabelValues(chainLabels...).Set(now.Sub(status.BlockTimestamp.Time).Seconds())
+		}
+	}
+}
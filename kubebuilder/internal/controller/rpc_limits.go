@@ -0,0 +1,328 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// rpcWorkloadOther labels requests of components that did not name themselves
+	rpcWorkloadOther = "Other"
+
+	// rpcMaxThrottleRetries is how often a request answered with HTTP 429 is retried
+	rpcMaxThrottleRetries = 3
+
+	// rpcMinThrottleBackoff and rpcMaxThrottleBackoff bound the pause after HTTP 429 when the provider sends no Retry-After
+	rpcMinThrottleBackoff = time.Second
+	rpcMaxThrottleBackoff = time.Minute
+)
+
+var (
+	rpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kontract_rpc_requests_total",
+		Help: "Requests the operator sent to an RPCProvider, by the component that sent them",
+	}, []string{"namespace", "rpcprovider", "workload"})
+	rpcThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kontract_rpc_throttled_total",
+		Help: "Requests an RPCProvider answered with HTTP 429",
+	}, []string{"namespace", "rpcprovider"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rpcRequests, rpcThrottled)
+}
+
+// rpcWorkloadKey is the context key of the component that sends RPC requests
+type rpcWorkloadKey struct{}
+
+// withRPCWorkload names the component whose RPC requests are made with ctx, for the usage reported per provider
+func withRPCWorkload(ctx context.Context, workload string) context.Context {
+	return context.WithValue(ctx, rpcWorkloadKey{}, workload)
+}
+
+// rpcWorkload returns the component named in ctx
+func rpcWorkload(ctx context.Context) string {
+	if workload, ok := ctx.Value(rpcWorkloadKey{}).(string); ok && workload != "" {
+		return workload
+	}
+	return rpcWorkloadOther
+}
+
+// rpcLimiters holds the limiter of every RPCProvider, shared by all components of the operator
+var rpcLimiters = &rpcLimiterRegistry{limiters: map[types.NamespacedName]*rpcLimiter{}}
+
+// rpcLimiterRegistry keeps one limiter per RPCProvider
+type rpcLimiterRegistry struct {
+	mu       sync.Mutex
+	limiters map[types.NamespacedName]*rpcLimiter
+}
+
+// forProvider returns the limiter of an RPCProvider with its current rate limit applied.
+// A new limiter continues the usage the provider reported for today, so a restart does not reset the daily budget.
+func (r *rpcLimiterRegistry) forProvider(rpcProvider *kontractdeployerv1alpha1.RPCProvider) *rpcLimiter {
+	key := types.NamespacedName{Namespace: rpcProvider.Namespace, Name: rpcProvider.Name}
+
+	r.mu.Lock()
+	limiter, ok := r.limiters[key]
+	if !ok {
+		limiter = &rpcLimiter{key: key, workloads: map[string]int64{}}
+		limiter.seed(rpcProvider.Status.Usage, time.Now())
+		r.limiters[key] = limiter
+	}
+	r.mu.Unlock()
+
+	limiter.configure(rpcProvider.Spec.RateLimit)
+	return limiter
+}
+
+// forget drops the limiter and request metrics of a deleted RPCProvider
+func (r *rpcLimiterRegistry) forget(namespace, name string) {
+	r.mu.Lock()
+	delete(r.limiters, types.NamespacedName{Namespace: namespace, Name: name})
+	r.mu.Unlock()
+
+	labels := prometheus.Labels{"namespace": namespace, "rpcprovider": name}
+	rpcRequests.DeletePartialMatch(labels)
+	rpcThrottled.DeletePartialMatch(labels)
+}
+
+// rpcLimiter enforces the request rate and daily budget of an RPCProvider and counts its usage
+type rpcLimiter struct {
+	key types.NamespacedName
+
+	mu    sync.Mutex
+	rate  *rate.Limiter
+	daily int64
+
+	day       string
+	requests  int64
+	throttled int64
+	workloads map[string]int64
+
+	backoffUntil         time.Time
+	consecutiveThrottles int
+}
+
+// configure applies a rate limit spec; nil fields lift the corresponding limit
+func (l *rpcLimiter) configure(spec *kontractdeployerv1alpha1.RPCRateLimitSpec) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.daily = 0
+	if spec == nil || spec.RequestsPerSecond == nil {
+		l.rate = nil
+	} else {
+		limit := rate.Limit(*spec.RequestsPerSecond)
+		burst := int(*spec.RequestsPerSecond)
+		if spec.Burst != nil {
+			burst = int(*spec.Burst)
+		}
+		if l.rate == nil {
+			l.rate = rate.NewLimiter(limit, burst)
+		} else {
+			l.rate.SetLimit(limit)
+			l.rate.SetBurst(burst)
+		}
+	}
+	if spec != nil && spec.DailyRequests != nil {
+		l.daily = *spec.DailyRequests
+	}
+}
+
+// seed restores the counters of today from the status of the provider
+func (l *rpcLimiter) seed(usage *kontractdeployerv1alpha1.RPCUsageStatus, now time.Time) {
+	l.rollDay(now)
+	if usage == nil || usage.Day != l.day {
+		return
+	}
+	l.requests = usage.Requests
+	l.throttled = usage.Throttled
+	for _, workload := range usage.Workloads {
+		l.workloads[workload.Name] = workload.Requests
+	}
+}
+
+// rollDay resets the counters when a new UTC day starts; the caller holds l.mu
+func (l *rpcLimiter) rollDay(now time.Time) {
+	if day := now.UTC().Format(time.DateOnly); day != l.day {
+		l.day = day
+		l.requests = 0
+		l.throttled = 0
+		l.workloads = map[string]int64{}
+	}
+}
+
+// acquire waits until a request may be sent and counts it against the budget
+func (l *rpcLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	l.rollDay(time.Now())
+	if l.daily > 0 && l.requests >= l.daily {
+		l.mu.Unlock()
+		return fmt.Errorf("daily request budget of RPCProvider %s is exhausted", l.key.Name)
+	}
+	wait := time.Until(l.backoffUntil)
+	limiter := l.rate
+	l.mu.Unlock()
+
+	// Hold back while the provider is throttling
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	workload := rpcWorkload(ctx)
+	l.mu.Lock()
+	l.rollDay(time.Now())
+	l.requests++
+	l.workloads[workload]++
+	l.mu.Unlock()
+	rpcRequests.WithLabelValues(l.key.Namespace, l.key.Name, workload).Inc()
+	return nil
+}
+
+// throttle pauses all requests to the provider after HTTP 429, for retryAfter or, when it is negative, a growing backoff
+func (l *rpcLimiter) throttle(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	backoff := retryAfter
+	if backoff < 0 {
+		backoff = rpcMinThrottleBackoff << l.consecutiveThrottles
+		if backoff > rpcMaxThrottleBackoff || backoff <= 0 {
+			backoff = rpcMaxThrottleBackoff
+		}
+	}
+	l.consecutiveThrottles++
+	l.throttled++
+	if until := time.Now().Add(backoff); until.After(l.backoffUntil) {
+		l.backoffUntil = until
+	}
+	rpcThrottled.WithLabelValues(l.key.Namespace, l.key.Name).Inc()
+}
+
+// succeeded resets the throttling backoff after a request that was not throttled
+func (l *rpcLimiter) succeeded() {
+	l.mu.Lock()
+	l.consecutiveThrottles = 0
+	l.mu.Unlock()
+}
+
+// usage returns the counters of today for the provider status
+func (l *rpcLimiter) usage() *kontractdeployerv1alpha1.RPCUsageStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollDay(time.Now())
+
+	usage := &kontractdeployerv1alpha1.RPCUsageStatus{
+		Day:             l.day,
+		Requests:        l.requests,
+		Throttled:       l.throttled,
+		BudgetExhausted: l.daily > 0 && l.requests >= l.daily,
+	}
+	for name, requests := range l.workloads {
+		usage.Workloads = append(usage.Workloads, kontractdeployerv1alpha1.RPCWorkloadUsage{Name: name, Requests: requests})
+	}
+	sort.Slice(usage.Workloads, func(i, j int) bool { return usage.Workloads[i].Name < usage.Workloads[j].Name })
+	return usage
+}
+
+// rpcLimitedTransport sends requests through the limiter of their RPCProvider and retries those answered with HTTP 429
+type rpcLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *rpcLimiter
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rpcLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// JSON-RPC bodies are small, so they are kept to be sent again on retries
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.acquire(req.Context()); err != nil {
+			return nil, err
+		}
+
+		attemptReq := req.Clone(req.Context())
+		if body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+			attemptReq.ContentLength = int64(len(body))
+		}
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.limiter.succeeded()
+			return resp, nil
+		}
+
+		t.limiter.throttle(parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+		if attempt == rpcMaxThrottleRetries {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date; it returns -1 without a usable header
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return -1
+}
@@ -18,18 +18,20 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"strings"
+	neturl "net/url"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
@@ -40,11 +42,15 @@ type RPCProviderReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder // Event recorder for logging events
+
+	// HealthCheckWorkers is the number of RPCProviders checked at the same time
+	HealthCheckWorkers int
 }
 
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=rpcproviders,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=rpcproviders/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=rpcproviders/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=networks,verbs=get;list;watch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=wallets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=wallets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=wallets/finalizers,verbs=update
@@ -54,168 +60,204 @@ type RPCProviderReconciler struct {
 
 // Reconcile is part of the main Kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
+// Each RPCProvider is checked on its own interval by requeueing, so checks only run on the elected leader
+// and at most HealthCheckWorkers providers are checked at the same time.
 func (r *RPCProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
 	// Fetch the RPCProvider instance
 	var rpcProvider kontractdeployerv1alpha1.RPCProvider
 	if err := r.Get(ctx, req.NamespacedName, &rpcProvider); err != nil {
+		if apierrors.IsNotFound(err) {
+			forgetRPCMetrics(req.Namespace, req.Name)
+			rpcLimiters.forget(req.Namespace, req.Name)
+			return ctrl.Result{}, nil
+		}
 		log.Error(err, "unable to fetch RPCProvider")
-		r.Recorder.Event(&rpcProvider, corev1.EventTypeWarning, "FetchFailed", "Unable to fetch RPCProvider")
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		return ctrl.Result{}, err
 	}
 
-	log.Info("Successfully fetched RPCProvider", "RPCProvider.Name", rpcProvider.Name)
-
-	// Perform reconciliation logic if needed
-	// (You can leave this part empty or perform some reconciliation logic if required)
+	// Status updates of the last check trigger a reconcile as well; wait for the interval to pass
+	// unless a Secret of the provider changed, such as a rotated API key
+	interval := healthCheckInterval(rpcHealthCheckIntervalSeconds(rpcProvider.Spec.HealthCheck))
+	secrets := secretVersions(ctx, r.Client, rpcProvider.Namespace, providerSecretNames(&rpcProvider))
+	if wait := nextHealthCheck(rpcProvider.Status.LastChecked, rpcProvider.Status.ObservedGeneration, rpcProvider.Generation, rpcProvider.Status.SecretVersions, secrets, interval, time.Now()); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
 
-	return ctrl.Result{}, nil
+	if err := r.checkRPCProvider(ctx, &rpcProvider, secrets); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
 }
 
-// StartPeriodicHealthCheck starts a background goroutine that checks the health of all RPCProviders every minute
-func (r *RPCProviderReconciler) StartPeriodicHealthCheck(ctx context.Context) {
-	go func() {
+// rpcHealthCheckIntervalSeconds returns the configured check interval of an RPCProvider, if any
+func rpcHealthCheckIntervalSeconds(spec *kontractdeployerv1alpha1.RPCHealthCheckSpec) *int64 {
+	if spec == nil {
+		return nil
+	}
+	return spec.IntervalSeconds
+}
 
-		ticker := time.NewTicker(1 * time.Minute)
-		defer ticker.Stop()
+// checkRPCProvider checks the health of all endpoints of an RPCProvider and records the results
+// together with the versions of the Secrets that were read for the check
+func (r *RPCProviderReconciler) checkRPCProvider(ctx context.Context, rpcProvider *kontractdeployerv1alpha1.RPCProvider, secrets map[string]string) error {
+	ctx = withRPCWorkload(ctx, "HealthCheck")
+	log := log.FromContext(ctx)
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				r.checkAllRPCProviders(ctx)
-			}
+	// The Networks that use the provider tell which chain it must serve
+	var expectedChainIDs []int64
+	var networks kontractdeployerv1alpha1.NetworkList
+	if err := r.List(ctx, &networks, client.InNamespace(rpcProvider.Namespace)); err != nil {
+		log.Error(err, "unable to list Networks, chain IDs are not verified")
+	}
+	for _, network := range networks.Items {
+		chainID := networkChainID(&network)
+		if chainID != 0 && containsString(networkProviderNames(&network), rpcProvider.Name) && !containsInt64(expectedChainIDs, chainID) {
+			expectedChainIDs = append(expectedChainIDs, chainID)
 		}
-	}()
-}
+	}
 
-// checkAllRPCProviders checks the health of all RPCProviders and updates their statuses
-func (r *RPCProviderReconciler) checkAllRPCProviders(ctx context.Context) {
-	log := log.FromContext(ctx)
+	statuses := r.probeRPCProvider(ctx, rpcProvider)
 
-	// List all RPCProviders
+	// The block lag compares the endpoints with the heads other providers of the same chain reported at their last check
+	results := [][]kontractdeployerv1alpha1.RPCEndpointStatus{statuses}
 	var rpcProviders kontractdeployerv1alpha1.RPCProviderList
 	if err := r.List(ctx, &rpcProviders); err != nil {
-		log.Error(err, "unable to list RPCProviders")
-		return
+		log.Error(err, "unable to list RPCProviders, block lag only compares the endpoints of this provider")
+	}
+	for _, other := range rpcProviders.Items {
+		if other.Namespace == rpcProvider.Namespace && other.Name == rpcProvider.Name {
+			continue
+		}
+		results = append(results, other.Status.Endpoints)
+	}
+
+	now := time.Now()
+	heads := highestHeads(results)
+	thresholds := healthThresholds(rpcProvider.Spec.HealthCheck)
+	for i := range statuses {
+		evaluateEndpoint(&statuses[i], thresholds, heads, expectedChainIDs, now)
 	}
+	if err := r.recordHealth(ctx, rpcProvider, statuses, secrets, now); err != nil {
+		return err
+	}
+	recordRPCMetrics(rpcProvider, now)
+	return nil
+}
+
+// probeRPCProvider queries every endpoint of an RPCProvider; endpoints that cannot be queried are Unhealthy
+func (r *RPCProviderReconciler) probeRPCProvider(ctx context.Context, rpcProvider *kontractdeployerv1alpha1.RPCProvider) []kontractdeployerv1alpha1.RPCEndpointStatus {
+	log := log.FromContext(ctx).WithValues("RPCProvider", rpcProvider.Name, "Namespace", rpcProvider.Namespace)
 
-	for _, rpcProvider := range rpcProviders.Items {
-		log := log.WithValues("RPCProvider", rpcProvider.Name, "Namespace", rpcProvider.Namespace)
+	var statuses []kontractdeployerv1alpha1.RPCEndpointStatus
+	secrets := map[string]*corev1.Secret{}
+	for _, endpoint := range providerEndpoints(rpcProvider) {
+		status := kontractdeployerv1alpha1.RPCEndpointStatus{Name: endpoint.Name, LastChecked: metav1.Now()}
 
-		// Fetch the referenced secret
+		// Fetch the secret of the endpoint
 		var secret corev1.Secret
-		secretName := types.NamespacedName{Namespace: rpcProvider.Namespace, Name: rpcProvider.Spec.SecretRef.Name}
+		secretName := types.NamespacedName{Namespace: rpcProvider.Namespace, Name: endpoint.SecretRef.Name}
 		if err := r.Get(ctx, secretName, &secret); err != nil {
-			log.Error(err, fmt.Sprintf("RPCProvider (%s) - unable to fetch Secret", rpcProvider.Name), "Secret", secretName)
-			r.updateStatus(ctx, &rpcProvider, false, "")
-			r.Recorder.Event(&rpcProvider, corev1.EventTypeWarning, "SecretFetchFailed", "Unable to fetch Secret for RPCProvider")
+			log.Error(err, fmt.Sprintf("RPCProvider (%s) - unable to fetch Secret of endpoint %s", rpcProvider.Name, endpoint.Name), "Secret", secretName)
+			status.State = rpcStateUnhealthy
+			status.Message = "unable to fetch Secret"
+			statuses = append(statuses, status)
+			r.Recorder.Event(rpcProvider, corev1.EventTypeWarning, "SecretFetchFailed", fmt.Sprintf("Unable to fetch Secret for endpoint %s", endpoint.Name))
 			continue
 		}
 
-		// Extract tokenKey and urlKey from the secret
-		urlKey := string(secret.Data[rpcProvider.Spec.SecretRef.URLKey])
-		tokenKey := ""
-		if rpcProvider.Spec.SecretRef.TokenKey != "" {
-			tokenKey = string(secret.Data[rpcProvider.Spec.SecretRef.TokenKey])
-		}
-
-		// Validate the existence of urlKey
-		if urlKey == "" {
-			log.Error(fmt.Errorf("missing URL key"), fmt.Sprintf("RPCProvider (%s) - missing required data in Secret", rpcProvider.Name))
-			r.updateStatus(ctx, &rpcProvider, false, "")
+		// Build the connection for the health check without logging the API key
+		secrets[secret.Name] = &secret
+		status.APIEndpoint = string(secret.Data[endpoint.SecretRef.URLKey])
+		conn, err := loadRPCConnection(ctx, r.Client, rpcProvider.Namespace, endpoint, secrets)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("RPCProvider (%s) - invalid credentials for endpoint %s", rpcProvider.Name, endpoint.Name))
+			status.State = rpcStateUnhealthy
+			status.Message = err.Error()
+			statuses = append(statuses, status)
 			continue
 		}
-
-		// Construct the URL for the health check without logging the API key
-		url := strings.TrimRight(urlKey, "/")
-		if tokenKey != "" {
-			url = fmt.Sprintf("%s/%s", url, tokenKey)
-		}
-		log.Info(fmt.Sprintf("RPCProvider (%s) - Performing periodic API health check", rpcProvider.Name))
+		conn.limiter = rpcLimiters.forProvider(rpcProvider)
+		log.Info(fmt.Sprintf("RPCProvider (%s) - Performing periodic API health check of endpoint %s", rpcProvider.Name, endpoint.Name))
 
 		// Perform the health check
-		if err := r.checkAPIHealth(ctx, url, rpcProvider.Name); err != nil {
-			log.Error(err, fmt.Sprintf("RPCProvider (%s) - API health check failed", rpcProvider.Name))
-			r.updateStatus(ctx, &rpcProvider, false, urlKey)
-			r.Recorder.Event(&rpcProvider, corev1.EventTypeWarning, "APIHealthCheckFailed", "API health check failed")
+		if err := probeEndpoint(ctx, conn, &status); err != nil {
+			// Errors of the HTTP client contain the URL and with it the token
+			var urlErr *neturl.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			log.Error(err, fmt.Sprintf("RPCProvider (%s) - API health check of endpoint %s failed", rpcProvider.Name, endpoint.Name))
+			status.State = rpcStateUnhealthy
+			status.Message = err.Error()
+			statuses = append(statuses, status)
+			r.Recorder.Event(rpcProvider, corev1.EventTypeWarning, "APIHealthCheckFailed", fmt.Sprintf("API health check of endpoint %s failed", endpoint.Name))
 			continue
 		}
-
-		// Update the status to healthy: true without creating an event
-		r.updateStatus(ctx, &rpcProvider, true, urlKey)
+		statuses = append(statuses, status)
 	}
+	return statuses
 }
 
-// checkAPIHealth sends a POST request to check the health of the RPC provider by calling `eth_blockNumber`.
-func (r *RPCProviderReconciler) checkAPIHealth(ctx context.Context, url, rpcProviderName string) error {
-	log := log.FromContext(ctx)
-	client := http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// Mock blockchain operation - call eth_blockNumber
-	requestBody := strings.NewReader(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
-	req, err := http.NewRequest("POST", url, requestBody)
-	if err != nil {
-		log.Error(err, fmt.Sprintf("RPCProvider (%s) - Failed to create API health check request", rpcProviderName))
-		return err
+// recordHealth stores the evaluated endpoint statuses of an RPCProvider and reports newly degraded endpoints
+func (r *RPCProviderReconciler) recordHealth(ctx context.Context, rpcProvider *kontractdeployerv1alpha1.RPCProvider, statuses []kontractdeployerv1alpha1.RPCEndpointStatus, secrets map[string]string, now time.Time) error {
+	previous := map[string]string{}
+	for _, status := range rpcProvider.Status.Endpoints {
+		previous[status.Name] = status.State
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	log.Info(fmt.Sprintf("RPCProvider (%s) - Sending API health check request", rpcProviderName))
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Error(err, fmt.Sprintf("RPCProvider (%s) - Failed to send API health check request", rpcProviderName))
-		return err
-	}
-	defer resp.Body.Close()
-
-	log.Info(fmt.Sprintf("RPCProvider (%s) - Received response from API health check", rpcProviderName), "statusCode", resp.StatusCode)
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received non-200 response: %d", resp.StatusCode)
+	healthy := false
+	apiEndpoint := ""
+	for _, status := range statuses {
+		if status.State == rpcStateDegraded && previous[status.Name] != rpcStateDegraded {
+			r.Recorder.Event(rpcProvider, corev1.EventTypeWarning, "EndpointDegraded", fmt.Sprintf("Endpoint %s is degraded: %s", status.Name, status.Message))
+		}
+		if status.Healthy && !healthy {
+			healthy = true
+			apiEndpoint = status.APIEndpoint
+		}
 	}
 
-	// Decode the JSON response to verify it's a valid JSON-RPC response
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Error(err, fmt.Sprintf("RPCProvider (%s) - Failed to decode API health check response", rpcProviderName))
-		return err
+	// Report the first endpoint when none is healthy
+	if !healthy && len(statuses) > 0 {
+		apiEndpoint = statuses[0].APIEndpoint
 	}
 
-	log.Info(fmt.Sprintf("RPCProvider (%s) - Decoded API health check response", rpcProviderName))
-
-	if _, ok := result["result"]; !ok {
-		return fmt.Errorf("invalid JSON-RPC response: %v", result)
+	usage := rpcLimiters.forProvider(rpcProvider).usage()
+	if usage.BudgetExhausted && (rpcProvider.Status.Usage == nil || !rpcProvider.Status.Usage.BudgetExhausted || rpcProvider.Status.Usage.Day != usage.Day) {
+		r.Recorder.Event(rpcProvider, corev1.EventTypeWarning, "RequestBudgetExhausted", fmt.Sprintf("Daily budget of %d requests is used up until the end of %s UTC", usage.Requests, usage.Day))
 	}
-
-	log.Info(fmt.Sprintf("RPCProvider (%s) - API health check succeeded", rpcProviderName))
-	return nil
+	rpcProvider.Status.Usage = usage
+	return r.updateStatus(ctx, rpcProvider, healthy, apiEndpoint, statuses, secrets, now)
 }
 
-// updateStatus updates the status of the RPCProvider resource
-func (r *RPCProviderReconciler) updateStatus(ctx context.Context, rpcProvider *kontractdeployerv1alpha1.RPCProvider, healthy bool, apiEndpoint string) {
+// updateStatus patches the status of the RPCProvider resource with the results of a health check
+func (r *RPCProviderReconciler) updateStatus(ctx context.Context, rpcProvider *kontractdeployerv1alpha1.RPCProvider, healthy bool, apiEndpoint string, endpoints []kontractdeployerv1alpha1.RPCEndpointStatus, secrets map[string]string, now time.Time) error {
+	patch := client.MergeFrom(rpcProvider.DeepCopy())
+	checked := metav1.NewTime(now)
 	rpcProvider.Status.Healthy = healthy
+	rpcProvider.Status.State = providerState(endpoints)
 	rpcProvider.Status.APIEndpoint = apiEndpoint
-	if err := r.Status().Update(ctx, rpcProvider); err != nil {
+	rpcProvider.Status.Endpoints = endpoints
+	rpcProvider.Status.LastChecked = &checked
+	rpcProvider.Status.ObservedGeneration = rpcProvider.Generation
+	rpcProvider.Status.SecretVersions = secrets
+	if err := r.Status().Patch(ctx, rpcProvider, patch); err != nil {
 		log.FromContext(ctx).Error(err, fmt.Sprintf("RPCProvider (%s) - unable to update RPCProvider status", rpcProvider.Name))
 		r.Recorder.Event(rpcProvider, corev1.EventTypeWarning, "StatusUpdateFailed", "Failed to update RPCProvider status")
-	} else {
-		log.FromContext(ctx).Info("RPCProvider status updated successfully", "RPCProvider.Name", rpcProvider.Name)
+		return fmt.Errorf("failed to update RPCProvider status: %w", err)
 	}
+	log.FromContext(ctx).Info("RPCProvider status updated successfully", "RPCProvider.Name", rpcProvider.Name)
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *RPCProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Recorder = mgr.GetEventRecorderFor("rpcprovider-controller") // Initialize the event recorder
-	if err := ctrl.NewControllerManagedBy(mgr).
+	return ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.RPCProvider{}).
-		Complete(r); err != nil {
-		return err
-	}
-	// Start the periodic health check
-	r.StartPeriodicHealthCheck(context.Background())
-	return nil
+		Watches(&corev1.Secret{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.RPCProviderList{}, secretRefField)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: healthCheckWorkers(r.HealthCheckWorkers)}).
+		Complete(r)
 }
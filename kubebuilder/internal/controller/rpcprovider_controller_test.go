@@ -18,11 +18,27 @@ package controller
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -69,8 +85,9 @@ var _ = Describe("RPCProvider Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &RPCProviderReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -82,3 +99,483 @@ var _ = Describe("RPCProvider Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("RPC endpoint routing", func() {
+	provider := func(name, strategy string, endpoints ...kontractdeployerv1alpha1.RPCEndpoint) *kontractdeployerv1alpha1.RPCProvider {
+		return &kontractdeployerv1alpha1.RPCProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       kontractdeployerv1alpha1.RPCProviderSpec{Endpoints: endpoints, Strategy: strategy},
+		}
+	}
+	endpoint := func(name string, weight int32) kontractdeployerv1alpha1.RPCEndpoint {
+		return kontractdeployerv1alpha1.RPCEndpoint{Name: name, Weight: weight}
+	}
+	names := func(routed []routedEndpoint) []string {
+		var result []string
+		for _, r := range routed {
+			result = append(result, r.provider.Name+"/"+r.endpoint.Name)
+		}
+		return result
+	}
+
+	It("prefers healthy endpoints across providers and keeps provider priority", func() {
+		primary := provider("primary", "", endpoint("a", 1), endpoint("b", 1))
+		primary.Status.Endpoints = []kontractdeployerv1alpha1.RPCEndpointStatus{{Name: "a", Healthy: false}, {Name: "b", Healthy: true}}
+		fallback := provider("fallback", "", endpoint("c", 1), endpoint("d", 1))
+		fallback.Status.Endpoints = []kontractdeployerv1alpha1.RPCEndpointStatus{{Name: "c", Healthy: true}}
+
+		routed := routeEndpoints([]*kontractdeployerv1alpha1.RPCProvider{primary, fallback}, rand.New(rand.NewSource(1)))
+		Expect(names(routed)).To(Equal([]string{"primary/b", "fallback/c", "fallback/d", "primary/a"}))
+	})
+
+	It("treats spec.secretRef as a single endpoint", func() {
+		legacy := &kontractdeployerv1alpha1.RPCProvider{
+			Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+				SecretRef: kontractdeployerv1alpha1.SecretKeyReference{Name: "infura", URLKey: "url"},
+			},
+		}
+		endpoints := providerEndpoints(legacy)
+		Expect(endpoints).To(HaveLen(1))
+		Expect(endpoints[0].Name).To(Equal(defaultEndpointName))
+		Expect(endpoints[0].SecretRef.Name).To(Equal("infura"))
+
+		network := &kontractdeployerv1alpha1.Network{
+			Spec: kontractdeployerv1alpha1.NetworkSpec{
+				RPCProviderRef:  corev1.LocalObjectReference{Name: "infura"},
+				RPCProviderRefs: []corev1.LocalObjectReference{{Name: "alchemy"}, {Name: "infura"}},
+			},
+		}
+		Expect(networkProviderNames(network)).To(Equal([]string{"infura", "alchemy"}))
+	})
+
+	It("spreads requests in proportion to endpoint weights", func() {
+		weighted := provider("weighted", rpcStrategyWeighted, endpoint("heavy", 9), endpoint("light", 1))
+		random := rand.New(rand.NewSource(42))
+		first := map[string]int{}
+		for i := 0; i < 1000; i++ {
+			routed := routeEndpoints([]*kontractdeployerv1alpha1.RPCProvider{weighted}, random)
+			Expect(routed).To(HaveLen(2))
+			first[routed[0].endpoint.Name]++
+		}
+		Expect(first["heavy"]).To(BeNumerically(">", 800))
+		Expect(first["light"]).To(BeNumerically(">", 50))
+	})
+
+	It("only dials endpoints that answer", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x7a69"}`))
+		}))
+		defer server.Close()
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer down.Close()
+
+		ethClient, err := dialEndpoint(context.Background(), rpcConnection{url: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		ethClient.Close()
+
+		_, err = dialEndpoint(context.Background(), rpcConnection{url: down.URL})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("checks an endpoint once until a health check fails", func() {
+		var chainIDCalls atomic.Int32
+		var failing atomic.Bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var request struct {
+				Method string `json:"method"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			if request.Method == "eth_chainId" {
+				chainIDCalls.Add(1)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x7a69"}`))
+		}))
+		defer server.Close()
+		conn := rpcConnection{url: server.URL}
+
+		for i := 0; i < 3; i++ {
+			ethClient, err := dialEndpoint(context.Background(), conn)
+			Expect(err).NotTo(HaveOccurred())
+			ethClient.Close()
+		}
+		Expect(chainIDCalls.Load()).To(Equal(int32(1)))
+
+		failing.Store(true)
+		status := kontractdeployerv1alpha1.RPCEndpointStatus{Name: "primary"}
+		Expect(probeEndpoint(context.Background(), conn, &status)).NotTo(Succeed())
+		_, err := dialEndpoint(context.Background(), conn)
+		Expect(err).To(HaveOccurred())
+
+		failing.Store(false)
+		ethClient, err := dialEndpoint(context.Background(), conn)
+		Expect(err).NotTo(HaveOccurred())
+		ethClient.Close()
+		Expect(chainIDCalls.Load()).To(Equal(int32(2)))
+	})
+})
+
+var _ = Describe("RPC health checks", func() {
+	It("records chain ID, sync state, head block and latency of an endpoint", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var request struct {
+				Method string `json:"method"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			results := map[string]string{
+				"eth_chainId":          `"0x1"`,
+				"eth_syncing":          `{"currentBlock":"0x10","highestBlock":"0x20"}`,
+				"eth_getBlockByNumber": `{"number":"0x10","timestamp":"0x6553f100"}`,
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + results[request.Method] + `}`))
+		}))
+		defer server.Close()
+
+		status := kontractdeployerv1alpha1.RPCEndpointStatus{Name: "primary"}
+		Expect(probeEndpoint(context.Background(), rpcConnection{url: server.URL}, &status)).To(Succeed())
+		Expect(status.ChainID).To(Equal(int64(1)))
+		Expect(status.Syncing).To(BeTrue())
+		Expect(status.BlockNumber).To(Equal(int64(16)))
+		Expect(status.BlockTimestamp.Unix()).To(Equal(int64(0x6553f100)))
+	})
+
+	It("degrades endpoints that fail a threshold and rejects the wrong chain", func() {
+		now := time.Now()
+		recent := metav1.NewTime(now.Add(-10 * time.Second))
+		heads := map[int64]int64{1: 1000}
+		thresholds := healthThresholds(nil)
+
+		healthy := kontractdeployerv1alpha1.RPCEndpointStatus{ChainID: 1, BlockNumber: 998, BlockTimestamp: &recent, LatencyMilliseconds: 100}
+		evaluateEndpoint(&healthy, thresholds, heads, []int64{1}, now)
+		Expect(healthy.State).To(Equal(rpcStateHealthy))
+		Expect(healthy.BlockLag).To(Equal(int64(2)))
+
+		lagging := kontractdeployerv1alpha1.RPCEndpointStatus{ChainID: 1, BlockNumber: 500, BlockTimestamp: &recent, LatencyMilliseconds: 100}
+		evaluateEndpoint(&lagging, thresholds, heads, []int64{1}, now)
+		Expect(lagging.State).To(Equal(rpcStateDegraded))
+		Expect(lagging.Message).To(ContainSubstring("500 blocks behind"))
+
+		syncing := kontractdeployerv1alpha1.RPCEndpointStatus{ChainID: 1, BlockNumber: 1000, Syncing: true, LatencyMilliseconds: 5000}
+		evaluateEndpoint(&syncing, thresholds, heads, nil, now)
+		Expect(syncing.State).To(Equal(rpcStateDegraded))
+		Expect(syncing.Message).To(ContainSubstring("syncing"))
+		Expect(syncing.Message).To(ContainSubstring("latency"))
+
+		maxHeadAge := int64(60)
+		stale := metav1.NewTime(now.Add(-10 * time.Minute))
+		old := kontractdeployerv1alpha1.RPCEndpointStatus{ChainID: 1, BlockNumber: 1000, BlockTimestamp: &stale}
+		evaluateEndpoint(&old, healthThresholds(&kontractdeployerv1alpha1.RPCHealthCheckSpec{MaxHeadAgeSeconds: &maxHeadAge}), heads, nil, now)
+		Expect(old.State).To(Equal(rpcStateDegraded))
+
+		wrongChain := kontractdeployerv1alpha1.RPCEndpointStatus{ChainID: 5, BlockNumber: 1000}
+		evaluateEndpoint(&wrongChain, thresholds, heads, []int64{1}, now)
+		Expect(wrongChain.State).To(Equal(rpcStateUnhealthy))
+
+		Expect(providerState([]kontractdeployerv1alpha1.RPCEndpointStatus{wrongChain, lagging})).To(Equal(rpcStateDegraded))
+		Expect(providerState([]kontractdeployerv1alpha1.RPCEndpointStatus{lagging, healthy})).To(Equal(rpcStateHealthy))
+	})
+
+	It("compares heads only among reachable endpoints of the same chain", func() {
+		heads := highestHeads([][]kontractdeployerv1alpha1.RPCEndpointStatus{
+			{{ChainID: 1, BlockNumber: 100}, {ChainID: 1, BlockNumber: 900, State: rpcStateUnhealthy}},
+			{{ChainID: 1, BlockNumber: 120}, {ChainID: 137, BlockNumber: 5000}},
+		})
+		Expect(heads).To(Equal(map[int64]int64{1: 120, 137: 5000}))
+	})
+})
+
+var _ = Describe("Health check scheduling", func() {
+	It("waits for the configured interval after the last check", func() {
+		now := time.Now()
+		lastChecked := metav1.NewTime(now.Add(-20 * time.Second))
+		intervalSeconds := int64(30)
+
+		Expect(healthCheckInterval(nil)).To(Equal(time.Minute))
+		Expect(healthCheckInterval(&intervalSeconds)).To(Equal(30 * time.Second))
+		Expect(nextHealthCheck(&lastChecked, 1, 1, nil, nil, 30*time.Second, now)).To(Equal(10 * time.Second))
+		Expect(nextHealthCheck(&lastChecked, 1, 1, nil, nil, 10*time.Second, now)).To(BeZero())
+	})
+
+	It("checks objects that were never checked or whose spec or Secrets changed right away", func() {
+		now := time.Now()
+		lastChecked := metav1.NewTime(now)
+
+		Expect(nextHealthCheck(nil, 0, 1, nil, nil, time.Minute, now)).To(BeZero())
+		Expect(nextHealthCheck(&lastChecked, 1, 2, nil, nil, time.Minute, now)).To(BeZero())
+		Expect(nextHealthCheck(&lastChecked, 1, 1, map[string]string{"rpc": "1"}, map[string]string{"rpc": "2"}, time.Minute, now)).To(BeZero())
+		Expect(nextHealthCheck(&lastChecked, 1, 1, nil, map[string]string{}, time.Minute, now)).To(Equal(time.Minute))
+		Expect(healthCheckWorkers(0)).To(Equal(defaultHealthCheckWorkers))
+	})
+})
+
+var _ = Describe("RPC authentication", func() {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "rpc"},
+		Data: map[string][]byte{
+			"url":      []byte("https://rpc.example.com/v1/"),
+			"token":    []byte("secret-token"),
+			"username": []byte("deployer"),
+		},
+	}
+	ref := kontractdeployerv1alpha1.SecretKeyReference{Name: "rpc", URLKey: "url", TokenKey: "token", UsernameKey: "username"}
+
+	It("sends the token the way the auth type asks for", func() {
+		conn, err := newRPCConnection(nil, ref, secret, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.url).To(Equal("https://rpc.example.com/v1/secret-token"))
+
+		conn, err = newRPCConnection(&kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthBearer}, ref, secret, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.url).To(Equal("https://rpc.example.com/v1"))
+		Expect(conn.header.Get("Authorization")).To(Equal("Bearer secret-token"))
+
+		conn, err = newRPCConnection(&kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthHeader, HeaderName: "x-api-key"}, ref, secret, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.header.Get("X-Api-Key")).To(Equal("secret-token"))
+
+		conn, err = newRPCConnection(&kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthBasic}, ref, secret, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.header.Get("Authorization")).To(Equal("Basic ZGVwbG95ZXI6c2VjcmV0LXRva2Vu"))
+
+		conn, err = newRPCConnection(&kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthQuery, QueryParameter: "apikey"}, ref, secret, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.url).To(Equal("https://rpc.example.com/v1?apikey=secret-token"))
+
+		_, err = newRPCConnection(&kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthHeader}, ref, secret, nil)
+		Expect(err).To(MatchError(ContainSubstring("headerName")))
+		_, err = newRPCConnection(&kontractdeployerv1alpha1.RPCAuthSpec{TLS: &kontractdeployerv1alpha1.RPCTLSSpec{SecretName: "client-cert"}}, ref, secret, nil)
+		Expect(err).To(MatchError(ContainSubstring("client-cert")))
+	})
+
+	It("sends the credentials with health checks and operator requests", func() {
+		var authorization []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			authorization = append(authorization, req.Header.Get("Authorization"))
+			if req.Header.Get("Authorization") != "Bearer secret-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+		}))
+		defer server.Close()
+
+		conn := rpcConnection{url: server.URL, header: http.Header{"Authorization": []string{"Bearer secret-token"}}}
+		var chainID string
+		Expect(jsonRPCCall(context.Background(), rpcHealthHTTPClient, conn, "eth_chainId", nil, &chainID)).To(Succeed())
+		Expect(chainID).To(Equal("0x1"))
+
+		ethClient, err := dialEndpoint(context.Background(), conn)
+		Expect(err).NotTo(HaveOccurred())
+		ethClient.Close()
+		Expect(authorization).To(HaveEach("Bearer secret-token"))
+
+		_, err = dialEndpoint(context.Background(), rpcConnection{url: server.URL})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("presents the client certificate for mutual TLS", func() {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+		}))
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		server.StartTLS()
+		defer server.Close()
+
+		// The test server's own certificate doubles as the client certificate and the CA
+		certificate := server.TLS.Certificates[0]
+		key, err := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+		Expect(err).NotTo(HaveOccurred())
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+		tlsSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "client-cert"},
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}),
+				rpcTLSCAKey:             certPEM,
+			},
+		}
+		serverSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "rpc"}, Data: map[string][]byte{"url": []byte(server.URL)}}
+
+		auth := &kontractdeployerv1alpha1.RPCAuthSpec{Type: rpcAuthNone, TLS: &kontractdeployerv1alpha1.RPCTLSSpec{SecretName: "client-cert"}}
+		conn, err := newRPCConnection(auth, kontractdeployerv1alpha1.SecretKeyReference{Name: "rpc", URLKey: "url"}, serverSecret, tlsSecret)
+		Expect(err).NotTo(HaveOccurred())
+
+		ethClient, err := dialEndpoint(context.Background(), conn)
+		Expect(err).NotTo(HaveOccurred())
+		ethClient.Close()
+
+		var chainID string
+		Expect(jsonRPCCall(context.Background(), conn.httpClient(rpcHealthHTTPClient), conn, "eth_chainId", nil, &chainID)).To(Succeed())
+	})
+
+	It("passes the auth type and client certificate to deploy Jobs", func() {
+		endpoint := resolvedEndpoint{
+			secretRef: ref,
+			auth: &kontractdeployerv1alpha1.RPCAuthSpec{
+				Type:       rpcAuthHeader,
+				HeaderName: "x-api-key",
+				TLS:        &kontractdeployerv1alpha1.RPCTLSSpec{SecretName: "client-cert"},
+			},
+		}
+		envVars, volumes, volumeMounts := rpcJobEnv(endpoint)
+
+		values := map[string]string{}
+		for _, env := range envVars {
+			if env.ValueFrom != nil {
+				values[env.Name] = env.ValueFrom.SecretKeyRef.Key
+				continue
+			}
+			values[env.Name] = env.Value
+		}
+		Expect(values).To(Equal(map[string]string{
+			"RPC_URL":         "url",
+			"RPC_KEY":         "token",
+			"RPC_USERNAME":    "username",
+			"RPC_AUTH_TYPE":   rpcAuthHeader,
+			"RPC_AUTH_HEADER": "x-api-key",
+			"RPC_TLS_DIR":     rpcTLSMountPath,
+		}))
+		Expect(volumes).To(HaveLen(1))
+		Expect(volumes[0].Secret.SecretName).To(Equal("client-cert"))
+		Expect(volumeMounts[0].MountPath).To(Equal(rpcTLSMountPath))
+	})
+})
+
+// newHeadsStandIn serves eth_subscribe("newHeads") and publishes the headers sent to blocks
+type newHeadsStandIn struct {
+	blocks chan *gethtypes.Header
+}
+
+func (n *newHeadsStandIn) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	subscription := notifier.CreateSubscription()
+	go func() {
+		for {
+			select {
+			case header := <-n.blocks:
+				_ = notifier.Notify(subscription.ID, header)
+			case <-subscription.Err():
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
+
+var _ = Describe("WebSocket subscriptions", func() {
+	It("wakes up consumers on new blocks and falls back to polling when the subscription is lost", func() {
+		blocks := make(chan *gethtypes.Header, 1)
+		rpcServer := rpc.NewServer()
+		Expect(rpcServer.RegisterName("eth", &newHeadsStandIn{blocks: blocks})).To(Succeed())
+		server := httptest.NewServer(rpcServer.WebsocketHandler([]string{"*"}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		manager := NewSubscriptionManager(nil)
+		heads := manager.Heads()
+		network := &kontractdeployerv1alpha1.Network{ObjectMeta: metav1.ObjectMeta{Name: "mainnet", Namespace: "default"}}
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+		manager.mu.Lock()
+		manager.watch(ctx, network, []resolvedEndpoint{{provider: "infura", name: "default", ws: &rpcConnection{url: wsURL}}}, wsURL)
+		manager.mu.Unlock()
+
+		pending := &kontractdeployerv1alpha1.WalletTransactionStatus{State: txStatePending}
+		Eventually(func() bool { return manager.Connected("default", "mainnet") }).Should(BeTrue())
+		Expect(manager.pendingTransactionInterval("default", "mainnet", pending)).To(Equal(walletTransactionWatchInterval))
+
+		blocks <- &gethtypes.Header{Number: big.NewInt(1), Difficulty: big.NewInt(0)}
+		var received event.GenericEvent
+		Eventually(heads).Should(Receive(&received))
+		Expect(received.Object.GetName()).To(Equal("mainnet"))
+
+		rpcServer.Stop()
+		server.CloseClientConnections()
+		Eventually(func() bool { return manager.Connected("default", "mainnet") }).Should(BeFalse())
+		Expect(manager.pendingTransactionInterval("default", "mainnet", pending)).To(Equal(walletTransactionPollInterval))
+
+		var unset *SubscriptionManager
+		Expect(unset.pendingTransactionInterval("default", "mainnet", pending)).To(Equal(walletTransactionPollInterval))
+	})
+})
+
+var _ = Describe("RPC rate limiting", func() {
+	provider := func(name string, rateLimit *kontractdeployerv1alpha1.RPCRateLimitSpec) *kontractdeployerv1alpha1.RPCProvider {
+		return &kontractdeployerv1alpha1.RPCProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "rate-limits"},
+			Spec:       kontractdeployerv1alpha1.RPCProviderSpec{RateLimit: rateLimit},
+		}
+	}
+
+	It("refuses requests once the daily budget is used up and reports usage per workload", func() {
+		daily := int64(2)
+		limiter := rpcLimiters.forProvider(provider("budget", &kontractdeployerv1alpha1.RPCRateLimitSpec{DailyRequests: &daily}))
+		defer rpcLimiters.forget("rate-limits", "budget")
+
+		Expect(limiter.acquire(withRPCWorkload(context.Background(), "Action"))).To(Succeed())
+		Expect(limiter.acquire(withRPCWorkload(context.Background(), "HealthCheck"))).To(Succeed())
+		Expect(limiter.acquire(context.Background())).To(MatchError(ContainSubstring("budget")))
+
+		usage := limiter.usage()
+		Expect(usage.Requests).To(Equal(int64(2)))
+		Expect(usage.BudgetExhausted).To(BeTrue())
+		Expect(usage.Workloads).To(Equal([]kontractdeployerv1alpha1.RPCWorkloadUsage{{Name: "Action", Requests: 1}, {Name: "HealthCheck", Requests: 1}}))
+	})
+
+	It("continues the usage of today from the status and resets it on a new day", func() {
+		today := time.Now().UTC().Format(time.DateOnly)
+		seeded := provider("seeded", nil)
+		seeded.Status.Usage = &kontractdeployerv1alpha1.RPCUsageStatus{Day: today, Requests: 40, Workloads: []kontractdeployerv1alpha1.RPCWorkloadUsage{{Name: "Wallet", Requests: 40}}}
+		defer rpcLimiters.forget("rate-limits", "seeded")
+		Expect(rpcLimiters.forProvider(seeded).usage().Requests).To(Equal(int64(40)))
+
+		stale := provider("stale", nil)
+		stale.Status.Usage = &kontractdeployerv1alpha1.RPCUsageStatus{Day: "2020-01-01", Requests: 40}
+		defer rpcLimiters.forget("rate-limits", "stale")
+		Expect(rpcLimiters.forProvider(stale).usage().Requests).To(BeZero())
+	})
+
+	It("backs off and retries requests answered with HTTP 429", func() {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+		}))
+		defer server.Close()
+
+		rps := int32(100)
+		conn := rpcConnection{url: server.URL, limiter: rpcLimiters.forProvider(provider("throttled", &kontractdeployerv1alpha1.RPCRateLimitSpec{RequestsPerSecond: &rps}))}
+		defer rpcLimiters.forget("rate-limits", "throttled")
+
+		ethClient, err := dialEndpoint(withRPCWorkload(context.Background(), "Action"), conn)
+		Expect(err).NotTo(HaveOccurred())
+		ethClient.Close()
+
+		usage := conn.limiter.usage()
+		Expect(calls).To(Equal(2))
+		Expect(usage.Requests).To(Equal(int64(2)))
+		Expect(usage.Throttled).To(Equal(int64(1)))
+	})
+
+	It("reads Retry-After in seconds and as a date", func() {
+		now := time.Now()
+		Expect(parseRetryAfter("3", now)).To(Equal(3 * time.Second))
+		Expect(parseRetryAfter(now.Add(10*time.Second).UTC().Format(http.TimeFormat), now)).To(BeNumerically("~", 10*time.Second, time.Second))
+		Expect(parseRetryAfter("", now)).To(BeNumerically("<", 0))
+	})
+})
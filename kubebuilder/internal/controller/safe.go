@@ -0,0 +1,447 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+// walletTypeSafe is the WalletType of a Safe multisig wallet
+const walletTypeSafe = "Safe"
+
+// Conditions and reasons of a Safe wallet
+const (
+	conditionSafeAvailable  = "Available"
+	conditionDelegatesReady = "DelegatesReady"
+
+	reasonSafeAvailable    = "SafeAvailable"
+	reasonSafeUnavailable  = "SafeUnavailable"
+	reasonDelegatesReady   = "DelegatesAreOwners"
+	reasonDelegateNotFound = "DelegateNotFound"
+	reasonDelegateNotOwner = "DelegateNotOwner"
+)
+
+// safeABI covers the Safe functions used to sign and execute transactions
+var safeABI = mustParseABI(`[
+	{"type":"function","name":"nonce","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getThreshold","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getOwners","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address[]"}]},
+	{"type":"function","name":"getTransactionHash","stateMutability":"view","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"_nonce","type":"uint256"}],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"execTransaction","stateMutability":"payable","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"signatures","type":"bytes"}],"outputs":[{"name":"success","type":"bool"}]}
+]`)
+
+// safeProposalLocks holds a mutex per Safe address, so the operator picks the nonce of one proposal at a time
+var safeProposalLocks sync.Map
+
+// lockSafe serializes proposals to a Safe and returns the function that releases the lock
+func lockSafe(safe common.Address) func() {
+	lock, _ := safeProposalLocks.LoadOrStore(safe, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// safeTransaction is a plain CALL through a Safe without gas refunds
+type safeTransaction struct {
+	to    common.Address
+	value *big.Int
+	data  []byte
+	nonce *big.Int
+}
+
+// safeCall performs a read-only call of a safeABI method and unpacks its single result
+func safeCall(ctx context.Context, ethClient *ethclient.Client, safe common.Address, result interface{}, method string, args ...interface{}) error {
+	data, err := safeABI.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	output, err := ethClient.CallContract(ctx, ethereum.CallMsg{To: &safe, Data: data}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to call %s on Safe %s: %w", method, safe.Hex(), err)
+	}
+	values, err := safeABI.Unpack(method, output)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s of Safe %s: %w", method, safe.Hex(), err)
+	}
+	return safeABI.Methods[method].Outputs.Copy(result, values)
+}
+
+// safeOwnersAndThreshold returns the owners and signature threshold of a Safe
+func safeOwnersAndThreshold(ctx context.Context, ethClient *ethclient.Client, safe common.Address) ([]common.Address, *big.Int, error) {
+	var owners []common.Address
+	if err := safeCall(ctx, ethClient, safe, &owners, "getOwners"); err != nil {
+		return nil, nil, err
+	}
+	threshold := new(big.Int)
+	if err := safeCall(ctx, ethClient, safe, &threshold, "getThreshold"); err != nil {
+		return nil, nil, err
+	}
+	return owners, threshold, nil
+}
+
+// safeNonce returns the nonce of the next Safe transaction
+func safeNonce(ctx context.Context, ethClient *ethclient.Client, safe common.Address) (*big.Int, error) {
+	nonce := new(big.Int)
+	err := safeCall(ctx, ethClient, safe, &nonce, "nonce")
+	return nonce, err
+}
+
+// safeTransactionHash asks the Safe for the EIP-712 hash its owners sign
+func safeTransactionHash(ctx context.Context, ethClient *ethclient.Client, safe common.Address, tx safeTransaction) (common.Hash, error) {
+	var hash [32]byte
+	err := safeCall(ctx, ethClient, safe, &hash, "getTransactionHash",
+		tx.to, tx.value, tx.data, uint8(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), common.Address{}, common.Address{}, tx.nonce)
+	return hash, err
+}
+
+// safeExecCallData encodes execTransaction for a transaction signed by enough owners
+func safeExecCallData(tx safeTransaction, signatures map[common.Address][]byte) ([]byte, error) {
+	return safeABI.Pack("execTransaction",
+		tx.to, tx.value, tx.data, uint8(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), common.Address{}, common.Address{}, packSafeSignatures(signatures))
+}
+
+// signSafeTransaction signs a Safe transaction hash as an owner
+func signSafeTransaction(privateKey *ecdsa.PrivateKey, hash common.Hash) ([]byte, error) {
+	signature, err := crypto.Sign(hash.Bytes(), privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign Safe transaction: %w", err)
+	}
+	// The Safe expects v as 27 or 28 for signatures over the plain transaction hash
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// packSafeSignatures concatenates owner signatures sorted by owner address, as the Safe requires
+func packSafeSignatures(signatures map[common.Address][]byte) []byte {
+	owners := make([]common.Address, 0, len(signatures))
+	for owner := range signatures {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return bytes.Compare(owners[i].Bytes(), owners[j].Bytes()) < 0
+	})
+
+	var packed []byte
+	for _, owner := range owners {
+		packed = append(packed, signatures[owner]...)
+	}
+	return packed
+}
+
+// safeDelegateSignature is a Safe transaction signature from a delegate Wallet
+type safeDelegateSignature struct {
+	owner     common.Address
+	key       *ecdsa.PrivateKey
+	signature []byte
+}
+
+// safeDelegateSignatures signs the Safe transaction hash with every delegate Wallet that is a Safe owner
+func safeDelegateSignatures(ctx context.Context, c client.Client, wallet *kontractdeployerv1alpha1.Wallet, owners []common.Address, hash common.Hash) ([]safeDelegateSignature, error) {
+	isOwner := map[common.Address]bool{}
+	for _, owner := range owners {
+		isOwner[owner] = true
+	}
+
+	var signatures []safeDelegateSignature
+	for _, delegateName := range wallet.Spec.Safe.Delegates {
+		delegate := &kontractdeployerv1alpha1.Wallet{}
+		if err := c.Get(ctx, client.ObjectKey{Name: delegateName, Namespace: wallet.Namespace}, delegate); err != nil {
+			return nil, fmt.Errorf("failed to get delegate Wallet %s: %w", delegateName, err)
+		}
+		if delegate.Spec.WalletType == walletTypeSafe || delegate.Spec.WalletType == walletTypeSmartAccount {
+			return nil, fmt.Errorf("delegate Wallet %s is a %s, delegates must be EOAs", delegateName, delegate.Spec.WalletType)
+		}
+		privateKey, err := walletPrivateKey(ctx, c, delegate)
+		if err != nil {
+			return nil, err
+		}
+		owner := crypto.PubkeyToAddress(privateKey.PublicKey)
+		if !isOwner[owner] {
+			continue
+		}
+		signature, err := signSafeTransaction(privateKey, hash)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, safeDelegateSignature{owner: owner, key: privateKey, signature: signature})
+	}
+	return signatures, nil
+}
+
+// safeServiceClient talks to a Safe Transaction Service-compatible API
+type safeServiceClient struct {
+	baseURL string
+	apiKey  string
+}
+
+// safeServiceTransaction is the part of a multisig transaction returned by the service that the operator uses
+type safeServiceTransaction struct {
+	SafeTxHash            string  `json:"safeTxHash"`
+	Nonce                 int64   `json:"nonce"`
+	IsExecuted            bool    `json:"isExecuted"`
+	IsSuccessful          *bool   `json:"isSuccessful"`
+	TransactionHash       *string `json:"transactionHash"`
+	ConfirmationsRequired int64   `json:"confirmationsRequired"`
+	Confirmations         []struct {
+		Owner string `json:"owner"`
+	} `json:"confirmations"`
+}
+
+// newSafeServiceClient builds a client for the transaction service of a Safe wallet
+func newSafeServiceClient(ctx context.Context, c client.Client, namespace string, spec *kontractdeployerv1alpha1.SafeTransactionServiceSpec) (*safeServiceClient, error) {
+	service := &safeServiceClient{baseURL: strings.TrimRight(spec.URL, "/")}
+	if spec.APIKeySecretRef != nil {
+		apiKey, err := secretKeyValue(ctx, c, namespace, *spec.APIKeySecretRef)
+		if err != nil {
+			return nil, err
+		}
+		service.apiKey = apiKey
+	}
+	return service, nil
+}
+
+// propose submits a Safe transaction together with the signature of its first owner
+func (s *safeServiceClient) propose(ctx context.Context, safe common.Address, tx safeTransaction, hash common.Hash, sender common.Address, signature []byte) error {
+	var data *string
+	if len(tx.data) > 0 {
+		encoded := hexutil.Encode(tx.data)
+		data = &encoded
+	}
+	body := map[string]interface{}{
+		"to":                      tx.to.Hex(),
+		"value":                   tx.value.String(),
+		"data":                    data,
+		"operation":               0,
+		"safeTxGas":               "0",
+		"baseGas":                 "0",
+		"gasPrice":                "0",
+		"gasToken":                common.Address{}.Hex(),
+		"refundReceiver":          common.Address{}.Hex(),
+		"nonce":                   tx.nonce.Int64(),
+		"contractTransactionHash": hash.Hex(),
+		"sender":                  sender.Hex(),
+		"signature":               hexutil.Encode(signature),
+		"origin":                  "kontract",
+	}
+	return s.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/safes/%s/multisig-transactions/", safe.Hex()), body, nil)
+}
+
+// nextNonce returns the nonce for a new proposal: the on-chain nonce, or the nonce after the last transaction
+// that is still queued in the service, so proposals do not replace each other
+func (s *safeServiceClient) nextNonce(ctx context.Context, safe common.Address, onChain *big.Int) (*big.Int, error) {
+	var queued struct {
+		Results []safeServiceTransaction `json:"results"`
+	}
+	path := fmt.Sprintf("/api/v1/safes/%s/multisig-transactions/?executed=false&nonce__gte=%s&ordering=-nonce&limit=1", safe.Hex(), onChain.String())
+	if err := s.do(ctx, http.MethodGet, path, nil, &queued); err != nil {
+		return nil, err
+	}
+	if len(queued.Results) > 0 && queued.Results[0].Nonce >= onChain.Int64() {
+		return big.NewInt(queued.Results[0].Nonce + 1), nil
+	}
+	return onChain, nil
+}
+
+// confirm adds an owner signature to a proposed Safe transaction
+func (s *safeServiceClient) confirm(ctx context.Context, hash common.Hash, signature []byte) error {
+	body := map[string]string{"signature": hexutil.Encode(signature)}
+	return s.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/multisig-transactions/%s/confirmations/", hash.Hex()), body, nil)
+}
+
+// transaction fetches the confirmation and execution state of a proposed Safe transaction
+func (s *safeServiceClient) transaction(ctx context.Context, hash common.Hash) (*safeServiceTransaction, error) {
+	tx := &safeServiceTransaction{}
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/multisig-transactions/%s/", hash.Hex()), nil, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// do sends a JSON request to the service and decodes the JSON response into result, if given
+func (s *safeServiceClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create Safe Transaction Service request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := externalHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Safe Transaction Service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("transaction service returned status %d for %s %s: %s", resp.StatusCode, method, path, strings.TrimSpace(string(message)))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode Safe Transaction Service response: %w", err)
+	}
+	return nil
+}
+
+// reconcileSafe records the address, owners and threshold of a Safe wallet
+// Warnings are recorded as conditions and emitted as events only when a condition changes
+func (r *WalletReconciler) reconcileSafe(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	spec := wallet.Spec.Safe
+	if spec == nil || !common.IsHexAddress(spec.Address) {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidSafeConfig", "Safe wallets require a valid safe.address")
+		return ctrl.Result{}, nil
+	}
+	safe := common.HexToAddress(spec.Address)
+
+	ethClient, _, err := dialNetwork(ctx, r.Client, wallet.Namespace, wallet.Spec.NetworkRef)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer ethClient.Close()
+
+	previous := wallet.Status.DeepCopy()
+	wallet.Status.PublicKey = safe.Hex()
+	wallet.Status.SecretRef = ""
+	wallet.Status.KeyFormat = ""
+	if wallet.Status.Safe == nil {
+		wallet.Status.Safe = &kontractdeployerv1alpha1.SafeStatus{}
+	}
+
+	owners, threshold, err := safeOwnersAndThreshold(ctx, ethClient, safe)
+	if err != nil {
+		logger.Error(err, "Failed to read Safe", "Address", safe.Hex())
+		r.setSafeCondition(wallet, conditionSafeAvailable, reasonSafeUnavailable, err.Error())
+		if updateErr := r.updateSafeStatus(ctx, wallet, previous); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, err
+	}
+	r.setSafeCondition(wallet, conditionSafeAvailable, reasonSafeAvailable, fmt.Sprintf("Safe %s has %d owners", safe.Hex(), len(owners)))
+
+	ownerAddresses := make([]string, 0, len(owners))
+	isOwner := map[string]bool{}
+	for _, owner := range owners {
+		ownerAddresses = append(ownerAddresses, owner.Hex())
+		isOwner[owner.Hex()] = true
+	}
+
+	// Warn about delegates that cannot sign for the Safe
+	delegatesReason, delegatesMessage := reasonDelegatesReady, "All delegates are owners of the Safe"
+	var problems []string
+	for _, delegateName := range spec.Delegates {
+		delegate := &kontractdeployerv1alpha1.Wallet{}
+		if err := r.Get(ctx, client.ObjectKey{Name: delegateName, Namespace: wallet.Namespace}, delegate); err != nil {
+			if len(problems) == 0 {
+				delegatesReason = reasonDelegateNotFound
+			}
+			problems = append(problems, fmt.Sprintf("Delegate Wallet %s not found", delegateName))
+			continue
+		}
+		if delegate.Status.PublicKey != "" && !isOwner[common.HexToAddress(delegate.Status.PublicKey).Hex()] {
+			if len(problems) == 0 {
+				delegatesReason = reasonDelegateNotOwner
+			}
+			problems = append(problems, fmt.Sprintf("Delegate Wallet %s (%s) is not an owner of Safe %s", delegateName, delegate.Status.PublicKey, safe.Hex()))
+		}
+	}
+	if len(problems) > 0 {
+		delegatesMessage = strings.Join(problems, "; ")
+	}
+	r.setSafeCondition(wallet, conditionDelegatesReady, delegatesReason, delegatesMessage)
+
+	wallet.Status.Safe.Threshold = threshold.Int64()
+	wallet.Status.Safe.Owners = ownerAddresses
+	if err := r.updateSafeStatus(ctx, wallet, previous); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return r.reconcileFunding(ctx, wallet)
+}
+
+// setSafeCondition records a condition of a Safe wallet and emits a warning event when it turns into a new warning
+// Conditions with a ready reason are true, any other reason is a warning
+func (r *WalletReconciler) setSafeCondition(wallet *kontractdeployerv1alpha1.Wallet, conditionType, reason, message string) {
+	status := metav1.ConditionFalse
+	if reason == reasonSafeAvailable || reason == reasonDelegatesReady {
+		status = metav1.ConditionTrue
+	}
+	existing := meta.FindStatusCondition(wallet.Status.Safe.Conditions, conditionType)
+	changed := existing == nil || existing.Status != status || existing.Reason != reason || existing.Message != message
+	if changed && status == metav1.ConditionFalse {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, reason, message)
+	}
+	meta.SetStatusCondition(&wallet.Status.Safe.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: wallet.Generation,
+	})
+}
+
+// updateSafeStatus writes the status of a Safe wallet if it differs from the previous status
+func (r *WalletReconciler) updateSafeStatus(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet, previous *kontractdeployerv1alpha1.WalletStatus) error {
+	if reflect.DeepEqual(previous, &wallet.Status) {
+		return nil
+	}
+	if err := r.Status().Update(ctx, wallet); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update Safe wallet status")
+		return err
+	}
+	return nil
+}
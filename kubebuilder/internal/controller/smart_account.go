@@ -0,0 +1,642 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// walletTypeSmartAccount is the WalletType of an ERC-4337 smart account wallet
+	walletTypeSmartAccount = "SmartAccount"
+
+	// defaultEntryPoint is the canonical ERC-4337 v0.7 EntryPoint
+	defaultEntryPoint = "0x0000000071727De22E5E9d8BAf0edAc6f37da032"
+)
+
+// smartAccountABI covers the SimpleAccount-compatible account and factory functions and the EntryPoint nonce
+var smartAccountABI = mustParseABI(`[
+	{"type":"function","name":"execute","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address"},{"name":"value","type":"uint256"},{"name":"func","type":"bytes"}],"outputs":[]},
+	{"type":"function","name":"createAccount","stateMutability":"nonpayable","inputs":[{"name":"owner","type":"address"},{"name":"salt","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"getAddress","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"salt","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"getNonce","stateMutability":"view","inputs":[{"name":"sender","type":"address"},{"name":"key","type":"uint192"}],"outputs":[{"name":"nonce","type":"uint256"}]}
+]`)
+
+// userOperation is an ERC-4337 v0.7 UserOperation in the JSON-RPC form accepted by bundlers
+type userOperation struct {
+	Sender                        common.Address  `json:"sender"`
+	Nonce                         *hexutil.Big    `json:"nonce"`
+	Factory                       *common.Address `json:"factory,omitempty"`
+	FactoryData                   *hexutil.Bytes  `json:"factoryData,omitempty"`
+	CallData                      hexutil.Bytes   `json:"callData"`
+	CallGasLimit                  *hexutil.Big    `json:"callGasLimit"`
+	VerificationGasLimit          *hexutil.Big    `json:"verificationGasLimit"`
+	PreVerificationGas            *hexutil.Big    `json:"preVerificationGas"`
+	MaxFeePerGas                  *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas          *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Paymaster                     *common.Address `json:"paymaster,omitempty"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit,omitempty"`
+	PaymasterData                 *hexutil.Bytes  `json:"paymasterData,omitempty"`
+	Signature                     hexutil.Bytes   `json:"signature"`
+}
+
+// userOperationGas is the result of eth_estimateUserOperationGas
+type userOperationGas struct {
+	PreVerificationGas            *hexutil.Big `json:"preVerificationGas"`
+	VerificationGasLimit          *hexutil.Big `json:"verificationGasLimit"`
+	CallGasLimit                  *hexutil.Big `json:"callGasLimit"`
+	PaymasterVerificationGasLimit *hexutil.Big `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGasLimit       *hexutil.Big `json:"paymasterPostOpGasLimit"`
+}
+
+// userOperationReceipt is the part of the eth_getUserOperationReceipt result that the operator uses
+type userOperationReceipt struct {
+	Success bool   `json:"success"`
+	Reason  string `json:"reason"`
+	Receipt struct {
+		TransactionHash common.Hash `json:"transactionHash"`
+	} `json:"receipt"`
+}
+
+// paymasterResult is the result of the ERC-7677 pm_getPaymasterStubData and pm_getPaymasterData methods
+type paymasterResult struct {
+	Paymaster                     *common.Address `json:"paymaster"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit"`
+}
+
+// hexBig wraps a big integer for a UserOperation field
+func hexBig(value *big.Int) *hexutil.Big {
+	return (*hexutil.Big)(new(big.Int).Set(value))
+}
+
+// bigValue returns the integer of a UserOperation field, treating a missing field as zero
+func bigValue(value *hexutil.Big) *big.Int {
+	if value == nil {
+		return new(big.Int)
+	}
+	return value.ToInt()
+}
+
+// uint128Pair packs two 128-bit values into a single word, as the v0.7 EntryPoint stores gas limits and fees
+func uint128Pair(high, low *big.Int) []byte {
+	return append(common.LeftPadBytes(high.Bytes(), 16), common.LeftPadBytes(low.Bytes(), 16)...)
+}
+
+// initCode returns the factory address and call data the EntryPoint uses to deploy the account
+func (op *userOperation) initCode() []byte {
+	if op.Factory == nil {
+		return nil
+	}
+	code := append([]byte{}, op.Factory.Bytes()...)
+	if op.FactoryData != nil {
+		code = append(code, *op.FactoryData...)
+	}
+	return code
+}
+
+// paymasterAndData returns the packed paymaster address, gas limits and data
+func (op *userOperation) paymasterAndData() []byte {
+	if op.Paymaster == nil {
+		return nil
+	}
+	packed := append([]byte{}, op.Paymaster.Bytes()...)
+	packed = append(packed, uint128Pair(bigValue(op.PaymasterVerificationGasLimit), bigValue(op.PaymasterPostOpGasLimit))...)
+	if op.PaymasterData != nil {
+		packed = append(packed, *op.PaymasterData...)
+	}
+	return packed
+}
+
+// applyPaymaster sets the paymaster fields returned by a paymaster service
+func (op *userOperation) applyPaymaster(result *paymasterResult) {
+	if result.Paymaster == nil {
+		return
+	}
+	data := result.PaymasterData
+	op.Paymaster = result.Paymaster
+	op.PaymasterData = &data
+	if result.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = result.PaymasterVerificationGasLimit
+	}
+	if result.PaymasterPostOpGasLimit != nil {
+		op.PaymasterPostOpGasLimit = result.PaymasterPostOpGasLimit
+	}
+}
+
+// userOperationHash computes the hash the v0.7 EntryPoint asks the account owner to sign
+func userOperationHash(op *userOperation, entryPoint common.Address, chainID *big.Int) common.Hash {
+	// Every field is a static ABI type, so abi.encode is a concatenation of 32 byte words
+	word := func(value []byte) []byte {
+		return common.LeftPadBytes(value, 32)
+	}
+	var packed []byte
+	packed = append(packed, word(op.Sender.Bytes())...)
+	packed = append(packed, word(bigValue(op.Nonce).Bytes())...)
+	packed = append(packed, crypto.Keccak256(op.initCode())...)
+	packed = append(packed, crypto.Keccak256(op.CallData)...)
+	packed = append(packed, uint128Pair(bigValue(op.VerificationGasLimit), bigValue(op.CallGasLimit))...)
+	packed = append(packed, word(bigValue(op.PreVerificationGas).Bytes())...)
+	packed = append(packed, uint128Pair(bigValue(op.MaxPriorityFeePerGas), bigValue(op.MaxFeePerGas))...)
+	packed = append(packed, crypto.Keccak256(op.paymasterAndData())...)
+
+	var envelope []byte
+	envelope = append(envelope, crypto.Keccak256(packed)...)
+	envelope = append(envelope, word(entryPoint.Bytes())...)
+	envelope = append(envelope, word(chainID.Bytes())...)
+	return crypto.Keccak256Hash(envelope)
+}
+
+// signUserOperation signs a UserOperation hash as a personal message, as SimpleAccount-compatible accounts expect
+func signUserOperation(privateKey *ecdsa.PrivateKey, hash common.Hash) ([]byte, error) {
+	signature, err := crypto.Sign(accounts.TextHash(hash.Bytes()), privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign UserOperation: %w", err)
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// smartAccountEntryPoint returns the EntryPoint configured for a smart account
+func smartAccountEntryPoint(spec *kontractdeployerv1alpha1.SmartAccountSpec) (common.Address, error) {
+	entryPoint := spec.EntryPoint
+	if entryPoint == "" {
+		entryPoint = defaultEntryPoint
+	}
+	if !common.IsHexAddress(entryPoint) {
+		return common.Address{}, fmt.Errorf("invalid EntryPoint address %q", entryPoint)
+	}
+	return common.HexToAddress(entryPoint), nil
+}
+
+// smartAccountFactoryCall returns the factory address and createAccount call data of a smart account
+func smartAccountFactoryCall(factory *kontractdeployerv1alpha1.SmartAccountFactorySpec, owner common.Address) (common.Address, []byte, error) {
+	if !common.IsHexAddress(factory.Address) {
+		return common.Address{}, nil, fmt.Errorf("invalid factory address %q", factory.Address)
+	}
+	salt := new(big.Int)
+	if factory.Salt != "" {
+		if _, ok := salt.SetString(strings.TrimSpace(factory.Salt), 0); !ok {
+			return common.Address{}, nil, fmt.Errorf("invalid factory salt %q", factory.Salt)
+		}
+	}
+	data, err := smartAccountABI.Pack("createAccount", owner, salt)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return common.HexToAddress(factory.Address), data, nil
+}
+
+// smartAccountCall performs a read-only call of a smartAccountABI method and unpacks its single result
+func smartAccountCall(ctx context.Context, ethClient *ethclient.Client, to common.Address, result interface{}, method string, args ...interface{}) error {
+	data, err := smartAccountABI.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	output, err := ethClient.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to call %s on %s: %w", method, to.Hex(), err)
+	}
+	values, err := smartAccountABI.Unpack(method, output)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s of %s: %w", method, to.Hex(), err)
+	}
+	return smartAccountABI.Methods[method].Outputs.Copy(result, values)
+}
+
+// smartAccountAddress returns the configured address of a smart account or its counterfactual address from the factory
+func smartAccountAddress(ctx context.Context, ethClient *ethclient.Client, spec *kontractdeployerv1alpha1.SmartAccountSpec, owner common.Address) (common.Address, error) {
+	if spec.Address != "" {
+		if !common.IsHexAddress(spec.Address) {
+			return common.Address{}, fmt.Errorf("invalid smart account address %q", spec.Address)
+		}
+		return common.HexToAddress(spec.Address), nil
+	}
+	if spec.Factory == nil {
+		return common.Address{}, errors.New("smart accounts require an address or a factory")
+	}
+
+	factory, data, err := smartAccountFactoryCall(spec.Factory, owner)
+	if err != nil {
+		return common.Address{}, err
+	}
+	// getAddress takes the same arguments as createAccount
+	data = append(smartAccountABI.Methods["getAddress"].ID, data[4:]...)
+	output, err := ethClient.CallContract(ctx, ethereum.CallMsg{To: &factory, Data: data}, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get the account address from factory %s: %w", factory.Hex(), err)
+	}
+	values, err := smartAccountABI.Unpack("getAddress", output)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to decode the account address from factory %s: %w", factory.Hex(), err)
+	}
+	return values[0].(common.Address), nil
+}
+
+// bundlerClient calls the ERC-4337 JSON-RPC methods of a bundler and the ERC-7677 methods of a paymaster service
+type bundlerClient struct {
+	rpc *rpc.Client
+}
+
+// dialBundler connects to the endpoint of the named RPCProvider
+func dialBundler(ctx context.Context, c client.Client, namespace, name string) (*bundlerClient, error) {
+	conn, err := rpcProviderConnection(ctx, c, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	rpcClient, err := conn.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RPCProvider %s: %w", name, err)
+	}
+	return &bundlerClient{rpc: rpcClient}, nil
+}
+
+// close closes the connection to the bundler
+func (b *bundlerClient) close() {
+	b.rpc.Close()
+}
+
+// supportedEntryPoints returns the EntryPoint contracts the bundler accepts UserOperations for
+func (b *bundlerClient) supportedEntryPoints(ctx context.Context) ([]common.Address, error) {
+	var entryPoints []common.Address
+	if err := b.rpc.CallContext(ctx, &entryPoints, "eth_supportedEntryPoints"); err != nil {
+		return nil, fmt.Errorf("failed to get supported EntryPoints: %w", err)
+	}
+	return entryPoints, nil
+}
+
+// estimateGas asks the bundler for the gas limits of a UserOperation
+func (b *bundlerClient) estimateGas(ctx context.Context, op *userOperation, entryPoint common.Address) (*userOperationGas, error) {
+	gas := &userOperationGas{}
+	if err := b.rpc.CallContext(ctx, gas, "eth_estimateUserOperationGas", op, entryPoint); err != nil {
+		return nil, fmt.Errorf("failed to estimate UserOperation gas: %w", err)
+	}
+	return gas, nil
+}
+
+// send submits a signed UserOperation and returns its hash
+func (b *bundlerClient) send(ctx context.Context, op *userOperation, entryPoint common.Address) (common.Hash, error) {
+	var hash common.Hash
+	if err := b.rpc.CallContext(ctx, &hash, "eth_sendUserOperation", op, entryPoint); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to send UserOperation: %w", err)
+	}
+	return hash, nil
+}
+
+// receipt returns the receipt of a UserOperation, or nil while it is not included
+func (b *bundlerClient) receipt(ctx context.Context, hash common.Hash) (*userOperationReceipt, error) {
+	var receipt *userOperationReceipt
+	if err := b.rpc.CallContext(ctx, &receipt, "eth_getUserOperationReceipt", hash); err != nil {
+		return nil, fmt.Errorf("failed to get UserOperation receipt of %s: %w", hash.Hex(), err)
+	}
+	return receipt, nil
+}
+
+// paymasterData calls an ERC-7677 paymaster method (pm_getPaymasterStubData or pm_getPaymasterData)
+func (b *bundlerClient) paymasterData(ctx context.Context, method string, op *userOperation, entryPoint common.Address, chainID *big.Int, paymasterContext map[string]string) (*paymasterResult, error) {
+	if paymasterContext == nil {
+		paymasterContext = map[string]string{}
+	}
+	result := &paymasterResult{}
+	if err := b.rpc.CallContext(ctx, result, method, op, entryPoint, hexutil.EncodeBig(chainID), paymasterContext); err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	return result, nil
+}
+
+// isRPCError reports whether err is an error response from a JSON-RPC server rather than a connection failure
+func isRPCError(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr)
+}
+
+// submitUserOperation sends the call as a UserOperation of the smart account and tracks it until it is included
+func submitUserOperation(ctx context.Context, c client.Client, ethClient *ethclient.Client, wallet *kontractdeployerv1alpha1.Wallet, call walletCall, status *kontractdeployerv1alpha1.WalletTransactionStatus) error {
+	spec := wallet.Spec.SmartAccount
+	if spec == nil {
+		failWalletTransaction(status, fmt.Sprintf("wallet %s has no smartAccount configuration", wallet.Name))
+		return nil
+	}
+	if !common.IsHexAddress(wallet.Status.PublicKey) {
+		return fmt.Errorf("smart account wallet %s has no address yet", wallet.Name)
+	}
+	sender := common.HexToAddress(wallet.Status.PublicKey)
+	entryPoint, err := smartAccountEntryPoint(spec)
+	if err != nil {
+		failWalletTransaction(status, err.Error())
+		return nil
+	}
+
+	bundler, err := dialBundler(ctx, c, wallet.Namespace, spec.BundlerRef)
+	if err != nil {
+		return err
+	}
+	defer bundler.close()
+
+	if status.State == txStatePending {
+		return checkUserOperationReceipt(ctx, bundler, status)
+	}
+
+	// The account owner signs the UserOperation
+	owner := &kontractdeployerv1alpha1.Wallet{}
+	if err := c.Get(ctx, client.ObjectKey{Name: spec.OwnerRef, Namespace: wallet.Namespace}, owner); err != nil {
+		return fmt.Errorf("failed to get owner Wallet %s: %w", spec.OwnerRef, err)
+	}
+	ownerKey, err := walletPrivateKey(ctx, c, owner)
+	if err != nil {
+		return err
+	}
+
+	op, err := buildUserOperation(ctx, ethClient, spec, sender, entryPoint, crypto.PubkeyToAddress(ownerKey.PublicKey), call)
+	if err != nil {
+		return err
+	}
+	if op == nil {
+		failWalletTransaction(status, fmt.Sprintf("smart account %s is not deployed and has no factory", sender.Hex()))
+		return nil
+	}
+	chainID, err := ethClient.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	// Gas is estimated with a well-formed signature over an unrelated hash, which the account rejects without reverting
+	if op.Signature, err = signUserOperation(ownerKey, common.Hash{}); err != nil {
+		return err
+	}
+
+	var paymasterService *bundlerClient
+	if spec.Paymaster != nil && spec.Paymaster.ServiceRef != "" {
+		if paymasterService, err = dialBundler(ctx, c, wallet.Namespace, spec.Paymaster.ServiceRef); err != nil {
+			return err
+		}
+		defer paymasterService.close()
+
+		stub, err := paymasterService.paymasterData(ctx, "pm_getPaymasterStubData", op, entryPoint, chainID, spec.Paymaster.Context)
+		if err != nil {
+			return permanentUserOperationError(status, err)
+		}
+		op.applyPaymaster(stub)
+	}
+
+	gas, err := bundler.estimateGas(ctx, op, entryPoint)
+	if err != nil {
+		return permanentUserOperationError(status, err)
+	}
+	op.PreVerificationGas = gas.PreVerificationGas
+	op.VerificationGasLimit = gas.VerificationGasLimit
+	op.CallGasLimit = gas.CallGasLimit
+	if op.Paymaster != nil {
+		if gas.PaymasterVerificationGasLimit != nil {
+			op.PaymasterVerificationGasLimit = gas.PaymasterVerificationGasLimit
+		}
+		if gas.PaymasterPostOpGasLimit != nil {
+			op.PaymasterPostOpGasLimit = gas.PaymasterPostOpGasLimit
+		}
+	}
+
+	// The paymaster service signs over the final gas limits
+	if paymasterService != nil {
+		sponsored, err := paymasterService.paymasterData(ctx, "pm_getPaymasterData", op, entryPoint, chainID, spec.Paymaster.Context)
+		if err != nil {
+			return permanentUserOperationError(status, err)
+		}
+		op.applyPaymaster(sponsored)
+	}
+
+	if op.Signature, err = signUserOperation(ownerKey, userOperationHash(op, entryPoint, chainID)); err != nil {
+		return err
+	}
+	userOpHash, err := bundler.send(ctx, op, entryPoint)
+	if err != nil {
+		return permanentUserOperationError(status, err)
+	}
+
+	status.State = txStatePending
+	status.UserOpHash = userOpHash.Hex()
+	status.SubmittedAt = metav1.Now()
+	status.Message = "waiting for the bundler to include the UserOperation"
+	return nil
+}
+
+// buildUserOperation prepares an unsigned UserOperation for the call, or returns nil if the account cannot be deployed
+func buildUserOperation(ctx context.Context, ethClient *ethclient.Client, spec *kontractdeployerv1alpha1.SmartAccountSpec, sender, entryPoint, owner common.Address, call walletCall) (*userOperation, error) {
+	nonce := new(big.Int)
+	if err := smartAccountCall(ctx, ethClient, entryPoint, &nonce, "getNonce", sender, big.NewInt(0)); err != nil {
+		return nil, err
+	}
+	callData, err := smartAccountABI.Pack("execute", call.to, call.value, call.data)
+	if err != nil {
+		return nil, err
+	}
+
+	tip, err := ethClient.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas tip: %w", err)
+	}
+	head, err := ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest block: %w", err)
+	}
+	maxFee := new(big.Int).Set(tip)
+	if head.BaseFee != nil {
+		maxFee.Add(maxFee, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+
+	op := &userOperation{
+		Sender:               sender,
+		Nonce:                hexBig(nonce),
+		CallData:             callData,
+		CallGasLimit:         hexBig(big.NewInt(0)),
+		VerificationGasLimit: hexBig(big.NewInt(0)),
+		PreVerificationGas:   hexBig(big.NewInt(0)),
+		MaxFeePerGas:         hexBig(maxFee),
+		MaxPriorityFeePerGas: hexBig(tip),
+	}
+
+	// Undeployed accounts are created by their first UserOperation
+	code, err := ethClient.CodeAt(ctx, sender, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get code of %s: %w", sender.Hex(), err)
+	}
+	if len(code) == 0 {
+		if spec.Factory == nil {
+			return nil, nil
+		}
+		factory, factoryData, err := smartAccountFactoryCall(spec.Factory, owner)
+		if err != nil {
+			return nil, err
+		}
+		data := hexutil.Bytes(factoryData)
+		op.Factory = &factory
+		op.FactoryData = &data
+	}
+
+	if paymaster := spec.Paymaster; paymaster != nil && paymaster.ServiceRef == "" && paymaster.Address != "" {
+		if !common.IsHexAddress(paymaster.Address) {
+			return nil, fmt.Errorf("invalid paymaster address %q", paymaster.Address)
+		}
+		data := hexutil.Bytes{}
+		if paymaster.Data != "" {
+			if data, err = hexutil.Decode(paymaster.Data); err != nil {
+				return nil, fmt.Errorf("invalid paymaster data: %w", err)
+			}
+		}
+		address := common.HexToAddress(paymaster.Address)
+		op.Paymaster = &address
+		op.PaymasterData = &data
+	}
+	return op, nil
+}
+
+// permanentUserOperationError fails the transaction on errors returned by the bundler or paymaster and retries connection failures
+func permanentUserOperationError(status *kontractdeployerv1alpha1.WalletTransactionStatus, err error) error {
+	if !isRPCError(err) {
+		return err
+	}
+	failWalletTransaction(status, err.Error())
+	return nil
+}
+
+// checkUserOperationReceipt marks a UserOperation as succeeded or failed once the bundler included it
+func checkUserOperationReceipt(ctx context.Context, bundler *bundlerClient, status *kontractdeployerv1alpha1.WalletTransactionStatus) error {
+	receipt, err := bundler.receipt(ctx, common.HexToHash(status.UserOpHash))
+	if err != nil || receipt == nil {
+		return err
+	}
+
+	status.TransactionHash = receipt.Receipt.TransactionHash.Hex()
+	if !receipt.Success {
+		message := "UserOperation reverted"
+		if receipt.Reason != "" {
+			message = fmt.Sprintf("%s: %s", message, receipt.Reason)
+		}
+		failWalletTransaction(status, message)
+		return nil
+	}
+	status.State = txStateSucceeded
+	status.Message = ""
+	return nil
+}
+
+// reconcileSmartAccount records the address, owner and deployment state of a smart account wallet
+func (r *WalletReconciler) reconcileSmartAccount(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	spec := wallet.Spec.SmartAccount
+	if spec == nil || spec.OwnerRef == "" || spec.BundlerRef == "" {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidSmartAccountConfig", "SmartAccount wallets require smartAccount.ownerRef and smartAccount.bundlerRef")
+		return ctrl.Result{}, nil
+	}
+	entryPoint, err := smartAccountEntryPoint(spec)
+	if err != nil {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidSmartAccountConfig", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	owner := &kontractdeployerv1alpha1.Wallet{}
+	if err := r.Get(ctx, client.ObjectKey{Name: spec.OwnerRef, Namespace: wallet.Namespace}, owner); err != nil {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "OwnerNotFound", fmt.Sprintf("Owner Wallet %s not found", spec.OwnerRef))
+		return ctrl.Result{}, err
+	}
+	if owner.Spec.WalletType == walletTypeSafe || owner.Spec.WalletType == walletTypeSmartAccount {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidSmartAccountConfig", fmt.Sprintf("Owner Wallet %s must be an EOA", spec.OwnerRef))
+		return ctrl.Result{}, nil
+	}
+	if owner.Status.PublicKey == "" {
+		logger.Info("Waiting for the owner Wallet to be created", "Owner", spec.OwnerRef)
+		return ctrl.Result{RequeueAfter: walletTransactionPollInterval}, nil
+	}
+	ownerAddress := common.HexToAddress(owner.Status.PublicKey)
+
+	ethClient, _, err := dialNetwork(ctx, r.Client, wallet.Namespace, wallet.Spec.NetworkRef)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer ethClient.Close()
+
+	address, err := smartAccountAddress(ctx, ethClient, spec, ownerAddress)
+	if err != nil {
+		logger.Error(err, "Failed to resolve smart account address")
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidSmartAccountConfig", err.Error())
+		return ctrl.Result{}, err
+	}
+	code, err := ethClient.CodeAt(ctx, address, nil)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get code of %s: %w", address.Hex(), err)
+	}
+	deployed := len(code) > 0
+	if !deployed && spec.Factory == nil {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "SmartAccountNotDeployed", fmt.Sprintf("Smart account %s has no code and no factory to deploy it", address.Hex()))
+	}
+
+	bundler, err := dialBundler(ctx, r.Client, wallet.Namespace, spec.BundlerRef)
+	if err != nil {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "BundlerUnavailable", err.Error())
+		return ctrl.Result{}, err
+	}
+	defer bundler.close()
+	entryPoints, err := bundler.supportedEntryPoints(ctx)
+	if err != nil {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "BundlerUnavailable", err.Error())
+		return ctrl.Result{}, err
+	}
+	supported := false
+	for _, candidate := range entryPoints {
+		supported = supported || candidate == entryPoint
+	}
+	if !supported {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "EntryPointNotSupported", fmt.Sprintf("Bundler %s does not support EntryPoint %s", spec.BundlerRef, entryPoint.Hex()))
+	}
+
+	wallet.Status.PublicKey = address.Hex()
+	wallet.Status.SecretRef = ""
+	wallet.Status.KeyFormat = ""
+	wallet.Status.SmartAccount = &kontractdeployerv1alpha1.SmartAccountStatus{
+		Owner:      ownerAddress.Hex(),
+		EntryPoint: entryPoint.Hex(),
+		Deployed:   deployed,
+	}
+	if err := r.Status().Update(ctx, wallet); err != nil {
+		logger.Error(err, "Failed to update smart account wallet status")
+		return ctrl.Result{}, err
+	}
+
+	return r.reconcileFunding(ctx, wallet)
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// subscriptionSyncInterval is how often the subscription manager picks up new, changed and deleted Networks
+	subscriptionSyncInterval = 30 * time.Second
+
+	// subscriptionMinBackoff and subscriptionMaxBackoff bound the wait before a lost subscription is retried
+	subscriptionMinBackoff = time.Second
+	subscriptionMaxBackoff = 2 * time.Minute
+
+	// subscriptionNotifyInterval limits how often consumers are woken up for a Network with fast blocks
+	subscriptionNotifyInterval = 2 * time.Second
+
+	// walletTransactionWatchInterval is how often a pending transaction is polled while new blocks arrive over WebSocket
+	walletTransactionWatchInterval = time.Minute
+)
+
+var (
+	rpcSubscriptionConnected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontract_rpc_subscription_connected",
+		Help: "1 while new blocks of a Network arrive over a WebSocket subscription",
+	}, []string{"namespace", "network"})
+	rpcSubscriptionReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kontract_rpc_subscription_reconnects_total",
+		Help: "Number of times the WebSocket subscription of a Network was lost",
+	}, []string{"namespace", "network"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rpcSubscriptionConnected, rpcSubscriptionReconnects)
+}
+
+// SubscriptionManager keeps one new-block subscription per Network that has a WebSocket endpoint.
+// It runs as a manager Runnable, so only the elected leader holds subscriptions.
+// Consumers are woken up with the Network when a block arrives and fall back to polling while the subscription is down.
+type SubscriptionManager struct {
+	Client client.Client
+
+	mu        sync.Mutex
+	networks  map[types.NamespacedName]*networkSubscription
+	listeners []chan event.GenericEvent
+}
+
+// networkSubscription is the running subscription of a Network
+type networkSubscription struct {
+	cancel context.CancelFunc
+	// endpoints identifies the WebSocket endpoints the subscription was started with
+	endpoints string
+	connected atomic.Bool
+	// lastNotified is the time consumers were last woken up, in Unix nanoseconds
+	lastNotified atomic.Int64
+}
+
+// NewSubscriptionManager returns a SubscriptionManager that reads Networks and RPCProviders through c
+func NewSubscriptionManager(c client.Client) *SubscriptionManager {
+	return &SubscriptionManager{Client: c, networks: map[types.NamespacedName]*networkSubscription{}}
+}
+
+// NeedLeaderElection makes the manager start subscriptions only on the leader
+func (m *SubscriptionManager) NeedLeaderElection() bool {
+	return true
+}
+
+// Heads returns a channel that receives a Network whenever one of its subscriptions reports a new block.
+// It must be called before the manager starts.
+func (m *SubscriptionManager) Heads() <-chan event.GenericEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	listener := make(chan event.GenericEvent, 100)
+	m.listeners = append(m.listeners, listener)
+	return listener
+}
+
+// Connected reports whether new blocks of a Network currently arrive over WebSocket
+func (m *SubscriptionManager) Connected(namespace, network string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.networks[types.NamespacedName{Namespace: namespace, Name: network}]
+	return ok && sub.connected.Load()
+}
+
+// pendingTransactionInterval returns how long to wait before a pending transaction on a Network is checked again.
+// While the Network's subscription is up, new blocks trigger the checks and polling only backs them up.
+func (m *SubscriptionManager) pendingTransactionInterval(namespace, network string, transaction *kontractdeployerv1alpha1.WalletTransactionStatus) time.Duration {
+	if transaction.State == txStatePending && m.Connected(namespace, network) {
+		return walletTransactionWatchInterval
+	}
+	return walletTransactionPollInterval
+}
+
+// Start keeps the subscriptions in line with the Networks until ctx is done
+func (m *SubscriptionManager) Start(ctx context.Context) error {
+	ticker := time.NewTicker(subscriptionSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		m.sync(ctx)
+		select {
+		case <-ctx.Done():
+			m.mu.Lock()
+			for key := range m.networks {
+				m.stop(key)
+			}
+			m.mu.Unlock()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync starts subscriptions for Networks with WebSocket endpoints and stops those no longer needed
+func (m *SubscriptionManager) sync(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("subscriptions")
+
+	var networks kontractdeployerv1alpha1.NetworkList
+	if err := m.Client.List(ctx, &networks); err != nil {
+		logger.Error(err, "unable to list Networks")
+		return
+	}
+
+	wanted := map[types.NamespacedName]bool{}
+	for i := range networks.Items {
+		network := &networks.Items[i]
+		key := types.NamespacedName{Namespace: network.Namespace, Name: network.Name}
+
+		endpoints, err := networkEndpoints(ctx, m.Client, network)
+		if err != nil {
+			continue
+		}
+		var wsEndpoints []resolvedEndpoint
+		var fingerprint []string
+		for _, endpoint := range endpoints {
+			if endpoint.ws != nil {
+				wsEndpoints = append(wsEndpoints, endpoint)
+				fingerprint = append(fingerprint, endpoint.ws.url)
+			}
+		}
+		if len(wsEndpoints) == 0 {
+			continue
+		}
+		wanted[key] = true
+
+		m.mu.Lock()
+		if sub, ok := m.networks[key]; !ok || sub.endpoints != strings.Join(fingerprint, " ") {
+			m.stop(key)
+			m.watch(ctx, network, wsEndpoints, strings.Join(fingerprint, " "))
+		}
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.networks {
+		if !wanted[key] {
+			m.stop(key)
+		}
+	}
+}
+
+// watch starts the subscription of a Network; the caller holds m.mu
+func (m *SubscriptionManager) watch(ctx context.Context, network *kontractdeployerv1alpha1.Network, endpoints []resolvedEndpoint, fingerprint string) {
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &networkSubscription{cancel: cancel, endpoints: fingerprint}
+	m.networks[types.NamespacedName{Namespace: network.Namespace, Name: network.Name}] = sub
+	go m.run(subCtx, network.DeepCopy(), endpoints, sub)
+}
+
+// stop cancels the subscription of a Network; the caller holds m.mu
+func (m *SubscriptionManager) stop(key types.NamespacedName) {
+	if sub, ok := m.networks[key]; ok {
+		sub.cancel()
+		delete(m.networks, key)
+		rpcSubscriptionConnected.DeleteLabelValues(key.Namespace, key.Name)
+	}
+}
+
+// run keeps a Network subscribed, moving to the next endpoint and backing off whenever the subscription is lost
+func (m *SubscriptionManager) run(ctx context.Context, network *kontractdeployerv1alpha1.Network, endpoints []resolvedEndpoint, sub *networkSubscription) {
+	logger := log.FromContext(ctx).WithName("subscriptions").WithValues("Network", network.Name, "Namespace", network.Namespace)
+
+	backoff := subscriptionMinBackoff
+	for attempt := 0; ; attempt++ {
+		endpoint := endpoints[attempt%len(endpoints)]
+		received, err := m.follow(ctx, network, endpoint, sub)
+		sub.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		rpcSubscriptionConnected.WithLabelValues(network.Namespace, network.Name).Set(0)
+		rpcSubscriptionReconnects.WithLabelValues(network.Namespace, network.Name).Inc()
+
+		// A subscription that delivered blocks was healthy, so the next attempt starts without delay growth
+		if received {
+			backoff = subscriptionMinBackoff
+		}
+		logger.Error(err, "WebSocket subscription lost, polling until it is restored", "Endpoint", endpoint.provider+"/"+endpoint.name, "RetryIn", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > subscriptionMaxBackoff {
+			backoff = subscriptionMaxBackoff
+		}
+	}
+}
+
+// follow subscribes to new blocks over an endpoint and wakes up consumers until the subscription fails.
+// It reports whether any block was received.
+func (m *SubscriptionManager) follow(ctx context.Context, network *kontractdeployerv1alpha1.Network, endpoint resolvedEndpoint, sub *networkSubscription) (bool, error) {
+	rpcClient, err := endpoint.ws.dial(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer rpcClient.Close()
+
+	heads := make(chan *gethtypes.Header, 16)
+	subscription, err := ethclient.NewClient(rpcClient).SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return false, fmt.Errorf("failed to subscribe to new blocks: %w", err)
+	}
+	defer subscription.Unsubscribe()
+
+	sub.connected.Store(true)
+	rpcSubscriptionConnected.WithLabelValues(network.Namespace, network.Name).Set(1)
+
+	received := false
+	for {
+		select {
+		case <-ctx.Done():
+			return received, nil
+		case err := <-subscription.Err():
+			if err == nil {
+				err = errors.New("subscription closed")
+			}
+			return received, err
+		case <-heads:
+			received = true
+			m.notify(network, sub)
+		}
+	}
+}
+
+// notify wakes up consumers for a Network, at most once per subscriptionNotifyInterval
+func (m *SubscriptionManager) notify(network *kontractdeployerv1alpha1.Network, sub *networkSubscription) {
+	now := time.Now().UnixNano()
+	if now-sub.lastNotified.Load() < int64(subscriptionNotifyInterval) {
+		return
+	}
+	sub.lastNotified.Store(now)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, listener := range m.listeners {
+		// A consumer that is behind catches up with the next block or its own polling
+		select {
+		case listener <- event.GenericEvent{Object: network}:
+		default:
+		}
+	}
+}
@@ -20,15 +20,19 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
 )
@@ -36,17 +40,23 @@ import (
 // WalletReconciler reconciles a Wallet object
 type WalletReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=wallets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=wallets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=wallets/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch;update
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=networks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=rpcproviders,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kontract.expedio.xyz,resources=contracts;contractversions;proxyadmins,verbs=get;list;watch
 
 // Reconcile is part of the main Kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 func (r *WalletReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx = withRPCWorkload(ctx, "Wallet")
 	logger := log.FromContext(ctx)
 
 	// Fetch the Wallet instance
@@ -56,10 +66,23 @@ func (r *WalletReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Safe wallets have no key of their own and sign through their delegates
+	if wallet.Spec.WalletType == walletTypeSafe {
+		return r.reconcileSafe(ctx, wallet)
+	}
+
+	// Smart account wallets send UserOperations signed by their owner Wallet
+	if wallet.Spec.WalletType == walletTypeSmartAccount {
+		return r.reconcileSmartAccount(ctx, wallet)
+	}
+
 	// Check if the wallet is already created
 	if wallet.Status.PublicKey != "" && wallet.Status.SecretRef != "" {
 		logger.Info("Wallet already created", "PublicKey", wallet.Status.PublicKey)
-		return ctrl.Result{}, nil
+		if rotationRequested(wallet) {
+			return r.reconcileRotation(ctx, wallet)
+		}
+		return r.reconcileFunding(ctx, wallet)
 	}
 
 	var secretName string
@@ -76,14 +99,16 @@ func (r *WalletReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 
 		// Extract the public key from the Secret
-		publicKey, exists := existingSecret.Data["publicKey"]
-		if !exists {
-			return ctrl.Result{}, fmt.Errorf("publicKey not found in the secret: %s", secretName)
+		publicKey, keyFormat, err := r.importedPublicKey(ctx, wallet, existingSecret)
+		if err != nil {
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "WalletImportFailed", err.Error())
+			return ctrl.Result{}, err
 		}
 
 		// Update the Wallet status with the public key and secretRef
-		wallet.Status.PublicKey = string(publicKey)
+		wallet.Status.PublicKey = publicKey
 		wallet.Status.SecretRef = secretName
+		wallet.Status.KeyFormat = keyFormat
 		err = r.Status().Update(ctx, wallet)
 		if err != nil {
 			return ctrl.Result{}, err
@@ -91,50 +116,17 @@ func (r *WalletReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		logger.Info("Wallet imported", "PublicKey", wallet.Status.PublicKey, "SecretRef", wallet.Status.SecretRef)
 
 	} else {
-		// Generate a new Ethereum wallet
-		privateKey, err := crypto.GenerateKey()
-		if err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to generate wallet: %v", err)
-		}
-
-		publicKey := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
-		privateKeyHex := hex.EncodeToString(crypto.FromECDSA(privateKey))
-
-		// Create a new Kubernetes Secret to store the wallet keys
+		// Generate a new Ethereum wallet and store it in a new Secret
 		secretName = fmt.Sprintf("%s-wallet-secret", wallet.Name)
-		secret := &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      secretName,
-				Namespace: req.Namespace,
-			},
-			StringData: map[string]string{
-				"privateKey": privateKeyHex,
-				"publicKey":  publicKey,
-			},
-		}
-
-		// Set the Wallet instance as the owner of the Secret
-		if err := controllerutil.SetControllerReference(wallet, secret, r.Scheme); err != nil {
-			return ctrl.Result{}, err
-		}
-
-		// Check if the Secret already exists
-		found := &corev1.Secret{}
-		err = r.Get(ctx, client.ObjectKey{Name: secret.Name, Namespace: secret.Namespace}, found)
-		if err != nil && client.IgnoreNotFound(err) == nil {
-			// Create the Secret
-			err = r.Create(ctx, secret)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			logger.Info("Secret created", "Secret.Name", secret.Name)
-		} else if err != nil {
+		publicKey, keyFormat, err := r.generateWalletSecret(ctx, wallet, secretName)
+		if err != nil {
 			return ctrl.Result{}, err
 		}
 
 		// Update the Wallet status with the public key and secretRef
 		wallet.Status.PublicKey = publicKey
 		wallet.Status.SecretRef = secretName
+		wallet.Status.KeyFormat = keyFormat
 		err = r.Status().Update(ctx, wallet)
 		if err != nil {
 			return ctrl.Result{}, err
@@ -143,12 +135,114 @@ func (r *WalletReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		logger.Info("Wallet created", "PublicKey", publicKey, "SecretRef", secretName)
 	}
 
-	return ctrl.Result{}, nil
+	return r.reconcileFunding(ctx, wallet)
+}
+
+// generateWalletSecret generates a new key and stores it in the named Secret, honoring the keystore settings
+// If the Secret already exists, the key it holds is kept
+func (r *WalletReconciler) generateWalletSecret(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet, secretName string) (string, string, error) {
+	logger := log.FromContext(ctx)
+
+	// Reuse the Secret of an earlier attempt
+	found := &corev1.Secret{}
+	err := r.Get(ctx, client.ObjectKey{Name: secretName, Namespace: wallet.Namespace}, found)
+	if err == nil {
+		return r.importedPublicKey(ctx, wallet, found)
+	} else if client.IgnoreNotFound(err) != nil {
+		return "", "", err
+	}
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate wallet: %v", err)
+	}
+
+	publicKey := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+	secretData := map[string]string{
+		"privateKey": hex.EncodeToString(crypto.FromECDSA(privateKey)),
+		"publicKey":  publicKey,
+	}
+	keyFormat := keyFormatPrivateKey
+
+	// Store only the encrypted keystore when requested
+	if wallet.Spec.Keystore != nil {
+		passphrase, err := keystorePassphrase(ctx, r.Client, wallet)
+		if err != nil {
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "KeystorePassphraseUnavailable", err.Error())
+			return "", "", err
+		}
+		keyJSON, err := encryptKeystore(privateKey, passphrase)
+		if err != nil {
+			return "", "", err
+		}
+		secretData = map[string]string{
+			walletKeystoreKey: string(keyJSON),
+			"publicKey":       publicKey,
+		}
+		keyFormat = keyFormatKeystore
+	}
+
+	// Create a new Kubernetes Secret to store the wallet keys
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: wallet.Namespace,
+		},
+		StringData: secretData,
+	}
+
+	// Set the Wallet instance as the owner of the Secret
+	if err := controllerutil.SetControllerReference(wallet, secret, r.Scheme); err != nil {
+		return "", "", err
+	}
+
+	if err := r.Create(ctx, secret); err != nil {
+		return "", "", err
+	}
+	logger.Info("Secret created", "Secret.Name", secret.Name)
+
+	return publicKey, keyFormat, nil
+}
+
+// importedPublicKey returns the address and key format of an imported wallet Secret
+func (r *WalletReconciler) importedPublicKey(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet, secret *corev1.Secret) (string, string, error) {
+	keyJSON, isKeystore := secret.Data[walletKeystoreKey]
+	keyFormat := keyFormatPrivateKey
+	if isKeystore {
+		keyFormat = keyFormatKeystore
+	}
+
+	publicKey, hasPublicKey := secret.Data["publicKey"]
+	if !isKeystore {
+		if !hasPublicKey {
+			return "", "", fmt.Errorf("publicKey not found in the secret: %s", secret.Name)
+		}
+		return string(publicKey), keyFormat, nil
+	}
+
+	// The address is taken from the decrypted key, so a keystore header or publicKey that names another account is rejected
+	address, err := keystoreKeyAddress(ctx, r.Client, wallet, secret)
+	if err != nil {
+		return "", "", err
+	}
+	if header := keystoreAddress(keyJSON); header != "" && header != address {
+		return "", "", fmt.Errorf("keystore in the secret %s records the address %s but holds the key of %s", secret.Name, header, address)
+	}
+	if hasPublicKey && !strings.EqualFold(strings.TrimSpace(string(publicKey)), address) {
+		return "", "", fmt.Errorf("publicKey %s in the secret %s does not match the keystore key %s", publicKey, secret.Name, address)
+	}
+	return address, keyFormat, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WalletReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.EventRecorder = mgr.GetEventRecorderFor("wallet-controller")
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kontractdeployerv1alpha1.Wallet{}).
+		Watches(&corev1.Secret{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.WalletList{}, secretRefField)).
+		Watches(&kontractdeployerv1alpha1.Wallet{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.WalletList{}, walletRefField),
+			builder.WithPredicates(walletKeyPredicate())).
+		Watches(&kontractdeployerv1alpha1.RPCProvider{}, enqueueReferencing(r.Client, &kontractdeployerv1alpha1.WalletList{}, rpcProviderRefField),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
@@ -18,11 +18,33 @@ package controller
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -82,3 +104,918 @@ var _ = Describe("Wallet Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Wallet funding", func() {
+	It("tops up to the target balance", func() {
+		amount := fundingAmount(big.NewInt(10), big.NewInt(100), nil, big.NewInt(0))
+		Expect(amount.Int64()).To(Equal(int64(90)))
+	})
+
+	It("limits the top-up to what is left of the daily cap", func() {
+		amount := fundingAmount(big.NewInt(10), big.NewInt(100), big.NewInt(50), big.NewInt(20))
+		Expect(amount.Int64()).To(Equal(int64(30)))
+	})
+
+	It("returns nothing once the daily cap is used up", func() {
+		amount := fundingAmount(big.NewInt(10), big.NewInt(100), big.NewInt(50), big.NewInt(50))
+		Expect(amount.Sign()).To(Equal(0))
+	})
+
+	Context("on a network", func() {
+		ctx := context.Background()
+		var (
+			server   *httptest.Server
+			mu       sync.Mutex
+			methods  []string
+			rawTxs   []string
+			failSend bool
+		)
+
+		BeforeEach(func() {
+			methods, rawTxs, failSend = nil, nil, false
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var request struct {
+					Method string            `json:"method"`
+					Params []json.RawMessage `json:"params"`
+				}
+				_ = json.NewDecoder(req.Body).Decode(&request)
+				mu.Lock()
+				defer mu.Unlock()
+				methods = append(methods, request.Method)
+				result := `"0x7a69"`
+				switch request.Method {
+				case "eth_getBalance":
+					result = `"0x0"`
+				case "eth_getTransactionCount":
+					result = `"0x3"`
+				case "eth_getTransactionReceipt", "eth_getTransactionByHash":
+					result = `null`
+				case "eth_sendRawTransaction":
+					if failSend {
+						_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"connection reset"}}`))
+						return
+					}
+					var raw string
+					_ = json.Unmarshal(request.Params[0], &raw)
+					rawTxs = append(rawTxs, raw)
+					result = fmt.Sprintf(`"0x%064x"`, 1)
+				}
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+			}))
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		newReconciler := func(funding *kontractdeployerv1alpha1.FundingSpec) (*WalletReconciler, *kontractdeployerv1alpha1.Wallet, *record.FakeRecorder) {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+			wallet := &kontractdeployerv1alpha1.Wallet{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default"},
+				Spec:       kontractdeployerv1alpha1.WalletSpec{WalletType: "EOA", NetworkRef: "local", Funding: funding},
+				Status:     kontractdeployerv1alpha1.WalletStatus{PublicKey: "0x00000000000000000000000000000000000000aA"},
+			}
+			treasuryKey, err := crypto.GenerateKey()
+			Expect(err).NotTo(HaveOccurred())
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				wallet,
+				&kontractdeployerv1alpha1.Wallet{
+					ObjectMeta: metav1.ObjectMeta{Name: "treasury", Namespace: "default"},
+					Status: kontractdeployerv1alpha1.WalletStatus{
+						PublicKey: crypto.PubkeyToAddress(treasuryKey.PublicKey).Hex(),
+						SecretRef: "treasury-key",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "treasury-key", Namespace: "default"},
+					Data:       map[string][]byte{"privateKey": []byte(hexutil.Encode(crypto.FromECDSA(treasuryKey)))},
+				},
+				&kontractdeployerv1alpha1.Network{
+					ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+					Spec: kontractdeployerv1alpha1.NetworkSpec{
+						NetworkName:    "local",
+						ChainID:        31337,
+						RPCProviderRef: corev1.LocalObjectReference{Name: "local"},
+					},
+				},
+				&kontractdeployerv1alpha1.RPCProvider{
+					ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+					Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+						ProviderName: "Anvil",
+						SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "local-rpc-secret", URLKey: "url"},
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "local-rpc-secret", Namespace: "default"},
+					Data:       map[string][]byte{"url": []byte(server.URL)},
+				},
+			).WithStatusSubresource(&kontractdeployerv1alpha1.Wallet{}).Build()
+			recorder := record.NewFakeRecorder(10)
+			return &WalletReconciler{Client: c, Scheme: scheme, EventRecorder: recorder}, wallet, recorder
+		}
+
+		It("rejects a target balance below the minimum balance", func() {
+			r, wallet, recorder := newReconciler(&kontractdeployerv1alpha1.FundingSpec{
+				FundFrom: "treasury",
+				Targets:  []kontractdeployerv1alpha1.FundingTarget{{MinBalance: "100", TargetBalance: "50"}},
+			})
+			result, err := r.reconcileFunding(ctx, wallet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(recorder.Events).To(Receive(ContainSubstring("InvalidFundingTarget")))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("warns once per window when the daily cap holds back a top-up", func() {
+			r, wallet, recorder := newReconciler(nil)
+			target := kontractdeployerv1alpha1.FundingTarget{MinBalance: "100", TargetBalance: "200"}
+			treasury := &kontractdeployerv1alpha1.Wallet{ObjectMeta: metav1.ObjectMeta{Name: "treasury", Namespace: "default"}}
+			status := &kontractdeployerv1alpha1.FundingNetworkStatus{NetworkRef: "local"}
+
+			Expect(r.fundOnNetwork(ctx, wallet, treasury, target, big.NewInt(0), status)).To(Succeed())
+			Expect(status.CapReached).To(BeTrue())
+			Expect(recorder.Events).To(Receive(ContainSubstring("FundingCapReached")))
+
+			// Later checks within the window stay quiet
+			Expect(r.fundOnNetwork(ctx, wallet, treasury, target, big.NewInt(0), status)).To(Succeed())
+			Expect(recorder.Events).NotTo(Receive())
+
+			// A new window warns again
+			status.WindowStart = metav1.NewTime(time.Now().Add(-fundingWindow))
+			Expect(r.fundOnNetwork(ctx, wallet, treasury, target, big.NewInt(0), status)).To(Succeed())
+			Expect(recorder.Events).To(Receive(ContainSubstring("FundingCapReached")))
+		})
+
+		It("skips networks whose balance was checked within the interval", func() {
+			r, wallet, _ := newReconciler(&kontractdeployerv1alpha1.FundingSpec{
+				FundFrom: "treasury",
+				Targets:  []kontractdeployerv1alpha1.FundingTarget{{MinBalance: "100", TargetBalance: "200"}},
+			})
+			wallet.Status.Funding = []kontractdeployerv1alpha1.FundingNetworkStatus{{
+				NetworkRef:  "local",
+				LastChecked: metav1.NewTime(time.Now().Add(-fundingCheckInterval / 2)),
+			}}
+
+			result, err := r.reconcileFunding(ctx, wallet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("<=", fundingCheckInterval/2))
+			Expect(methods).To(BeEmpty())
+		})
+
+		It("records a top-up transfer before sending it and resends it with the same nonce", func() {
+			r, wallet, recorder := newReconciler(&kontractdeployerv1alpha1.FundingSpec{
+				FundFrom: "treasury",
+				Targets:  []kontractdeployerv1alpha1.FundingTarget{{MinBalance: "100", TargetBalance: "200"}},
+			})
+			failSend = true
+			_, err := r.reconcileFunding(ctx, wallet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(ContainSubstring("FundingFailed")))
+
+			// The transfer was recorded although sending it failed
+			stored := &kontractdeployerv1alpha1.Wallet{}
+			Expect(r.Get(ctx, client.ObjectKeyFromObject(wallet), stored)).To(Succeed())
+			Expect(stored.Status.Funding).To(HaveLen(1))
+			status := stored.Status.Funding[0]
+			Expect(status.PendingTransaction).NotTo(BeEmpty())
+			Expect(status.PendingNonce).To(HaveValue(Equal(uint64(3))))
+
+			// The transfer never reached the network, so the next check sends it again with its nonce
+			failSend = false
+			stored.Status.Funding[0].LastChecked = metav1.Time{}
+			_, err = r.reconcileFunding(ctx, stored)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(ContainSubstring("FundingTransferDropped")))
+			Expect(recorder.Events).To(Receive(ContainSubstring("WalletFunded")))
+			Expect(rawTxs).To(HaveLen(1))
+			tx := new(gethtypes.Transaction)
+			Expect(tx.UnmarshalBinary(hexutil.MustDecode(rawTxs[0]))).To(Succeed())
+			Expect(tx.Nonce()).To(Equal(uint64(3)))
+			Expect(tx.Value().Int64()).To(Equal(int64(200)))
+			Expect(stored.Status.Funding[0].PendingTransaction).To(Equal(tx.Hash().Hex()))
+		})
+	})
+})
+
+var _ = Describe("Wallet keystore", func() {
+	It("decrypts a generated keystore back to the same key", func() {
+		privateKey, err := crypto.GenerateKey()
+		Expect(err).NotTo(HaveOccurred())
+
+		keyJSON, err := encryptKeystore(privateKey, "passphrase")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keystoreAddress(keyJSON)).To(Equal(crypto.PubkeyToAddress(privateKey.PublicKey).Hex()))
+
+		decrypted, err := decryptKeystore(keyJSON, "passphrase")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crypto.FromECDSA(decrypted)).To(Equal(crypto.FromECDSA(privateKey)))
+
+		_, err = decryptKeystore(keyJSON, "wrong")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("takes the address of an imported keystore from the decrypted key", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		r := &WalletReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "passphrase", Namespace: "default"},
+				Data:       map[string][]byte{"passphrase": []byte("passphrase")},
+			}).Build(),
+			Scheme: scheme,
+		}
+		wallet := &kontractdeployerv1alpha1.Wallet{
+			ObjectMeta: metav1.ObjectMeta{Name: "imported", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.WalletSpec{Keystore: &kontractdeployerv1alpha1.KeystoreSpec{
+				PassphraseSecretRef: &kontractdeployerv1alpha1.SecretKeySelector{Name: "passphrase", Key: "passphrase"},
+			}},
+		}
+		privateKey, err := crypto.GenerateKey()
+		Expect(err).NotTo(HaveOccurred())
+		keyJSON, err := encryptKeystore(privateKey, "passphrase")
+		Expect(err).NotTo(HaveOccurred())
+		address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+		other := "0x00000000000000000000000000000000000000aA"
+		imported := func(revision string, data map[string][]byte) (string, error) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "imported", Namespace: "default", UID: "imported", ResourceVersion: revision},
+				Data:       data,
+			}
+			publicKey, _, err := r.importedPublicKey(ctx, wallet, secret)
+			return publicKey, err
+		}
+
+		Expect(imported("1", map[string][]byte{walletKeystoreKey: keyJSON})).To(Equal(address))
+		Expect(imported("2", map[string][]byte{walletKeystoreKey: keyJSON, "publicKey": []byte(strings.ToLower(address))})).To(Equal(address))
+
+		// A publicKey naming another account is rejected
+		_, err = imported("3", map[string][]byte{walletKeystoreKey: keyJSON, "publicKey": []byte(other)})
+		Expect(err).To(MatchError(ContainSubstring("does not match the keystore key")))
+
+		// So is a keystore whose header names another account
+		var header map[string]any
+		Expect(json.Unmarshal(keyJSON, &header)).To(Succeed())
+		header["address"] = strings.TrimPrefix(strings.ToLower(other), "0x")
+		forged, err := json.Marshal(header)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = imported("4", map[string][]byte{walletKeystoreKey: forged})
+		Expect(err).To(MatchError(ContainSubstring("records the address")))
+	})
+
+	It("mounts the keystore passphrase as a file for deploy Jobs", func() {
+		wallet := &kontractdeployerv1alpha1.Wallet{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default"},
+			Spec: kontractdeployerv1alpha1.WalletSpec{Keystore: &kontractdeployerv1alpha1.KeystoreSpec{
+				PassphraseSecretRef: &kontractdeployerv1alpha1.SecretKeySelector{Name: "deployer-passphrase", Key: "passphrase"},
+			}},
+			Status: kontractdeployerv1alpha1.WalletStatus{SecretRef: "deployer-wallet-secret"},
+		}
+		envVars, err := walletKeystoreEnv(wallet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envVars).To(ContainElement(corev1.EnvVar{Name: "WALLET_KEYSTORE_PASSWORD_FILE", Value: walletKeystoreDir + "/passphrase"}))
+		for _, envVar := range envVars {
+			Expect(envVar.ValueFrom).To(BeNil(), envVar.Name)
+		}
+
+		sources := walletKeystoreVolume(wallet).Projected.Sources
+		Expect(sources).To(HaveLen(2))
+		Expect(sources[0].Secret.Name).To(Equal("deployer-wallet-secret"))
+		Expect(sources[1].Secret.Name).To(Equal("deployer-passphrase"))
+		Expect(sources[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "passphrase", Path: "passphrase"}}))
+	})
+})
+
+var _ = Describe("Wallet key rotation", func() {
+	It("parses role names and hashes", func() {
+		role, err := parseRole(defaultAdminRole)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(role).To(Equal(common.Hash{}))
+
+		role, err = parseRole("MINTER_ROLE")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(role).To(Equal(crypto.Keccak256Hash([]byte("MINTER_ROLE"))))
+
+		role, err = parseRole(role.Hex())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(role).To(Equal(crypto.Keccak256Hash([]byte("MINTER_ROLE"))))
+
+		_, err = parseRole("0x1234")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("grants roles to the new key and renounces them for the old key", func() {
+		oldAddress := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+		newAddress := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+
+		data, err := rotationCallData(&kontractdeployerv1alpha1.RotationStep{Action: rotationActionGrantRole, Role: "MINTER_ROLE"}, oldAddress, newAddress)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data[:4]).To(Equal(rotationABI.Methods["grantRole"].ID))
+		Expect(common.BytesToAddress(data[36:68])).To(Equal(newAddress))
+
+		data, err = rotationCallData(&kontractdeployerv1alpha1.RotationStep{Action: rotationActionRenounceRole, Role: "MINTER_ROLE"}, oldAddress, newAddress)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data[:4]).To(Equal(rotationABI.Methods["renounceRole"].ID))
+		Expect(common.BytesToAddress(data[36:68])).To(Equal(oldAddress))
+
+		data, err = rotationCallData(&kontractdeployerv1alpha1.RotationStep{Action: rotationActionTransferOwnership}, oldAddress, newAddress)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data[:4]).To(Equal(rotationABI.Methods["transferOwnership"].ID))
+		Expect(common.BytesToAddress(data[4:36])).To(Equal(newAddress))
+	})
+
+	Context("when a step reverts", func() {
+		ctx := context.Background()
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var request struct {
+					Method string `json:"method"`
+				}
+				_ = json.NewDecoder(req.Body).Decode(&request)
+				result := `"0x7a69"`
+				if request.Method == "eth_getTransactionReceipt" {
+					result = fmt.Sprintf(`{"status":"0x0","cumulativeGasUsed":"0x5208","gasUsed":"0x5208","logs":[],"logsBloom":"0x%0512x","transactionHash":"0x%064x"}`, 0, 1)
+				}
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+			}))
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		newReconciler := func(steps ...kontractdeployerv1alpha1.RotationStep) (*WalletReconciler, client.Client, *record.FakeRecorder) {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+			wallet := &kontractdeployerv1alpha1.Wallet{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default"},
+				Spec: kontractdeployerv1alpha1.WalletSpec{
+					WalletType: "EOA",
+					NetworkRef: "local",
+					Rotation:   &kontractdeployerv1alpha1.RotationSpec{RequestID: "r1"},
+				},
+				Status: kontractdeployerv1alpha1.WalletStatus{
+					PublicKey: "0x00000000000000000000000000000000000000aA",
+					Rotation: &kontractdeployerv1alpha1.RotationStatus{
+						RequestID:    "r1",
+						Phase:        rotationPhaseMigrating,
+						OldPublicKey: "0x00000000000000000000000000000000000000aA",
+						NewPublicKey: "0x00000000000000000000000000000000000000bB",
+						Steps:        steps,
+					},
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				wallet,
+				&kontractdeployerv1alpha1.Network{
+					ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+					Spec: kontractdeployerv1alpha1.NetworkSpec{
+						NetworkName:    "local",
+						ChainID:        31337,
+						RPCProviderRef: corev1.LocalObjectReference{Name: "local"},
+					},
+				},
+				&kontractdeployerv1alpha1.RPCProvider{
+					ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+					Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+						ProviderName: "Anvil",
+						SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "local-rpc-secret", URLKey: "url"},
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "local-rpc-secret", Namespace: "default"},
+					Data:       map[string][]byte{"url": []byte(server.URL)},
+				},
+			).WithStatusSubresource(&kontractdeployerv1alpha1.Wallet{}).Build()
+			recorder := record.NewFakeRecorder(10)
+			return &WalletReconciler{Client: c, Scheme: scheme, EventRecorder: recorder}, c, recorder
+		}
+		step := func(action, state string) kontractdeployerv1alpha1.RotationStep {
+			return kontractdeployerv1alpha1.RotationStep{
+				NetworkRef:      "local",
+				Address:         "0x00000000000000000000000000000000000000cC",
+				Action:          action,
+				Role:            defaultAdminRole,
+				State:           state,
+				TransactionHash: fmt.Sprintf("0x%064x", 1),
+			}
+		}
+		reconcileRotation := func(r *WalletReconciler, c client.Client) (reconcile.Result, *kontractdeployerv1alpha1.Wallet) {
+			wallet := &kontractdeployerv1alpha1.Wallet{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: "deployer", Namespace: "default"}, wallet)).To(Succeed())
+			result, err := r.reconcileRotation(ctx, wallet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, types.NamespacedName{Name: "deployer", Namespace: "default"}, wallet)).To(Succeed())
+			return result, wallet
+		}
+
+		It("retries a failed step once the new key holds a permission", func() {
+			r, c, recorder := newReconciler(
+				step(rotationActionGrantRole, rotationStepSucceeded),
+				step(rotationActionRenounceRole, rotationStepPending),
+			)
+
+			result, wallet := reconcileRotation(r, c)
+			Expect(result.RequeueAfter).To(Equal(rotationRetryInterval))
+			Expect(recorder.Events).To(Receive(ContainSubstring("RotationStepFailed")))
+			Expect(rotationRequested(wallet)).To(BeTrue())
+
+			rotation := wallet.Status.Rotation
+			Expect(rotation.Phase).To(Equal(rotationPhaseMigrating))
+			Expect(rotation.Steps[1].State).To(Equal(rotationStepPlanned))
+			Expect(rotation.Steps[1].Message).To(Equal("transaction reverted"))
+			Expect(rotation.Steps[1].TransactionHash).To(BeEmpty())
+			Expect(nextRotationStep(rotation)).To(Equal(&rotation.Steps[1]))
+		})
+
+		It("fails a rotation whose first permission could not be moved", func() {
+			r, c, recorder := newReconciler(
+				step(rotationActionGrantRole, rotationStepPending),
+				step(rotationActionRenounceRole, rotationStepPlanned),
+			)
+
+			result, wallet := reconcileRotation(r, c)
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(recorder.Events).To(Receive(ContainSubstring("RotationFailed")))
+			Expect(wallet.Status.Rotation.Phase).To(Equal(rotationPhaseFailed))
+			Expect(wallet.Status.PublicKey).To(Equal("0x00000000000000000000000000000000000000aA"))
+		})
+
+		It("backs off between attempts and fails the rotation after the last one", func() {
+			retried := step(rotationActionRenounceRole, rotationStepPending)
+			retried.Attempts = 1
+			r, c, recorder := newReconciler(step(rotationActionGrantRole, rotationStepSucceeded), retried)
+
+			result, wallet := reconcileRotation(r, c)
+			Expect(result.RequeueAfter).To(Equal(2 * rotationRetryInterval))
+			Expect(recorder.Events).To(Receive(ContainSubstring("attempt 2 of 5")))
+			Expect(wallet.Status.Rotation.Steps[1].RetryTime).NotTo(BeNil())
+
+			// The step waits for its retry time although status updates reconcile the wallet again
+			result, wallet = reconcileRotation(r, c)
+			Expect(result.RequeueAfter).To(BeNumerically("~", 2*rotationRetryInterval, time.Second))
+			Expect(wallet.Status.Rotation.Steps[1].State).To(Equal(rotationStepPlanned))
+			Expect(recorder.Events).NotTo(Receive())
+
+			wallet.Status.Rotation.Steps[1].State = rotationStepPending
+			wallet.Status.Rotation.Steps[1].RetryTime = nil
+			wallet.Status.Rotation.Steps[1].Attempts = rotationMaxAttempts - 1
+			Expect(c.Status().Update(ctx, wallet)).To(Succeed())
+			result, wallet = reconcileRotation(r, c)
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(recorder.Events).To(Receive(ContainSubstring("failed after 5 attempts")))
+			Expect(wallet.Status.Rotation.Phase).To(Equal(rotationPhaseFailed))
+		})
+	})
+
+	Context("with an Ownable2Step contract", func() {
+		ctx := context.Background()
+		oldKey, _ := crypto.GenerateKey()
+		newKey, _ := crypto.GenerateKey()
+		oldAddress := crypto.PubkeyToAddress(oldKey.PublicKey)
+		newAddress := crypto.PubkeyToAddress(newKey.PublicKey)
+		contract := common.HexToAddress("0x00000000000000000000000000000000000000cC")
+		var (
+			server *httptest.Server
+			mu     sync.Mutex
+			rawTxs []string
+		)
+
+		BeforeEach(func() {
+			rawTxs = nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var request struct {
+					Method string            `json:"method"`
+					Params []json.RawMessage `json:"params"`
+				}
+				_ = json.NewDecoder(req.Body).Decode(&request)
+				result := `"0x7a69"`
+				switch request.Method {
+				case "eth_call":
+					var call struct {
+						Input string `json:"input"`
+						Data  string `json:"data"`
+					}
+					_ = json.Unmarshal(request.Params[0], &call)
+					input := call.Input + call.Data
+					result = fmt.Sprintf(`"0x%064x"`, 0)
+					if strings.HasPrefix(input, hexutil.Encode(rotationABI.Methods["owner"].ID)) {
+						result = fmt.Sprintf(`"0x%064s"`, strings.TrimPrefix(strings.ToLower(oldAddress.Hex()), "0x"))
+					}
+				case "eth_getTransactionCount":
+					result = `"0x7"`
+				case "eth_estimateGas":
+					result = `"0xc350"`
+				case "eth_getTransactionReceipt", "eth_getTransactionByHash":
+					result = `null`
+				case "eth_sendRawTransaction":
+					var raw string
+					_ = json.Unmarshal(request.Params[0], &raw)
+					mu.Lock()
+					rawTxs = append(rawTxs, raw)
+					mu.Unlock()
+					_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"connection reset"}}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+			}))
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("plans acceptOwnership for the new key", func() {
+			ethClient, err := ethclient.Dial(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			defer ethClient.Close()
+
+			steps, accept := planTargetRotation(ctx, ethClient, rotationTarget{networkRef: "local", address: contract}, oldAddress, nil)
+			Expect(steps).To(HaveLen(1))
+			Expect(steps[0].Action).To(Equal(rotationActionTransferOwnership))
+			Expect(accept).NotTo(BeNil())
+			Expect(accept.Action).To(Equal(rotationActionAcceptOwnership))
+			Expect(accept.Address).To(Equal(contract.Hex()))
+		})
+
+		It("records the acceptOwnership transaction of the new key before sending it", func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(kontractdeployerv1alpha1.AddToScheme(scheme)).To(Succeed())
+			keySecret := func(name string, key *ecdsa.PrivateKey) *corev1.Secret {
+				return &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Data:       map[string][]byte{"privateKey": []byte(hexutil.Encode(crypto.FromECDSA(key)))},
+				}
+			}
+			wallet := &kontractdeployerv1alpha1.Wallet{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default"},
+				Spec: kontractdeployerv1alpha1.WalletSpec{
+					WalletType: "EOA",
+					NetworkRef: "local",
+					Rotation:   &kontractdeployerv1alpha1.RotationSpec{RequestID: "r1"},
+				},
+				Status: kontractdeployerv1alpha1.WalletStatus{
+					PublicKey: oldAddress.Hex(),
+					SecretRef: "deployer-wallet-secret",
+					Rotation: &kontractdeployerv1alpha1.RotationStatus{
+						RequestID:    "r1",
+						Phase:        rotationPhaseMigrating,
+						OldPublicKey: oldAddress.Hex(),
+						NewPublicKey: newAddress.Hex(),
+						NewSecretRef: "deployer-wallet-secret-r1",
+						Steps: []kontractdeployerv1alpha1.RotationStep{
+							{NetworkRef: "local", Address: contract.Hex(), Action: rotationActionTransferOwnership, State: rotationStepSucceeded},
+							{NetworkRef: "local", Address: contract.Hex(), Action: rotationActionAcceptOwnership, State: rotationStepPlanned},
+						},
+					},
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				wallet,
+				keySecret("deployer-wallet-secret", oldKey),
+				keySecret("deployer-wallet-secret-r1", newKey),
+				&kontractdeployerv1alpha1.Network{
+					ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+					Spec: kontractdeployerv1alpha1.NetworkSpec{
+						NetworkName:    "local",
+						ChainID:        31337,
+						RPCProviderRef: corev1.LocalObjectReference{Name: "local"},
+					},
+				},
+				&kontractdeployerv1alpha1.RPCProvider{
+					ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+					Spec: kontractdeployerv1alpha1.RPCProviderSpec{
+						ProviderName: "Anvil",
+						SecretRef:    kontractdeployerv1alpha1.SecretKeyReference{Name: "local-rpc-secret", URLKey: "url"},
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "local-rpc-secret", Namespace: "default"},
+					Data:       map[string][]byte{"url": []byte(server.URL)},
+				},
+			).WithStatusSubresource(&kontractdeployerv1alpha1.Wallet{}).Build()
+			r := &WalletReconciler{Client: c, Scheme: scheme, EventRecorder: record.NewFakeRecorder(10)}
+
+			// Sending fails, but the transaction was recorded first
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(wallet), wallet)).To(Succeed())
+			_, err := r.reconcileRotation(ctx, wallet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rawTxs).To(HaveLen(1))
+			tx := new(gethtypes.Transaction)
+			Expect(tx.UnmarshalBinary(hexutil.MustDecode(rawTxs[0]))).To(Succeed())
+			sender, err := gethtypes.Sender(gethtypes.LatestSignerForChainID(tx.ChainId()), tx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sender).To(Equal(newAddress))
+			Expect(tx.Data()).To(Equal(rotationABI.Methods["acceptOwnership"].ID))
+
+			stored := &kontractdeployerv1alpha1.Wallet{}
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(wallet), stored)).To(Succeed())
+			accept := stored.Status.Rotation.Steps[1]
+			Expect(accept.State).To(Equal(rotationStepPending))
+			Expect(accept.TransactionHash).To(Equal(tx.Hash().Hex()))
+			Expect(accept.Nonce).To(HaveValue(Equal(uint64(7))))
+
+			// The transaction never reached the network, so the step is retried with its nonce
+			_, err = r.reconcileRotation(ctx, stored)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(wallet), stored)).To(Succeed())
+			accept = stored.Status.Rotation.Steps[1]
+			Expect(accept.State).To(Equal(rotationStepPlanned))
+			Expect(accept.Attempts).To(Equal(int32(1)))
+			Expect(accept.Nonce).To(HaveValue(Equal(uint64(7))))
+			Expect(accept.Message).To(Equal("transaction was not found on the network"))
+		})
+	})
+})
+
+// safeServiceStandIn is a minimal in-memory Safe Transaction Service
+type safeServiceStandIn struct {
+	mu           sync.Mutex
+	transactions map[string]map[string]interface{}
+}
+
+func (s *safeServiceStandIn) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case req.Method == http.MethodPost && len(parts) == 5 && parts[2] == "safes":
+		body := map[string]interface{}{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		hash := body["contractTransactionHash"].(string)
+		s.transactions[hash] = map[string]interface{}{
+			"safeTxHash":            hash,
+			"nonce":                 body["nonce"],
+			"isExecuted":            false,
+			"confirmationsRequired": 2,
+			"confirmations":         []map[string]string{{"owner": body["sender"].(string)}},
+		}
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodGet && len(parts) == 5 && parts[2] == "safes":
+		// Queued transactions, highest nonce first
+		var queued []map[string]interface{}
+		for _, tx := range s.transactions {
+			if !tx["isExecuted"].(bool) && (len(queued) == 0 || toInt64(tx["nonce"]) > toInt64(queued[0]["nonce"])) {
+				queued = []map[string]interface{}{tx}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": queued})
+	case req.Method == http.MethodPost && len(parts) == 5 && parts[4] == "confirmations":
+		tx, ok := s.transactions[parts[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		tx["confirmations"] = append(tx["confirmations"].([]map[string]string), map[string]string{"owner": "confirmed"})
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodGet && len(parts) == 4:
+		tx, ok := s.transactions[parts[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(tx)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// toInt64 converts a JSON number decoded into an interface to int64
+func toInt64(value interface{}) int64 {
+	number, _ := value.(float64)
+	return int64(number)
+}
+
+var _ = Describe("Safe wallets", func() {
+	It("signs Safe transactions with a recoverable owner signature", func() {
+		privateKey, err := crypto.GenerateKey()
+		Expect(err).NotTo(HaveOccurred())
+		hash := crypto.Keccak256Hash([]byte("safe transaction"))
+
+		signature, err := signSafeTransaction(privateKey, hash)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signature[64]).To(BeNumerically(">=", 27))
+
+		recoverable := append([]byte{}, signature...)
+		recoverable[64] -= 27
+		publicKey, err := crypto.SigToPub(hash.Bytes(), recoverable)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crypto.PubkeyToAddress(*publicKey)).To(Equal(crypto.PubkeyToAddress(privateKey.PublicKey)))
+	})
+
+	It("packs signatures in ascending owner order", func() {
+		low := common.HexToAddress("0x0000000000000000000000000000000000000001")
+		high := common.HexToAddress("0x0000000000000000000000000000000000000002")
+		packed := packSafeSignatures(map[common.Address][]byte{
+			high: {0x02},
+			low:  {0x01},
+		})
+		Expect(packed).To(Equal([]byte{0x01, 0x02}))
+	})
+
+	It("proposes and confirms transactions through a transaction service", func() {
+		standIn := &safeServiceStandIn{transactions: map[string]map[string]interface{}{}}
+		server := httptest.NewServer(standIn)
+		defer server.Close()
+
+		service := &safeServiceClient{baseURL: server.URL}
+		safe := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		tx := safeTransaction{
+			to:    common.HexToAddress("0x00000000000000000000000000000000000000dd"),
+			value: big.NewInt(0),
+			data:  hexutil.MustDecode("0x8456cb59"),
+			nonce: big.NewInt(3),
+		}
+		hash := crypto.Keccak256Hash([]byte("proposal"))
+		sender := common.HexToAddress("0x00000000000000000000000000000000000000ee")
+
+		Expect(service.propose(context.Background(), safe, tx, hash, sender, []byte{0x01})).To(Succeed())
+		Expect(service.confirm(context.Background(), hash, []byte{0x02})).To(Succeed())
+
+		proposed, err := service.transaction(context.Background(), hash)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(proposed.Nonce).To(Equal(int64(3)))
+		Expect(proposed.IsExecuted).To(BeFalse())
+		Expect(proposed.ConfirmationsRequired).To(Equal(int64(2)))
+		Expect(proposed.Confirmations).To(HaveLen(2))
+
+		_, err = service.transaction(context.Background(), crypto.Keccak256Hash([]byte("unknown")))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("proposes after the transactions queued in the transaction service", func() {
+		standIn := &safeServiceStandIn{transactions: map[string]map[string]interface{}{}}
+		server := httptest.NewServer(standIn)
+		defer server.Close()
+
+		service := &safeServiceClient{baseURL: server.URL}
+		safe := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		nonce, err := service.nextNonce(context.Background(), safe, big.NewInt(3))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nonce.Int64()).To(Equal(int64(3)))
+
+		sender := common.HexToAddress("0x00000000000000000000000000000000000000ee")
+		for _, queued := range []int64{3, 4} {
+			tx := safeTransaction{to: safe, value: big.NewInt(0), nonce: big.NewInt(queued)}
+			hash := crypto.Keccak256Hash([]byte(fmt.Sprintf("proposal %d", queued)))
+			Expect(service.propose(context.Background(), safe, tx, hash, sender, []byte{0x01})).To(Succeed())
+		}
+		nonce, err = service.nextNonce(context.Background(), safe, big.NewInt(3))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nonce.Int64()).To(Equal(int64(5)))
+	})
+
+	It("emits a Safe warning only when its condition changes", func() {
+		recorder := record.NewFakeRecorder(10)
+		r := &WalletReconciler{EventRecorder: recorder}
+		wallet := &kontractdeployerv1alpha1.Wallet{Status: kontractdeployerv1alpha1.WalletStatus{Safe: &kontractdeployerv1alpha1.SafeStatus{}}}
+
+		r.setSafeCondition(wallet, conditionDelegatesReady, reasonDelegateNotFound, "Delegate Wallet signer not found")
+		r.setSafeCondition(wallet, conditionDelegatesReady, reasonDelegateNotFound, "Delegate Wallet signer not found")
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(ContainSubstring("DelegateNotFound"))
+
+		r.setSafeCondition(wallet, conditionDelegatesReady, reasonDelegatesReady, "All delegates are owners of the Safe")
+		Expect(recorder.Events).To(BeEmpty())
+		r.setSafeCondition(wallet, conditionDelegatesReady, reasonDelegateNotFound, "Delegate Wallet signer not found")
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(wallet.Status.Safe.Conditions).To(HaveLen(1))
+		Expect(wallet.Status.Safe.Conditions[0].Status).To(Equal(metav1.ConditionFalse))
+	})
+})
+
+// bundlerStandIn is a minimal in-memory ERC-4337 bundler served over JSON-RPC
+type bundlerStandIn struct {
+	mu       sync.Mutex
+	included map[common.Hash]bool
+}
+
+func (b *bundlerStandIn) SupportedEntryPoints() []common.Address {
+	return []common.Address{common.HexToAddress(defaultEntryPoint)}
+}
+
+func (b *bundlerStandIn) SendUserOperation(op userOperation, entryPoint common.Address) (common.Hash, error) {
+	if len(op.Signature) != 65 {
+		return common.Hash{}, fmt.Errorf("AA24 signature error")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hash := userOperationHash(&op, entryPoint, big.NewInt(31337))
+	b.included[hash] = false
+	return hash, nil
+}
+
+func (b *bundlerStandIn) GetUserOperationReceipt(hash common.Hash) (*userOperationReceipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.included[hash] {
+		return nil, nil
+	}
+	receipt := &userOperationReceipt{Success: true}
+	receipt.Receipt.TransactionHash = crypto.Keccak256Hash(hash.Bytes())
+	return receipt, nil
+}
+
+var _ = Describe("Smart account wallets", func() {
+	op := func() *userOperation {
+		factory := common.HexToAddress("0x00000000000000000000000000000000000000fa")
+		factoryData := hexutil.Bytes{0x5f, 0xbf, 0xb9, 0xcf}
+		return &userOperation{
+			Sender:               common.HexToAddress("0x00000000000000000000000000000000000000aa"),
+			Nonce:                hexBig(big.NewInt(1)),
+			Factory:              &factory,
+			FactoryData:          &factoryData,
+			CallData:             hexutil.MustDecode("0xb61d27f6"),
+			CallGasLimit:         hexBig(big.NewInt(100000)),
+			VerificationGasLimit: hexBig(big.NewInt(200000)),
+			PreVerificationGas:   hexBig(big.NewInt(50000)),
+			MaxFeePerGas:         hexBig(big.NewInt(2000000000)),
+			MaxPriorityFeePerGas: hexBig(big.NewInt(1000000000)),
+		}
+	}
+
+	It("signs the UserOperation hash as a personal message of the owner", func() {
+		privateKey, err := crypto.GenerateKey()
+		Expect(err).NotTo(HaveOccurred())
+		entryPoint := common.HexToAddress(defaultEntryPoint)
+		hash := userOperationHash(op(), entryPoint, big.NewInt(1))
+
+		signed := op()
+		signed.Signature = hexutil.Bytes{0x01}
+		Expect(userOperationHash(signed, entryPoint, big.NewInt(1))).To(Equal(hash))
+		Expect(userOperationHash(op(), entryPoint, big.NewInt(10))).NotTo(Equal(hash))
+
+		signature, err := signUserOperation(privateKey, hash)
+		Expect(err).NotTo(HaveOccurred())
+		recoverable := append([]byte{}, signature...)
+		recoverable[64] -= 27
+		publicKey, err := crypto.SigToPub(accounts.TextHash(hash.Bytes()), recoverable)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crypto.PubkeyToAddress(*publicKey)).To(Equal(crypto.PubkeyToAddress(privateKey.PublicKey)))
+	})
+
+	It("packs the init code and paymaster fields as the v0.7 EntryPoint expects", func() {
+		operation := op()
+		Expect(operation.initCode()).To(HaveLen(24))
+		Expect(operation.paymasterAndData()).To(BeEmpty())
+
+		paymaster := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+		operation.applyPaymaster(&paymasterResult{
+			Paymaster:                     &paymaster,
+			PaymasterData:                 hexutil.Bytes{0xca, 0xfe},
+			PaymasterVerificationGasLimit: hexBig(big.NewInt(0x10)),
+			PaymasterPostOpGasLimit:       hexBig(big.NewInt(0x20)),
+		})
+		packed := operation.paymasterAndData()
+		Expect(packed).To(HaveLen(20 + 16 + 16 + 2))
+		Expect(packed[:20]).To(Equal(paymaster.Bytes()))
+		Expect(packed[35]).To(Equal(byte(0x10)))
+		Expect(packed[51]).To(Equal(byte(0x20)))
+		Expect(packed[52:]).To(Equal([]byte{0xca, 0xfe}))
+	})
+
+	It("tracks a UserOperation until the bundler includes it", func() {
+		standIn := &bundlerStandIn{included: map[common.Hash]bool{}}
+		server := rpc.NewServer()
+		Expect(server.RegisterName("eth", standIn)).To(Succeed())
+		httpServer := httptest.NewServer(server)
+		defer httpServer.Close()
+
+		rpcClient, err := rpc.Dial(httpServer.URL)
+		Expect(err).NotTo(HaveOccurred())
+		bundler := &bundlerClient{rpc: rpcClient}
+		defer bundler.close()
+		entryPoint := common.HexToAddress(defaultEntryPoint)
+
+		entryPoints, err := bundler.supportedEntryPoints(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entryPoints).To(ContainElement(entryPoint))
+
+		status := &kontractdeployerv1alpha1.WalletTransactionStatus{}
+		_, err = bundler.send(context.Background(), op(), entryPoint)
+		Expect(permanentUserOperationError(status, err)).To(Succeed())
+		Expect(status.State).To(Equal(txStateFailed))
+		Expect(status.Message).To(ContainSubstring("AA24"))
+
+		signed := op()
+		signed.Signature = make(hexutil.Bytes, 65)
+		hash, err := bundler.send(context.Background(), signed, entryPoint)
+		Expect(err).NotTo(HaveOccurred())
+
+		status = &kontractdeployerv1alpha1.WalletTransactionStatus{State: txStatePending, UserOpHash: hash.Hex()}
+		Expect(checkUserOperationReceipt(context.Background(), bundler, status)).To(Succeed())
+		Expect(status.State).To(Equal(txStatePending))
+
+		standIn.mu.Lock()
+		standIn.included[hash] = true
+		standIn.mu.Unlock()
+		Expect(checkUserOperationReceipt(context.Background(), bundler, status)).To(Succeed())
+		Expect(status.State).To(Equal(txStateSucceeded))
+		Expect(status.TransactionHash).To(Equal(crypto.Keccak256Hash(hash.Bytes()).Hex()))
+	})
+})
@@ -0,0 +1,315 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// fundingCheckInterval is how often the balances of a funded wallet are checked
+	fundingCheckInterval = time.Minute
+
+	// fundingWindow is the length of the daily cap window
+	fundingWindow = 24 * time.Hour
+
+	// maxRecordedTransfers bounds the number of top-up transfers kept in the Wallet status
+	maxRecordedTransfers = 20
+
+	fundingMethodTransfer        = "transfer"
+	fundingMethodAnvilSetBalance = "anvil_setBalance"
+)
+
+// reconcileFunding tops up the wallet on every network listed in its funding policy
+func (r *WalletReconciler) reconcileFunding(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	funding := wallet.Spec.Funding
+	if funding == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if funding.FundFrom == wallet.Name {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidFundingPolicy", "A wallet cannot fund itself")
+		return ctrl.Result{}, nil
+	}
+
+	var dailyCap *big.Int
+	if funding.DailyCap != "" {
+		var err error
+		if dailyCap, err = parseWei(funding.DailyCap); err != nil {
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidFundingPolicy", fmt.Sprintf("Invalid daily cap: %v", err))
+			return ctrl.Result{}, nil
+		}
+	}
+
+	if err := validateFundingTargets(wallet); err != nil {
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "InvalidFundingTarget", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	previous := map[string]kontractdeployerv1alpha1.FundingNetworkStatus{}
+	for _, status := range wallet.Status.Funding {
+		previous[status.NetworkRef] = status
+	}
+
+	// Status updates trigger a reconcile as well, so networks checked within the interval are skipped
+	now := time.Now()
+	requeueAfter := fundingCheckInterval
+	checked := len(wallet.Status.Funding) != len(funding.Targets)
+	fundingStatuses := make([]kontractdeployerv1alpha1.FundingNetworkStatus, 0, len(funding.Targets))
+	due := make([]bool, 0, len(funding.Targets))
+	for _, target := range funding.Targets {
+		networkRef := target.NetworkRef
+		if networkRef == "" {
+			networkRef = wallet.Spec.NetworkRef
+		}
+
+		status, ok := previous[networkRef]
+		if !ok {
+			status = kontractdeployerv1alpha1.FundingNetworkStatus{NetworkRef: networkRef}
+		}
+		wait := status.LastChecked.Add(fundingCheckInterval).Sub(now)
+		if wait > 0 && wait < requeueAfter {
+			requeueAfter = wait
+		}
+		due = append(due, wait <= 0)
+		checked = checked || wait <= 0
+		fundingStatuses = append(fundingStatuses, status)
+	}
+	if !checked {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	// Fetch the wallet that sends the top-up transfers
+	treasury := &kontractdeployerv1alpha1.Wallet{}
+	if err := r.Get(ctx, client.ObjectKey{Name: funding.FundFrom, Namespace: wallet.Namespace}, treasury); err != nil {
+		logger.Error(err, "Failed to get funding source Wallet", "FundFrom", funding.FundFrom)
+		r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "FundingSourceNotFound", fmt.Sprintf("Funding source Wallet %s not found", funding.FundFrom))
+		return ctrl.Result{}, err
+	}
+	if treasury.Status.PublicKey == "" {
+		logger.Info("Funding source Wallet is not ready yet", "FundFrom", funding.FundFrom)
+		return ctrl.Result{RequeueAfter: fundingCheckInterval}, nil
+	}
+
+	// fundOnNetwork persists a transfer before sending it, so it works on the status of the Wallet itself
+	wallet.Status.Funding = fundingStatuses
+	for i, target := range funding.Targets {
+		if !due[i] {
+			continue
+		}
+		status := &wallet.Status.Funding[i]
+		if err := r.fundOnNetwork(ctx, wallet, treasury, target, dailyCap, status); err != nil {
+			logger.Error(err, "Failed to top up wallet", "Network", status.NetworkRef)
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "FundingFailed", fmt.Sprintf("Failed to top up wallet on network %s: %v", status.NetworkRef, err))
+		}
+	}
+
+	if err := r.Status().Update(ctx, wallet); err != nil {
+		logger.Error(err, "Failed to update Wallet funding status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// fundOnNetwork checks the wallet balance on a single network and tops it up when it is below the minimum
+func (r *WalletReconciler) fundOnNetwork(ctx context.Context, wallet, treasury *kontractdeployerv1alpha1.Wallet, target kontractdeployerv1alpha1.FundingTarget, dailyCap *big.Int, status *kontractdeployerv1alpha1.FundingNetworkStatus) error {
+	minBalance, err := parseWei(target.MinBalance)
+	if err != nil {
+		return fmt.Errorf("invalid minBalance: %w", err)
+	}
+	targetBalance, err := parseWei(target.TargetBalance)
+	if err != nil {
+		return fmt.Errorf("invalid targetBalance: %w", err)
+	}
+
+	ethClient, network, err := dialNetwork(ctx, r.Client, wallet.Namespace, status.NetworkRef)
+	if err != nil {
+		return err
+	}
+	defer ethClient.Close()
+
+	now := metav1.Now()
+	status.LastChecked = now
+
+	// Wait for the previous top-up to be mined before looking at the balance again
+	if status.PendingTransaction != "" {
+		receipt, err := ethClient.TransactionReceipt(ctx, common.HexToHash(status.PendingTransaction))
+		if errors.Is(err, ethereum.NotFound) {
+			if _, _, err := ethClient.TransactionByHash(ctx, common.HexToHash(status.PendingTransaction)); err == nil {
+				return nil
+			} else if !errors.Is(err, ethereum.NotFound) {
+				return fmt.Errorf("failed to get transaction %s: %w", status.PendingTransaction, err)
+			}
+			// The transfer never reached the network, so the next one reuses its nonce
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "FundingTransferDropped", fmt.Sprintf("Top-up transfer %s on network %s was not found on the network", status.PendingTransaction, status.NetworkRef))
+			status.PendingTransaction = ""
+		} else if err != nil {
+			return fmt.Errorf("failed to get receipt of %s: %w", status.PendingTransaction, err)
+		} else {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "FundingTransferReverted", fmt.Sprintf("Top-up transfer %s on network %s reverted", status.PendingTransaction, status.NetworkRef))
+			}
+			status.PendingTransaction = ""
+			status.PendingNonce = nil
+		}
+	}
+
+	address := common.HexToAddress(wallet.Status.PublicKey)
+	balance, err := ethClient.BalanceAt(ctx, address, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get balance: %w", err)
+	}
+	status.Balance = balance.String()
+
+	if balance.Cmp(minBalance) >= 0 {
+		return nil
+	}
+
+	// Start a new daily cap window once the previous one has passed
+	if status.WindowStart.IsZero() || now.Sub(status.WindowStart.Time) >= fundingWindow {
+		status.WindowStart = now
+		status.FundedInWindow = "0"
+		status.CapReached = false
+	}
+	fundedInWindow := big.NewInt(0)
+	if status.FundedInWindow != "" {
+		if fundedInWindow, err = parseWei(status.FundedInWindow); err != nil {
+			fundedInWindow = big.NewInt(0)
+		}
+	}
+
+	amount := fundingAmount(balance, targetBalance, dailyCap, fundedInWindow)
+	if amount.Sign() <= 0 {
+		// Warn once per window rather than on every balance check
+		if !status.CapReached {
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "FundingCapReached", fmt.Sprintf("Daily funding cap reached on network %s, balance %s wei is below the minimum", status.NetworkRef, balance))
+		}
+		status.CapReached = true
+		return nil
+	}
+
+	transfer := kontractdeployerv1alpha1.FundingTransfer{
+		NetworkRef: status.NetworkRef,
+		Amount:     amount.String(),
+		Time:       now,
+	}
+
+	if network.Spec.NetworkName == anvilNetworkName {
+		// Local Anvil networks can set the balance directly instead of moving funds
+		newBalance := new(big.Int).Add(balance, amount)
+		if err := ethClient.Client().CallContext(ctx, nil, "anvil_setBalance", address, hexutil.EncodeBig(newBalance)); err != nil {
+			return fmt.Errorf("anvil_setBalance failed: %w", err)
+		}
+		transfer.Method = fundingMethodAnvilSetBalance
+		status.Balance = newBalance.String()
+	} else {
+		treasuryKey, err := walletPrivateKey(ctx, r.Client, treasury)
+		if err != nil {
+			return err
+		}
+		nonce, err := reusableNonce(ctx, ethClient, common.HexToAddress(treasury.Status.PublicKey), status.PendingNonce)
+		if err != nil {
+			return err
+		}
+		signedTx, err := signNativeTransfer(ctx, ethClient, treasuryKey, nonce, address, amount)
+		if err != nil {
+			return err
+		}
+		transfer.Method = fundingMethodTransfer
+		transfer.TransactionHash = signedTx.Hash().Hex()
+		status.PendingTransaction = signedTx.Hash().Hex()
+		status.PendingNonce = &nonce
+		recordFundingTransfer(wallet, status, fundedInWindow, amount, transfer)
+
+		// Persist the transfer first: if the update fails nothing was sent, and a later resend reuses the nonce
+		if err := r.Status().Update(ctx, wallet); err != nil {
+			return fmt.Errorf("failed to record top-up transfer: %w", err)
+		}
+		if err := ethClient.SendTransaction(ctx, signedTx); err != nil {
+			return fmt.Errorf("failed to send transaction: %w", err)
+		}
+		r.EventRecorder.Event(wallet, corev1.EventTypeNormal, "WalletFunded", fmt.Sprintf("Topped up %s wei on network %s from wallet %s using %s %s", amount, status.NetworkRef, treasury.Name, transfer.Method, transfer.TransactionHash))
+		return nil
+	}
+	recordFundingTransfer(wallet, status, fundedInWindow, amount, transfer)
+
+	r.EventRecorder.Event(wallet, corev1.EventTypeNormal, "WalletFunded", fmt.Sprintf("Topped up %s wei on network %s from wallet %s using %s %s", amount, status.NetworkRef, treasury.Name, transfer.Method, transfer.TransactionHash))
+	return nil
+}
+
+// recordFundingTransfer adds a top-up transfer to the daily cap window and the recorded transfers of the wallet
+func recordFundingTransfer(wallet *kontractdeployerv1alpha1.Wallet, status *kontractdeployerv1alpha1.FundingNetworkStatus, fundedInWindow, amount *big.Int, transfer kontractdeployerv1alpha1.FundingTransfer) {
+	status.FundedInWindow = new(big.Int).Add(fundedInWindow, amount).String()
+
+	wallet.Status.Transfers = append(wallet.Status.Transfers, transfer)
+	if len(wallet.Status.Transfers) > maxRecordedTransfers {
+		wallet.Status.Transfers = wallet.Status.Transfers[len(wallet.Status.Transfers)-maxRecordedTransfers:]
+	}
+}
+
+// validateFundingTargets checks that every target has valid balances and tops up to at least its minimum
+func validateFundingTargets(wallet *kontractdeployerv1alpha1.Wallet) error {
+	for _, target := range wallet.Spec.Funding.Targets {
+		networkRef := target.NetworkRef
+		if networkRef == "" {
+			networkRef = wallet.Spec.NetworkRef
+		}
+		minBalance, err := parseWei(target.MinBalance)
+		if err != nil {
+			return fmt.Errorf("invalid minBalance of funding target %s: %w", networkRef, err)
+		}
+		targetBalance, err := parseWei(target.TargetBalance)
+		if err != nil {
+			return fmt.Errorf("invalid targetBalance of funding target %s: %w", networkRef, err)
+		}
+		if targetBalance.Cmp(minBalance) < 0 {
+			return fmt.Errorf("targetBalance %s of funding target %s is below its minBalance %s", targetBalance, networkRef, minBalance)
+		}
+	}
+	return nil
+}
+
+// fundingAmount returns the amount needed to reach the target balance, limited by what is left of the daily cap
+func fundingAmount(balance, targetBalance, dailyCap, fundedInWindow *big.Int) *big.Int {
+	amount := new(big.Int).Sub(targetBalance, balance)
+	if dailyCap != nil {
+		remaining := new(big.Int).Sub(dailyCap, fundedInWindow)
+		if amount.Cmp(remaining) > 0 {
+			amount = remaining
+		}
+	}
+	return amount
+}
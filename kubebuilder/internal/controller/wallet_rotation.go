@@ -0,0 +1,657 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// rotationPollInterval is how often a running rotation checks its pending transaction
+	rotationPollInterval = 5 * time.Second
+
+	// rotationRetryInterval is how long a rotation that moved permissions already waits before retrying a failed
+	// step for the first time. The wait doubles with every further attempt.
+	rotationRetryInterval = time.Minute
+
+	// rotationMaxAttempts is how often a step is tried before the rotation fails
+	rotationMaxAttempts = 5
+
+	rotationPhaseMigrating = "Migrating"
+	rotationPhaseCompleted = "Completed"
+	rotationPhaseFailed    = "Failed"
+
+	rotationStepPlanned   = "Planned"
+	rotationStepPending   = "Pending"
+	rotationStepSucceeded = "Succeeded"
+	rotationStepSkipped   = "Skipped"
+	rotationStepFailed    = "Failed"
+
+	rotationActionTransferOwnership = "transferOwnership"
+	rotationActionAcceptOwnership   = "acceptOwnership"
+	rotationActionGrantRole         = "grantRole"
+	rotationActionRenounceRole      = "renounceRole"
+	rotationActionSweep             = "sweep"
+
+	defaultAdminRole = "DEFAULT_ADMIN_ROLE"
+
+	// retiredKeyAnnotation marks the Secret of a key that was replaced by a rotation
+	retiredKeyAnnotation = "kontract.expedio.xyz/retired-by-rotation"
+)
+
+// rotationABI covers the Ownable, Ownable2Step, AccessControl and ProxyAdmin functions used to migrate permissions
+var rotationABI = mustParseABI(`[
+	{"type":"function","name":"owner","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"pendingOwner","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"transferOwnership","stateMutability":"nonpayable","inputs":[{"name":"newOwner","type":"address"}],"outputs":[]},
+	{"type":"function","name":"acceptOwnership","stateMutability":"nonpayable","inputs":[],"outputs":[]},
+	{"type":"function","name":"hasRole","stateMutability":"view","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"grantRole","stateMutability":"nonpayable","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[]},
+	{"type":"function","name":"renounceRole","stateMutability":"nonpayable","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[]}
+]`)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// rotationTarget is a contract whose permissions may be held by the rotated key
+type rotationTarget struct {
+	networkRef string
+	target     string
+	address    common.Address
+}
+
+// rotationRequested reports whether the wallet has a rotation to start or to continue
+func rotationRequested(wallet *kontractdeployerv1alpha1.Wallet) bool {
+	if wallet.Spec.Rotation == nil || wallet.Spec.Rotation.RequestID == "" {
+		return false
+	}
+	rotation := wallet.Status.Rotation
+	return rotation == nil || rotation.RequestID != wallet.Spec.Rotation.RequestID || rotation.Phase == rotationPhaseMigrating
+}
+
+// reconcileRotation starts or advances the key rotation requested in the wallet spec
+func (r *WalletReconciler) reconcileRotation(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if wallet.Status.Rotation == nil || wallet.Status.Rotation.RequestID != wallet.Spec.Rotation.RequestID {
+		if err := r.startRotation(ctx, wallet); err != nil {
+			logger.Error(err, "Failed to start key rotation")
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "RotationStartFailed", fmt.Sprintf("Failed to start key rotation: %v", err))
+			return ctrl.Result{}, err
+		}
+		r.EventRecorder.Event(wallet, corev1.EventTypeNormal, "RotationStarted", fmt.Sprintf("Rotating key %s to %s with %d transactions", wallet.Status.Rotation.OldPublicKey, wallet.Status.Rotation.NewPublicKey, len(wallet.Status.Rotation.Steps)))
+		if err := r.Status().Update(ctx, wallet); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	rotation := wallet.Status.Rotation
+	step := nextRotationStep(rotation)
+	if step == nil {
+		if err := r.completeRotation(ctx, wallet); err != nil {
+			logger.Error(err, "Failed to complete key rotation")
+			return ctrl.Result{}, err
+		}
+		r.EventRecorder.Event(wallet, corev1.EventTypeNormal, "KeyRotated", fmt.Sprintf("Key %s retired, wallet now uses %s", rotation.OldPublicKey, rotation.NewPublicKey))
+		return ctrl.Result{}, nil
+	}
+
+	// A failed step that is retried waits for its backoff, as status updates trigger a reconcile as well
+	if step.RetryTime != nil {
+		if wait := time.Until(step.RetryTime.Time); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// Errors are transient (e.g., RPC outages) and retried; a step that cannot succeed is marked failed instead
+	if err := r.advanceRotationStep(ctx, wallet, step); err != nil {
+		logger.Error(err, "Failed to advance key rotation", "Action", step.Action, "Address", step.Address, "Network", step.NetworkRef)
+		return ctrl.Result{}, err
+	}
+
+	var retryAfter time.Duration
+	if step.State == rotationStepFailed {
+		step.Attempts++
+		moved := rotationPermissionsMoved(rotation)
+		if moved && step.Attempts < rotationMaxAttempts {
+			// Permissions held by the new key already should not be left behind, so the step is retried with a backoff
+			retryAfter = rotationRetryInterval << (step.Attempts - 1)
+			retryTime := metav1.NewTime(time.Now().Add(retryAfter))
+			step.State = rotationStepPlanned
+			step.TransactionHash = ""
+			step.RetryTime = &retryTime
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "RotationStepFailed", fmt.Sprintf("%s on %s (%s) failed, retrying in %s (attempt %d of %d): %s", step.Action, step.Address, step.NetworkRef, retryAfter, step.Attempts, rotationMaxAttempts, step.Message))
+		} else {
+			now := metav1.Now()
+			rotation.Phase = rotationPhaseFailed
+			rotation.CompletionTime = &now
+			message := fmt.Sprintf("%s on %s (%s) failed: %s", step.Action, step.Address, step.NetworkRef, step.Message)
+			if moved {
+				message = fmt.Sprintf("%s on %s (%s) failed after %d attempts, the new key %s keeps the permissions moved so far: %s", step.Action, step.Address, step.NetworkRef, step.Attempts, rotation.NewPublicKey, step.Message)
+			}
+			r.EventRecorder.Event(wallet, corev1.EventTypeWarning, "RotationFailed", message)
+		}
+	}
+
+	if err := r.Status().Update(ctx, wallet); err != nil {
+		logger.Error(err, "Failed to update Wallet rotation status")
+		return ctrl.Result{}, err
+	}
+	switch {
+	case rotation.Phase == rotationPhaseFailed:
+		return ctrl.Result{}, nil
+	case retryAfter > 0:
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	case step.State == rotationStepPending:
+		return ctrl.Result{RequeueAfter: rotationPollInterval}, nil
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// startRotation generates the new key and plans the transactions that move permissions and funds to it
+func (r *WalletReconciler) startRotation(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet) error {
+	spec := wallet.Spec.Rotation
+	oldAddress := common.HexToAddress(wallet.Status.PublicKey)
+
+	newSecretName := fmt.Sprintf("%s-wallet-secret-%s", wallet.Name, spec.RequestID)
+	newPublicKey, _, err := r.generateWalletSecret(ctx, wallet, newSecretName)
+	if err != nil {
+		return err
+	}
+	newAddress := common.HexToAddress(newPublicKey)
+	if newAddress == oldAddress {
+		return fmt.Errorf("secret %s holds the key being rotated", newSecretName)
+	}
+
+	roles := spec.Roles
+	if len(roles) == 0 {
+		roles = []string{defaultAdminRole}
+	}
+	for _, role := range roles {
+		if _, err := parseRole(role); err != nil {
+			return err
+		}
+	}
+
+	targets, err := r.rotationTargets(ctx, wallet)
+	if err != nil {
+		return err
+	}
+
+	// Look up the permissions held by the old key, one connection per network
+	clients := map[string]*ethclient.Client{}
+	defer func() {
+		for _, ethClient := range clients {
+			ethClient.Close()
+		}
+	}()
+
+	var steps, accepts []kontractdeployerv1alpha1.RotationStep
+	networks := map[string]bool{wallet.Spec.NetworkRef: true}
+	for _, target := range targets {
+		ethClient, ok := clients[target.networkRef]
+		if !ok {
+			if ethClient, _, err = dialNetwork(ctx, r.Client, wallet.Namespace, target.networkRef); err != nil {
+				return err
+			}
+			clients[target.networkRef] = ethClient
+		}
+		networks[target.networkRef] = true
+		targetSteps, accept := planTargetRotation(ctx, ethClient, target, oldAddress, roles)
+		steps = append(steps, targetSteps...)
+		if accept != nil {
+			accepts = append(accepts, *accept)
+		}
+	}
+
+	if spec.SweepFunds == nil || *spec.SweepFunds {
+		networkRefs := make([]string, 0, len(networks))
+		for networkRef := range networks {
+			networkRefs = append(networkRefs, networkRef)
+		}
+		sort.Strings(networkRefs)
+		for _, networkRef := range networkRefs {
+			steps = append(steps, kontractdeployerv1alpha1.RotationStep{
+				NetworkRef: networkRef,
+				Address:    newAddress.Hex(),
+				Action:     rotationActionSweep,
+				State:      rotationStepPlanned,
+			})
+		}
+	}
+	// The new key accepts ownership last, once the sweep paid for its gas
+	steps = append(steps, accepts...)
+
+	wallet.Status.Rotation = &kontractdeployerv1alpha1.RotationStatus{
+		RequestID:    spec.RequestID,
+		Phase:        rotationPhaseMigrating,
+		OldPublicKey: oldAddress.Hex(),
+		NewPublicKey: newAddress.Hex(),
+		NewSecretRef: newSecretName,
+		StartTime:    metav1.Now(),
+		Steps:        steps,
+	}
+	return nil
+}
+
+// rotationTargets lists the contracts signed for by the wallet: deployed versions, imported contracts and ProxyAdmins
+func (r *WalletReconciler) rotationTargets(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet) ([]rotationTarget, error) {
+	var targets []rotationTarget
+	seen := map[string]bool{}
+	add := func(networkRef, target, address string) {
+		if !common.IsHexAddress(address) {
+			return
+		}
+		key := networkRef + "/" + strings.ToLower(address)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		targets = append(targets, rotationTarget{networkRef: networkRef, target: target, address: common.HexToAddress(address)})
+	}
+
+	contractVersions := &kontractdeployerv1alpha1.ContractVersionList{}
+	if err := r.List(ctx, contractVersions, client.InNamespace(wallet.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list ContractVersions: %w", err)
+	}
+	for _, version := range contractVersions.Items {
+		if version.Spec.WalletRef == wallet.Name {
+			add(version.Spec.NetworkRef, "ContractVersion/"+version.Name, version.Status.ContractAddress)
+		}
+	}
+
+	contracts := &kontractdeployerv1alpha1.ContractList{}
+	if err := r.List(ctx, contracts, client.InNamespace(wallet.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list Contracts: %w", err)
+	}
+	for _, contract := range contracts.Items {
+		if contract.Spec.WalletRef == wallet.Name && contract.Spec.Import {
+			for _, networkRef := range contract.Spec.NetworkRefs {
+				add(networkRef, "Contract/"+contract.Name, contract.Spec.ImportContractAddress)
+			}
+		}
+	}
+
+	proxyAdmins := &kontractdeployerv1alpha1.ProxyAdminList{}
+	if err := r.List(ctx, proxyAdmins, client.InNamespace(wallet.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list ProxyAdmins: %w", err)
+	}
+	for _, proxyAdmin := range proxyAdmins.Items {
+		if proxyAdmin.Spec.WalletRef == wallet.Name {
+			add(proxyAdmin.Spec.NetworkRef, "ProxyAdmin/"+proxyAdmin.Name, proxyAdmin.Spec.AdminAddress)
+		}
+	}
+
+	return targets, nil
+}
+
+// planTargetRotation returns the steps that move ownership and roles of a single contract to the new key
+// Grants come before renounces so the contract is never left without an admin.
+// For Ownable2Step contracts it also returns the acceptOwnership step that the new key sends.
+func planTargetRotation(ctx context.Context, ethClient *ethclient.Client, target rotationTarget, oldAddress common.Address, roles []string) ([]kontractdeployerv1alpha1.RotationStep, *kontractdeployerv1alpha1.RotationStep) {
+	newStep := func(action, role string) kontractdeployerv1alpha1.RotationStep {
+		return kontractdeployerv1alpha1.RotationStep{
+			NetworkRef: target.networkRef,
+			Target:     target.target,
+			Address:    target.address.Hex(),
+			Action:     action,
+			Role:       role,
+			State:      rotationStepPlanned,
+		}
+	}
+
+	var steps, renounces []kontractdeployerv1alpha1.RotationStep
+	var accept *kontractdeployerv1alpha1.RotationStep
+
+	// Contracts that are not Ownable revert or return nothing, and are skipped
+	if owner, err := callOwner(ctx, ethClient, target.address); err == nil && owner == oldAddress {
+		steps = append(steps, newStep(rotationActionTransferOwnership, ""))
+		if _, err := callPendingOwner(ctx, ethClient, target.address); err == nil {
+			acceptStep := newStep(rotationActionAcceptOwnership, "")
+			accept = &acceptStep
+		}
+	}
+
+	var adminRenounce *kontractdeployerv1alpha1.RotationStep
+	for _, role := range roles {
+		roleHash, _ := parseRole(role)
+		if hasRole, err := callHasRole(ctx, ethClient, target.address, roleHash, oldAddress); err != nil || !hasRole {
+			continue
+		}
+		steps = append(steps, newStep(rotationActionGrantRole, role))
+		renounce := newStep(rotationActionRenounceRole, role)
+		if roleHash == (common.Hash{}) {
+			adminRenounce = &renounce
+			continue
+		}
+		renounces = append(renounces, renounce)
+	}
+	if adminRenounce != nil {
+		renounces = append(renounces, *adminRenounce)
+	}
+
+	return append(steps, renounces...), accept
+}
+
+// nextRotationStep returns the first step that is not finished yet
+func nextRotationStep(rotation *kontractdeployerv1alpha1.RotationStatus) *kontractdeployerv1alpha1.RotationStep {
+	for i := range rotation.Steps {
+		switch rotation.Steps[i].State {
+		case rotationStepPlanned, rotationStepPending:
+			return &rotation.Steps[i]
+		}
+	}
+	return nil
+}
+
+// rotationPermissionsMoved reports whether the new key already holds ownership or a role granted by the rotation
+func rotationPermissionsMoved(rotation *kontractdeployerv1alpha1.RotationStatus) bool {
+	for _, step := range rotation.Steps {
+		switch step.Action {
+		case rotationActionTransferOwnership, rotationActionAcceptOwnership, rotationActionGrantRole:
+			if step.State == rotationStepSucceeded {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// advanceRotationStep sends a planned step, or checks the transaction of a pending one.
+// The transaction is recorded in the wallet status before it is sent, so it is never sent twice.
+func (r *WalletReconciler) advanceRotationStep(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet, step *kontractdeployerv1alpha1.RotationStep) error {
+	rotation := wallet.Status.Rotation
+	oldAddress := common.HexToAddress(rotation.OldPublicKey)
+	newAddress := common.HexToAddress(rotation.NewPublicKey)
+
+	ethClient, _, err := dialNetwork(ctx, r.Client, wallet.Namespace, step.NetworkRef)
+	if err != nil {
+		return err
+	}
+	defer ethClient.Close()
+
+	if step.State == rotationStepPending {
+		return checkRotationStep(ctx, ethClient, step, newAddress)
+	}
+
+	// The new key accepts ownership itself, every other step is sent by the old key
+	signer, signerAddress := wallet, oldAddress
+	if step.Action == rotationActionAcceptOwnership {
+		signer = wallet.DeepCopy()
+		signer.Status.SecretRef = rotation.NewSecretRef
+		signerAddress = newAddress
+	}
+	key, err := walletPrivateKey(ctx, r.Client, signer)
+	if err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(key.PublicKey) != signerAddress {
+		step.State = rotationStepFailed
+		step.Message = fmt.Sprintf("wallet secret %s no longer holds key %s", signer.Status.SecretRef, signerAddress.Hex())
+		return nil
+	}
+	nonce, err := reusableNonce(ctx, ethClient, signerAddress, step.Nonce)
+	if err != nil {
+		return err
+	}
+
+	var signedTx *types.Transaction
+	if step.Action == rotationActionSweep {
+		signedTx, err = signSweep(ctx, ethClient, key, nonce, newAddress)
+		if errors.Is(err, errNothingToSweep) {
+			step.State = rotationStepSkipped
+			step.Message = err.Error()
+			return nil
+		}
+	} else {
+		var data []byte
+		if data, err = rotationCallData(step, oldAddress, newAddress); err == nil {
+			signedTx, err = signContractTransaction(ctx, ethClient, key, nonce, common.HexToAddress(step.Address), big.NewInt(0), data)
+		}
+	}
+	if err != nil {
+		step.State = rotationStepFailed
+		step.Message = err.Error()
+		return nil
+	}
+
+	step.State = rotationStepPending
+	step.TransactionHash = signedTx.Hash().Hex()
+	step.Nonce = &nonce
+	step.RetryTime = nil
+	step.Message = ""
+	if err := r.Status().Update(ctx, wallet); err != nil {
+		return fmt.Errorf("failed to record rotation step: %w", err)
+	}
+	if err := ethClient.SendTransaction(ctx, signedTx); err != nil {
+		// The step stays pending; if the transaction never reached the network, it is sent again with its nonce
+		step.Message = fmt.Sprintf("failed to send transaction: %v", err)
+	}
+	return nil
+}
+
+// checkRotationStep checks whether the transaction of a pending step was mined and had the intended effect
+func checkRotationStep(ctx context.Context, ethClient *ethclient.Client, step *kontractdeployerv1alpha1.RotationStep, newAddress common.Address) error {
+	txHash := common.HexToHash(step.TransactionHash)
+	receipt, err := ethClient.TransactionReceipt(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		if _, _, err := ethClient.TransactionByHash(ctx, txHash); err == nil {
+			return nil
+		} else if !errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("failed to get transaction %s: %w", step.TransactionHash, err)
+		}
+		step.State = rotationStepFailed
+		step.Message = "transaction was not found on the network"
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get receipt of %s: %w", step.TransactionHash, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		step.State = rotationStepFailed
+		step.Message = "transaction reverted"
+		return nil
+	}
+
+	switch step.Action {
+	case rotationActionTransferOwnership, rotationActionAcceptOwnership:
+		contract := common.HexToAddress(step.Address)
+		owner, err := callOwner(ctx, ethClient, contract)
+		if err != nil {
+			return fmt.Errorf("failed to verify new owner: %w", err)
+		}
+		if owner != newAddress {
+			// Ownable2Step contracts only record a pending owner, which accepts in a later step
+			pendingOwner, err := callPendingOwner(ctx, ethClient, contract)
+			if step.Action == rotationActionAcceptOwnership || err != nil || pendingOwner != newAddress {
+				step.State = rotationStepFailed
+				step.Message = fmt.Sprintf("owner is still %s", owner.Hex())
+				return nil
+			}
+		}
+	}
+	step.State = rotationStepSucceeded
+	step.Message = ""
+	return nil
+}
+
+// rotationCallData encodes the contract call of an ownership or role step
+func rotationCallData(step *kontractdeployerv1alpha1.RotationStep, oldAddress, newAddress common.Address) ([]byte, error) {
+	switch step.Action {
+	case rotationActionTransferOwnership:
+		return rotationABI.Pack("transferOwnership", newAddress)
+	case rotationActionAcceptOwnership:
+		return rotationABI.Pack("acceptOwnership")
+	case rotationActionGrantRole, rotationActionRenounceRole:
+		role, err := parseRole(step.Role)
+		if err != nil {
+			return nil, err
+		}
+		if step.Action == rotationActionGrantRole {
+			return rotationABI.Pack("grantRole", role, newAddress)
+		}
+		return rotationABI.Pack("renounceRole", role, oldAddress)
+	}
+	return nil, fmt.Errorf("unknown rotation action %s", step.Action)
+}
+
+// errNothingToSweep is returned when the old key cannot cover the gas of a sweep
+var errNothingToSweep = errors.New("balance does not cover the transfer gas")
+
+// signSweep signs a transfer of the whole balance of the key, minus the transfer gas, to the given address
+func signSweep(ctx context.Context, ethClient *ethclient.Client, privateKey *ecdsa.PrivateKey, nonce uint64, to common.Address) (*types.Transaction, error) {
+	balance, err := ethClient.BalanceAt(ctx, crypto.PubkeyToAddress(privateKey.PublicKey), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	gasPrice, err := ethClient.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+
+	amount := new(big.Int).Sub(balance, new(big.Int).Mul(gasPrice, big.NewInt(nativeTransferGas)))
+	if amount.Sign() <= 0 {
+		return nil, errNothingToSweep
+	}
+	return signLegacyTransaction(ctx, ethClient, privateKey, nonce, to, amount, nil, nativeTransferGas, gasPrice)
+}
+
+// completeRotation switches the wallet to the new key and marks the old key retired
+func (r *WalletReconciler) completeRotation(ctx context.Context, wallet *kontractdeployerv1alpha1.Wallet) error {
+	rotation := wallet.Status.Rotation
+
+	oldSecret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: wallet.Status.SecretRef, Namespace: wallet.Namespace}, oldSecret); err != nil {
+		return fmt.Errorf("failed to get retired Secret %s: %w", wallet.Status.SecretRef, err)
+	}
+	if oldSecret.Annotations == nil {
+		oldSecret.Annotations = map[string]string{}
+	}
+	oldSecret.Annotations[retiredKeyAnnotation] = rotation.RequestID
+	if err := r.Update(ctx, oldSecret); err != nil {
+		return fmt.Errorf("failed to mark Secret %s retired: %w", oldSecret.Name, err)
+	}
+
+	newSecret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: rotation.NewSecretRef, Namespace: wallet.Namespace}, newSecret); err != nil {
+		return fmt.Errorf("failed to get new Secret %s: %w", rotation.NewSecretRef, err)
+	}
+	keyFormat := keyFormatPrivateKey
+	if _, ok := newSecret.Data[walletKeystoreKey]; ok {
+		keyFormat = keyFormatKeystore
+	}
+
+	now := metav1.Now()
+	wallet.Status.RetiredKeys = append(wallet.Status.RetiredKeys, kontractdeployerv1alpha1.RetiredKey{
+		PublicKey: rotation.OldPublicKey,
+		SecretRef: wallet.Status.SecretRef,
+		RequestID: rotation.RequestID,
+		RetiredAt: now,
+	})
+	wallet.Status.PublicKey = rotation.NewPublicKey
+	wallet.Status.SecretRef = rotation.NewSecretRef
+	wallet.Status.KeyFormat = keyFormat
+	rotation.Phase = rotationPhaseCompleted
+	rotation.CompletionTime = &now
+
+	return r.Status().Update(ctx, wallet)
+}
+
+// parseRole converts a role name (e.g., MINTER_ROLE) or 32 byte hex value into the role hash
+func parseRole(role string) (common.Hash, error) {
+	switch {
+	case role == defaultAdminRole:
+		return common.Hash{}, nil
+	case strings.HasPrefix(role, "0x"):
+		value, err := hexutil.Decode(role)
+		if err != nil || len(value) != common.HashLength {
+			return common.Hash{}, fmt.Errorf("invalid role %q: expected 32 bytes of hex", role)
+		}
+		return common.BytesToHash(value), nil
+	case role == "":
+		return common.Hash{}, fmt.Errorf("empty role")
+	}
+	return crypto.Keccak256Hash([]byte(role)), nil
+}
+
+// callOwner returns the owner of an Ownable contract
+func callOwner(ctx context.Context, ethClient *ethclient.Client, contract common.Address) (common.Address, error) {
+	var owner common.Address
+	err := callRotationABI(ctx, ethClient, contract, &owner, "owner")
+	return owner, err
+}
+
+// callPendingOwner returns the pending owner of an Ownable2Step contract
+func callPendingOwner(ctx context.Context, ethClient *ethclient.Client, contract common.Address) (common.Address, error) {
+	var owner common.Address
+	err := callRotationABI(ctx, ethClient, contract, &owner, "pendingOwner")
+	return owner, err
+}
+
+// callHasRole reports whether the account holds the role in an AccessControl contract
+func callHasRole(ctx context.Context, ethClient *ethclient.Client, contract common.Address, role common.Hash, account common.Address) (bool, error) {
+	var hasRole bool
+	err := callRotationABI(ctx, ethClient, contract, &hasRole, "hasRole", role, account)
+	return hasRole, err
+}
+
+// callRotationABI performs a read-only call of a rotationABI method and unpacks its single result
+func callRotationABI(ctx context.Context, ethClient *ethclient.Client, contract common.Address, result interface{}, method string, args ...interface{}) error {
+	data, err := rotationABI.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	output, err := ethClient.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return err
+	}
+	values, err := rotationABI.Unpack(method, output)
+	if err != nil {
+		return err
+	}
+	if len(values) != 1 {
+		return fmt.Errorf("unexpected %s result", method)
+	}
+	return rotationABI.Methods[method].Outputs.Copy(result, values)
+}
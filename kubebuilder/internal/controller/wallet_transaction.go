@@ -0,0 +1,271 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kontractdeployerv1alpha1 "github.com/expedio-blockchain/Kontract/api/v1alpha1"
+)
+
+const (
+	// walletTransactionPollInterval is how often an unfinished wallet transaction is checked
+	walletTransactionPollInterval = 10 * time.Second
+
+	txStatePending            = "Pending"
+	txStateAwaitingSignatures = "AwaitingSignatures"
+	txStateSucceeded          = "Succeeded"
+	txStateFailed             = "Failed"
+)
+
+// walletCall is a contract call to be signed by a Wallet
+type walletCall struct {
+	to    common.Address
+	value *big.Int
+	data  []byte
+}
+
+// walletTransactionDone reports whether a wallet transaction reached a final state
+func walletTransactionDone(status *kontractdeployerv1alpha1.WalletTransactionStatus) bool {
+	return status != nil && (status.State == txStateSucceeded || status.State == txStateFailed)
+}
+
+// submitWalletCall starts or advances a call signed by the wallet and records its progress in status
+// EOA wallets send the call directly, Safe wallets propose it to their owners and smart accounts send it as a UserOperation.
+// Returned errors are transient and the call is retried; failures that cannot recover are recorded in status.
+func submitWalletCall(ctx context.Context, c client.Client, wallet *kontractdeployerv1alpha1.Wallet, networkRef string, call walletCall, status *kontractdeployerv1alpha1.WalletTransactionStatus) error {
+	if walletTransactionDone(status) {
+		return nil
+	}
+	if call.value == nil {
+		call.value = big.NewInt(0)
+	}
+
+	ethClient, _, err := dialNetwork(ctx, c, wallet.Namespace, networkRef)
+	if err != nil {
+		return err
+	}
+	defer ethClient.Close()
+
+	switch wallet.Spec.WalletType {
+	case walletTypeSafe:
+		return submitSafeCall(ctx, c, ethClient, wallet, call, status)
+	case walletTypeSmartAccount:
+		return submitUserOperation(ctx, c, ethClient, wallet, call, status)
+	}
+	return submitEOACall(ctx, c, ethClient, wallet, call, status)
+}
+
+// submitEOACall sends the call from the wallet key and waits for its receipt
+func submitEOACall(ctx context.Context, c client.Client, ethClient *ethclient.Client, wallet *kontractdeployerv1alpha1.Wallet, call walletCall, status *kontractdeployerv1alpha1.WalletTransactionStatus) error {
+	if status.State == txStatePending {
+		return checkTransactionReceipt(ctx, ethClient, status)
+	}
+
+	privateKey, err := walletPrivateKey(ctx, c, wallet)
+	if err != nil {
+		return err
+	}
+	txHash, err := sendContractTransaction(ctx, ethClient, privateKey, call.to, call.value, call.data)
+	if err != nil {
+		failWalletTransaction(status, err.Error())
+		return nil
+	}
+
+	status.State = txStatePending
+	status.TransactionHash = txHash.Hex()
+	status.SubmittedAt = metav1.Now()
+	status.Message = ""
+	return nil
+}
+
+// submitSafeCall proposes the call to the Safe and tracks it until it is executed
+func submitSafeCall(ctx context.Context, c client.Client, ethClient *ethclient.Client, wallet *kontractdeployerv1alpha1.Wallet, call walletCall, status *kontractdeployerv1alpha1.WalletTransactionStatus) error {
+	spec := wallet.Spec.Safe
+	if spec == nil || !common.IsHexAddress(spec.Address) {
+		failWalletTransaction(status, fmt.Sprintf("wallet %s has no valid Safe address", wallet.Name))
+		return nil
+	}
+	safe := common.HexToAddress(spec.Address)
+
+	switch status.State {
+	case txStatePending:
+		return checkTransactionReceipt(ctx, ethClient, status)
+	case txStateAwaitingSignatures:
+		return pollSafeService(ctx, c, ethClient, wallet, safe, status)
+	}
+
+	// The nonce is picked and the proposal submitted under a lock, so concurrent calls do not propose the same nonce
+	defer lockSafe(safe)()
+
+	owners, threshold, err := safeOwnersAndThreshold(ctx, ethClient, safe)
+	if err != nil {
+		return err
+	}
+	nonce, err := safeNonce(ctx, ethClient, safe)
+	if err != nil {
+		return err
+	}
+	var service *safeServiceClient
+	if spec.TransactionService != nil {
+		if service, err = newSafeServiceClient(ctx, c, wallet.Namespace, spec.TransactionService); err != nil {
+			return err
+		}
+		if nonce, err = service.nextNonce(ctx, safe, nonce); err != nil {
+			return err
+		}
+	}
+	tx := safeTransaction{to: call.to, value: call.value, data: call.data, nonce: nonce}
+	hash, err := safeTransactionHash(ctx, ethClient, safe, tx)
+	if err != nil {
+		return err
+	}
+
+	signatures, err := safeDelegateSignatures(ctx, c, wallet, owners, hash)
+	if err != nil {
+		return err
+	}
+
+	safeNonce := nonce.Int64()
+	status.SafeTxHash = hash.Hex()
+	status.SafeNonce = &safeNonce
+	status.Threshold = threshold.Int64()
+	status.Confirmations = int64(len(signatures))
+	if len(signatures) == 0 {
+		failWalletTransaction(status, fmt.Sprintf("none of the delegates of wallet %s is an owner of Safe %s", wallet.Name, safe.Hex()))
+		return nil
+	}
+
+	// With a transaction service, the remaining owners confirm and execute the transaction themselves
+	if service != nil {
+		if err := service.propose(ctx, safe, tx, hash, signatures[0].owner, signatures[0].signature); err != nil {
+			return err
+		}
+		for _, delegate := range signatures[1:] {
+			if err := service.confirm(ctx, hash, delegate.signature); err != nil {
+				return err
+			}
+		}
+		status.State = txStateAwaitingSignatures
+		status.SubmittedAt = metav1.Now()
+		status.Message = fmt.Sprintf("proposed with %d of %d confirmations", status.Confirmations, status.Threshold)
+		return nil
+	}
+
+	if threshold.Cmp(big.NewInt(int64(len(signatures)))) > 0 {
+		failWalletTransaction(status, fmt.Sprintf("delegates provide %d of %d required signatures, configure a transaction service to collect the rest", len(signatures), threshold.Int64()))
+		return nil
+	}
+
+	// Enough delegates signed: the first delegate executes the transaction and pays its gas
+	ownerSignatures := map[common.Address][]byte{}
+	for _, delegate := range signatures {
+		ownerSignatures[delegate.owner] = delegate.signature
+	}
+	data, err := safeExecCallData(tx, ownerSignatures)
+	if err != nil {
+		return err
+	}
+	txHash, err := sendContractTransaction(ctx, ethClient, signatures[0].key, safe, big.NewInt(0), data)
+	if err != nil {
+		failWalletTransaction(status, err.Error())
+		return nil
+	}
+
+	status.State = txStatePending
+	status.TransactionHash = txHash.Hex()
+	status.SubmittedAt = metav1.Now()
+	status.Message = fmt.Sprintf("executed by delegate %s", signatures[0].owner.Hex())
+	return nil
+}
+
+// pollSafeService updates a proposed Safe transaction from the transaction service
+func pollSafeService(ctx context.Context, c client.Client, ethClient *ethclient.Client, wallet *kontractdeployerv1alpha1.Wallet, safe common.Address, status *kontractdeployerv1alpha1.WalletTransactionStatus) error {
+	service, err := newSafeServiceClient(ctx, c, wallet.Namespace, wallet.Spec.Safe.TransactionService)
+	if err != nil {
+		return err
+	}
+	tx, err := service.transaction(ctx, common.HexToHash(status.SafeTxHash))
+	if err != nil {
+		return err
+	}
+
+	status.Confirmations = int64(len(tx.Confirmations))
+	if tx.ConfirmationsRequired > 0 {
+		status.Threshold = tx.ConfirmationsRequired
+	}
+
+	if tx.IsExecuted {
+		if tx.TransactionHash != nil {
+			status.TransactionHash = *tx.TransactionHash
+		}
+		if tx.IsSuccessful != nil && !*tx.IsSuccessful {
+			failWalletTransaction(status, "Safe transaction execution failed")
+			return nil
+		}
+		status.State = txStateSucceeded
+		status.Message = ""
+		return nil
+	}
+
+	// Another transaction with the same nonce was executed instead
+	nonce, err := safeNonce(ctx, ethClient, safe)
+	if err != nil {
+		return err
+	}
+	if status.SafeNonce != nil && nonce.Cmp(big.NewInt(*status.SafeNonce)) > 0 {
+		failWalletTransaction(status, fmt.Sprintf("Safe nonce %d was used by another transaction", *status.SafeNonce))
+		return nil
+	}
+
+	status.Message = fmt.Sprintf("waiting for execution with %d of %d confirmations", status.Confirmations, status.Threshold)
+	return nil
+}
+
+// checkTransactionReceipt marks a sent transaction as succeeded or failed once it is mined
+func checkTransactionReceipt(ctx context.Context, ethClient *ethclient.Client, status *kontractdeployerv1alpha1.WalletTransactionStatus) error {
+	receipt, err := ethClient.TransactionReceipt(ctx, common.HexToHash(status.TransactionHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get receipt of %s: %w", status.TransactionHash, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		failWalletTransaction(status, "transaction reverted")
+		return nil
+	}
+	status.State = txStateSucceeded
+	status.Message = ""
+	return nil
+}
+
+// failWalletTransaction records a wallet transaction that cannot succeed
+func failWalletTransaction(status *kontractdeployerv1alpha1.WalletTransactionStatus, message string) {
+	status.State = txStateFailed
+	status.Message = message
+}